@@ -24,6 +24,7 @@ const (
 	Authorizing                          ConfigurationState = "Authorizing"
 	ProviderNotFound                     ConfigurationState = "ProviderNotFound"
 	ProviderNotReady                     ConfigurationState = "ProviderNotReady"
+	ProviderAccessDenied                 ConfigurationState = "ProviderAccessDenied"
 	ConfigurationStaticCheckFailed       ConfigurationState = "ConfigurationSpecNotValid"
 	Available                            ConfigurationState = "Available"
 	ConfigurationProvisioningAndChecking ConfigurationState = "ProvisioningAndChecking"
@@ -34,6 +35,79 @@ const (
 	GeneratingOutputs                    ConfigurationState = "GeneratingTerraformOutputs"
 	InvalidRegion                        ConfigurationState = "InvalidRegion"
 	TerraformInitError                   ConfigurationState = "TerraformInitError"
+	ConfigurationPendingOnConcurrency    ConfigurationState = "PendingOnConcurrency"
+	ExistingStateFound                   ConfigurationState = "ExistingStateFound"
+	WaitingForDependency                 ConfigurationState = "WaitingForDependency"
+	DependencyCycleDetected              ConfigurationState = "DependencyCycleDetected"
+	WaitingForDependentsDeletion         ConfigurationState = "WaitingForDependentsDeletion"
+	RegistryUnreachable                  ConfigurationState = "RegistryUnreachable"
+	ConfigurationDestroyBlocked          ConfigurationState = "DestroyBlocked"
+	VariableTypeMismatch                 ConfigurationState = "VariableTypeMismatch"
+	PendingScheduledApply                ConfigurationState = "PendingScheduledApply"
+	BackendKeyCollision                  ConfigurationState = "BackendKeyCollision"
+	BackendMigrationRequired             ConfigurationState = "BackendMigrationRequired"
+	DestroyPreviewRunning                ConfigurationState = "DestroyPreviewRunning"
+	DestroyPreviewCompleted              ConfigurationState = "DestroyPreviewCompleted"
+	DestroyPreviewFailed                 ConfigurationState = "DestroyPreviewFailed"
+	CredentialsExpiringSoon              ConfigurationState = "CredentialsExpiringSoon"
+	PlanRunning                          ConfigurationState = "PlanRunning"
+	PlanPendingApproval                  ConfigurationState = "PlanPendingApproval"
+	PlanStale                            ConfigurationState = "PlanStale"
+	PlanFailed                           ConfigurationState = "PlanFailed"
+	PlanApproved                         ConfigurationState = "PlanApproved"
+	DriftCheckRunning                    ConfigurationState = "DriftCheckRunning"
+	DriftCheckFailed                     ConfigurationState = "DriftCheckFailed"
+	NoDrift                              ConfigurationState = "NoDrift"
+	DriftDetected                        ConfigurationState = "DriftDetected"
+	DriftDetectedPendingWindow           ConfigurationState = "DriftDetectedPendingWindow"
+	SelfHealing                          ConfigurationState = "SelfHealing"
+	DriftCorrected                       ConfigurationState = "DriftCorrected"
+	SecretCopyForbidden                  ConfigurationState = "SecretCopyForbidden"
+	RetryableApplyError                  ConfigurationState = "RetryableApplyError"
+	FatalApplyError                      ConfigurationState = "FatalApplyError"
+	ApplyCancelled                       ConfigurationState = "ApplyCancelled"
+	SensitiveLeak                        ConfigurationState = "SensitiveLeak"
+	RegionChangeNotAcknowledged          ConfigurationState = "RegionChangeNotAcknowledged"
+	ProviderChecksumMismatch             ConfigurationState = "ProviderChecksumMismatch"
+	TerraformWarnings                    ConfigurationState = "TerraformWarnings"
+)
+
+// A ReconcileReason explains the outcome of the latest reconcile of a Configuration
+type ReconcileReason string
+
+// Reasons a reconcile applies or doesn't apply the Configuration.
+const (
+	ReconcileUpToDate              ReconcileReason = "UpToDate"
+	ReconcileNoChange              ReconcileReason = "NoChange"
+	ReconcileApplyTriggered        ReconcileReason = "ApplyTriggered"
+	ReconcileApplyInProgress       ReconcileReason = "ApplyInProgress"
+	ReconcileApplyFailed           ReconcileReason = "ApplyFailed"
+	ReconcileWaitingForDependency  ReconcileReason = "WaitingForDependency"
+	ReconcileWaitingForApplyNow    ReconcileReason = "WaitingForApplyNow"
+	ReconcilePendingScheduledApply ReconcileReason = "PendingScheduledApply"
+	ReconcilePendingOnConcurrency  ReconcileReason = "PendingOnConcurrency"
+	ReconcileDestroyPreviewRunning ReconcileReason = "DestroyPreviewRunning"
+	ReconcileCredentialsExpiring   ReconcileReason = "CredentialsExpiringSoon"
+	ReconcilePlanRunning           ReconcileReason = "PlanRunning"
+	ReconcileWaitingForApproval    ReconcileReason = "WaitingForPlanApproval"
+	ReconcileWaitingForUntaint     ReconcileReason = "WaitingForUntaint"
+	ReconcileDriftCheckRunning     ReconcileReason = "DriftCheckRunning"
+	ReconcileSelfHealTriggered     ReconcileReason = "SelfHealTriggered"
+	ReconcileSecretCopyForbidden   ReconcileReason = "SecretCopyForbidden"
+	ReconcileProviderAccessDenied  ReconcileReason = "ProviderAccessDenied"
+	ReconcileRegionChangeBlocked   ReconcileReason = "RegionChangeNotAcknowledged"
+	ReconcileDestroying            ReconcileReason = "Destroying"
+	ReconcileError                 ReconcileReason = "Error"
+)
+
+// An ApplyPhase is the phase reached by a running apply job
+type ApplyPhase string
+
+// Phases of an apply job, in order.
+const (
+	ApplyPhaseInitComplete    ApplyPhase = "InitComplete"
+	ApplyPhasePlanComplete    ApplyPhase = "PlanComplete"
+	ApplyPhaseApplyInProgress ApplyPhase = "ApplyInProgress"
 )
 
 // Stage is the Terraform stage
@@ -61,12 +135,136 @@ const (
 	ErrProviderNotFound = "provider not found"
 	// ErrProviderNotReady means provider object is not ready
 	ErrProviderNotReady = "Provider is not ready"
+	// MessageProviderAccessDenied is the message when the Configuration references a Provider which it's not allowed
+	// to use
+	MessageProviderAccessDenied = "Configuration %s/%s is not allowed to use Provider %s/%s, %s"
 	// ConfigurationReloadingAsHCLChanged means Configuration changed and needs reloading
 	ConfigurationReloadingAsHCLChanged = "Configuration's HCL has changed, and starts reloading"
 	// ConfigurationReloadingAsVariableChanged means Configuration changed and needs reloading
 	ConfigurationReloadingAsVariableChanged = "Configuration's variable has changed, and starts reloading"
 	// ErrGenerateOutputs means error to generate outputs
 	ErrGenerateOutputs = "Hit an issue to generate outputs"
+	// MessageExistingStateFound is the message when the Terraform state of a Configuration exists before the
+	// Configuration is created
+	MessageExistingStateFound = "Terraform state %s/%s exists before the Configuration is created, set spec.adoptExistingState to true to adopt it, or delete it to provision new cloud resources"
+	// MessageWaitingForDependency is the message when the Configuration waits for a Configuration it depends on
+	MessageWaitingForDependency = "Waiting for the dependency %s to be Available"
+	// MessageDependencyCycleDetected is the message when the dependencies of the Configuration form a cycle
+	MessageDependencyCycleDetected = "Dependency cycle is detected: %s"
+	// MessageRegistryUnreachable is the message when the provider registry can't be reached by the Terraform executor
+	MessageRegistryUnreachable = "The provider registry %s is unreachable from the Terraform executor"
+	// RegistryUnreachableLogPrefix prefixes the line in the logs of `terraform init`, which is printed by the registry
+	// preflight when the registry can't be reached
+	RegistryUnreachableLogPrefix = "RegistryUnreachable: "
+	// MessageProviderChecksumMismatch is the message when a provider plugin downloaded by `terraform init` is not in
+	// the checksum allowlist of the controller
+	MessageProviderChecksumMismatch = "The checksum %s of the provider plugin %s is not in the allowlist %s"
+	// ProviderChecksumMismatchLogPrefix prefixes the line in the logs of `terraform init`, which is printed by the
+	// verification of the provider plugins when a plugin is not in the allowlist
+	ProviderChecksumMismatchLogPrefix = "ProviderChecksumMismatch: "
+	// MessageWaitingForDependentsDeletion is the message when the Configuration waits for a Configuration depending on
+	// it to be deleted before it's destroyed
+	MessageWaitingForDependentsDeletion = "Waiting for the dependent %s to be deleted"
+	// MessageConcurrencyLimitReached is the message when the Terraform job waits for other jobs as the concurrency limit
+	// is reached
+	MessageConcurrencyLimitReached = "The number of running Terraform jobs reaches the limit, waiting for other jobs to complete"
+	// MessageCredentialsExpiringSoon is the message when the apply waits for the temporary credentials of the Provider,
+	// which expire soon, to be refreshed
+	MessageCredentialsExpiringSoon = "The credentials of the Provider expire at %s, waiting for them to be refreshed before applying"
+	// ErrCredentialsExpiringSoon means the temporary credentials of the Provider expire before the apply may complete
+	ErrCredentialsExpiringSoon = "the credentials of the Provider expire soon"
+	// MessagePlanRunning is the message when the changes are planned for approval
+	MessagePlanRunning = "Planning the changes for approval..."
+	// MessagePlanPendingApproval is the message when the saved plan waits for approval
+	MessagePlanPendingApproval = "The plan %s waits for approval, set the annotation terraform.core.oam.dev/approve-plan to it to apply the plan"
+	// MessagePlanStale is the message when the inputs of the Configuration change after the plan is saved
+	MessagePlanStale = "The inputs of the Configuration changed after the plan %s was saved, the changes are planned again"
+	// MessagePlannedChangesStale is the message when the changes planned by the apply differ from the approved plan
+	MessagePlannedChangesStale = "The planned changes differ from the approved plan %s, the changes are planned again"
+	// PlanChecksumLogPrefix prefixes the line in the logs of the plan job, which is the checksum of the saved plan
+	PlanChecksumLogPrefix = "PlanChecksum: "
+	// PlanStaleLogPrefix prefixes the line in the logs of the apply job, which is printed when the planned changes
+	// differ from the approved plan
+	PlanStaleLogPrefix = "PlanStale: "
+	// TerraformWarningsLogPrefix prefixes the line in the logs of the apply job, which is printed when the plan reports
+	// warnings and spec.WarningsAsErrors is true
+	TerraformWarningsLogPrefix = "TerraformWarnings: "
+	// MessageTerraformWarnings is the message when the plan reports warnings, which are treated as errors
+	MessageTerraformWarnings = "The plan reports warnings, which are treated as errors by spec.WarningsAsErrors"
+	// CostEstimateLogPrefix prefixes the line in the logs of the plan job, which is the cost estimate of the saved plan
+	CostEstimateLogPrefix = "CostEstimate: "
+	// MessagePlanApproved is the message when the saved plan is approved and applied
+	MessagePlanApproved = "The plan %s is approved"
+	// MessageResourcesTainted is the message when resources are tainted in the state, which are replaced by the next
+	// apply
+	MessageResourcesTainted = "The resources are tainted and will be replaced by the next apply unless they are untainted: %s"
+	// MessageRegionChangeNotAcknowledged is the message when the region of a Configuration differs from the region of
+	// the deployed cloud resources, which are not moved to the new region
+	MessageRegionChangeNotAcknowledged = "The region changes from %s to %s, the cloud resources in %[1]s are not moved and new ones would be created in %[2]s, set the annotation %s to %[2]s to acknowledge it"
+	// MessageSensitiveLeak is the message when the values of the sensitive variables appear in the non-sensitive
+	// outputs
+	MessageSensitiveLeak = "The values of the sensitive variables appear in the non-sensitive outputs: %s"
+	// ErrPlanNotApproved means the apply waits for approval of the saved plan
+	ErrPlanNotApproved = "the saved plan is not approved"
+	// MessagePreDestroyHookRunning is the message when the pre-destroy hook Job is running
+	MessagePreDestroyHookRunning = "The pre-destroy hook is running"
+	// MessagePreDestroyHookFailed is the message when the pre-destroy hook Job fails and the destroy is blocked
+	MessagePreDestroyHookFailed = "The pre-destroy hook Job %s failed: %s, delete the Job to retry"
+	// MessagePendingScheduledApply is the message when the changes of the Configuration wait for the next time of
+	// spec.ApplySchedule
+	MessagePendingScheduledApply = "The changes are pending until the next scheduled apply at %s"
+	// MessageBackendKeyCollision is the message when the Terraform state of the Configuration is already used by another
+	// Configuration
+	MessageBackendKeyCollision = "Terraform state %s/%s is already used by the Configuration %s, set spec.backend.secretSuffix to use another state"
+	// MessageDeletionProtected is the message when the deletion of the Configuration is blocked by the label
+	// terraform.core.oam.dev/deletion-protection
+	MessageDeletionProtected = "The Configuration is protected from deletion by the label terraform.core.oam.dev/deletion-protection=true, remove the label to delete it"
+	// MessageDestroyPreviewRunning is the message when `terraform plan -destroy` is running for the destroy preview
+	MessageDestroyPreviewRunning = "Previewing the resources which would be destroyed..."
+	// MessageDestroyPreviewCompleted is the message when the destroy preview completes
+	MessageDestroyPreviewCompleted = "%d resources would be destroyed"
+	// MessageDriftCheckRunning is the message when `terraform plan` is running to check the drift
+	MessageDriftCheckRunning = "Checking whether the cloud resources drift from the Configuration..."
+	// MessageNoDrift is the message when the drift check finds no changes
+	MessageNoDrift = "The cloud resources don't drift from the Configuration"
+	// MessageDriftDetected is the message when the drift check finds changes and spec.SelfHeal is false
+	MessageDriftDetected = "%d resources drift from the Configuration, set spec.selfHeal to true to correct the drift automatically"
+	// MessageSelfHealRateLimited is the message when the drift is detected, but the latest self-heal is too recent
+	MessageSelfHealRateLimited = "%d resources drift from the Configuration, the self-heal is skipped as the latest one at %s is too recent"
+	// MessageDriftDetectedPendingWindow is the message when the drift is detected, and the self-heal is deferred to the
+	// next time of spec.ApplySchedule
+	MessageDriftDetectedPendingWindow = "%d resources drift from the Configuration, the self-heal is deferred to %s by spec.applySchedule, set the annotation terraform.core.oam.dev/critical-drift to true to correct the drift now"
+	// MessageSelfHealing is the message when the Configuration is applied again to correct the drift
+	MessageSelfHealing = "Applying the Configuration again to correct the drift of %d resources"
+	// MessageDriftCorrected is the message when the apply of the self-heal succeeds
+	MessageDriftCorrected = "The drift is corrected by applying the Configuration again"
+	// MessageSecretCopyForbidden is the message when the controller isn't allowed to copy the variables and the
+	// credentials to the secret in the namespace of the Configuration
+	MessageSecretCopyForbidden = "The controller is forbidden to %[1]s the secret %[2]s in namespace %[3]s, grant the verb %[1]s on the resource secrets in namespace %[3]s to the controller by a Role"
+	// MessageFatalApplyError is the message when the apply fails by an error which a retry may make worse
+	MessageFatalApplyError = "The apply is not retried as the error may make the state worse, fix it and set the annotation terraform.core.oam.dev/apply-now or change the Configuration to retry: %s"
+	// ErrFatalApplyError means the apply job is paused after a fatal error
+	ErrFatalApplyError = "the apply job is paused after a fatal error"
+	// MessageApplyCancelled is the message when the running apply is interrupted as the Configuration is deleted
+	MessageApplyCancelled = "The running apply is interrupted as the Configuration is deleted, the destroy starts once Terraform stops"
+	// ErrApplyCancelling means the destroy waits for the interrupted apply job to stop
+	ErrApplyCancelling = "waiting for the interrupted apply job to stop"
+	// MessageWaitingForPostApplyDelay is the message when the apply succeeds and the outputs are read after
+	// spec.PostApplyDelay
+	MessageWaitingForPostApplyDelay = "The apply succeeded, the outputs will be read in %s"
+	// ErrWaitingForPostApplyDelay means the outputs are not read until spec.PostApplyDelay passes
+	ErrWaitingForPostApplyDelay = "waiting for the post-apply delay"
+	// ErrSecretCopyForbidden means the RBAC of the controller is insufficient to copy the secret of the variables
+	ErrSecretCopyForbidden = "the controller is forbidden to copy the secret of the variables"
+	// MessageStateMigrationTargetExists is the message when the Terraform state can't be migrated to the changed
+	// backend, as a state already exists there
+	MessageStateMigrationTargetExists = "Terraform state %s/%s can't be migrated to %s/%s which already exists, delete one of them to continue"
+	// MessageStateDiscardedByLocalBackend is the message when the backend changes to `local`, which would orphan the
+	// Terraform state in the kubernetes backend
+	MessageStateDiscardedByLocalBackend = "Terraform state %s/%s is not used by the local backend, delete it to confirm the cloud resources are orphaned"
+	// MessageDestroyBackendChanged is the message when the Configuration is deleted before its Terraform state is
+	// migrated to the changed backend, so the destroy would run with the state of the changed backend
+	MessageDestroyBackendChanged = "Terraform state %s/%s is not migrated to the changed backend yet, revert spec.backend to destroy the cloud resources in it"
 )
 
 // ProviderState is the type for Provider state
@@ -3,6 +3,15 @@ package types
 const (
 	// TerraformHCLConfigurationName is the file name for Terraform hcl Configuration
 	TerraformHCLConfigurationName = "main.tf"
+	// TerraformLockFileName is the file name of the dependency lock file of Terraform, which records the versions and
+	// checksums of the providers
+	TerraformLockFileName = ".terraform.lock.hcl"
+	// TerraformVariablesFileName is the file name of the variables of the list or object types, which Terraform loads
+	// automatically from the working directory
+	TerraformVariablesFileName = "variables.auto.tfvars.json"
+	// NetrcFileName is the file name of the credentials of the HTTP sources, which are used to download the modules and
+	// providers
+	NetrcFileName = ".netrc"
 )
 
 // ConfigurationType is the type for Terraform Configuration
@@ -14,3 +23,13 @@ const (
 	// ConfigurationRemote means HCL stores in a remote git repository
 	ConfigurationRemote ConfigurationType = "Remote"
 )
+
+// BackendType is the type of the Terraform backend which stores the state
+type BackendType string
+
+const (
+	// BackendKubernetes stores the state in a Kubernetes secret, which is the default backend
+	BackendKubernetes BackendType = "kubernetes"
+	// BackendLocal stores the state in the working directory of the Terraform job, and it will be discarded with the job
+	BackendLocal BackendType = "local"
+)
@@ -33,6 +33,86 @@ type ProviderSpec struct {
 
 	// Credentials required to authenticate to this provider.
 	Credentials ProviderCredentials `json:"credentials"`
+
+	// DefaultTags are the tags applied to all resources provisioned by the provider, like `default_tags` of the AWS
+	// provider. The tags set in the Terraform configuration take precedence over them. Currently, only the AWS provider
+	// is supported.
+	// +optional
+	DefaultTags map[string]string `json:"defaultTags,omitempty"`
+
+	// Retry is the retry settings of the requests to the cloud APIs when they are throttled, which are rendered to the
+	// provider block. Currently, only the AWS provider is supported.
+	// +optional
+	Retry *ProviderRetry `json:"retry,omitempty"`
+
+	// SkipCredentialsValidation determines whether to skip validating the credentials with the cloud provider before
+	// the Provider is ready. Currently, only the credentials of the Alibaba Cloud provider are validated.
+	// +optional
+	SkipCredentialsValidation bool `json:"skipCredentialsValidation,omitempty"`
+
+	// Account identifies the cloud account of the credentials, like the AWS account ID. The Providers with the same
+	// account share the limit of running Terraform jobs per Provider, so that they won't exhaust the quota of the account.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`
+	// +optional
+	Account string `json:"account,omitempty"`
+
+	// ProviderConfig is a verbatim provider block for the providers whose configuration doesn't map to the region and
+	// credentials, like the kubeconfig of the Kubernetes provider or the address and token of the Vault provider.
+	// +optional
+	ProviderConfig *ProviderConfig `json:"providerConfig,omitempty"`
+
+	// Backend is the default backend of the Configurations which use the Provider, the backend of a Configuration
+	// overrides it
+	// +optional
+	Backend *ProviderBackend `json:"backend,omitempty"`
+
+	// AllowedNamespaces are the namespaces of the Configurations which are allowed to use the Provider, besides the
+	// namespace of the Provider itself. The Configurations of all namespaces are allowed if it's empty.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// ConfigurationSelector selects the Configurations which are allowed to use the Provider by their labels. The
+	// Configurations of the allowed namespaces are all allowed if it's not set.
+	// +optional
+	ConfigurationSelector *metav1.LabelSelector `json:"configurationSelector,omitempty"`
+}
+
+// ProviderBackend is the default backend settings of the Configurations
+type ProviderBackend struct {
+	// Type is the default type of the backend, which could be `kubernetes` or `local`. It's inherited by the
+	// Configurations which don't set spec.backend.type and don't store any state yet, so the state is not discarded
+	// or moved when the default changes.
+	// +kubebuilder:validation:Enum=kubernetes;local
+	// +optional
+	Type string `json:"type,omitempty"`
+}
+
+// ProviderConfig is a verbatim provider block and the secrets injected into it
+type ProviderConfig struct {
+	// Template is the HCL of the provider blocks, which is rendered to the Terraform configuration as it is. A secret
+	// of SecretRefs is referenced as a variable by its name, like `token = var.vault_token`.
+	Template string `json:"template"`
+
+	// SecretRefs are the secret keys injected into Template, keyed by the names of the variables. The namespace of a
+	// secret defaults to the namespace of the Provider. The values are passed to the Terraform jobs as environment
+	// variables, so they are not stored in the Terraform configuration.
+	// +optional
+	SecretRefs map[string]crossplanetypes.SecretKeySelector `json:"secretRefs,omitempty"`
+}
+
+// ProviderRetry is the retry settings of the requests to the cloud APIs
+type ProviderRetry struct {
+	// MaxRetries is the maximum number of retries of a request, like `max_retries` of the AWS provider
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxRetries *int `json:"maxRetries,omitempty"`
+
+	// RetryMode is how the requests are retried, like `retry_mode` of the AWS provider. `adaptive` also limits the rate
+	// of the requests on throttling.
+	// +kubebuilder:validation:Enum=standard;adaptive
+	// +optional
+	RetryMode string `json:"retryMode,omitempty"`
 }
 
 // ProviderCredentials required to authenticate.
@@ -45,12 +125,40 @@ type ProviderCredentials struct {
 	// that must be used to connect to the provider.
 	// +optional
 	SecretRef *crossplanetypes.SecretKeySelector `json:"secretRef,omitempty"`
+
+	// KeyMapping maps the keys of the credentials in the secret to the conventional keys of the cloud provider, like
+	// `accessKey: awsAccessKeyID`, so an existing secret with different key names could be used. The keys which are
+	// not mapped are used as they are.
+	// +optional
+	KeyMapping map[string]string `json:"keyMapping,omitempty"`
+
+	// File writes the whole value of the key of SecretRef to a credentials file in the Terraform jobs, instead of
+	// converting it to the environment variables of the cloud provider, like the JSON key of a GCP service account.
+	// KeyMapping is not applied to the file.
+	// +optional
+	File *CredentialsFile `json:"file,omitempty"`
+}
+
+// CredentialsFile is the credentials file of a Provider, which is pointed to by an environment variable
+type CredentialsFile struct {
+	// Env is the environment variable which points to the file, like `GOOGLE_APPLICATION_CREDENTIALS`
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*$`
+	Env string `json:"env"`
+
+	// Encoding is the encoding of the value of the key, `json` is a JSON object and `base64` is a base64-encoded JSON
+	// object. It defaults to `json`.
+	// +kubebuilder:validation:Enum=json;base64
+	// +optional
+	Encoding string `json:"encoding,omitempty"`
 }
 
 // ProviderStatus defines the observed state of Provider.
 type ProviderStatus struct {
 	State   types.ProviderState `json:"state,omitempty"`
 	Message string              `json:"message,omitempty"`
+	// CredentialsExpiration is the expiration of the temporary credentials, like STS tokens, which is set by the
+	// `expiration` key of the credentials
+	CredentialsExpiration *metav1.Time `json:"credentialsExpiration,omitempty"`
 }
 
 // +kubebuilder:object:root=true
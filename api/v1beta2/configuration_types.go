@@ -17,6 +17,8 @@ limitations under the License.
 package v1beta2
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -30,8 +32,17 @@ type ConfigurationSpec struct {
 	HCL string `json:"hcl,omitempty"`
 
 	// Remote is a git repo which contains hcl files. Currently, only public git repos are supported.
+	//
+	// Deprecated: use GitRemote, which could also set the ref and the credentials of the git repo.
 	Remote string `json:"remote,omitempty"`
 
+	// GitRemote is a git repo which contains hcl files. It can't be set with Remote at the same time.
+	// +optional
+	GitRemote *GitRemote `json:"gitRemote,omitempty"`
+
+	// Variable is the values of the variables. The string values could reference the region of the Configuration,
+	// which is spec.customRegion, the region label of the controller or the region of the Provider, by a template like
+	// `ami-{{.Region}}`.
 	// +kubebuilder:pruning:PreserveUnknownFields
 	Variable *runtime.RawExtension `json:"variable,omitempty"`
 
@@ -43,9 +54,226 @@ type ConfigurationSpec struct {
 	// Path is the sub-directory of remote git repository.
 	Path string `json:"path,omitempty"`
 
+	// ExtraApplyArgs are the extra arguments appended to `terraform apply`. The forbidden flags are rejected at
+	// admission, and only the flags in the allowlist of the controller are accepted when the Configuration is
+	// reconciled.
+	ExtraApplyArgs []ExtraArg `json:"extraApplyArgs,omitempty"`
+
+	// ExtraDestroyArgs are the extra arguments appended to `terraform destroy`. The forbidden flags are rejected at
+	// admission, and only the flags in the allowlist of the controller are accepted when the Configuration is
+	// reconciled.
+	ExtraDestroyArgs []ExtraArg `json:"extraDestroyArgs,omitempty"`
+
+	// SkipDestroy are the addresses of the resources, like `aws_s3_bucket.data`, which are removed from the state by
+	// `terraform state rm` before `terraform destroy`, so they are orphaned instead of destroyed when the
+	// Configuration is deleted.
+	// +optional
+	SkipDestroy []string `json:"skipDestroy,omitempty"`
+
+	// LockTimeout is the duration, like `30s`, to retry acquiring the state lock of `terraform init/apply/destroy`.
+	// If it's not set, state locking is disabled and the execution doesn't wait for any lock.
+	LockTimeout string `json:"lockTimeout,omitempty"`
+
+	// PostApplyDelay is the duration, like `30s`, to wait after the apply succeeds before the outputs are read and
+	// written to the connection secret, so the outputs which are eventually consistent, like DNS names, are settled.
+	// +optional
+	PostApplyDelay string `json:"postApplyDelay,omitempty"`
+
+	// RunnerImage is the image of the Terraform executor which runs `terraform init/apply/destroy`. It overrides the
+	// default image of the controller, which is set by the env TERRAFORM_IMAGE.
+	RunnerImage string `json:"runnerImage,omitempty"`
+
+	// ServiceAccountName is the ServiceAccount of the Terraform executor which runs `terraform init/apply/destroy`,
+	// like a ServiceAccount with workload identity. It must exist in the namespace of the Configuration, and could not
+	// be `default`. It's only granted the access to the backend secret of the Configuration, by a Role in the backend
+	// namespace. It overrides the default ServiceAccount of the controller, which is set by the env
+	// TERRAFORM_EXECUTOR_SERVICE_ACCOUNT.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// AdoptExistingState determines whether to adopt the Terraform state which exists before the Configuration is
+	// created, like the state left by a deleted Configuration with the same name. If it's false, the Configuration
+	// will not be provisioned until the state is deleted.
+	AdoptExistingState bool `json:"adoptExistingState,omitempty"`
+
+	// ProviderProfile selects a credential profile of the Provider, which is a key of the secret referenced by the
+	// Provider. The key in the secretRef of the Provider is used if it's not set.
+	// +optional
+	ProviderProfile string `json:"providerProfile,omitempty"`
+
+	// ApplySchedule is a cron schedule in UTC like `0 2 * * *`. If it's set, the changes of the Configuration are not
+	// applied immediately, but at the next time of the schedule. Set the annotation `terraform.core.oam.dev/apply-now`
+	// to a token like a timestamp to apply the changes immediately.
+	// +optional
+	ApplySchedule string `json:"applySchedule,omitempty"`
+
+	// ProviderLockConfigMapRef refers to a ConfigMap in the namespace of the Configuration, whose key
+	// `.terraform.lock.hcl` is the dependency lock file of Terraform. If it's set, `terraform init` fails when the
+	// providers resolved don't match the lock file.
+	// +optional
+	ProviderLockConfigMapRef *corev1.LocalObjectReference `json:"providerLockConfigMapRef,omitempty"`
+
+	// NetrcSecretRef refers to a Secret in the namespace of the Configuration, whose key `.netrc` is written to the home
+	// directory of the Terraform executor before `terraform init`, so the modules and providers could be downloaded
+	// from the authenticated HTTP sources like Artifactory. It's removed once the execution exits.
+	// +optional
+	NetrcSecretRef *corev1.LocalObjectReference `json:"netrcSecretRef,omitempty"`
+
+	// ValidateVariables determines whether to validate spec.Variable against the variables declared in spec.HCL before
+	// running Terraform, like the required variables and the types of the values. The variables of a remote git repo
+	// are not validated.
+	// +optional
+	ValidateVariables bool `json:"validateVariables,omitempty"`
+
+	// DependsOn are the Configurations which must be Available before the Configuration is applied
+	// +optional
+	DependsOn []ConfigurationReference `json:"dependsOn,omitempty"`
+
+	// PreDestroyHook is a Job which runs to completion before the cloud resources are destroyed
+	// +optional
+	PreDestroyHook *PreDestroyHook `json:"preDestroyHook,omitempty"`
+
+	// RequiredProviders pins the Terraform providers, the key is the local name of the provider. They are rendered
+	// into the required_providers of the terraform block, and can't be declared in the HCL at the same time.
+	// +optional
+	RequiredProviders map[string]RequiredProvider `json:"requiredProviders,omitempty"`
+
+	// WorkingDirectoryCleanupPolicy determines when the working directory of the Terraform executor, like the
+	// providers and modules downloaded by `terraform init` and the cloned git repo, is wiped after the execution. It
+	// could be `Always`, `OnSuccess` or `Never`, and defaults to `OnSuccess`, so the working directory of a failed
+	// execution is kept for debugging. The files which may contain secrets, like the backup of the state, are removed
+	// regardless of the policy.
+	// +kubebuilder:validation:Enum=Always;OnSuccess;Never
+	// +kubebuilder:default:=OnSuccess
+	// +optional
+	WorkingDirectoryCleanupPolicy WorkingDirectoryCleanupPolicy `json:"workingDirectoryCleanupPolicy,omitempty"`
+
+	// RegistryPreflight checks whether the Terraform executor can reach the provider registry before `terraform init`,
+	// so an unreachable registry is reported as RegistryUnreachable instead of an init timeout. The check is skipped if
+	// it's not set, like in the offline setups.
+	// +optional
+	RegistryPreflight *RegistryPreflight `json:"registryPreflight,omitempty"`
+
+	// Priority is the priority of the Configuration when the number of running Terraform jobs reaches the limit, the
+	// pending Configurations of a higher priority get the job slots first. It could be `High`, `Normal` or `Low`, and
+	// defaults to `Normal`.
+	// +kubebuilder:validation:Enum=High;Normal;Low
+	// +kubebuilder:default:=Normal
+	// +optional
+	Priority Priority `json:"priority,omitempty"`
+
+	// VendoredModules is a pre-populated directory of the modules, like the `.terraform/modules` directory of a
+	// `terraform init` on a connected machine, so the modules are resolved locally instead of fetched from their
+	// sources in the air-gapped clusters.
+	// +optional
+	VendoredModules *VendoredModules `json:"vendoredModules,omitempty"`
+
+	// PluginCacheClaimName is a PersistentVolumeClaim in the namespace of the Configuration, which is the provider
+	// plugin cache shared by the Terraform jobs, so the providers are not downloaded again for every job. It should be
+	// ReadWriteMany if the jobs run on different nodes. It overrides the default PersistentVolumeClaim of the
+	// controller, which is set by the env TERRAFORM_PLUGIN_CACHE_CLAIM.
+	// +optional
+	PluginCacheClaimName string `json:"pluginCacheClaimName,omitempty"`
+
+	// WorkingDirectoryClaimName is a PersistentVolumeClaim in the namespace of the Configuration, which persists the
+	// working directory of the apply and destroy jobs in the sub-directory named by the Configuration. `terraform init`
+	// is skipped if the source and the providers are unchanged, and the working directory is wiped once they change.
+	// The git repo is cloned again by every job unless the git ref is a full commit hash, and the working directory is
+	// wiped once the cloned commit changes, like a branch moves. The working directory is not wiped by
+	// spec.WorkingDirectoryCleanupPolicy, and the sub-directory is removed once the destroy succeeds.
+	// +optional
+	WorkingDirectoryClaimName string `json:"workingDirectoryClaimName,omitempty"`
+
+	// SavedPlan determines whether to apply only the plan which is reviewed. The changes are planned by `terraform
+	// plan -out` first, and the plan is applied once its checksum in status.plan is approved by the annotation
+	// terraform.core.oam.dev/approve-plan. The apply is refused as PlanStale if the inputs or the planned changes
+	// differ from the approved plan, and the changes are planned again.
+	// +optional
+	SavedPlan bool `json:"savedPlan,omitempty"`
+
+	// WarningsAsErrors determines whether the warnings of Terraform, like the deprecated arguments, fail the apply. The
+	// changes are planned before the apply, which is refused as TerraformWarnings if the plan reports any warning, and
+	// the warnings are listed in status.apply.diagnostics. It also fails the saved plan with warnings.
+	// +optional
+	WarningsAsErrors bool `json:"warningsAsErrors,omitempty"`
+
+	// OutputTargets writes the outputs to the Kubernetes resources in the namespace of the Configuration, besides the
+	// connection secret, after a successful apply. The sensitive outputs are only allowed to be written to the
+	// connection secret. The Secrets, ConfigMaps, RBAC objects and Configurations are not allowed to be patched.
+	// +optional
+	OutputTargets []OutputTarget `json:"outputTargets,omitempty"`
+
+	// DriftCheckInterval is the interval, like `1h`, to check whether the cloud resources drift from the Configuration
+	// by `terraform plan` once it's Available. The drift is not checked if it's not set.
+	// +optional
+	DriftCheckInterval string `json:"driftCheckInterval,omitempty"`
+
+	// SelfHeal determines whether to apply the Configuration again when a drift is detected, so the cloud resources are
+	// restored to the desired state. The applies of the self-heal are at least 10 minutes apart, so it doesn't keep
+	// fighting with another controller which changes the same resources. If it's false, the drift is only reported.
+	// If spec.ApplySchedule is set, the self-heal is deferred to the next time of the schedule as
+	// DriftDetectedPendingWindow, unless the annotation terraform.core.oam.dev/critical-drift is `true`.
+	// +optional
+	SelfHeal bool `json:"selfHeal,omitempty"`
+
+	// StateOperations are the `terraform state mv`, `terraform state rm` and `terraform import` which run in order
+	// before the next apply, like when the modules are refactored. Each operation runs once, the completed ones are
+	// recorded in status.stateOperations and are skipped by the later applies.
+	// +optional
+	StateOperations []StateOperation `json:"stateOperations,omitempty"`
+
+	// Moved are the `moved` blocks which are rendered with the configuration, so a renamed resource or module is moved
+	// in the state by the apply instead of destroyed and created again. Unlike StateOperations, the moves are planned
+	// with the other changes, and they need Terraform v1.1 or later.
+	// +optional
+	Moved []MovedBlock `json:"moved,omitempty"`
+
+	// SensitiveLeakCheck checks whether the values of the sensitive variables declared in spec.HCL appear in the
+	// non-sensitive outputs after an apply, which is reported by the SensitiveLeak condition. It could be `Warn`, which
+	// only reports the leak, or `Block`, which doesn't publish the outputs and fails the Configuration as
+	// SensitiveLeak. The outputs are not checked if it's not set.
+	// +kubebuilder:validation:Enum=Warn;Block
+	// +optional
+	SensitiveLeakCheck SensitiveLeakCheck `json:"sensitiveLeakCheck,omitempty"`
+
 	BaseConfigurationSpec `json:",inline"`
 }
 
+// OutputTarget is a Kubernetes resource which an output is written to. Exactly one of ConfigMap and Patch should be
+// set.
+type OutputTarget struct {
+	// Output is the name of the output
+	Output string `json:"output"`
+	// ConfigMap writes the output to a key of a ConfigMap, which is created with an owner reference to the
+	// Configuration, so it's deleted along with the Configuration
+	// +optional
+	ConfigMap *ConfigMapOutputTarget `json:"configMap,omitempty"`
+	// Patch adds the output to a field of an existing object by a JSON patch. The object is not owned by the
+	// Configuration, and the controller needs the permission to patch it.
+	// +optional
+	Patch *PatchOutputTarget `json:"patch,omitempty"`
+}
+
+// ConfigMapOutputTarget is a key of a ConfigMap
+type ConfigMapOutputTarget struct {
+	// Name is the name of the ConfigMap
+	Name string `json:"name"`
+	// Key is the key of the output in the ConfigMap. It defaults to the name of the output.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// PatchOutputTarget is a field of an object
+type PatchOutputTarget struct {
+	// APIVersion is the API version of the object, like `apps/v1`
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the object
+	Kind string `json:"kind"`
+	// Name is the name of the object
+	Name string `json:"name"`
+	// Path is the JSON pointer to the field, like `/spec/endpoint`, which the value of the output is added to
+	Path string `json:"path"`
+}
+
 // BaseConfigurationSpec defines the common fields of a ConfigurationSpec
 type BaseConfigurationSpec struct {
 	// WriteConnectionSecretToReference specifies the namespace and name of a
@@ -74,8 +302,67 @@ type ConfigurationStatus struct {
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 
+	// ConfigurationHash is the hash of the Configuration spec and the referenced Provider spec when the cloud
+	// resources were deployed. It's used to skip reconciling a Configuration which is identical and healthy.
+	// +optional
+	ConfigurationHash string `json:"configurationHash,omitempty"`
+
+	// LastReconcileReason explains the outcome of the latest reconcile, like why no apply happened
+	// +optional
+	LastReconcileReason state.ReconcileReason `json:"lastReconcileReason,omitempty"`
+
+	// StateSecretRef is the secret of the kubernetes backend which stores the Terraform state when the cloud resources
+	// were deployed. The state is migrated if the backend changes.
+	// +optional
+	StateSecretRef *types.SecretReference `json:"stateSecretRef,omitempty"`
+
+	// Region is the region of the cloud resources when they were deployed. Changing the region of the Configuration
+	// afterwards needs the annotation terraform.core.oam.dev/acknowledge-region-change.
+	// +optional
+	Region string `json:"region,omitempty"`
+
 	Apply   ConfigurationApplyStatus   `json:"apply,omitempty"`
 	Destroy ConfigurationDestroyStatus `json:"destroy,omitempty"`
+
+	// DestroyPreview is the result of the latest destroy preview requested by the annotation
+	// terraform.core.oam.dev/destroy-preview
+	// +optional
+	DestroyPreview *ConfigurationDestroyPreviewStatus `json:"destroyPreview,omitempty"`
+
+	// Plan is the saved plan which waits for approval when spec.SavedPlan is true
+	// +optional
+	Plan *ConfigurationPlanStatus `json:"plan,omitempty"`
+
+	// Drift is the result of the latest drift check when spec.DriftCheckInterval is set
+	// +optional
+	Drift *ConfigurationDriftStatus `json:"drift,omitempty"`
+
+	// StateOperations are the operations of spec.StateOperations which completed
+	// +optional
+	StateOperations *ConfigurationStateOperationsStatus `json:"stateOperations,omitempty"`
+
+	// TaintedResources are the addresses of the resources which are tainted in the state after the latest apply, they
+	// are replaced by the next apply unless they are untainted by the annotation terraform.core.oam.dev/untaint
+	// +optional
+	TaintedResources []string `json:"taintedResources,omitempty"`
+
+	// ResourceCount is the number of the instances of the managed resources in the state after the latest apply or
+	// destroy, the data sources are not counted
+	// +optional
+	ResourceCount *int32 `json:"resourceCount,omitempty"`
+
+	// ResourceTypes is the number of the instances of the managed resources in the state by their types, like
+	// `aws_s3_bucket`
+	// +optional
+	ResourceTypes map[string]int32 `json:"resourceTypes,omitempty"`
+
+	// LastChangeSummary is the resources which were changed by the latest successful apply
+	// +optional
+	LastChangeSummary *ConfigurationChangeSummary `json:"lastChangeSummary,omitempty"`
+
+	// Conditions are the latest observations of the apply and destroy of the Configuration
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // ConfigurationApplyStatus is the status for Configuration apply
@@ -83,12 +370,257 @@ type ConfigurationApplyStatus struct {
 	State   state.ConfigurationState `json:"state,omitempty"`
 	Message string                   `json:"message,omitempty"`
 	Outputs map[string]Property      `json:"outputs,omitempty"`
+	// Diagnostics are the errors reported by `terraform apply` when it fails, or the warnings which fail it when
+	// spec.WarningsAsErrors is true
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// NextScheduledApplyTime is the time when the pending changes are applied according to spec.ApplySchedule
+	NextScheduledApplyTime *metav1.Time `json:"nextScheduledApplyTime,omitempty"`
+	// Phase is the latest phase reached by the running apply job, like `PlanComplete`. It's cleared once the apply
+	// completes or fails.
+	Phase state.ApplyPhase `json:"phase,omitempty"`
 }
 
 // ConfigurationDestroyStatus is the status for Configuration destroy
 type ConfigurationDestroyStatus struct {
 	State   state.ConfigurationState `json:"state,omitempty"`
 	Message string                   `json:"message,omitempty"`
+	// OrphanedResources are the resources in spec.SkipDestroy which are removed from the state instead of destroyed
+	OrphanedResources []string `json:"orphanedResources,omitempty"`
+}
+
+// ConfigurationDestroyPreviewStatus is the status for the preview of Configuration destroy, which runs
+// `terraform plan -destroy` without destroying anything
+type ConfigurationDestroyPreviewStatus struct {
+	// Token is the value of the annotation which requests the preview
+	Token   string                   `json:"token,omitempty"`
+	State   state.ConfigurationState `json:"state,omitempty"`
+	Message string                   `json:"message,omitempty"`
+	// Resources are the addresses of the resources which would be destroyed
+	Resources []string `json:"resources,omitempty"`
+}
+
+// ConfigurationDriftStatus is the status of the drift check, which runs `terraform plan` to find the changes made to
+// the cloud resources outside of the Configuration
+type ConfigurationDriftStatus struct {
+	State   state.ConfigurationState `json:"state,omitempty"`
+	Message string                   `json:"message,omitempty"`
+	// LastCheckTime is the time when the latest drift check started
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+	// Changes are the changes planned to correct the drift, like `update aws_s3_bucket.b`
+	Changes []string `json:"changes,omitempty"`
+	// LastSelfHealTime is the time of the latest apply triggered by spec.SelfHeal
+	LastSelfHealTime *metav1.Time `json:"lastSelfHealTime,omitempty"`
+	// SelfHealCount is the number of the applies triggered by spec.SelfHeal
+	SelfHealCount int64 `json:"selfHealCount,omitempty"`
+	// NextSelfHealTime is the time of spec.ApplySchedule which the self-heal of the detected drift is deferred to
+	NextSelfHealTime *metav1.Time `json:"nextSelfHealTime,omitempty"`
+}
+
+// ConfigurationStateOperationsStatus is the status of spec.StateOperations
+type ConfigurationStateOperationsStatus struct {
+	// Completed are the operations which completed, like `mv module.a module.b`, they are not run again
+	Completed []string `json:"completed,omitempty"`
+	// LastCompletionTime is the time when the latest operations were recorded as completed
+	LastCompletionTime *metav1.Time `json:"lastCompletionTime,omitempty"`
+}
+
+// ConfigurationChangeSummary is the addresses of the resources changed by an apply, parsed from its JSON output. Only
+// the addresses are recorded, not the attributes, and a replaced resource is both deleted and created.
+type ConfigurationChangeSummary struct {
+	// JobUID is the UID of the apply job, the summary of a job is only parsed once
+	JobUID string `json:"jobUID,omitempty"`
+	// CompletionTime is the time when the apply job completed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	Created        []string     `json:"created,omitempty"`
+	Updated        []string     `json:"updated,omitempty"`
+	Deleted        []string     `json:"deleted,omitempty"`
+}
+
+// ConfigurationPlanStatus is the status of the saved plan, which is applied once it's approved
+type ConfigurationPlanStatus struct {
+	// Checksum is the checksum of the planned changes, the plan is approved by setting the annotation
+	// terraform.core.oam.dev/approve-plan to it
+	Checksum string `json:"checksum,omitempty"`
+	// ConfigurationHash is the hash of the inputs of the plan, the plan is stale once they change
+	ConfigurationHash string                   `json:"configurationHash,omitempty"`
+	State             state.ConfigurationState `json:"state,omitempty"`
+	Message           string                   `json:"message,omitempty"`
+	// Changes are the planned changes of the resources, like `create aws_s3_bucket.b`
+	Changes []string `json:"changes,omitempty"`
+	// CostEstimate is the estimated cost of the plan, which is set if the env TERRAFORM_COST_ESTIMATOR of the
+	// controller is set and the estimation succeeds
+	// +optional
+	CostEstimate *CostEstimate `json:"costEstimate,omitempty"`
+}
+
+// CostEstimate is the estimated monthly cost of a plan
+type CostEstimate struct {
+	// Estimator is the cost estimator, like `infracost`
+	Estimator string `json:"estimator,omitempty"`
+	// Currency is the currency of the costs, like `USD`
+	Currency string `json:"currency,omitempty"`
+	// MonthlyCostDelta is the change of the monthly cost once the plan is applied, like `12.5` or `-3.2`
+	MonthlyCostDelta string `json:"monthlyCostDelta,omitempty"`
+	// TotalMonthlyCost is the monthly cost once the plan is applied
+	// +optional
+	TotalMonthlyCost string `json:"totalMonthlyCost,omitempty"`
+}
+
+// StateOperation is an operation on the Terraform state
+type StateOperation struct {
+	// Type is the type of the operation, which could be `mv`, `rm` or `import`
+	// +kubebuilder:validation:Enum=mv;rm;import
+	Type StateOperationType `json:"type"`
+	// Address is the address of the resource or module which is moved or removed, or of the resource which is
+	// imported
+	Address string `json:"address"`
+	// Destination is the address which the resource or module is moved to by `mv`
+	// +optional
+	Destination string `json:"destination,omitempty"`
+	// ID is the ID of the cloud resource which is imported by `import`
+	// +optional
+	ID string `json:"id,omitempty"`
+}
+
+// MovedBlock is a `moved` block of Terraform
+type MovedBlock struct {
+	// From is the address of the resource or module before the refactor, like `aws_s3_bucket.logs`
+	From string `json:"from"`
+	// To is the address of the resource or module after the refactor, like `module.storage.aws_s3_bucket.logs`
+	To string `json:"to"`
+}
+
+// SensitiveLeakCheck is the severity of a sensitive variable which appears in the non-sensitive outputs
+type SensitiveLeakCheck string
+
+const (
+	// SensitiveLeakWarn reports the leak by the SensitiveLeak condition, the outputs are still published
+	SensitiveLeakWarn SensitiveLeakCheck = "Warn"
+	// SensitiveLeakBlock fails the Configuration, the outputs are not published
+	SensitiveLeakBlock SensitiveLeakCheck = "Block"
+)
+
+// ExtraArg is an extra argument of `terraform apply` or `terraform destroy`, like `-parallelism=5`. It's one of the
+// flags of the commands, except the ones which could embed secrets or change the target of the execution, like
+// `-var`, `-var-file`, `-target` and `-state`.
+// +kubebuilder:validation:Pattern=`^-(auto-approve|compact-warnings|destroy|input|json|lock|lock-timeout|no-color|parallelism|refresh|refresh-only)(=[A-Za-z0-9._:/-]+)?$`
+type ExtraArg string
+
+// StateOperationType is the type of a StateOperation
+type StateOperationType string
+
+const (
+	// StateOperationMove moves a resource or module to another address by `terraform state mv`
+	StateOperationMove StateOperationType = "mv"
+	// StateOperationRemove removes a resource or module from the state by `terraform state rm`
+	StateOperationRemove StateOperationType = "rm"
+	// StateOperationImport imports an existing cloud resource to an address by `terraform import`
+	StateOperationImport StateOperationType = "import"
+)
+
+// GitRemote is a git repo which contains hcl files
+type GitRemote struct {
+	// URL of the git repo, like https://github.com/org/repo.git
+	URL string `json:"url"`
+
+	// Ref is the branch, tag or commit to check out. The default branch is used if it's not set.
+	// +optional
+	Ref string `json:"ref,omitempty"`
+
+	// Path is the sub-directory of the git repo.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// CredentialsSecretRef refers to a secret in the namespace of the Configuration, whose keys `username` and
+	// `password` are used to clone a private git repo over HTTPS.
+	// +optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// PreDestroyHook is a Job which runs to completion before `terraform destroy`, like draining or taking a snapshot.
+// The destroy is blocked until the Job succeeds.
+type PreDestroyHook struct {
+	// Template is the template of the hook Job
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Template batchv1.JobTemplateSpec `json:"template"`
+}
+
+// RegistryPreflight is the connectivity check of the provider registry
+type RegistryPreflight struct {
+	// Endpoint is the http(s) URL which is requested by the check, like the URL of a provider mirror. It defaults to
+	// https://registry.terraform.io/.well-known/terraform.json
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// WorkingDirectoryCleanupPolicy determines when the working directory of the Terraform executor is wiped
+type WorkingDirectoryCleanupPolicy string
+
+const (
+	// CleanupAlways wipes the working directory after every execution
+	CleanupAlways WorkingDirectoryCleanupPolicy = "Always"
+	// CleanupOnSuccess wipes the working directory after a successful execution
+	CleanupOnSuccess WorkingDirectoryCleanupPolicy = "OnSuccess"
+	// CleanupNever keeps the working directory
+	CleanupNever WorkingDirectoryCleanupPolicy = "Never"
+)
+
+// VendoredModules is the volume or the image which contains the modules. Exactly one of ClaimName and Image is set.
+type VendoredModules struct {
+	// ClaimName is the name of a PersistentVolumeClaim in the namespace of the Configuration which contains the modules
+	// +optional
+	ClaimName string `json:"claimName,omitempty"`
+
+	// Image is an image which contains the modules, they're copied from it by an init container, so it needs `sh`
+	// and `cp`
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Path is the directory of the modules, including modules.json, relative to the root of the PersistentVolumeClaim
+	// or the image. It defaults to the root.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// Priority is the priority of a Configuration to get a slot of the running Terraform jobs
+type Priority string
+
+const (
+	// PriorityHigh gets the job slots before the others
+	PriorityHigh Priority = "High"
+	// PriorityNormal is the default priority
+	PriorityNormal Priority = "Normal"
+	// PriorityLow gets the job slots after the others
+	PriorityLow Priority = "Low"
+)
+
+// RequiredProvider is the source and version constraint of a Terraform provider
+type RequiredProvider struct {
+	// Source is the source address of the provider, like hashicorp/aws
+	Source string `json:"source"`
+
+	// Version is the version constraint of the provider, like ~> 4.0
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// ConfigurationReference is a reference to a Configuration
+type ConfigurationReference struct {
+	// Name of the referenced Configuration.
+	Name string `json:"name"`
+
+	// Namespace of the referenced Configuration. It defaults to the namespace of the referencing Configuration.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Diagnostic is an error or warning diagnostic of Terraform
+type Diagnostic struct {
+	// Address is the address of the resource which the diagnostic is about
+	Address string `json:"address,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	Detail  string `json:"detail,omitempty"`
 }
 
 // Property is the property for an output
@@ -98,6 +630,9 @@ type Property struct {
 
 // Backend stores the state in a Kubernetes secret with locking done using a Lease resource.
 type Backend struct {
+	// Type is the type of the backend, which could be `kubernetes` or `local`. It defaults to `kubernetes`. If it's
+	// `local`, no backend block will be rendered and the state will be discarded along with the Terraform job.
+	Type string `json:"type,omitempty"`
 	// SecretSuffix used when creating secrets. Secrets will be named in the format: tfstate-{workspace}-{secretSuffix}
 	SecretSuffix string `json:"secretSuffix,omitempty"`
 	// InClusterConfig Used to authenticate to the cluster from inside a pod. Only `true` is allowed
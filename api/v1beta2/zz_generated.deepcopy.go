@@ -23,6 +23,8 @@ package v1beta2
 
 import (
 	crossplane_runtime "github.com/oam-dev/terraform-controller/api/types/crossplane-runtime"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -66,6 +68,21 @@ func (in *BaseConfigurationSpec) DeepCopy() *BaseConfigurationSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapOutputTarget) DeepCopyInto(out *ConfigMapOutputTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapOutputTarget.
+func (in *ConfigMapOutputTarget) DeepCopy() *ConfigMapOutputTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapOutputTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Configuration) DeepCopyInto(out *Configuration) {
 	*out = *in
@@ -103,6 +120,15 @@ func (in *ConfigurationApplyStatus) DeepCopyInto(out *ConfigurationApplyStatus)
 			(*out)[key] = val
 		}
 	}
+	if in.Diagnostics != nil {
+		in, out := &in.Diagnostics, &out.Diagnostics
+		*out = make([]Diagnostic, len(*in))
+		copy(*out, *in)
+	}
+	if in.NextScheduledApplyTime != nil {
+		in, out := &in.NextScheduledApplyTime, &out.NextScheduledApplyTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigurationApplyStatus.
@@ -115,9 +141,68 @@ func (in *ConfigurationApplyStatus) DeepCopy() *ConfigurationApplyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigurationChangeSummary) DeepCopyInto(out *ConfigurationChangeSummary) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Created != nil {
+		in, out := &in.Created, &out.Created
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Updated != nil {
+		in, out := &in.Updated, &out.Updated
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deleted != nil {
+		in, out := &in.Deleted, &out.Deleted
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigurationChangeSummary.
+func (in *ConfigurationChangeSummary) DeepCopy() *ConfigurationChangeSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigurationChangeSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigurationDestroyPreviewStatus) DeepCopyInto(out *ConfigurationDestroyPreviewStatus) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigurationDestroyPreviewStatus.
+func (in *ConfigurationDestroyPreviewStatus) DeepCopy() *ConfigurationDestroyPreviewStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigurationDestroyPreviewStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigurationDestroyStatus) DeepCopyInto(out *ConfigurationDestroyStatus) {
 	*out = *in
+	if in.OrphanedResources != nil {
+		in, out := &in.OrphanedResources, &out.OrphanedResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigurationDestroyStatus.
@@ -130,6 +215,38 @@ func (in *ConfigurationDestroyStatus) DeepCopy() *ConfigurationDestroyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigurationDriftStatus) DeepCopyInto(out *ConfigurationDriftStatus) {
+	*out = *in
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Changes != nil {
+		in, out := &in.Changes, &out.Changes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastSelfHealTime != nil {
+		in, out := &in.LastSelfHealTime, &out.LastSelfHealTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextSelfHealTime != nil {
+		in, out := &in.NextSelfHealTime, &out.NextSelfHealTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigurationDriftStatus.
+func (in *ConfigurationDriftStatus) DeepCopy() *ConfigurationDriftStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigurationDriftStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigurationList) DeepCopyInto(out *ConfigurationList) {
 	*out = *in
@@ -162,9 +279,54 @@ func (in *ConfigurationList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigurationPlanStatus) DeepCopyInto(out *ConfigurationPlanStatus) {
+	*out = *in
+	if in.Changes != nil {
+		in, out := &in.Changes, &out.Changes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CostEstimate != nil {
+		in, out := &in.CostEstimate, &out.CostEstimate
+		*out = new(CostEstimate)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigurationPlanStatus.
+func (in *ConfigurationPlanStatus) DeepCopy() *ConfigurationPlanStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigurationPlanStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigurationReference) DeepCopyInto(out *ConfigurationReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigurationReference.
+func (in *ConfigurationReference) DeepCopy() *ConfigurationReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigurationReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigurationSpec) DeepCopyInto(out *ConfigurationSpec) {
 	*out = *in
+	if in.GitRemote != nil {
+		in, out := &in.GitRemote, &out.GitRemote
+		*out = new(GitRemote)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Variable != nil {
 		in, out := &in.Variable, &out.Variable
 		*out = new(runtime.RawExtension)
@@ -175,6 +337,75 @@ func (in *ConfigurationSpec) DeepCopyInto(out *ConfigurationSpec) {
 		*out = new(Backend)
 		**out = **in
 	}
+	if in.ExtraApplyArgs != nil {
+		in, out := &in.ExtraApplyArgs, &out.ExtraApplyArgs
+		*out = make([]ExtraArg, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExtraDestroyArgs != nil {
+		in, out := &in.ExtraDestroyArgs, &out.ExtraDestroyArgs
+		*out = make([]ExtraArg, len(*in))
+		copy(*out, *in)
+	}
+	if in.SkipDestroy != nil {
+		in, out := &in.SkipDestroy, &out.SkipDestroy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProviderLockConfigMapRef != nil {
+		in, out := &in.ProviderLockConfigMapRef, &out.ProviderLockConfigMapRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.NetrcSecretRef != nil {
+		in, out := &in.NetrcSecretRef, &out.NetrcSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]ConfigurationReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.PreDestroyHook != nil {
+		in, out := &in.PreDestroyHook, &out.PreDestroyHook
+		*out = new(PreDestroyHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequiredProviders != nil {
+		in, out := &in.RequiredProviders, &out.RequiredProviders
+		*out = make(map[string]RequiredProvider, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RegistryPreflight != nil {
+		in, out := &in.RegistryPreflight, &out.RegistryPreflight
+		*out = new(RegistryPreflight)
+		**out = **in
+	}
+	if in.VendoredModules != nil {
+		in, out := &in.VendoredModules, &out.VendoredModules
+		*out = new(VendoredModules)
+		**out = **in
+	}
+	if in.OutputTargets != nil {
+		in, out := &in.OutputTargets, &out.OutputTargets
+		*out = make([]OutputTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StateOperations != nil {
+		in, out := &in.StateOperations, &out.StateOperations
+		*out = make([]StateOperation, len(*in))
+		copy(*out, *in)
+	}
+	if in.Moved != nil {
+		in, out := &in.Moved, &out.Moved
+		*out = make([]MovedBlock, len(*in))
+		copy(*out, *in)
+	}
 	in.BaseConfigurationSpec.DeepCopyInto(&out.BaseConfigurationSpec)
 }
 
@@ -188,11 +419,89 @@ func (in *ConfigurationSpec) DeepCopy() *ConfigurationSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigurationStateOperationsStatus) DeepCopyInto(out *ConfigurationStateOperationsStatus) {
+	*out = *in
+	if in.Completed != nil {
+		in, out := &in.Completed, &out.Completed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastCompletionTime != nil {
+		in, out := &in.LastCompletionTime, &out.LastCompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigurationStateOperationsStatus.
+func (in *ConfigurationStateOperationsStatus) DeepCopy() *ConfigurationStateOperationsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigurationStateOperationsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigurationStatus) DeepCopyInto(out *ConfigurationStatus) {
 	*out = *in
+	if in.StateSecretRef != nil {
+		in, out := &in.StateSecretRef, &out.StateSecretRef
+		*out = new(crossplane_runtime.SecretReference)
+		**out = **in
+	}
 	in.Apply.DeepCopyInto(&out.Apply)
-	out.Destroy = in.Destroy
+	in.Destroy.DeepCopyInto(&out.Destroy)
+	if in.DestroyPreview != nil {
+		in, out := &in.DestroyPreview, &out.DestroyPreview
+		*out = new(ConfigurationDestroyPreviewStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(ConfigurationPlanStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = new(ConfigurationDriftStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StateOperations != nil {
+		in, out := &in.StateOperations, &out.StateOperations
+		*out = new(ConfigurationStateOperationsStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TaintedResources != nil {
+		in, out := &in.TaintedResources, &out.TaintedResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResourceCount != nil {
+		in, out := &in.ResourceCount, &out.ResourceCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ResourceTypes != nil {
+		in, out := &in.ResourceTypes, &out.ResourceTypes
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LastChangeSummary != nil {
+		in, out := &in.LastChangeSummary, &out.LastChangeSummary
+		*out = new(ConfigurationChangeSummary)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigurationStatus.
@@ -205,6 +514,127 @@ func (in *ConfigurationStatus) DeepCopy() *ConfigurationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CostEstimate) DeepCopyInto(out *CostEstimate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CostEstimate.
+func (in *CostEstimate) DeepCopy() *CostEstimate {
+	if in == nil {
+		return nil
+	}
+	out := new(CostEstimate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Diagnostic) DeepCopyInto(out *Diagnostic) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Diagnostic.
+func (in *Diagnostic) DeepCopy() *Diagnostic {
+	if in == nil {
+		return nil
+	}
+	out := new(Diagnostic)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitRemote) DeepCopyInto(out *GitRemote) {
+	*out = *in
+	if in.CredentialsSecretRef != nil {
+		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitRemote.
+func (in *GitRemote) DeepCopy() *GitRemote {
+	if in == nil {
+		return nil
+	}
+	out := new(GitRemote)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MovedBlock) DeepCopyInto(out *MovedBlock) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MovedBlock.
+func (in *MovedBlock) DeepCopy() *MovedBlock {
+	if in == nil {
+		return nil
+	}
+	out := new(MovedBlock)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutputTarget) DeepCopyInto(out *OutputTarget) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(ConfigMapOutputTarget)
+		**out = **in
+	}
+	if in.Patch != nil {
+		in, out := &in.Patch, &out.Patch
+		*out = new(PatchOutputTarget)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputTarget.
+func (in *OutputTarget) DeepCopy() *OutputTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(OutputTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatchOutputTarget) DeepCopyInto(out *PatchOutputTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatchOutputTarget.
+func (in *PatchOutputTarget) DeepCopy() *PatchOutputTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PatchOutputTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreDestroyHook) DeepCopyInto(out *PreDestroyHook) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreDestroyHook.
+func (in *PreDestroyHook) DeepCopy() *PreDestroyHook {
+	if in == nil {
+		return nil
+	}
+	out := new(PreDestroyHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Property) DeepCopyInto(out *Property) {
 	*out = *in
@@ -219,3 +649,63 @@ func (in *Property) DeepCopy() *Property {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryPreflight) DeepCopyInto(out *RegistryPreflight) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryPreflight.
+func (in *RegistryPreflight) DeepCopy() *RegistryPreflight {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryPreflight)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequiredProvider) DeepCopyInto(out *RequiredProvider) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequiredProvider.
+func (in *RequiredProvider) DeepCopy() *RequiredProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(RequiredProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StateOperation) DeepCopyInto(out *StateOperation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StateOperation.
+func (in *StateOperation) DeepCopy() *StateOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(StateOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VendoredModules) DeepCopyInto(out *VendoredModules) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VendoredModules.
+func (in *VendoredModules) DeepCopy() *VendoredModules {
+	if in == nil {
+		return nil
+	}
+	out := new(VendoredModules)
+	in.DeepCopyInto(out)
+	return out
+}
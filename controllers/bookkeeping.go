@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+const (
+	// BookkeepingStorageEnv is the env of where the bookkeeping of the Configurations is stored, which is the hash of
+	// the deployed Configuration, the saved plan and the destroy preview. It could be `Status`, `ConfigMap` or `Secret`,
+	// and defaults to `Status`.
+	BookkeepingStorageEnv = "TERRAFORM_BOOKKEEPING_STORAGE"
+	// BookkeepingInStatus stores the bookkeeping in the status of the Configuration
+	BookkeepingInStatus = "Status"
+	// BookkeepingInConfigMap stores the bookkeeping in a ConfigMap owned by the Configuration, only the states and
+	// the messages, and the token of the destroy preview, are kept in the status
+	BookkeepingInConfigMap = "ConfigMap"
+	// BookkeepingInSecret is like BookkeepingInConfigMap, except that the bookkeeping is stored in a Secret
+	BookkeepingInSecret = "Secret"
+	// TFBookkeepingName is the name of the ConfigMap or the Secret which stores the bookkeeping of a Configuration
+	TFBookkeepingName = "%s-terraform-bookkeeping"
+
+	bookkeepingHashKey           = "configurationHash"
+	bookkeepingPlanKey           = "plan"
+	bookkeepingDestroyPreviewKey = "destroyPreview"
+)
+
+// GetBookkeepingStorage gets where the bookkeeping of the Configurations is stored
+func GetBookkeepingStorage() string {
+	if storage := os.Getenv(BookkeepingStorageEnv); storage != "" {
+		return storage
+	}
+	return BookkeepingInStatus
+}
+
+// ValidBookkeepingStorage checks the env BookkeepingStorageEnv is one of the storages
+func ValidBookkeepingStorage() error {
+	switch storage := GetBookkeepingStorage(); storage {
+	case BookkeepingInStatus, BookkeepingInConfigMap, BookkeepingInSecret:
+		return nil
+	default:
+		return fmt.Errorf("%s should be one of %s, %s and %s, but got %s", BookkeepingStorageEnv,
+			BookkeepingInStatus, BookkeepingInConfigMap, BookkeepingInSecret, storage)
+	}
+}
+
+func (meta *TFConfigurationMeta) isBookkeepingOffloaded() bool {
+	return meta.BookkeepingStorage == BookkeepingInConfigMap || meta.BookkeepingStorage == BookkeepingInSecret
+}
+
+// readBookkeeping reads the data of the ConfigMap or the Secret of the bookkeeping, it's empty if it doesn't exist
+func (meta *TFConfigurationMeta) readBookkeeping(ctx context.Context, k8sClient client.Client) (map[string]string, error) {
+	key := client.ObjectKey{Name: fmt.Sprintf(TFBookkeepingName, meta.Name), Namespace: meta.Namespace}
+	data := map[string]string{}
+	if meta.BookkeepingStorage == BookkeepingInSecret {
+		var secret v1.Secret
+		if err := k8sClient.Get(ctx, key, &secret); err != nil {
+			return data, errors.Wrap(client.IgnoreNotFound(err), "failed to get the Secret of the bookkeeping")
+		}
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+		return data, nil
+	}
+	var cm v1.ConfigMap
+	if err := k8sClient.Get(ctx, key, &cm); err != nil {
+		return data, errors.Wrap(client.IgnoreNotFound(err), "failed to get the ConfigMap of the bookkeeping")
+	}
+	for k, v := range cm.Data {
+		data[k] = v
+	}
+	return data, nil
+}
+
+// writeBookkeeping writes a key of the bookkeeping to the ConfigMap or the Secret, which is created with an owner
+// reference to the Configuration. The key is removed if the value is empty.
+func (meta *TFConfigurationMeta) writeBookkeeping(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration, key, value string) error {
+	objectMeta := metav1.ObjectMeta{
+		Name:      fmt.Sprintf(TFBookkeepingName, meta.Name),
+		Namespace: meta.Namespace,
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: v1beta2.GroupVersion.String(),
+			Kind:       "Configuration",
+			Name:       configuration.Name,
+			UID:        configuration.UID,
+		}},
+	}
+	if meta.BookkeepingStorage == BookkeepingInSecret {
+		var secret v1.Secret
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: objectMeta.Name, Namespace: meta.Namespace}, &secret)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get the Secret of the bookkeeping")
+		}
+		notFound := kerrors.IsNotFound(err)
+		if notFound {
+			secret = v1.Secret{ObjectMeta: objectMeta}
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		if value == "" {
+			delete(secret.Data, key)
+		} else {
+			secret.Data[key] = []byte(value)
+		}
+		if notFound {
+			return errors.Wrap(k8sClient.Create(ctx, &secret), "failed to create the Secret of the bookkeeping")
+		}
+		return errors.Wrap(k8sClient.Update(ctx, &secret), "failed to update the Secret of the bookkeeping")
+	}
+
+	var cm v1.ConfigMap
+	err := k8sClient.Get(ctx, client.ObjectKey{Name: objectMeta.Name, Namespace: meta.Namespace}, &cm)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get the ConfigMap of the bookkeeping")
+	}
+	notFound := kerrors.IsNotFound(err)
+	if notFound {
+		cm = v1.ConfigMap{ObjectMeta: objectMeta}
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	if value == "" {
+		delete(cm.Data, key)
+	} else {
+		cm.Data[key] = value
+	}
+	if notFound {
+		return errors.Wrap(k8sClient.Create(ctx, &cm), "failed to create the ConfigMap of the bookkeeping")
+	}
+	return errors.Wrap(k8sClient.Update(ctx, &cm), "failed to update the ConfigMap of the bookkeeping")
+}
+
+// writeBookkeepingJSON writes a key of the bookkeeping as JSON, the key is removed if v is nil
+func (meta *TFConfigurationMeta) writeBookkeepingJSON(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	value := string(data)
+	// v could be a nil pointer of the status
+	if value == "null" {
+		value = ""
+	}
+	return meta.writeBookkeeping(ctx, k8sClient, configuration, key, value)
+}
+
+// getConfigurationHash gets the hash of the deployed Configuration, wherever it's stored
+func (meta *TFConfigurationMeta) getConfigurationHash(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) (string, error) {
+	if !meta.isBookkeepingOffloaded() {
+		return configuration.Status.ConfigurationHash, nil
+	}
+	data, err := meta.readBookkeeping(ctx, k8sClient)
+	if err != nil {
+		return "", err
+	}
+	return data[bookkeepingHashKey], nil
+}
+
+// getPlanStatus gets the status of the saved plan, including the inputs and the changes of the plan, wherever it's
+// stored
+func (meta *TFConfigurationMeta) getPlanStatus(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) (*v1beta2.ConfigurationPlanStatus, error) {
+	if !meta.isBookkeepingOffloaded() {
+		return configuration.Status.Plan, nil
+	}
+	data, err := meta.readBookkeeping(ctx, k8sClient)
+	if err != nil || data[bookkeepingPlanKey] == "" {
+		return nil, err
+	}
+	var plan v1beta2.ConfigurationPlanStatus
+	if err := json.Unmarshal([]byte(data[bookkeepingPlanKey]), &plan); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the plan of the bookkeeping")
+	}
+	return &plan, nil
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestValidBookkeepingStorage(t *testing.T) {
+	t.Setenv(BookkeepingStorageEnv, "")
+	assert.Nil(t, ValidBookkeepingStorage())
+	assert.Equal(t, BookkeepingInStatus, GetBookkeepingStorage())
+
+	t.Setenv(BookkeepingStorageEnv, BookkeepingInSecret)
+	assert.Nil(t, ValidBookkeepingStorage())
+
+	t.Setenv(BookkeepingStorageEnv, "Etcd")
+	assert.EqualError(t, ValidBookkeepingStorage(), "TERRAFORM_BOOKKEEPING_STORAGE should be one of Status, ConfigMap and Secret, but got Etcd")
+}
+
+func TestBookkeeping(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	plan := &v1beta2.ConfigurationPlanStatus{
+		Checksum:          "abc123",
+		ConfigurationHash: "hash",
+		State:             types.PlanPendingApproval,
+		Changes:           []string{"create aws_s3_bucket.b"},
+	}
+	preview := &v1beta2.ConfigurationDestroyPreviewStatus{
+		Token:     "1647426620",
+		State:     types.DestroyPreviewCompleted,
+		Resources: []string{"aws_s3_bucket.b"},
+	}
+
+	for _, storage := range []string{BookkeepingInStatus, BookkeepingInConfigMap, BookkeepingInSecret} {
+		t.Run(storage, func(t *testing.T) {
+			configuration := &v1beta2.Configuration{
+				ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default", UID: "configuration-uid"},
+			}
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(configuration).Build()
+			meta := &TFConfigurationMeta{Name: "abc", Namespace: "default", BookkeepingStorage: storage}
+			offloaded := storage != BookkeepingInStatus
+
+			assert.Nil(t, meta.updatePlanStatus(ctx, k8sClient, plan))
+			assert.Nil(t, meta.updateDestroyPreviewStatus(ctx, k8sClient, preview))
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			assert.Equal(t, "abc123", got.Status.Plan.Checksum)
+			assert.Equal(t, types.PlanPendingApproval, got.Status.Plan.State)
+			assert.Equal(t, offloaded, got.Status.Plan.Changes == nil)
+			assert.Equal(t, offloaded, got.Status.Plan.ConfigurationHash == "")
+			assert.Equal(t, "1647426620", got.Status.DestroyPreview.Token)
+			assert.Equal(t, offloaded, got.Status.DestroyPreview.Resources == nil)
+
+			gotPlan, err := meta.getPlanStatus(ctx, k8sClient, &got)
+			assert.Nil(t, err)
+			assert.Equal(t, plan, gotPlan)
+			if offloaded {
+				data, err := meta.readBookkeeping(ctx, k8sClient)
+				assert.Nil(t, err)
+				var gotPreview v1beta2.ConfigurationDestroyPreviewStatus
+				assert.Nil(t, json.Unmarshal([]byte(data[bookkeepingDestroyPreviewKey]), &gotPreview))
+				assert.Equal(t, preview, &gotPreview)
+			} else {
+				assert.Equal(t, preview, got.Status.DestroyPreview)
+			}
+
+			assert.Nil(t, meta.updatePlanStatus(ctx, k8sClient, nil))
+			got = v1beta2.Configuration{}
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			assert.Nil(t, got.Status.Plan)
+			gotPlan, err = meta.getPlanStatus(ctx, k8sClient, &got)
+			assert.Nil(t, err)
+			assert.Nil(t, gotPlan)
+
+			if !offloaded {
+				return
+			}
+			assert.Nil(t, meta.writeBookkeeping(ctx, k8sClient, &got, bookkeepingHashKey, "hash"))
+			hash, err := meta.getConfigurationHash(ctx, k8sClient, &got)
+			assert.Nil(t, err)
+			assert.Equal(t, "hash", hash)
+
+			var obj client.Object = &corev1.ConfigMap{}
+			if storage == BookkeepingInSecret {
+				obj = &corev1.Secret{}
+			}
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc-terraform-bookkeeping", Namespace: "default"}, obj))
+			assert.Equal(t, "configuration-uid", string(obj.GetOwnerReferences()[0].UID))
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	"github.com/oam-dev/terraform-controller/controllers/terraform"
+)
+
+// recordChangeSummary records the resources changed by the succeeded apply job to status.LastChangeSummary. The logs
+// of a job are only parsed once, and the values of the variables and credentials are redacted from the addresses, like
+// the keys of `for_each`.
+func (meta *TFConfigurationMeta) recordChangeSummary(ctx context.Context, k8sClient client.Client, job *batchv1.Job) error {
+	if job.Status.Succeeded != int32(1) {
+		return nil
+	}
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if summary := configuration.Status.LastChangeSummary; summary != nil && summary.JobUID == string(job.UID) {
+		return nil
+	}
+
+	logs, err := terraform.GetTerraformLogs(ctx, meta.Namespace, job.Name, terraformContainerName, terraformInitContainerName)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the logs of the Terraform apply job")
+	}
+	summary := &v1beta2.ConfigurationChangeSummary{
+		JobUID:         string(job.UID),
+		CompletionTime: job.Status.CompletionTime,
+	}
+	summary.Created, summary.Updated, summary.Deleted = terraform.ParseAppliedChanges(meta.redactLogs(logs))
+	klog.InfoS("The apply changed the resources", "Name", meta.Name, "Namespace", meta.Namespace,
+		"Created", summary.Created, "Updated", summary.Updated, "Deleted", summary.Deleted)
+	configuration.Status.LastChangeSummary = summary
+	return k8sClient.Status().Update(ctx, &configuration)
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	"github.com/oam-dev/terraform-controller/controllers/terraform"
+)
+
+func TestRecordChangeSummary(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+
+	configuration := &v1beta2.Configuration{ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(configuration).Build()
+	meta := &TFConfigurationMeta{
+		Name:      "abc",
+		Namespace: "default",
+		VariableSecretData: map[string][]byte{
+			"TF_VAR_tenant": []byte("s3cr3t-tenant"),
+		},
+	}
+
+	var fetched int
+	patches := gomonkey.ApplyFunc(terraform.GetTerraformLogs, func(ctx context.Context, namespace, jobName, containerName, initContainerName string) (string, error) {
+		fetched++
+		return `{"@level":"info","@message":"aws_s3_bucket.b[\"s3cr3t-tenant\"]: Creation complete after 1s [id=b]","@module":"terraform.ui","hook":{"resource":{"addr":"aws_s3_bucket.b[\"s3cr3t-tenant\"]"},"action":"create"},"type":"apply_complete"}
+{"@level":"info","@message":"aws_instance.a: Destruction complete after 1s","@module":"terraform.ui","hook":{"resource":{"addr":"aws_instance.a"},"action":"delete"},"type":"apply_complete"}`, nil
+	})
+	defer patches.Reset()
+
+	completionTime := v1.Now()
+	job := &batchv1.Job{
+		ObjectMeta: v1.ObjectMeta{Name: "abc-apply", Namespace: "default", UID: "job-1"},
+		Status:     batchv1.JobStatus{Succeeded: 1, CompletionTime: &completionTime},
+	}
+	getSummary := func() *v1beta2.ConfigurationChangeSummary {
+		var c v1beta2.Configuration
+		assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &c))
+		return c.Status.LastChangeSummary
+	}
+
+	// the running job is not parsed
+	assert.Nil(t, meta.recordChangeSummary(ctx, k8sClient, &batchv1.Job{ObjectMeta: job.ObjectMeta}))
+	assert.Nil(t, getSummary())
+	assert.Equal(t, 0, fetched)
+
+	assert.Nil(t, meta.recordChangeSummary(ctx, k8sClient, job))
+	summary := getSummary()
+	assert.Equal(t, "job-1", summary.JobUID)
+	assert.Equal(t, []string{`aws_s3_bucket.b["******"]`}, summary.Created)
+	assert.Nil(t, summary.Updated)
+	assert.Equal(t, []string{"aws_instance.a"}, summary.Deleted)
+	assert.NotNil(t, summary.CompletionTime)
+
+	// the logs of the same job are only parsed once
+	assert.Nil(t, meta.recordChangeSummary(ctx, k8sClient, job))
+	assert.Equal(t, 1, fetched)
+}
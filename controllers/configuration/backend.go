@@ -0,0 +1,90 @@
+package configuration
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// SetBackend sets the type of spec.Backend of the Configuration to the default backend of the Provider, like SetRegion,
+// if the Configuration doesn't set it. The Configuration which already stores a state in the kubernetes backend
+// doesn't inherit it, so its state is not discarded. The merged backend is validated.
+func SetBackend(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration, providerObj *v1beta1.Provider) error {
+	if providerObj == nil || providerObj.Spec.Backend == nil || providerObj.Spec.Backend.Type == "" {
+		return nil
+	}
+	if configuration.Spec.Backend != nil && configuration.Spec.Backend.Type != "" || configuration.Status.StateSecretRef != nil {
+		return nil
+	}
+	backend := &v1beta2.Backend{}
+	if configuration.Spec.Backend != nil {
+		backend = configuration.Spec.Backend.DeepCopy()
+	}
+	backend.Type = providerObj.Spec.Backend.Type
+	if err := validBackend(backend); err != nil {
+		return errors.Wrapf(err, "the backend of Provider %s/%s is not valid", providerObj.Namespace, providerObj.Name)
+	}
+
+	latest, err := Get(ctx, k8sClient, client.ObjectKey{Name: configuration.Name, Namespace: configuration.Namespace})
+	if err != nil {
+		return errors.Wrap(err, "failed to get configuration")
+	}
+	latest.Spec.Backend = backend
+	if err := Update(ctx, k8sClient, &latest); err != nil {
+		return err
+	}
+	configuration.Spec.Backend = backend.DeepCopy()
+	configuration.ResourceVersion = latest.ResourceVersion
+	return nil
+}
+
+// BackendSecretSuffix gets the suffix of the secret which stores the Terraform state of the Configuration in the
+// kubernetes backend, which is the name of the Configuration if spec.Backend.SecretSuffix is not set
+func BackendSecretSuffix(configuration *v1beta2.Configuration) string {
+	if configuration.Spec.Backend != nil && configuration.Spec.Backend.SecretSuffix != "" {
+		return configuration.Spec.Backend.SecretSuffix
+	}
+	return configuration.Name
+}
+
+// FindBackendCollision finds another Configuration which stores its Terraform state in the same secret of the
+// kubernetes backend as the Configuration. All Configurations share the backend namespace, so Configurations with the
+// same name in different namespaces collide unless spec.Backend.SecretSuffix is set. The Configuration created first
+// keeps the state, so only the later one collides. It returns the namespaced name of the Configuration which keeps the
+// state, or an empty string if there is no collision.
+func FindBackendCollision(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) (string, error) {
+	if IsLocalBackend(configuration) {
+		return "", nil
+	}
+	var configurations v1beta2.ConfigurationList
+	if err := k8sClient.List(ctx, &configurations); err != nil {
+		return "", errors.Wrap(err, "failed to list Configurations")
+	}
+	suffix := BackendSecretSuffix(configuration)
+	for i := range configurations.Items {
+		c := &configurations.Items[i]
+		if c.UID == configuration.UID || IsLocalBackend(c) || BackendSecretSuffix(c) != suffix {
+			continue
+		}
+		if createdBefore(c, configuration) {
+			return c.Namespace + "/" + c.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// createdBefore checks whether a is created before b. The namespaced names break the tie of the creation timestamps,
+// which are in seconds.
+func createdBefore(a, b *v1beta2.Configuration) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
@@ -0,0 +1,119 @@
+package configuration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	types "github.com/oam-dev/terraform-controller/api/types/crossplane-runtime"
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestFindBackendCollision(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+
+	created := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	newConfiguration := func(namespace, name string, createdAt metav1.Time, backend *v1beta2.Backend) *v1beta2.Configuration {
+		return &v1beta2.Configuration{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				UID:               apitypes.UID(namespace + "-" + name),
+				CreationTimestamp: createdAt,
+			},
+			Spec: v1beta2.ConfigurationSpec{Backend: backend},
+		}
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		newConfiguration("default", "vpc", created, nil),
+		newConfiguration("default", "rds", created, &v1beta2.Backend{SecretSuffix: "shared"}),
+		newConfiguration("default", "local", created, &v1beta2.Backend{Type: "local"}),
+	).Build()
+
+	testcases := []struct {
+		name          string
+		configuration *v1beta2.Configuration
+		want          string
+	}{
+		{
+			name:          "the same configuration",
+			configuration: newConfiguration("default", "vpc", created, nil),
+		},
+		{
+			name:          "same name in another namespace",
+			configuration: newConfiguration("dev", "vpc", metav1.Now(), nil),
+			want:          "default/vpc",
+		},
+		{
+			name:          "same secret suffix",
+			configuration: newConfiguration("dev", "db", metav1.Now(), &v1beta2.Backend{SecretSuffix: "shared"}),
+			want:          "default/rds",
+		},
+		{
+			name:          "the configuration created first keeps the state",
+			configuration: newConfiguration("dev", "vpc", metav1.NewTime(created.Add(-time.Hour)), nil),
+		},
+		{
+			name:          "different secret suffix",
+			configuration: newConfiguration("dev", "vpc", metav1.Now(), &v1beta2.Backend{SecretSuffix: "dev-vpc"}),
+		},
+		{
+			name:          "local backend",
+			configuration: newConfiguration("dev", "local", metav1.Now(), &v1beta2.Backend{Type: "local"}),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			collision, err := FindBackendCollision(ctx, k8sClient, tc.configuration)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, collision)
+		})
+	}
+}
+
+func TestSetBackend(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+	newConfiguration := func(name string) *v1beta2.Configuration {
+		return &v1beta2.Configuration{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	}
+	inherited := newConfiguration("inherited")
+	inherited.Spec.Backend = &v1beta2.Backend{SecretSuffix: "team-a"}
+	overridden := newConfiguration("overridden")
+	overridden.Spec.Backend = &v1beta2.Backend{Type: "kubernetes"}
+	stateful := newConfiguration("stateful")
+	stateful.Status.StateSecretRef = &types.SecretReference{Name: "tfstate-default-stateful", Namespace: "vela-system"}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(inherited, overridden, stateful).Build()
+
+	p := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "default"},
+		Spec:       v1beta1.ProviderSpec{Backend: &v1beta1.ProviderBackend{Type: "local"}},
+	}
+	assert.Nil(t, SetBackend(ctx, k8sClient, inherited, nil))
+	assert.Nil(t, SetBackend(ctx, k8sClient, inherited, p))
+	assert.Equal(t, &v1beta2.Backend{Type: "local", SecretSuffix: "team-a"}, inherited.Spec.Backend)
+	got, err := Get(ctx, k8sClient, apitypes.NamespacedName{Name: "inherited", Namespace: "default"})
+	assert.Nil(t, err)
+	assert.Equal(t, "local", got.Spec.Backend.Type)
+
+	assert.Nil(t, SetBackend(ctx, k8sClient, overridden, p))
+	assert.Equal(t, "kubernetes", overridden.Spec.Backend.Type)
+
+	assert.Nil(t, SetBackend(ctx, k8sClient, stateful, p))
+	assert.Nil(t, stateful.Spec.Backend)
+
+	p.Spec.Backend.Type = "s3"
+	assert.EqualError(t, SetBackend(ctx, k8sClient, newConfiguration("invalid"), p),
+		"the backend of Provider default/team-a is not valid: spec.Backend.Type s3 is not supported, it should be kubernetes or local")
+}
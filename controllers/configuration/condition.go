@@ -0,0 +1,175 @@
+package configuration
+
+import (
+	"fmt"
+	"strings"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// ConditionType is the type of a Configuration condition
+type ConditionType string
+
+const (
+	// ConditionApplied reports the result of provisioning the cloud resources
+	ConditionApplied ConditionType = "Applied"
+	// ConditionDestroyed reports the result of destroying the cloud resources
+	ConditionDestroyed ConditionType = "Destroyed"
+	// ConditionProviderReady reports the readiness of the Provider of the Configuration
+	ConditionProviderReady ConditionType = "ProviderReady"
+	// ConditionResourcesTainted reports whether any resource is tainted in the state after the latest apply
+	ConditionResourcesTainted ConditionType = "ResourcesTainted"
+	// ConditionSensitiveLeak reports whether the values of the sensitive variables appear in the non-sensitive outputs
+	// after the latest apply, when spec.SensitiveLeakCheck is set
+	ConditionSensitiveLeak ConditionType = "SensitiveLeak"
+)
+
+// The reasons of the ProviderReady condition
+const (
+	reasonProviderReady        = "ProviderReady"
+	reasonProviderNotReady     = "ProviderNotReady"
+	reasonProviderNotFound     = "ProviderNotFound"
+	reasonProviderAccessDenied = "ProviderAccessDenied"
+)
+
+// The reasons of the ResourcesTainted condition
+const (
+	reasonResourcesTainted   = "ResourcesTainted"
+	reasonNoResourcesTainted = "NoResourcesTainted"
+)
+
+// The reasons of the SensitiveLeak condition
+const (
+	reasonSensitiveLeakWarned  = "SensitiveLeakWarned"
+	reasonSensitiveLeakBlocked = "SensitiveLeakBlocked"
+	reasonNoSensitiveLeak      = "NoSensitiveLeak"
+)
+
+// conditionStatus maps a Configuration state to the status of a condition. States which are not listed are failures.
+var conditionStatus = map[types.ConfigurationState]metav1.ConditionStatus{
+	types.Available:   metav1.ConditionTrue,
+	types.Authorizing: metav1.ConditionUnknown,
+	types.ConfigurationProvisioningAndChecking: metav1.ConditionUnknown,
+	types.ConfigurationDestroying:              metav1.ConditionUnknown,
+	types.ConfigurationReloading:               metav1.ConditionUnknown,
+	types.GeneratingOutputs:                    metav1.ConditionUnknown,
+	types.ConfigurationPendingOnConcurrency:    metav1.ConditionUnknown,
+	types.WaitingForDependency:                 metav1.ConditionUnknown,
+	types.WaitingForDependentsDeletion:         metav1.ConditionUnknown,
+	types.PendingScheduledApply:                metav1.ConditionUnknown,
+	types.CredentialsExpiringSoon:              metav1.ConditionUnknown,
+	types.PlanRunning:                          metav1.ConditionUnknown,
+	types.PlanPendingApproval:                  metav1.ConditionUnknown,
+}
+
+// SetCondition sets the condition of conditionType according to the state of the Configuration. The reason of the
+// condition is the state, and the observedGeneration is the generation of the Configuration. The lastTransitionTime is
+// only updated when the status of the condition changes.
+func SetCondition(configuration *v1beta2.Configuration, conditionType ConditionType, state types.ConfigurationState, message string) {
+	status, ok := conditionStatus[state]
+	if !ok {
+		status = metav1.ConditionFalse
+	}
+	apimeta.SetStatusCondition(&configuration.Status.Conditions, metav1.Condition{
+		Type:               string(conditionType),
+		Status:             status,
+		ObservedGeneration: configuration.Generation,
+		Reason:             string(state),
+		Message:            message,
+	})
+}
+
+// GetCondition gets the condition of conditionType, it returns nil if the condition is not set
+func GetCondition(configuration *v1beta2.Configuration, conditionType ConditionType) *metav1.Condition {
+	return apimeta.FindStatusCondition(configuration.Status.Conditions, string(conditionType))
+}
+
+// SetProviderReadyCondition sets the ProviderReady condition according to the Provider of the Configuration, which
+// echoes the message of the Provider if it's not ready. It returns whether the condition changes.
+func SetProviderReadyCondition(configuration *v1beta2.Configuration, providerObj *v1beta1.Provider) bool {
+	condition := metav1.Condition{
+		Type:               string(ConditionProviderReady),
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: configuration.Generation,
+		Reason:             reasonProviderReady,
+	}
+	switch {
+	case providerObj == nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonProviderNotFound
+		condition.Message = types.ErrProviderNotFound
+	case providerObj.Status.State == types.ProviderIsNotReady:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonProviderNotReady
+		condition.Message = providerObj.Status.Message
+	}
+	return setProviderReadyCondition(configuration, condition)
+}
+
+// SetProviderAccessDeniedCondition sets the ProviderReady condition when the Configuration is not allowed to use the
+// Provider by the message of the denial. It returns whether the condition changes.
+func SetProviderAccessDeniedCondition(configuration *v1beta2.Configuration, message string) bool {
+	return setProviderReadyCondition(configuration, metav1.Condition{
+		Type:               string(ConditionProviderReady),
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: configuration.Generation,
+		Reason:             reasonProviderAccessDenied,
+		Message:            message,
+	})
+}
+
+func setProviderReadyCondition(configuration *v1beta2.Configuration, condition metav1.Condition) bool {
+	if existing := GetCondition(configuration, ConditionProviderReady); existing != nil && existing.Status == condition.Status &&
+		existing.Reason == condition.Reason && existing.Message == condition.Message &&
+		existing.ObservedGeneration == condition.ObservedGeneration {
+		return false
+	}
+	apimeta.SetStatusCondition(&configuration.Status.Conditions, condition)
+	return true
+}
+
+// SetResourcesTaintedCondition sets the ResourcesTainted condition according to the tainted resources, which are
+// replaced by the next apply
+func SetResourcesTaintedCondition(configuration *v1beta2.Configuration, taintedResources []string) {
+	condition := metav1.Condition{
+		Type:               string(ConditionResourcesTainted),
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: configuration.Generation,
+		Reason:             reasonNoResourcesTainted,
+	}
+	if len(taintedResources) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonResourcesTainted
+		condition.Message = fmt.Sprintf(types.MessageResourcesTainted, strings.Join(taintedResources, ", "))
+	}
+	apimeta.SetStatusCondition(&configuration.Status.Conditions, condition)
+}
+
+// SetSensitiveLeakCondition sets the SensitiveLeak condition according to the non-sensitive outputs which leak the
+// sensitive variables. The condition is removed if spec.SensitiveLeakCheck is not set.
+func SetSensitiveLeakCondition(configuration *v1beta2.Configuration, leakedOutputs []string) {
+	if configuration.Spec.SensitiveLeakCheck == "" {
+		apimeta.RemoveStatusCondition(&configuration.Status.Conditions, string(ConditionSensitiveLeak))
+		return
+	}
+	condition := metav1.Condition{
+		Type:               string(ConditionSensitiveLeak),
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: configuration.Generation,
+		Reason:             reasonNoSensitiveLeak,
+	}
+	if len(leakedOutputs) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonSensitiveLeakWarned
+		if configuration.Spec.SensitiveLeakCheck == v1beta2.SensitiveLeakBlock {
+			condition.Reason = reasonSensitiveLeakBlocked
+		}
+		condition.Message = fmt.Sprintf(types.MessageSensitiveLeak, strings.Join(leakedOutputs, ", "))
+	}
+	apimeta.SetStatusCondition(&configuration.Status.Conditions, condition)
+}
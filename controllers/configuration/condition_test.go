@@ -0,0 +1,174 @@
+package configuration
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestSetCondition(t *testing.T) {
+	type args struct {
+		state   types.ConfigurationState
+		message string
+	}
+	type want struct {
+		status metav1.ConditionStatus
+		reason string
+	}
+
+	testcases := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "available",
+			args: args{
+				state:   types.Available,
+				message: types.MessageCloudResourceDeployed,
+			},
+			want: want{
+				status: metav1.ConditionTrue,
+				reason: "Available",
+			},
+		},
+		{
+			name: "provisioning",
+			args: args{
+				state:   types.ConfigurationProvisioningAndChecking,
+				message: types.MessageCloudResourceProvisioningAndChecking,
+			},
+			want: want{
+				status: metav1.ConditionUnknown,
+				reason: "ProvisioningAndChecking",
+			},
+		},
+		{
+			name: "terraform init error",
+			args: args{
+				state:   types.TerraformInitError,
+				message: "init failed",
+			},
+			want: want{
+				status: metav1.ConditionFalse,
+				reason: "TerraformInitError",
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			configuration := &v1beta2.Configuration{}
+			configuration.Generation = 2
+			SetCondition(configuration, ConditionApplied, tc.args.state, tc.args.message)
+
+			condition := GetCondition(configuration, ConditionApplied)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, tc.want.status, condition.Status)
+			assert.Equal(t, tc.want.reason, condition.Reason)
+			assert.Equal(t, tc.args.message, condition.Message)
+			assert.Equal(t, int64(2), condition.ObservedGeneration)
+			assert.Assert(t, GetCondition(configuration, ConditionDestroyed) == nil)
+		})
+	}
+}
+
+func TestSetConditionKeepsTransitionTime(t *testing.T) {
+	configuration := &v1beta2.Configuration{}
+	SetCondition(configuration, ConditionApplied, types.ConfigurationApplyFailed, "failed")
+	transitionTime := metav1.NewTime(GetCondition(configuration, ConditionApplied).LastTransitionTime.Add(-time.Minute))
+	configuration.Status.Conditions[0].LastTransitionTime = transitionTime
+
+	// the status is still False, so the transition time is kept
+	SetCondition(configuration, ConditionApplied, types.ConfigurationStaticCheckFailed, "invalid")
+	condition := GetCondition(configuration, ConditionApplied)
+	assert.Equal(t, transitionTime, condition.LastTransitionTime)
+	assert.Equal(t, "ConfigurationSpecNotValid", condition.Reason)
+
+	SetCondition(configuration, ConditionApplied, types.Available, "ok")
+	assert.Assert(t, GetCondition(configuration, ConditionApplied).LastTransitionTime != transitionTime)
+	assert.Equal(t, 1, len(configuration.Status.Conditions))
+}
+
+func TestSetProviderReadyCondition(t *testing.T) {
+	configuration := &v1beta2.Configuration{}
+
+	assert.Assert(t, SetProviderReadyCondition(configuration, nil))
+	condition := GetCondition(configuration, ConditionProviderReady)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "ProviderNotFound", condition.Reason)
+	assert.Equal(t, types.ErrProviderNotFound, condition.Message)
+
+	notReady := &v1beta1.Provider{
+		Status: v1beta1.ProviderStatus{
+			State:   types.ProviderIsNotReady,
+			Message: "failed to get credentials from the cloud provider",
+		},
+	}
+	assert.Assert(t, SetProviderReadyCondition(configuration, notReady))
+	condition = GetCondition(configuration, ConditionProviderReady)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "ProviderNotReady", condition.Reason)
+	assert.Equal(t, "failed to get credentials from the cloud provider", condition.Message)
+	assert.Assert(t, !SetProviderReadyCondition(configuration, notReady))
+
+	ready := &v1beta1.Provider{Status: v1beta1.ProviderStatus{State: types.ProviderIsReady}}
+	assert.Assert(t, SetProviderReadyCondition(configuration, ready))
+	condition = GetCondition(configuration, ConditionProviderReady)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "ProviderReady", condition.Reason)
+	assert.Equal(t, "", condition.Message)
+	assert.Equal(t, 1, len(configuration.Status.Conditions))
+
+	assert.Assert(t, SetProviderAccessDeniedCondition(configuration, "Configuration a/b is not allowed to use Provider default/default"))
+	condition = GetCondition(configuration, ConditionProviderReady)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "ProviderAccessDenied", condition.Reason)
+	assert.Equal(t, "Configuration a/b is not allowed to use Provider default/default", condition.Message)
+	assert.Assert(t, !SetProviderAccessDeniedCondition(configuration, "Configuration a/b is not allowed to use Provider default/default"))
+}
+
+func TestSetResourcesTaintedCondition(t *testing.T) {
+	configuration := &v1beta2.Configuration{}
+
+	SetResourcesTaintedCondition(configuration, []string{"aws_s3_bucket.b", "aws_instance.a[1]"})
+	condition := GetCondition(configuration, ConditionResourcesTainted)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "ResourcesTainted", condition.Reason)
+	assert.Equal(t, "The resources are tainted and will be replaced by the next apply unless they are untainted: aws_s3_bucket.b, aws_instance.a[1]", condition.Message)
+
+	SetResourcesTaintedCondition(configuration, nil)
+	condition = GetCondition(configuration, ConditionResourcesTainted)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "NoResourcesTainted", condition.Reason)
+	assert.Equal(t, "", condition.Message)
+}
+
+func TestSetSensitiveLeakCondition(t *testing.T) {
+	configuration := &v1beta2.Configuration{}
+	SetSensitiveLeakCondition(configuration, []string{"dsn"})
+	assert.Assert(t, GetCondition(configuration, ConditionSensitiveLeak) == nil)
+
+	configuration.Spec.SensitiveLeakCheck = v1beta2.SensitiveLeakBlock
+	SetSensitiveLeakCondition(configuration, []string{"dsn", "url"})
+	condition := GetCondition(configuration, ConditionSensitiveLeak)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "SensitiveLeakBlocked", condition.Reason)
+	assert.Equal(t, "The values of the sensitive variables appear in the non-sensitive outputs: dsn, url", condition.Message)
+
+	SetSensitiveLeakCondition(configuration, nil)
+	condition = GetCondition(configuration, ConditionSensitiveLeak)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "NoSensitiveLeak", condition.Reason)
+
+	// the condition is removed once the check is disabled
+	configuration.Spec.SensitiveLeakCheck = ""
+	SetSensitiveLeakCondition(configuration, nil)
+	assert.Assert(t, GetCondition(configuration, ConditionSensitiveLeak) == nil)
+}
@@ -2,13 +2,24 @@ package configuration
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -17,6 +28,7 @@ import (
 	"github.com/oam-dev/terraform-controller/api/v1beta1"
 	"github.com/oam-dev/terraform-controller/api/v1beta2"
 	"github.com/oam-dev/terraform-controller/controllers/provider"
+	"github.com/oam-dev/terraform-controller/controllers/tracing"
 )
 
 const (
@@ -32,65 +44,603 @@ const (
 
 const errGitHubBlockedNotBoolean = "the value of githubBlocked is not a boolean"
 
+const (
+	// DefaultProviderNameEnv and DefaultProviderNamespaceEnv override the Provider of Configurations which don't set
+	// spec.ProviderReference
+	DefaultProviderNameEnv      = "DEFAULT_PROVIDER_NAME"
+	DefaultProviderNamespaceEnv = "DEFAULT_PROVIDER_NAMESPACE"
+	// DefaultProviderAnnotation is the annotation of a namespace, which overrides the Provider of Configurations in the
+	// namespace which don't set spec.ProviderReference. Its value is like `name`, which refers to a Provider in the
+	// same namespace, or `namespace/name`.
+	DefaultProviderAnnotation = "terraform.core.oam.dev/default-provider"
+)
+
+// GithubBlockedEnv is the env which marks whether GitHub is blocked in the cluster
+const GithubBlockedEnv = "GITHUB_BLOCKED"
+
+// GithubBlockedAllowlistEnv is the env of the comma-separated regular expressions of the Terraform sources which are
+// not replaced even if GitHub is blocked, like the repos which are reachable through a proxy
+const GithubBlockedAllowlistEnv = "GITHUB_BLOCKED_ALLOWLIST"
+
+// PlanApprovalAnnotation is the annotation of a Configuration which approves the saved plan when spec.SavedPlan is
+// true. Its value is the checksum of the plan in status.plan, so a plan which is not reviewed is never applied.
+const PlanApprovalAnnotation = "terraform.core.oam.dev/approve-plan"
+
+// RefreshSecretsAnnotation is the annotation of a Configuration which requests to refresh the secret of the variables,
+// which has a copy of the credentials of the Provider, like after the credentials are rotated. Its value is a token
+// like a timestamp, and the annotation is removed once the secret is refreshed. The changes are applied if the secret
+// is stale.
+const RefreshSecretsAnnotation = "terraform.core.oam.dev/refresh-secrets"
+
+// DestroyPreviewAnnotation is the annotation of a Configuration which requests a preview of the resources which would
+// be destroyed by deleting the Configuration. Its value is a token like a timestamp, and the preview runs once for a
+// token.
+const DestroyPreviewAnnotation = "terraform.core.oam.dev/destroy-preview"
+
+// RegionLabelEnv is the env of the label of a Configuration which sets its region when spec.Region is not set, like the
+// topology label set by a placement system. The region is spec.Region first, then the label, then the region of the
+// Provider. The label is not read if the env is not set.
+const RegionLabelEnv = "TERRAFORM_REGION_LABEL"
+
+// RegionSource is where the region of a Configuration comes from
+type RegionSource string
+
+const (
+	// RegionFromSpec is the region of spec.Region
+	RegionFromSpec RegionSource = "Spec"
+	// RegionFromLabel is the region of the label of RegionLabelEnv
+	RegionFromLabel RegionSource = "Label"
+	// RegionFromProvider is the region of the Provider
+	RegionFromProvider RegionSource = "Provider"
+)
+
+// RegionChangeAcknowledgementAnnotation is the annotation of a Configuration which acknowledges changing its region
+// after the cloud resources are deployed. Its value is the new region, so an acknowledgement doesn't cover a later
+// change.
+const RegionChangeAcknowledgementAnnotation = "terraform.core.oam.dev/acknowledge-region-change"
+
+// DeletionProtectionLabel is the label of a Configuration which protects it from deletion if its value is `true`. The
+// deletion is blocked by the finalizer until the label is removed.
+const DeletionProtectionLabel = "terraform.core.oam.dev/deletion-protection"
+
+// supportedRemoteSchemes are the URL schemes supported by spec.Remote
+var supportedRemoteSchemes = []string{"https", "ssh", "git"}
+
+// scpLikeRemotePattern matches the scp-like syntax of ssh remotes, like `git@github.com:org/repo.git`
+var scpLikeRemotePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[A-Za-z0-9._~-][A-Za-z0-9._~/-]*$`)
+
+// remoteShellMetacharacters are rejected in spec.Remote, as the URL is embedded into the shell command which clones the
+// git repo
+const remoteShellMetacharacters = ";&|$`'\"\\<>(){}[]*?!# \t\r\n"
+
+// imageReferencePattern matches a well-formed image reference like `registry.example.com:5000/org/terraform:1.1.2`,
+// with an optional tag and digest
+var imageReferencePattern = regexp.MustCompile(`^(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])` +
+	`(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[\w][\w.-]{0,127})?(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9A-Fa-f]{32,})?$`)
+
+// ExtraArgsAllowlistEnv is the env which overrides the allowed flags of spec.ExtraApplyArgs and spec.ExtraDestroyArgs,
+// in the format of a comma-separated list like `-compact-warnings,-parallelism`. The allowlist is enforced by the
+// static check when a Configuration is reconciled, the flags which are never allowed are rejected at admission by the
+// CRD.
+const ExtraArgsAllowlistEnv = "TERRAFORM_EXTRA_ARGS_ALLOWLIST"
+
+// defaultExtraArgsAllowlist are the flags allowed in extra arguments when ExtraArgsAllowlistEnv is not set. The lock
+// timeout is set by spec.LockTimeout, which applies to `terraform init` and the state commands as well.
+var defaultExtraArgsAllowlist = []string{"-compact-warnings", "-no-color", "-parallelism", "-refresh"}
+
+// MaxObjectSizeEnv is the env of the largest size, like `512Ki`, of the metadata and spec of a Configuration. etcd
+// refuses the objects larger than 1.5Mi by default, which make the updates of the status fail. It's checked at
+// reconcile time rather than admission, so a larger Configuration is still created, and fails the static check.
+const MaxObjectSizeEnv = "TERRAFORM_MAX_CONFIGURATION_SIZE"
+
+// defaultMaxObjectSize is the largest size of a Configuration when MaxObjectSizeEnv is not set, which leaves room
+// below the limit of etcd for the status to grow
+const defaultMaxObjectSize = 1024 * 1024
+
+// forbiddenExtraArgs could embed secrets or change the target of an execution, they are never allowed even if they
+// are in the allowlist
+var forbiddenExtraArgs = []string{"-var", "-var-file", "-target", "-replace", "-state", "-state-out", "-backup", "-chdir"}
+
+// extraArgPattern only accepts `-flag` or `-flag=value` without any shell meta characters
+var extraArgPattern = regexp.MustCompile(`^-[a-z][a-z-]*(=[A-Za-z0-9._:/-]+)?$`)
+
+// resourceAddressPattern accepts the address of a managed resource or its instance, like `aws_s3_bucket.data`,
+// `module.storage.aws_s3_bucket.data[0]` or `aws_s3_bucket.data["logs"]`, without any shell meta characters
+var resourceAddressPattern = regexp.MustCompile(`^(module\.[A-Za-z_][\w-]*(\[(\d+|"[\w.-]+")\])?\.)*[A-Za-z_][\w-]*\.[A-Za-z_][\w-]*(\[(\d+|"[\w.-]+")\])?$`)
+
 // ValidConfigurationObject will validate a Configuration
 func ValidConfigurationObject(configuration *v1beta2.Configuration) (types.ConfigurationType, error) {
 	hcl := configuration.Spec.HCL
-	remote := configuration.Spec.Remote
+	if configuration.Spec.Remote != "" && configuration.Spec.GitRemote != nil {
+		return "", errors.New("spec.Remote and spec.GitRemote cloud not be set at the same time")
+	}
+	if configuration.Spec.GitRemote != nil && configuration.Spec.Path != "" {
+		return "", errors.New("spec.Path could not be set with spec.GitRemote, use spec.GitRemote.Path instead")
+	}
+	gitRemote := GetGitRemote(configuration)
 	switch {
-	case hcl == "" && remote == "":
+	case hcl == "" && gitRemote == nil:
 		return "", errors.New("spec.HCL or spec.Remote should be set")
-	case hcl != "" && remote != "":
+	case hcl != "" && gitRemote != nil:
 		return "", errors.New("spec.HCL and spec.Remote cloud not be set at the same time")
-	case hcl != "":
+	case gitRemote != nil:
+		if err := validGitRemote(gitRemote); err != nil {
+			return "", err
+		}
+	}
+
+	if err := validObjectSize(configuration); err != nil {
+		return "", err
+	}
+
+	allowlist := getExtraArgsAllowlist()
+	if err := validExtraArgs(configuration.Spec.ExtraApplyArgs, allowlist); err != nil {
+		return "", errors.Wrap(err, "spec.ExtraApplyArgs is not valid")
+	}
+	if err := validExtraArgs(configuration.Spec.ExtraDestroyArgs, allowlist); err != nil {
+		return "", errors.Wrap(err, "spec.ExtraDestroyArgs is not valid")
+	}
+	if err := validLockTimeout(configuration); err != nil {
+		return "", err
+	}
+	if delay := configuration.Spec.PostApplyDelay; delay != "" {
+		if d, err := time.ParseDuration(delay); err != nil || d < 0 {
+			return "", fmt.Errorf("spec.PostApplyDelay %s is not a non-negative duration", delay)
+		}
+	}
+	if err := validRegistryPreflight(configuration.Spec.RegistryPreflight); err != nil {
+		return "", err
+	}
+	if err := validVendoredModules(configuration.Spec.VendoredModules); err != nil {
+		return "", err
+	}
+	for _, address := range configuration.Spec.SkipDestroy {
+		if !resourceAddressPattern.MatchString(address) {
+			return "", fmt.Errorf("spec.SkipDestroy %s is not a valid resource address", address)
+		}
+	}
+	for _, address := range GetUntaintResources(configuration) {
+		if !resourceAddressPattern.MatchString(address) {
+			return "", fmt.Errorf("the annotation %s %s is not a valid resource address", UntaintAnnotation, address)
+		}
+	}
+	if err := validLogLevel(configuration); err != nil {
+		return "", err
+	}
+	if err := validStateOperations(configuration.Spec.StateOperations); err != nil {
+		return "", err
+	}
+	if err := validMovedBlocks(configuration.Spec.Moved); err != nil {
+		return "", err
+	}
+	if err := validBackend(configuration.Spec.Backend); err != nil {
+		return "", err
+	}
+	if err := validOutputTargets(configuration.Spec.OutputTargets); err != nil {
+		return "", err
+	}
+	if err := validDriftCheck(configuration); err != nil {
+		return "", err
+	}
+	if err := validVariableTemplates(configuration); err != nil {
+		return "", err
+	}
+	if image := configuration.Spec.RunnerImage; image != "" && !imageReferencePattern.MatchString(image) {
+		return "", fmt.Errorf("spec.RunnerImage %s is not a valid image reference", image)
+	}
+
+	if hcl != "" {
 		return types.ConfigurationHCL, nil
-	case remote != "":
-		return types.ConfigurationRemote, nil
 	}
-	return "", nil
+	return types.ConfigurationRemote, nil
 }
 
-// RenderConfiguration will compose the Terraform configuration with hcl/json and backend
+// validVendoredModules checks whether the vendored modules come from exactly one of a PersistentVolumeClaim and an
+// image, and the path of them could be embedded into the shell commands
+func validVendoredModules(modules *v1beta2.VendoredModules) error {
+	if modules == nil {
+		return nil
+	}
+	switch {
+	case modules.ClaimName == "" && modules.Image == "":
+		return errors.New("spec.VendoredModules.ClaimName or spec.VendoredModules.Image should be set")
+	case modules.ClaimName != "" && modules.Image != "":
+		return errors.New("spec.VendoredModules.ClaimName and spec.VendoredModules.Image cloud not be set at the same time")
+	case modules.Image != "" && !imageReferencePattern.MatchString(modules.Image):
+		return fmt.Errorf("spec.VendoredModules.Image %s is not a valid image reference", modules.Image)
+	case modules.Path != "" && !gitPathPattern.MatchString(modules.Path):
+		return fmt.Errorf("spec.VendoredModules.Path %s is not a valid relative path", modules.Path)
+	}
+	return nil
+}
+
+// validBackend checks whether the type of the backend is supported
+func validBackend(backend *v1beta2.Backend) error {
+	if backend == nil {
+		return nil
+	}
+	switch types.BackendType(backend.Type) {
+	case "", types.BackendKubernetes, types.BackendLocal:
+		return nil
+	default:
+		return fmt.Errorf("spec.Backend.Type %s is not supported, it should be %s or %s", backend.Type,
+			types.BackendKubernetes, types.BackendLocal)
+	}
+}
+
+// IsLocalBackend checks whether the state of the Configuration is stored locally instead of in a Kubernetes secret
+func IsLocalBackend(configuration *v1beta2.Configuration) bool {
+	return configuration.Spec.Backend != nil && types.BackendType(configuration.Spec.Backend.Type) == types.BackendLocal
+}
+
+var (
+	// gitRefPattern matches the branches, tags and commits, which are embedded into the git commands
+	gitRefPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+	// gitPathPattern matches the relative sub-directories of the git repo, which are embedded into the shell commands
+	gitPathPattern = regexp.MustCompile(`^[A-Za-z0-9._][A-Za-z0-9._/-]*$`)
+)
+
+// GetGitRemote gets the git repo of the Configuration, spec.Remote and spec.Path are converted to a GitRemote. It
+// returns nil if the Configuration is not from a git repo.
+func GetGitRemote(configuration *v1beta2.Configuration) *v1beta2.GitRemote {
+	if configuration.Spec.GitRemote != nil {
+		return configuration.Spec.GitRemote
+	}
+	if configuration.Spec.Remote == "" {
+		return nil
+	}
+	return &v1beta2.GitRemote{
+		URL:  configuration.Spec.Remote,
+		Path: configuration.Spec.Path,
+	}
+}
+
+// validGitRemote checks the URL, ref, path and credentials of the git repo
+func validGitRemote(gitRemote *v1beta2.GitRemote) error {
+	if err := validRemote(gitRemote.URL, ReplaceTerraformSource(gitRemote.URL, GetGithubBlocked())); err != nil {
+		return err
+	}
+	if gitRemote.Ref != "" && (!gitRefPattern.MatchString(gitRemote.Ref) || strings.Contains(gitRemote.Ref, "..")) {
+		return fmt.Errorf("spec.GitRemote.Ref %s is not a valid git branch, tag or commit", gitRemote.Ref)
+	}
+	if gitRemote.Path != "" && (!gitPathPattern.MatchString(gitRemote.Path) || strings.Contains(gitRemote.Path, "..")) {
+		return fmt.Errorf("the path %s is not a valid sub-directory of the git repo", gitRemote.Path)
+	}
+	if gitRemote.CredentialsSecretRef != nil && gitRemote.CredentialsSecretRef.Name == "" {
+		return errors.New("spec.GitRemote.CredentialsSecretRef.Name should be set")
+	}
+	return nil
+}
+
+// validRemote checks whether the remote, which is replaced by ReplaceTerraformSource, is a supported git repository
+func validRemote(remote, replacedRemote string) error {
+	if replacedRemote == "" {
+		return fmt.Errorf("spec.Remote %s could not be mapped to a git repository", remote)
+	}
+	if strings.ContainsAny(replacedRemote, remoteShellMetacharacters) {
+		return fmt.Errorf("spec.Remote %s contains the shell metacharacters or whitespace, which are not allowed in a git repository URL", replacedRemote)
+	}
+	if scpLikeRemotePattern.MatchString(replacedRemote) {
+		return nil
+	}
+	u, err := url.Parse(replacedRemote)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("spec.Remote %s is not a valid git repository URL, it should be like https://github.com/org/repo.git", replacedRemote)
+	}
+	if !containsString(supportedRemoteSchemes, u.Scheme) {
+		return fmt.Errorf("the scheme %s of spec.Remote %s is not supported, supported schemes are %s", u.Scheme,
+			replacedRemote, strings.Join(supportedRemoteSchemes, ", "))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("spec.Remote %s is not a valid git repository URL, it should be like https://github.com/org/repo.git", replacedRemote)
+	}
+	return nil
+}
+
+// validObjectSize checks whether the size of the metadata and spec of the Configuration exceeds MaxObjectSizeEnv. The
+// status written by the controller isn't counted, or a Configuration would fail once its outputs grow. A warning is
+// logged once it's over 80% of the limit.
+func validObjectSize(configuration *v1beta2.Configuration) error {
+	limit := int64(defaultMaxObjectSize)
+	if value := os.Getenv(MaxObjectSizeEnv); value != "" {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil || quantity.Value() <= 0 {
+			return fmt.Errorf("failed to parse env variable %s into a positive quantity", MaxObjectSizeEnv)
+		}
+		limit = quantity.Value()
+	}
+	data, err := json.Marshal(struct {
+		Metadata metav1.ObjectMeta         `json:"metadata"`
+		Spec     v1beta2.ConfigurationSpec `json:"spec"`
+	}{configuration.ObjectMeta, configuration.Spec})
+	if err != nil {
+		return errors.Wrap(err, "failed to get the size of the Configuration")
+	}
+	size := int64(len(data))
+	if size > limit {
+		return fmt.Errorf("the Configuration is %d bytes, which exceeds the limit %d bytes, move spec.HCL to a git "+
+			"repository and refer to it by spec.Remote or spec.GitRemote", size, limit)
+	}
+	if size > limit/5*4 {
+		klog.Warningf("Configuration %s/%s is %d bytes, which is close to the limit %d bytes", configuration.Namespace,
+			configuration.Name, size, limit)
+	}
+	return nil
+}
+
+func getExtraArgsAllowlist() []string {
+	allowlistStr := os.Getenv(ExtraArgsAllowlistEnv)
+	if allowlistStr == "" {
+		return defaultExtraArgsAllowlist
+	}
+	var allowlist []string
+	for _, flag := range strings.Split(allowlistStr, ",") {
+		if flag = strings.TrimSpace(flag); flag != "" {
+			allowlist = append(allowlist, flag)
+		}
+	}
+	return allowlist
+}
+
+// validExtraArgs checks that every argument is a well-formed flag in the allowlist and not a forbidden one
+func validExtraArgs(args []v1beta2.ExtraArg, allowlist []string) error {
+	for _, extraArg := range args {
+		arg := string(extraArg)
+		flag := strings.SplitN(arg, "=", 2)[0]
+		if containsString(forbiddenExtraArgs, flag) {
+			return fmt.Errorf("argument %s is forbidden", flag)
+		}
+		if !extraArgPattern.MatchString(arg) {
+			return fmt.Errorf("argument %q is not in the format of -flag or -flag=value", arg)
+		}
+		if !containsString(allowlist, flag) {
+			if flag == "-lock-timeout" {
+				return errors.New("argument -lock-timeout is not allowed, the lock timeout is set by spec.LockTimeout")
+			}
+			return fmt.Errorf("argument %s is not allowed, allowed arguments are %s", flag, strings.Join(allowlist, ", "))
+		}
+	}
+	return nil
+}
+
+// validRegistryPreflight checks the endpoint of the registry preflight is a http(s) URL, which is quoted in the command
+// of the preflight
+func validRegistryPreflight(preflight *v1beta2.RegistryPreflight) error {
+	if preflight == nil || preflight.Endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(preflight.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.ContainsAny(preflight.Endpoint, `'"\`) {
+		return fmt.Errorf("spec.RegistryPreflight.Endpoint %s is not a valid http(s) URL", preflight.Endpoint)
+	}
+	return nil
+}
+
+// forbiddenPatchGroups are the API groups whose objects are never patched by spec.OutputTargets. The patch runs as the
+// controller, which could grant the access, or change the other Configurations, that the owner of the Configuration
+// doesn't have.
+var forbiddenPatchGroups = []string{"rbac.authorization.k8s.io", v1beta2.GroupVersion.Group}
+
+// forbiddenPatchKinds are the kinds of the core API group which are never patched by spec.OutputTargets. The secrets
+// are only written by the connection secret, and the ConfigMaps by the ConfigMap targets, which keep the sensitive
+// outputs out of them.
+var forbiddenPatchKinds = []string{"Secret", "ConfigMap"}
+
+// isForbiddenPatchTarget checks whether the object of the patch target is forbidden to be patched
+func isForbiddenPatchTarget(patch *v1beta2.PatchOutputTarget) bool {
+	group := ""
+	if i := strings.Index(patch.APIVersion, "/"); i >= 0 {
+		group = patch.APIVersion[:i]
+	}
+	if containsString(forbiddenPatchGroups, group) {
+		return true
+	}
+	return group == "" && containsString(forbiddenPatchKinds, patch.Kind)
+}
+
+// validOutputTargets checks each output target is either a key of a ConfigMap or a field of an object, and no key of a
+// ConfigMap is written by two outputs
+func validOutputTargets(targets []v1beta2.OutputTarget) error {
+	configMapKeys := map[string]bool{}
+	for _, target := range targets {
+		if target.Output == "" {
+			return errors.New("spec.OutputTargets should have the name of the output")
+		}
+		if (target.ConfigMap == nil) == (target.Patch == nil) {
+			return fmt.Errorf("exactly one of configMap and patch of spec.OutputTargets %s should be set", target.Output)
+		}
+		if cm := target.ConfigMap; cm != nil {
+			key := cm.Key
+			if key == "" {
+				key = target.Output
+			}
+			if errs := validation.IsDNS1123Subdomain(cm.Name); len(errs) > 0 {
+				return fmt.Errorf("the ConfigMap %s of spec.OutputTargets %s is not valid: %s", cm.Name, target.Output, strings.Join(errs, ", "))
+			}
+			if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+				return fmt.Errorf("the key %s of spec.OutputTargets %s is not valid: %s", key, target.Output, strings.Join(errs, ", "))
+			}
+			if configMapKeys[cm.Name+"/"+key] {
+				return fmt.Errorf("the key %s of ConfigMap %s is written by more than one of spec.OutputTargets", key, cm.Name)
+			}
+			configMapKeys[cm.Name+"/"+key] = true
+			continue
+		}
+		patch := target.Patch
+		if patch.APIVersion == "" || patch.Kind == "" || patch.Name == "" {
+			return fmt.Errorf("the patch of spec.OutputTargets %s should have the apiVersion, kind and name of the object", target.Output)
+		}
+		if isForbiddenPatchTarget(patch) {
+			return fmt.Errorf("%s %s is not allowed to be patched by spec.OutputTargets %s, use the connection secret or a ConfigMap target instead",
+				patch.APIVersion, patch.Kind, target.Output)
+		}
+		if !strings.HasPrefix(patch.Path, "/") {
+			return fmt.Errorf("the path %s of spec.OutputTargets %s is not a JSON pointer like `/spec/endpoint`", patch.Path, target.Output)
+		}
+	}
+	return nil
+}
+
+// validDriftCheck checks whether spec.DriftCheckInterval is a positive duration, which spec.SelfHeal requires
+func validDriftCheck(configuration *v1beta2.Configuration) error {
+	interval := configuration.Spec.DriftCheckInterval
+	if interval == "" {
+		if configuration.Spec.SelfHeal {
+			return errors.New("spec.SelfHeal requires spec.DriftCheckInterval to detect the drift")
+		}
+		return nil
+	}
+	if d, err := time.ParseDuration(interval); err != nil || d <= 0 {
+		return fmt.Errorf("spec.DriftCheckInterval %s is not a positive duration", interval)
+	}
+	return nil
+}
+
+func validLockTimeout(configuration *v1beta2.Configuration) error {
+	lockTimeout := configuration.Spec.LockTimeout
+	if lockTimeout == "" {
+		return nil
+	}
+	timeout, err := time.ParseDuration(lockTimeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("spec.LockTimeout %s is not a positive duration", lockTimeout)
+	}
+	for _, args := range [][]v1beta2.ExtraArg{configuration.Spec.ExtraApplyArgs, configuration.Spec.ExtraDestroyArgs} {
+		for _, arg := range args {
+			if strings.HasPrefix(string(arg), "-lock-timeout") {
+				return errors.New("spec.LockTimeout and -lock-timeout in extra arguments could not be set at the same time")
+			}
+		}
+	}
+	return nil
+}
+
+func containsString(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderConfiguration will compose the Terraform configuration with hcl/json and backend. The backend and
+// spec.RequiredProviders are rendered into one terraform block, which goes before the HCL.
 func RenderConfiguration(configuration *v1beta2.Configuration, terraformBackendNamespace string, configurationType types.ConfigurationType) (string, error) {
-	if configuration.Spec.Backend != nil {
-		if configuration.Spec.Backend.SecretSuffix == "" {
-			configuration.Spec.Backend.SecretSuffix = configuration.Name
+	var blocks []string
+	// The local backend is the default backend of Terraform, so no backend block is needed
+	if !IsLocalBackend(configuration) {
+		if configuration.Spec.Backend != nil {
+			if configuration.Spec.Backend.SecretSuffix == "" {
+				configuration.Spec.Backend.SecretSuffix = configuration.Name
+			}
+			configuration.Spec.Backend.InClusterConfig = true
+		} else {
+			configuration.Spec.Backend = &v1beta2.Backend{
+				SecretSuffix:    configuration.Name,
+				InClusterConfig: true,
+			}
 		}
-		configuration.Spec.Backend.InClusterConfig = true
-	} else {
-		configuration.Spec.Backend = &v1beta2.Backend{
-			SecretSuffix:    configuration.Name,
-			InClusterConfig: true,
+		backendBlock, err := renderBackendBlock(configuration.Spec.Backend, terraformBackendNamespace)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to prepare Terraform backend configuration")
 		}
+		blocks = append(blocks, backendBlock)
 	}
-	backendTF, err := RenderTemplate(configuration.Spec.Backend, terraformBackendNamespace)
+
+	requiredProvidersBlock, err := renderRequiredProvidersBlock(configuration.Spec.RequiredProviders, configuration.Spec.HCL)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to prepare Terraform backend configuration")
+		return "", err
+	}
+	if requiredProvidersBlock != "" {
+		blocks = append(blocks, requiredProvidersBlock)
+	}
+
+	var terraformTF string
+	if len(blocks) > 0 {
+		terraformTF = strings.TrimPrefix(terraformBlock(blocks...), "\n")
+	}
+
+	movedTF, err := renderMovedBlocks(configuration.Spec.Moved, configuration.Spec.HCL)
+	if err != nil {
+		return "", err
 	}
 
 	switch configurationType {
 	case types.ConfigurationHCL:
-		completedConfiguration := configuration.Spec.HCL
-		completedConfiguration += "\n" + backendTF
-		return completedConfiguration, nil
+		if terraformTF == "" {
+			return configuration.Spec.HCL + "\n" + movedTF, nil
+		}
+		return terraformTF + "\n" + configuration.Spec.HCL + "\n" + movedTF, nil
 	case types.ConfigurationRemote:
-		return backendTF, nil
+		if terraformTF == "" {
+			return strings.TrimPrefix(movedTF, "\n"), nil
+		}
+		return terraformTF + movedTF, nil
 	default:
 		return "", errors.New("Unsupported Configuration Type")
 	}
 }
 
-// SetRegion will set the region for Configuration
-func SetRegion(ctx context.Context, k8sClient client.Client, namespace, name string, providerObj *v1beta1.Provider) (string, error) {
+// ResolveRegion gets the region of the Configuration and where it comes from. It's spec.Region first, then the
+// label of RegionLabelEnv, then the region of the Provider.
+func ResolveRegion(configuration *v1beta2.Configuration, providerObj *v1beta1.Provider) (string, RegionSource) {
+	if configuration.Spec.Region != "" {
+		return configuration.Spec.Region, RegionFromSpec
+	}
+	if label := os.Getenv(RegionLabelEnv); label != "" {
+		if region := configuration.Labels[label]; region != "" {
+			return region, RegionFromLabel
+		}
+	}
+	if providerObj == nil {
+		return "", RegionFromProvider
+	}
+	return providerObj.Spec.Region, RegionFromProvider
+}
+
+// SetRegion will set the region for Configuration. The region of the label or the Provider is persisted to spec.Region,
+// so the region of the provisioned cloud resources doesn't change along with them.
+func SetRegion(ctx context.Context, k8sClient client.Client, namespace, name string, providerObj *v1beta1.Provider) (region string, source RegionSource, err error) {
+	ctx, span := tracing.StartSpan(ctx, "SetRegion", namespace, name)
+	defer func() { tracing.EndSpan(span, err) }()
 	configuration, err := Get(ctx, k8sClient, apitypes.NamespacedName{Namespace: namespace, Name: name})
 	if err != nil {
-		return "", errors.Wrap(err, "failed to get configuration")
+		return "", "", errors.Wrap(err, "failed to get configuration")
 	}
-	if configuration.Spec.Region != "" {
-		return configuration.Spec.Region, nil
+	region, source = ResolveRegion(&configuration, providerObj)
+	if err := CheckRegionChange(&configuration, region); err != nil {
+		return "", "", err
+	}
+	if source == RegionFromSpec {
+		return region, source, nil
 	}
 
-	configuration.Spec.Region = providerObj.Spec.Region
-	return providerObj.Spec.Region, Update(ctx, k8sClient, &configuration)
+	configuration.Spec.Region = region
+	return region, source, Update(ctx, k8sClient, &configuration)
+}
+
+// RegionChangeError is the error when the region of the Configuration differs from the region of the deployed cloud
+// resources, and the change is not acknowledged by RegionChangeAcknowledgementAnnotation
+type RegionChangeError struct {
+	Message string
+}
+
+func (e *RegionChangeError) Error() string {
+	return e.Message
+}
+
+// CheckRegionChange checks whether the region of the Configuration is the region of the deployed cloud resources in
+// status.region, or the change is acknowledged. A Configuration which is not deployed yet could change its region.
+func CheckRegionChange(configuration *v1beta2.Configuration, region string) error {
+	deployed := configuration.Status.Region
+	if deployed == "" || region == deployed || configuration.Annotations[RegionChangeAcknowledgementAnnotation] == region {
+		return nil
+	}
+	return &RegionChangeError{Message: fmt.Sprintf(types.MessageRegionChangeNotAcknowledged, deployed, region,
+		RegionChangeAcknowledgementAnnotation)}
 }
 
 // Update will update the Configuration
@@ -112,18 +662,53 @@ func Get(ctx context.Context, k8sClient client.Client, namespacedName apitypes.N
 
 // IsDeletable will check whether the Configuration can be deleted immediately
 // If deletable, it means no external cloud resources are provisioned
-func IsDeletable(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) (bool, error) {
-	providerRef := GetProviderNamespacedName(*configuration)
-	providerObj, err := provider.GetProviderFromConfiguration(ctx, k8sClient, providerRef.Namespace, providerRef.Name)
+func IsDeletable(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) (deletable bool, err error) {
+	ctx, span := tracing.StartSpan(ctx, "IsDeletable", configuration.Namespace, configuration.Name)
+	defer func() { tracing.EndSpan(span, err) }()
+	if IsDeletionProtected(configuration) {
+		return false, errors.New(types.MessageDeletionProtected)
+	}
+	// the Configuration which refused to adopt the existing state never applied with it, a destroy would destroy the
+	// cloud resources of the Configuration which left the state
+	if configuration.Status.Apply.State == types.ExistingStateFound && configuration.Status.StateSecretRef == nil {
+		return true, nil
+	}
+	providerRef, err := ResolveProviderReference(ctx, k8sClient, *configuration)
 	if err != nil {
 		return false, err
 	}
+	// a Configuration which is no longer allowed to use the Provider may have deployed the cloud resources with it, the
+	// destroy waits until the access is restored
+	providerObj, err := provider.GetProviderFromConfiguration(ctx, k8sClient, configuration, providerRef.Namespace, providerRef.Name)
+	if err != nil {
+		var deniedErr *provider.AccessDeniedError
+		if errors.As(err, &deniedErr) {
+			return false, &DestroyBlockedError{State: types.ProviderAccessDenied, Message: deniedErr.Message}
+		}
+		return false, err
+	}
 	// allow Configuration to delete when the Provider doesn't exist or is not ready, which means external cloud resources are
-	// not provisioned at all
+	// not provisioned at all. Configurations with a local backend are not special here, a destroy job still
+	// needs to run for them
 	if providerObj == nil || providerObj.Status.State == types.ProviderIsNotReady || configuration.Status.Apply.State == types.TerraformInitError {
 		return true, nil
 	}
 
+	// the Configuration which shares the state with an older Configuration never applied with it, a destroy would
+	// destroy the cloud resources of the older one
+	collision, err := FindBackendCollision(ctx, k8sClient, configuration)
+	if err != nil {
+		return false, err
+	}
+	if collision != "" {
+		return true, nil
+	}
+
+	// the cloud resources are destroyed in the reverse order of spec.DependsOn
+	if err := checkDependents(ctx, k8sClient, configuration); err != nil {
+		return false, err
+	}
+
 	if configuration.Status.Apply.State == types.ConfigurationProvisioningAndChecking {
 		warning := fmt.Sprintf("Destroy could not complete and needs to wait for Provision to complete first: %s", types.MessageCloudResourceProvisioningAndChecking)
 		klog.Warning(warning)
@@ -133,6 +718,38 @@ func IsDeletable(ctx context.Context, k8sClient client.Client, configuration *v1
 	return false, nil
 }
 
+// IsDeletionProtected checks whether the Configuration is protected from deletion by the label DeletionProtectionLabel
+func IsDeletionProtected(configuration *v1beta2.Configuration) bool {
+	protected, _ := strconv.ParseBool(configuration.Labels[DeletionProtectionLabel])
+	return protected
+}
+
+// GetGithubBlocked gets the value of env GITHUB_BLOCKED, which defaults to `false`
+func GetGithubBlocked() string {
+	githubBlockedStr := os.Getenv(GithubBlockedEnv)
+	if githubBlockedStr == "" {
+		githubBlockedStr = "false"
+	}
+	return githubBlockedStr
+}
+
+// ParseGithubBlockedAllowlist parses the regular expressions of GithubBlockedAllowlistEnv. It's checked when the
+// controller starts, so an invalid expression doesn't let the sources through silently.
+func ParseGithubBlockedAllowlist() ([]*regexp.Regexp, error) {
+	var allowlist []*regexp.Regexp
+	for _, expr := range strings.Split(os.Getenv(GithubBlockedAllowlistEnv), ",") {
+		if expr = strings.TrimSpace(expr); expr == "" {
+			continue
+		}
+		allowed, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s in env %s is not a valid regular expression", expr, GithubBlockedAllowlistEnv)
+		}
+		allowlist = append(allowlist, allowed)
+	}
+	return allowlist, nil
+}
+
 // ReplaceTerraformSource will replace the Terraform source from GitHub to Gitee
 func ReplaceTerraformSource(remote string, githubBlockedStr string) string {
 	klog.InfoS("Whether GitHub is blocked", "githubBlocked", githubBlockedStr)
@@ -150,15 +767,28 @@ func ReplaceTerraformSource(remote string, githubBlockedStr string) string {
 	if remote == "" {
 		return ""
 	}
+	allowlist, err := ParseGithubBlockedAllowlist()
+	if err != nil {
+		klog.ErrorS(err, "Failed to parse the allowlist of GitHub sources, it's ignored")
+	}
+	for _, allowed := range allowlist {
+		if allowed.MatchString(remote) {
+			klog.InfoS("Remote git is in the allowlist of GitHub sources", "Remote", remote)
+			return remote
+		}
+	}
 	if strings.HasPrefix(remote, GithubPrefix) {
 		var repo string
 		if strings.HasPrefix(remote, GithubKubeVelaContribPrefix) {
 			repo = strings.Replace(remote, GithubPrefix, GiteePrefix, 1)
 		} else {
-			tmp := strings.Split(strings.Replace(remote, GithubPrefix, "", 1), "/")
-			if len(tmp) == 2 {
-				repo = GiteeTerraformSourceOrg + "/" + tmp[1]
+			segments := strings.Split(strings.Replace(remote, GithubPrefix, "", 1), "/")
+			if len(segments) < 2 || segments[0] == "" || segments[1] == "" {
+				klog.Warningf("The remote %s could not be mapped to Gitee as it's not like https://github.com/org/repo, it's kept", remote)
+				return remote
 			}
+			// the path after the repo, like `tree/main/alibaba/rds`, is preserved
+			repo = GiteeTerraformSourceOrg + "/" + strings.Join(segments[1:], "/")
 		}
 		klog.InfoS("New remote git", "Gitee", repo)
 		return repo
@@ -166,13 +796,78 @@ func ReplaceTerraformSource(remote string, githubBlockedStr string) string {
 	return remote
 }
 
-// GetProviderNamespacedName will get the provider namespaced name
+// ComputeConfigurationHash computes the hash of the Configuration spec and the referenced Provider spec, which are the
+// inputs of a Terraform execution
+func ComputeConfigurationHash(configuration *v1beta2.Configuration, providerObj *v1beta1.Provider) (string, error) {
+	inputs := struct {
+		Configuration v1beta2.ConfigurationSpec `json:"configuration"`
+		Provider      *v1beta1.ProviderSpec     `json:"provider,omitempty"`
+	}{
+		Configuration: configuration.Spec,
+	}
+	if providerObj != nil {
+		inputs.Provider = &providerObj.Spec
+	}
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal the inputs of the Configuration")
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// GetProviderNamespacedName will get the provider namespaced name. If spec.ProviderReference is not set, the default
+// Provider of the controller is used, which is `default/default` unless it's overridden by the env
+// DEFAULT_PROVIDER_NAME and DEFAULT_PROVIDER_NAMESPACE.
 func GetProviderNamespacedName(configuration v1beta2.Configuration) *crossplane.Reference {
 	if configuration.Spec.ProviderReference != nil {
 		return configuration.Spec.ProviderReference
 	}
-	return &crossplane.Reference{
-		Name:      provider.DefaultName,
-		Namespace: provider.DefaultNamespace,
+	ref := &crossplane.Reference{
+		Name:      os.Getenv(DefaultProviderNameEnv),
+		Namespace: os.Getenv(DefaultProviderNamespaceEnv),
+	}
+	if ref.Name == "" {
+		ref.Name = provider.DefaultName
+	}
+	if ref.Namespace == "" {
+		ref.Namespace = provider.DefaultNamespace
+		// the controller which is scoped to a namespace only reads the Providers in the namespace
+		if watchNamespace := GetWatchNamespace(); watchNamespace != "" {
+			ref.Namespace = watchNamespace
+		}
+	}
+	return ref
+}
+
+// ResolveProviderReference gets the provider namespaced name like GetProviderNamespacedName, except that the
+// default Provider could be overridden per namespace by the annotation DefaultProviderAnnotation of the namespace of
+// the Configuration. The namespace is read from the API server by the client of the controller, which doesn't cache
+// the namespaces, as the controller is only allowed to get them.
+func ResolveProviderReference(ctx context.Context, k8sClient client.Client, configuration v1beta2.Configuration) (ref *crossplane.Reference, err error) {
+	ctx, span := tracing.StartSpan(ctx, "ResolveProviderReference", configuration.Namespace, configuration.Name)
+	defer func() { tracing.EndSpan(span, err) }()
+	if configuration.Spec.ProviderReference != nil || configuration.Namespace == "" {
+		return GetProviderNamespacedName(configuration), nil
+	}
+	var ns corev1.Namespace
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: configuration.Namespace}, &ns); err != nil {
+		if kerrors.IsNotFound(err) {
+			return GetProviderNamespacedName(configuration), nil
+		}
+		return nil, errors.Wrap(err, "failed to get the namespace of the Configuration")
+	}
+	defaultProvider := ns.Annotations[DefaultProviderAnnotation]
+	if defaultProvider == "" {
+		return GetProviderNamespacedName(configuration), nil
+	}
+	ref = &crossplane.Reference{Name: defaultProvider, Namespace: configuration.Namespace}
+	if parts := strings.Split(defaultProvider, "/"); len(parts) == 2 {
+		ref.Namespace, ref.Name = parts[0], parts[1]
+	}
+	if ref.Name == "" || ref.Namespace == "" || strings.Contains(ref.Name, "/") {
+		return nil, fmt.Errorf("the annotation %s of namespace %s is not valid, it should be like `name` or `namespace/name`",
+			DefaultProviderAnnotation, configuration.Namespace)
 	}
+	return ref, nil
 }
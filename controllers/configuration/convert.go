@@ -20,25 +20,91 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
 	"k8s.io/apimachinery/pkg/runtime"
 
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
 	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	"github.com/oam-dev/terraform-controller/controllers/provider"
 )
 
-var backendTF = `
-terraform {
-  backend "kubernetes" {
+const (
+	// ProviderDefaultTagsFileName is the file name of the provider block with default tags and retry settings
+	ProviderDefaultTagsFileName = "provider_default_tags.tf"
+	// ProviderDefaultTagsOverrideFileName is the file name of the provider default tags and retry settings when the
+	// provider block is declared in the configuration, Terraform will merge it into the declared provider block
+	ProviderDefaultTagsOverrideFileName = "provider_default_tags_override.tf"
+	// ProviderConfigFileName is the file name of the verbatim provider config of the Provider
+	ProviderConfigFileName = "provider_config.tf"
+)
+
+var backendBlockTF = `  backend "kubernetes" {
     secret_suffix     = "{{.SecretSuffix}}"
     in_cluster_config = {{.InClusterConfig}}
     namespace         = "{{.Namespace}}"
   }
+`
+
+var providerDefaultTagsTF = `
+provider "{{.Provider}}" {
+{{- if .MaxRetries}}
+  max_retries = {{.MaxRetries}}
+{{- end}}
+{{- if .RetryMode}}
+  retry_mode  = "{{.RetryMode}}"
+{{- end}}
+{{- if .Tags}}
+  default_tags {
+    tags = {
+{{- range $k, $v := .Tags}}
+      "{{$k}}" = "{{$v}}"
+{{- end}}
+    }
+  }
+{{- end}}
 }
 `
 
+var providerConfigTF = `
+{{- range $name, $_ := .SecretRefs}}
+variable "{{$name}}" {
+  type      = string
+  sensitive = true
+}
+{{- end}}
+{{.Template}}
+`
+
+var requiredProvidersBlockTF = `  required_providers {
+{{- range $name, $p := .}}
+    {{$name}} = {
+      source  = "{{$p.Source}}"
+{{- if $p.Version}}
+      version = "{{$p.Version}}"
+{{- end}}
+    }
+{{- end}}
+  }
+`
+
+var movedBlockTF = `{{- range .}}
+moved {
+  from = {{.From}}
+  to   = {{.To}}
+}
+{{end}}`
+
+var (
+	requiredProvidersBlock = regexp.MustCompile(`\brequired_providers\s*\{`)
+	providerLocalName      = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+	movedBlockFrom         = regexp.MustCompile(`\bmoved\s*\{[^}]*?\bfrom\s*=\s*([^\s}]+)`)
+)
+
 // RawExtension2Map will convert rawExtension to map
 // This function is copied from oam-dev/kubevela
 func RawExtension2Map(raw *runtime.RawExtension) (map[string]interface{}, error) {
@@ -63,9 +129,23 @@ type backendVars struct {
 	Namespace       string
 }
 
+// terraformBlock wraps the blocks, like the backend and the required_providers, into a terraform block
+func terraformBlock(blocks ...string) string {
+	return "\nterraform {\n" + strings.Join(blocks, "") + "}\n"
+}
+
 // RenderTemplate renders Backend template
 func RenderTemplate(backend *v1beta2.Backend, namespace string) (string, error) {
-	tmpl, err := template.New("backend").Funcs(template.FuncMap(sprig.FuncMap())).Parse(backendTF)
+	block, err := renderBackendBlock(backend, namespace)
+	if err != nil {
+		return "", err
+	}
+	return terraformBlock(block), nil
+}
+
+// renderBackendBlock renders the backend block, which is nested in a terraform block
+func renderBackendBlock(backend *v1beta2.Backend, namespace string) (string, error) {
+	tmpl, err := template.New("backend").Funcs(template.FuncMap(sprig.FuncMap())).Parse(backendBlockTF)
 	if err != nil {
 		return "", err
 	}
@@ -83,6 +163,152 @@ func RenderTemplate(backend *v1beta2.Backend, namespace string) (string, error)
 	return wr.String(), nil
 }
 
+// RenderProviderDefaultTags renders the default tags and the retry settings of the Provider to a provider block. It
+// returns the file name and the content which are empty if neither of them is set.
+func RenderProviderDefaultTags(providerObj *v1beta1.Provider, hcl string) (string, string, error) {
+	if providerObj == nil {
+		return "", "", nil
+	}
+	retry := providerObj.Spec.Retry
+	hasRetry := retry != nil && (retry.MaxRetries != nil || retry.RetryMode != "")
+	if len(providerObj.Spec.DefaultTags) == 0 && !hasRetry {
+		return "", "", nil
+	}
+	if err := provider.ValidDefaultTags(providerObj); err != nil {
+		return "", "", err
+	}
+	if err := provider.ValidRetry(providerObj); err != nil {
+		return "", "", err
+	}
+	tmpl, err := template.New("defaultTags").Parse(providerDefaultTagsTF)
+	if err != nil {
+		return "", "", err
+	}
+	var wr bytes.Buffer
+	templateVars := map[string]interface{}{
+		"Provider": providerObj.Spec.Provider,
+		"Tags":     providerObj.Spec.DefaultTags,
+	}
+	if hasRetry {
+		if retry.MaxRetries != nil {
+			templateVars["MaxRetries"] = strconv.Itoa(*retry.MaxRetries)
+		}
+		templateVars["RetryMode"] = retry.RetryMode
+	}
+	if err := tmpl.Execute(&wr, templateVars); err != nil {
+		return "", "", err
+	}
+
+	fileName := ProviderDefaultTagsFileName
+	providerBlock := regexp.MustCompile(fmt.Sprintf(`(?m)^\s*provider\s+"%s"\s*\{`, regexp.QuoteMeta(providerObj.Spec.Provider)))
+	if providerBlock.MatchString(hcl) {
+		fileName = ProviderDefaultTagsOverrideFileName
+	}
+	return fileName, wr.String(), nil
+}
+
+// RenderProviderConfig renders the verbatim provider config of the Provider, with the variables which its secrets are
+// injected as. The values of the secrets are not rendered, they are passed to the Terraform jobs by the environment
+// variables of GetProviderConfigSecrets.
+func RenderProviderConfig(providerObj *v1beta1.Provider, hcl string) (string, string, error) {
+	if providerObj == nil || providerObj.Spec.ProviderConfig == nil {
+		return "", "", nil
+	}
+	if err := provider.ValidProviderConfig(providerObj); err != nil {
+		return "", "", err
+	}
+	config := providerObj.Spec.ProviderConfig
+	for name := range config.SecretRefs {
+		variableBlock := regexp.MustCompile(fmt.Sprintf(`(?m)^\s*variable\s+"%s"\s*\{`, regexp.QuoteMeta(name)))
+		if variableBlock.MatchString(hcl) {
+			return "", "", fmt.Errorf("the variable %s is declared in the configuration, it conflicts with the secret of the provider config", name)
+		}
+	}
+	tmpl, err := template.New("providerConfig").Parse(providerConfigTF)
+	if err != nil {
+		return "", "", err
+	}
+	var wr bytes.Buffer
+	if err := tmpl.Execute(&wr, map[string]interface{}{
+		"SecretRefs": config.SecretRefs,
+		"Template":   strings.TrimSpace(config.Template),
+	}); err != nil {
+		return "", "", err
+	}
+	return ProviderConfigFileName, wr.String(), nil
+}
+
+// RenderRequiredProviders renders spec.RequiredProviders to a terraform block, Terraform merges it with the
+// required_providers declared in the HCL. It errors if a provider is declared in both of them.
+func RenderRequiredProviders(requiredProviders map[string]v1beta2.RequiredProvider, hcl string) (string, error) {
+	block, err := renderRequiredProvidersBlock(requiredProviders, hcl)
+	if err != nil || block == "" {
+		return "", err
+	}
+	return terraformBlock(block), nil
+}
+
+// renderRequiredProvidersBlock renders spec.RequiredProviders to a required_providers block, which is nested in a
+// terraform block. It's empty if spec.RequiredProviders is not set.
+func renderRequiredProvidersBlock(requiredProviders map[string]v1beta2.RequiredProvider, hcl string) (string, error) {
+	if len(requiredProviders) == 0 {
+		return "", nil
+	}
+	declared := declaredRequiredProviders(hcl)
+	for name, p := range requiredProviders {
+		if !providerLocalName.MatchString(name) {
+			return "", fmt.Errorf("spec.RequiredProviders %s is not a valid provider local name", name)
+		}
+		if p.Source == "" {
+			return "", fmt.Errorf("spec.RequiredProviders %s should have a source", name)
+		}
+		if declared[name] {
+			return "", fmt.Errorf("provider %s is declared in both spec.RequiredProviders and the required_providers of spec.HCL", name)
+		}
+	}
+
+	tmpl, err := template.New("requiredProviders").Parse(requiredProvidersBlockTF)
+	if err != nil {
+		return "", err
+	}
+	var wr bytes.Buffer
+	if err := tmpl.Execute(&wr, requiredProviders); err != nil {
+		return "", err
+	}
+	return wr.String(), nil
+}
+
+// declaredRequiredProviders gets the local names of the providers in the required_providers blocks of the HCL
+func declaredRequiredProviders(hcl string) map[string]bool {
+	declared := map[string]bool{}
+	for _, loc := range requiredProvidersBlock.FindAllStringIndex(hcl, -1) {
+		// walk to the closing brace of the block, the entries are the attributes at the top level of it
+		depth := 1
+		line := ""
+		for _, c := range hcl[loc[1]:] {
+			if c == '{' {
+				if depth == 1 {
+					if name := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), "=")); name != "" {
+						declared[strings.Trim(name, `"`)] = true
+					}
+				}
+				depth++
+			} else if c == '}' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+			if c == '\n' {
+				line = ""
+			} else {
+				line += string(c)
+			}
+		}
+	}
+	return declared
+}
+
 // Interface2String converts an interface{} type to string
 func Interface2String(v interface{}) (string, error) {
 	var value string
@@ -104,3 +330,30 @@ func Interface2String(v interface{}) (string, error) {
 	}
 	return value, nil
 }
+
+// renderMovedBlocks renders spec.Moved into the moved blocks, a move which is also declared by a moved block of the HCL
+// is refused, as Terraform doesn't allow two moves from the same address
+func renderMovedBlocks(moved []v1beta2.MovedBlock, hcl string) (string, error) {
+	if len(moved) == 0 {
+		return "", nil
+	}
+	declared := map[string]bool{}
+	for _, match := range movedBlockFrom.FindAllStringSubmatch(hcl, -1) {
+		declared[match[1]] = true
+	}
+	for _, m := range moved {
+		if declared[m.From] {
+			return "", fmt.Errorf("the move from %s is declared in both spec.Moved and the moved blocks of spec.HCL", m.From)
+		}
+	}
+
+	tmpl, err := template.New("moved").Parse(movedBlockTF)
+	if err != nil {
+		return "", err
+	}
+	var wr bytes.Buffer
+	if err := tmpl.Execute(&wr, moved); err != nil {
+		return "", err
+	}
+	return wr.String(), nil
+}
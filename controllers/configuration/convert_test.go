@@ -1,11 +1,16 @@
 package configuration
 
 import (
+	"strings"
 	"testing"
 
 	"gotest.tools/assert"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/yaml"
+
+	crossplane "github.com/oam-dev/terraform-controller/api/types/crossplane-runtime"
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
 )
 
 func TestRawExtension2Map(t *testing.T) {
@@ -185,3 +190,280 @@ func TestInterface2String(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderProviderDefaultTags(t *testing.T) {
+	awsProvider := &v1beta1.Provider{
+		Spec: v1beta1.ProviderSpec{
+			Provider:    "aws",
+			DefaultTags: map[string]string{"team": "infra", "env": "prod"},
+		},
+	}
+	maxRetries := 0
+	tagsTF := `
+provider "aws" {
+  default_tags {
+    tags = {
+      "env" = "prod"
+      "team" = "infra"
+    }
+  }
+}
+`
+	cases := map[string]struct {
+		provider *v1beta1.Provider
+		hcl      string
+		fileName string
+		content  string
+		errMsg   string
+	}{
+		"provider is nil": {},
+		"no default tags": {
+			provider: &v1beta1.Provider{Spec: v1beta1.ProviderSpec{Provider: "aws"}},
+		},
+		"provider block is not declared": {
+			provider: awsProvider,
+			hcl:      `resource "aws_s3_bucket" "b" {}`,
+			fileName: ProviderDefaultTagsFileName,
+			content:  tagsTF,
+		},
+		"provider block is declared": {
+			provider: awsProvider,
+			hcl: `
+provider "aws" {
+  region = "us-east-1"
+}`,
+			fileName: ProviderDefaultTagsOverrideFileName,
+			content:  tagsTF,
+		},
+		"retry settings": {
+			provider: &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider:    "aws",
+					DefaultTags: map[string]string{"team": "infra"},
+					Retry:       &v1beta1.ProviderRetry{MaxRetries: &maxRetries, RetryMode: "adaptive"},
+				},
+			},
+			fileName: ProviderDefaultTagsFileName,
+			content: `
+provider "aws" {
+  max_retries = 0
+  retry_mode  = "adaptive"
+  default_tags {
+    tags = {
+      "team" = "infra"
+    }
+  }
+}
+`,
+		},
+		"retry settings without default tags": {
+			provider: &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider: "aws",
+					Retry:    &v1beta1.ProviderRetry{RetryMode: "standard"},
+				},
+			},
+			hcl:      `provider "aws" {}`,
+			fileName: ProviderDefaultTagsOverrideFileName,
+			content: `
+provider "aws" {
+  retry_mode  = "standard"
+}
+`,
+		},
+		"invalid retry settings": {
+			provider: &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider: "gcp",
+					Retry:    &v1beta1.ProviderRetry{MaxRetries: &maxRetries},
+				},
+			},
+			errMsg: "retry settings are not supported by provider gcp",
+		},
+		"invalid default tags": {
+			provider: &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider:    "gcp",
+					DefaultTags: map[string]string{"team": "infra"},
+				},
+			},
+			errMsg: "default tags are not supported by provider gcp",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fileName, content, err := RenderProviderDefaultTags(tc.provider, tc.hcl)
+			if tc.errMsg != "" {
+				assert.Assert(t, err != nil && strings.Contains(err.Error(), tc.errMsg))
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tc.fileName, fileName)
+			assert.Equal(t, tc.content, content)
+		})
+	}
+}
+
+func TestRenderProviderConfig(t *testing.T) {
+	vaultProvider := &v1beta1.Provider{
+		Spec: v1beta1.ProviderSpec{
+			Provider: "custom",
+			ProviderConfig: &v1beta1.ProviderConfig{
+				Template: `
+provider "vault" {
+  address = "https://vault.example.com"
+  token   = var.vault_token
+}
+`,
+				SecretRefs: map[string]crossplane.SecretKeySelector{
+					"vault_token": {SecretReference: crossplane.SecretReference{Name: "vault"}, Key: "token"},
+				},
+			},
+		},
+	}
+	cases := map[string]struct {
+		provider *v1beta1.Provider
+		hcl      string
+		fileName string
+		content  string
+		errMsg   string
+	}{
+		"provider is nil": {},
+		"no provider config": {
+			provider: &v1beta1.Provider{Spec: v1beta1.ProviderSpec{Provider: "aws"}},
+		},
+		"provider config with secrets": {
+			provider: vaultProvider,
+			hcl:      `resource "vault_mount" "kv" {}`,
+			fileName: ProviderConfigFileName,
+			content: `
+variable "vault_token" {
+  type      = string
+  sensitive = true
+}
+provider "vault" {
+  address = "https://vault.example.com"
+  token   = var.vault_token
+}
+`,
+		},
+		"provider config without secrets": {
+			provider: &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider:       "custom",
+					ProviderConfig: &v1beta1.ProviderConfig{Template: `provider "kubernetes" {}`},
+				},
+			},
+			fileName: ProviderConfigFileName,
+			content: `
+provider "kubernetes" {}
+`,
+		},
+		"variable is declared in the configuration": {
+			provider: vaultProvider,
+			hcl: `
+variable "vault_token" {
+  type = string
+}`,
+			errMsg: "the variable vault_token is declared in the configuration, it conflicts with the secret of the provider config",
+		},
+		"invalid provider config": {
+			provider: &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{ProviderConfig: &v1beta1.ProviderConfig{}},
+			},
+			errMsg: "the template of the provider config is empty",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fileName, content, err := RenderProviderConfig(tc.provider, tc.hcl)
+			if tc.errMsg != "" {
+				assert.Error(t, err, tc.errMsg)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tc.fileName, fileName)
+			assert.Equal(t, tc.content, content)
+		})
+	}
+}
+
+func TestRenderRequiredProviders(t *testing.T) {
+	requiredProviders := map[string]v1beta2.RequiredProvider{
+		"aws":    {Source: "hashicorp/aws", Version: "~> 4.0"},
+		"random": {Source: "hashicorp/random"},
+	}
+	requiredProvidersTF := `
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.0"
+    }
+    random = {
+      source  = "hashicorp/random"
+    }
+  }
+}
+`
+	cases := map[string]struct {
+		requiredProviders map[string]v1beta2.RequiredProvider
+		hcl               string
+		content           string
+		errMsg            string
+	}{
+		"no required providers": {},
+		"required providers are rendered": {
+			requiredProviders: requiredProviders,
+			hcl: `
+terraform {
+  required_providers {
+    alicloud = {
+      source = "aliyun/alicloud"
+    }
+  }
+}`,
+			content: requiredProvidersTF,
+		},
+		"provider is declared in the HCL": {
+			requiredProviders: requiredProviders,
+			hcl: `
+terraform {
+  required_version = ">= 1.0"
+  required_providers {
+    alicloud = {
+      source = "aliyun/alicloud"
+    }
+    "aws" = {
+      source = "hashicorp/aws"
+    }
+  }
+}`,
+			errMsg: "provider aws is declared in both spec.RequiredProviders and the required_providers of spec.HCL",
+		},
+		"provider is declared in one line": {
+			requiredProviders: requiredProviders,
+			hcl:               `terraform { required_providers { random = { source = "hashicorp/random" } } }`,
+			errMsg:            "provider random is declared in both",
+		},
+		"no source": {
+			requiredProviders: map[string]v1beta2.RequiredProvider{"aws": {Version: "~> 4.0"}},
+			errMsg:            "spec.RequiredProviders aws should have a source",
+		},
+		"invalid local name": {
+			requiredProviders: map[string]v1beta2.RequiredProvider{"a ws": {Source: "hashicorp/aws"}},
+			errMsg:            "spec.RequiredProviders a ws is not a valid provider local name",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			content, err := RenderRequiredProviders(tc.requiredProviders, tc.hcl)
+			if tc.errMsg != "" {
+				assert.Assert(t, err != nil && strings.Contains(err.Error(), tc.errMsg))
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tc.content, content)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package configuration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// DestroyBlockedError is the error when the Configuration can't be destroyed yet, which carries the state reported in
+// the destroy status
+type DestroyBlockedError struct {
+	State   types.ConfigurationState
+	Message string
+}
+
+func (e *DestroyBlockedError) Error() string {
+	return e.Message
+}
+
+// dependencyKey gets the namespaced name of a Configuration which the Configuration in namespace depends on
+func dependencyKey(ref v1beta2.ConfigurationReference, namespace string) apitypes.NamespacedName {
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+	return apitypes.NamespacedName{Namespace: namespace, Name: ref.Name}
+}
+
+// GetUnavailableDependency gets the first Configuration in spec.DependsOn which is not Available. It returns an empty
+// string if all dependencies are Available.
+func GetUnavailableDependency(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) (string, error) {
+	for _, ref := range configuration.Spec.DependsOn {
+		key := dependencyKey(ref, configuration.Namespace)
+		dependency, err := Get(ctx, k8sClient, key)
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				return key.String(), nil
+			}
+			return "", errors.Wrapf(err, "failed to get the dependency %s", key.String())
+		}
+		if dependency.Status.Apply.State != types.Available {
+			return key.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// FindDependencyCycle walks the dependency graph from the Configuration, and returns the cycle like
+// `default/a -> default/b -> default/a` if there is a cycle reachable from the Configuration, which will never be
+// satisfied. It returns an empty string if there is no cycle. The dependencies which are not found are skipped.
+func FindDependencyCycle(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) (string, error) {
+	var (
+		start  = apitypes.NamespacedName{Namespace: configuration.Namespace, Name: configuration.Name}
+		path   []apitypes.NamespacedName
+		inPath = map[apitypes.NamespacedName]bool{}
+		done   = map[apitypes.NamespacedName]bool{}
+		visit  func(key apitypes.NamespacedName, dependsOn []v1beta2.ConfigurationReference) (string, error)
+	)
+	visit = func(key apitypes.NamespacedName, dependsOn []v1beta2.ConfigurationReference) (string, error) {
+		path = append(path, key)
+		inPath[key] = true
+		for _, ref := range dependsOn {
+			depKey := dependencyKey(ref, key.Namespace)
+			if inPath[depKey] {
+				var cycle []string
+				for i := len(path) - 1; i >= 0; i-- {
+					cycle = append([]string{path[i].String()}, cycle...)
+					if path[i] == depKey {
+						break
+					}
+				}
+				return strings.Join(append(cycle, depKey.String()), " -> "), nil
+			}
+			if done[depKey] {
+				continue
+			}
+			dependency, err := Get(ctx, k8sClient, depKey)
+			if err != nil {
+				if kerrors.IsNotFound(err) {
+					done[depKey] = true
+					continue
+				}
+				return "", errors.Wrapf(err, "failed to get the dependency %s", depKey.String())
+			}
+			cycle, err := visit(depKey, dependency.Spec.DependsOn)
+			if err != nil || cycle != "" {
+				return cycle, err
+			}
+		}
+		path = path[:len(path)-1]
+		inPath[key] = false
+		done[key] = true
+		return "", nil
+	}
+	return visit(start, configuration.Spec.DependsOn)
+}
+
+// GetDependent gets the first Configuration which depends on the Configuration in spec.DependsOn. It returns an empty
+// string if no Configuration depends on it.
+func GetDependent(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) (string, error) {
+	var configurations v1beta2.ConfigurationList
+	if err := k8sClient.List(ctx, &configurations); err != nil {
+		return "", errors.Wrap(err, "failed to list Configurations")
+	}
+	key := apitypes.NamespacedName{Namespace: configuration.Namespace, Name: configuration.Name}
+	for _, c := range configurations.Items {
+		for _, ref := range c.Spec.DependsOn {
+			if dependencyKey(ref, c.Namespace) == key {
+				return apitypes.NamespacedName{Namespace: c.Namespace, Name: c.Name}.String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// checkDependents returns a DestroyBlockedError if the Configuration is still depended on, so the dependents are
+// destroyed before the Configuration. The dependents of a cycle would wait for each other forever, which is reported
+// instead.
+func checkDependents(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) error {
+	dependent, err := GetDependent(ctx, k8sClient, configuration)
+	if err != nil || dependent == "" {
+		return err
+	}
+	cycle, err := FindDependencyCycle(ctx, k8sClient, configuration)
+	if err != nil {
+		return err
+	}
+	if cycle != "" {
+		return &DestroyBlockedError{State: types.DependencyCycleDetected, Message: fmt.Sprintf(types.MessageDependencyCycleDetected, cycle)}
+	}
+	return &DestroyBlockedError{State: types.WaitingForDependentsDeletion, Message: fmt.Sprintf(types.MessageWaitingForDependentsDeletion, dependent)}
+}
@@ -0,0 +1,186 @@
+package configuration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func newDependentConfiguration(namespace, name string, state types.ConfigurationState, dependsOn ...v1beta2.ConfigurationReference) *v1beta2.Configuration {
+	return &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: v1beta2.ConfigurationSpec{
+			DependsOn: dependsOn,
+		},
+		Status: v1beta2.ConfigurationStatus{
+			Apply: v1beta2.ConfigurationApplyStatus{
+				State: state,
+			},
+		},
+	}
+}
+
+func TestGetUnavailableDependency(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		newDependentConfiguration("default", "a", types.Available),
+		newDependentConfiguration("infra", "b", types.Available),
+		newDependentConfiguration("default", "c", types.ConfigurationProvisioningAndChecking),
+	).Build()
+
+	testcases := []struct {
+		name      string
+		dependsOn []v1beta2.ConfigurationReference
+		want      string
+	}{
+		{
+			name:      "all dependencies are available",
+			dependsOn: []v1beta2.ConfigurationReference{{Name: "a"}, {Name: "b", Namespace: "infra"}},
+			want:      "",
+		},
+		{
+			name:      "dependency is provisioning",
+			dependsOn: []v1beta2.ConfigurationReference{{Name: "a"}, {Name: "c"}},
+			want:      "default/c",
+		},
+		{
+			name:      "dependency is not found",
+			dependsOn: []v1beta2.ConfigurationReference{{Name: "b"}},
+			want:      "default/b",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			configuration := newDependentConfiguration("default", "x", "", tc.dependsOn...)
+			dependency, err := GetUnavailableDependency(ctx, k8sClient, configuration)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, dependency)
+		})
+	}
+}
+
+func TestFindDependencyCycle(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+
+	testcases := []struct {
+		name          string
+		objects       []client.Object
+		configuration *v1beta2.Configuration
+		want          string
+	}{
+		{
+			name: "no cycle",
+			objects: []client.Object{
+				newDependentConfiguration("default", "b", "", v1beta2.ConfigurationReference{Name: "c"}),
+				newDependentConfiguration("default", "c", ""),
+			},
+			configuration: newDependentConfiguration("default", "a", "",
+				v1beta2.ConfigurationReference{Name: "b"}, v1beta2.ConfigurationReference{Name: "c"},
+				v1beta2.ConfigurationReference{Name: "not-found"}),
+			want: "",
+		},
+		{
+			name:          "depends on itself",
+			configuration: newDependentConfiguration("default", "a", "", v1beta2.ConfigurationReference{Name: "a"}),
+			want:          "default/a -> default/a",
+		},
+		{
+			name: "cycle across namespaces",
+			objects: []client.Object{
+				newDependentConfiguration("infra", "b", "", v1beta2.ConfigurationReference{Name: "a", Namespace: "default"}),
+			},
+			configuration: newDependentConfiguration("default", "a", "", v1beta2.ConfigurationReference{Name: "b", Namespace: "infra"}),
+			want:          "default/a -> infra/b -> default/a",
+		},
+		{
+			name: "cycle of dependencies",
+			objects: []client.Object{
+				newDependentConfiguration("default", "b", "", v1beta2.ConfigurationReference{Name: "c"}),
+				newDependentConfiguration("default", "c", "", v1beta2.ConfigurationReference{Name: "b"}),
+			},
+			configuration: newDependentConfiguration("default", "a", "", v1beta2.ConfigurationReference{Name: "b"}),
+			want:          "default/b -> default/c -> default/b",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(tc.objects...).Build()
+			cycle, err := FindDependencyCycle(ctx, k8sClient, tc.configuration)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, cycle)
+		})
+	}
+}
+
+func TestCheckDependents(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+
+	testcases := []struct {
+		name          string
+		objects       []client.Object
+		configuration *v1beta2.Configuration
+		want          *DestroyBlockedError
+	}{
+		{
+			name: "no dependents",
+			objects: []client.Object{
+				newDependentConfiguration("default", "b", "", v1beta2.ConfigurationReference{Name: "c"}),
+				newDependentConfiguration("infra", "a", ""),
+			},
+			configuration: newDependentConfiguration("default", "a", ""),
+		},
+		{
+			name: "dependent exists",
+			objects: []client.Object{
+				newDependentConfiguration("infra", "b", "", v1beta2.ConfigurationReference{Name: "a", Namespace: "default"}),
+			},
+			configuration: newDependentConfiguration("default", "a", ""),
+			want: &DestroyBlockedError{
+				State:   types.WaitingForDependentsDeletion,
+				Message: "Waiting for the dependent infra/b to be deleted",
+			},
+		},
+		{
+			name: "dependents form a cycle",
+			objects: []client.Object{
+				newDependentConfiguration("default", "b", "", v1beta2.ConfigurationReference{Name: "a"}),
+			},
+			configuration: newDependentConfiguration("default", "a", "", v1beta2.ConfigurationReference{Name: "b"}),
+			want: &DestroyBlockedError{
+				State:   types.DependencyCycleDetected,
+				Message: "Dependency cycle is detected: default/a -> default/b -> default/a",
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(tc.objects...).Build()
+			err := checkDependents(ctx, k8sClient, tc.configuration)
+			if tc.want == nil {
+				assert.Nil(t, err)
+				return
+			}
+			assert.Equal(t, tc.want, err)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package configuration
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// LogLevelAnnotation is the annotation of a Configuration which raises the verbosity of the logs of its reconcile and
+// sets TF_LOG of its Terraform jobs, like `DEBUG`, so a Configuration is debugged without raising the log level of the
+// whole controller
+const LogLevelAnnotation = "terraform.core.oam.dev/log-level"
+
+// logLevelVerbosity maps the log levels of Terraform to the verbosity of klog
+var logLevelVerbosity = map[string]klog.Level{
+	"TRACE": 6,
+	"DEBUG": 4,
+	"INFO":  2,
+	"WARN":  0,
+	"ERROR": 0,
+}
+
+// GetLogLevel gets the log level of the annotation LogLevelAnnotation in upper case, like TF_LOG
+func GetLogLevel(configuration *v1beta2.Configuration) string {
+	return strings.ToUpper(strings.TrimSpace(configuration.Annotations[LogLevelAnnotation]))
+}
+
+// LogVerbosity gets the verbosity of klog of a log level, and false if the log level is not known
+func LogVerbosity(level string) (klog.Level, bool) {
+	verbosity, ok := logLevelVerbosity[level]
+	return verbosity, ok
+}
+
+func validLogLevel(configuration *v1beta2.Configuration) error {
+	level := GetLogLevel(configuration)
+	if level == "" {
+		return nil
+	}
+	if _, ok := LogVerbosity(level); !ok {
+		return fmt.Errorf("the annotation %s %s is not a valid log level, it should be one of TRACE, DEBUG, INFO, WARN and ERROR",
+			LogLevelAnnotation, configuration.Annotations[LogLevelAnnotation])
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package configuration
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestValidLogLevel(t *testing.T) {
+	configuration := &v1beta2.Configuration{}
+	assert.NilError(t, validLogLevel(configuration))
+	assert.Equal(t, "", GetLogLevel(configuration))
+
+	configuration.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{LogLevelAnnotation: " trace "}}
+	assert.NilError(t, validLogLevel(configuration))
+	assert.Equal(t, "TRACE", GetLogLevel(configuration))
+	verbosity, ok := LogVerbosity("TRACE")
+	assert.Assert(t, ok)
+	assert.Equal(t, 6, int(verbosity))
+
+	configuration.Annotations[LogLevelAnnotation] = "verbose"
+	assert.Error(t, validLogLevel(configuration),
+		"the annotation terraform.core.oam.dev/log-level verbose is not a valid log level, it should be one of TRACE, DEBUG, INFO, WARN and ERROR")
+}
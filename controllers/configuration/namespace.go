@@ -0,0 +1,103 @@
+package configuration
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// WatchNamespaceEnv is the env of the namespace which the controller is scoped to. If it's set, only the
+// Configurations and Providers in the namespace are reconciled, and all the objects referenced by them, including the
+// secrets of the kubernetes backend, should be in the namespace.
+const WatchNamespaceEnv = "WATCH_NAMESPACE"
+
+// BackendNamespaceEnv is the env of the namespace of the secrets of the kubernetes backend
+const BackendNamespaceEnv = "TERRAFORM_BACKEND_NAMESPACE"
+
+// defaultBackendNamespace is the namespace of the secrets of the kubernetes backend if BackendNamespaceEnv is not set
+const defaultBackendNamespace = "vela-system"
+
+// GetWatchNamespace gets the namespace which the controller is scoped to, it's empty if all namespaces are watched
+func GetWatchNamespace() string {
+	return os.Getenv(WatchNamespaceEnv)
+}
+
+// GetBackendNamespace gets the namespace of the secrets of the kubernetes backend. If BackendNamespaceEnv is not set,
+// it's the namespace which the controller is scoped to, or vela-system if all namespaces are watched.
+func GetBackendNamespace() string {
+	if ns := os.Getenv(BackendNamespaceEnv); ns != "" {
+		return ns
+	}
+	if ns := GetWatchNamespace(); ns != "" {
+		return ns
+	}
+	return defaultBackendNamespace
+}
+
+// ValidWatchNamespace checks the settings of the controller which is scoped to a namespace, the secrets of the
+// kubernetes backend should be in the namespace
+func ValidWatchNamespace() error {
+	watchNamespace := GetWatchNamespace()
+	if watchNamespace == "" {
+		return nil
+	}
+	if ns := GetBackendNamespace(); ns != watchNamespace {
+		return fmt.Errorf("the backend namespace %s should be the watch namespace %s set by %s", ns, watchNamespace, WatchNamespaceEnv)
+	}
+	return nil
+}
+
+// ValidNamespaceScope checks whether all the objects referenced by the Configuration are in the namespace which the
+// controller is scoped to. Nothing is checked if all namespaces are watched.
+func ValidNamespaceScope(configuration *v1beta2.Configuration, providerNamespace string) error {
+	watchNamespace := GetWatchNamespace()
+	if watchNamespace == "" {
+		return nil
+	}
+	if providerNamespace != watchNamespace {
+		return crossNamespaceError("the Provider", providerNamespace, watchNamespace)
+	}
+	for _, ref := range configuration.Spec.DependsOn {
+		if ref.Namespace != "" && ref.Namespace != watchNamespace {
+			return crossNamespaceError("spec.DependsOn "+ref.Name, ref.Namespace, watchNamespace)
+		}
+	}
+	if connection := configuration.Spec.WriteConnectionSecretToReference; connection != nil && connection.Name != "" {
+		// the namespace of the connection secret defaults to `default`
+		ns := connection.Namespace
+		if ns == "" {
+			ns = "default"
+		}
+		if ns != watchNamespace {
+			return crossNamespaceError("spec.WriteConnectionSecretToReference", ns, watchNamespace)
+		}
+	}
+	return nil
+}
+
+// ValidProviderNamespaceScope checks whether the secrets of the credentials and the provider config of the Provider are
+// in the namespace which the controller is scoped to
+func ValidProviderNamespaceScope(providerObj *v1beta1.Provider) error {
+	watchNamespace := GetWatchNamespace()
+	if watchNamespace == "" {
+		return nil
+	}
+	if secretRef := providerObj.Spec.Credentials.SecretRef; secretRef != nil && secretRef.Namespace != watchNamespace {
+		return crossNamespaceError("the secret of the credentials", secretRef.Namespace, watchNamespace)
+	}
+	if config := providerObj.Spec.ProviderConfig; config != nil {
+		for name, secretRef := range config.SecretRefs {
+			if secretRef.Namespace != "" && secretRef.Namespace != watchNamespace {
+				return crossNamespaceError(fmt.Sprintf("the secret %s of the provider config", name), secretRef.Namespace, watchNamespace)
+			}
+		}
+	}
+	return nil
+}
+
+func crossNamespaceError(object, namespace, watchNamespace string) error {
+	return fmt.Errorf("%s is in namespace %s, but the controller only watches namespace %s, the references across namespaces are not allowed",
+		object, namespace, watchNamespace)
+}
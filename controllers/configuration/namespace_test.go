@@ -0,0 +1,84 @@
+package configuration
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	crossplane "github.com/oam-dev/terraform-controller/api/types/crossplane-runtime"
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestGetBackendNamespace(t *testing.T) {
+	t.Setenv(WatchNamespaceEnv, "")
+	t.Setenv(BackendNamespaceEnv, "")
+	assert.Equal(t, "vela-system", GetBackendNamespace())
+	assert.NilError(t, ValidWatchNamespace())
+
+	t.Setenv(WatchNamespaceEnv, "tenant-a")
+	assert.Equal(t, "tenant-a", GetBackendNamespace())
+	assert.NilError(t, ValidWatchNamespace())
+
+	t.Setenv(BackendNamespaceEnv, "vela-system")
+	assert.Equal(t, "vela-system", GetBackendNamespace())
+	assert.Error(t, ValidWatchNamespace(), "the backend namespace vela-system should be the watch namespace tenant-a set by WATCH_NAMESPACE")
+}
+
+func TestValidNamespaceScope(t *testing.T) {
+	configuration := &v1beta2.Configuration{
+		Spec: v1beta2.ConfigurationSpec{
+			DependsOn: []v1beta2.ConfigurationReference{{Name: "vpc"}},
+			BaseConfigurationSpec: v1beta2.BaseConfigurationSpec{
+				WriteConnectionSecretToReference: &crossplane.SecretReference{Name: "conn", Namespace: "tenant-a"},
+			},
+		},
+	}
+
+	t.Setenv(WatchNamespaceEnv, "")
+	assert.NilError(t, ValidNamespaceScope(configuration, "default"))
+
+	t.Setenv(WatchNamespaceEnv, "tenant-a")
+	assert.NilError(t, ValidNamespaceScope(configuration, "tenant-a"))
+	assert.Error(t, ValidNamespaceScope(configuration, "default"),
+		"the Provider is in namespace default, but the controller only watches namespace tenant-a, the references across namespaces are not allowed")
+
+	dependsOn := configuration.DeepCopy()
+	dependsOn.Spec.DependsOn[0].Namespace = "tenant-b"
+	assert.ErrorContains(t, ValidNamespaceScope(dependsOn, "tenant-a"), "spec.DependsOn vpc is in namespace tenant-b")
+
+	connection := configuration.DeepCopy()
+	connection.Spec.WriteConnectionSecretToReference.Namespace = ""
+	assert.ErrorContains(t, ValidNamespaceScope(connection, "tenant-a"), "spec.WriteConnectionSecretToReference is in namespace default")
+
+	assert.Equal(t, "tenant-a", GetProviderNamespacedName(v1beta2.Configuration{}).Namespace)
+}
+
+func TestValidProviderNamespaceScope(t *testing.T) {
+	provider := &v1beta1.Provider{
+		Spec: v1beta1.ProviderSpec{
+			Credentials: v1beta1.ProviderCredentials{
+				Source:    crossplane.CredentialsSourceSecret,
+				SecretRef: &crossplane.SecretKeySelector{SecretReference: crossplane.SecretReference{Name: "creds", Namespace: "vela-system"}, Key: "credentials"},
+			},
+		},
+	}
+
+	t.Setenv(WatchNamespaceEnv, "")
+	assert.NilError(t, ValidProviderNamespaceScope(provider))
+
+	t.Setenv(WatchNamespaceEnv, "tenant-a")
+	assert.ErrorContains(t, ValidProviderNamespaceScope(provider), "the secret of the credentials is in namespace vela-system")
+	provider.Spec.Credentials.SecretRef.Namespace = "tenant-a"
+	assert.NilError(t, ValidProviderNamespaceScope(provider))
+
+	provider.Spec.ProviderConfig = &v1beta1.ProviderConfig{
+		Template: `provider "vault" {}`,
+		SecretRefs: map[string]crossplane.SecretKeySelector{
+			"vault_token": {SecretReference: crossplane.SecretReference{Name: "vault", Namespace: "vela-system"}, Key: "token"},
+		},
+	}
+	assert.ErrorContains(t, ValidProviderNamespaceScope(provider), "the secret vault_token of the provider config is in namespace vela-system")
+	provider.Spec.ProviderConfig.SecretRefs["vault_token"] = crossplane.SecretKeySelector{SecretReference: crossplane.SecretReference{Name: "vault"}, Key: "token"}
+	assert.NilError(t, ValidProviderNamespaceScope(provider))
+}
@@ -0,0 +1,31 @@
+package configuration
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// managedMode is the mode of a managed resource in the state, the other mode is `data` of the data sources
+const managedMode = "managed"
+
+// ParseResourceCount parses the number of the instances of the managed resources from the Terraform state, in total
+// and by their types
+func ParseResourceCount(stateJSON []byte) (int32, map[string]int32, error) {
+	var state struct {
+		Resources []stateResource `json:"resources"`
+	}
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		return 0, nil, errors.Wrap(err, "failed to parse the Terraform state")
+	}
+	var count int32
+	byType := map[string]int32{}
+	for _, r := range state.Resources {
+		if r.Mode != managedMode || len(r.Instances) == 0 {
+			continue
+		}
+		count += int32(len(r.Instances))
+		byType[r.Type] += int32(len(r.Instances))
+	}
+	return count, byType, nil
+}
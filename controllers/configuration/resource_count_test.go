@@ -0,0 +1,32 @@
+package configuration
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestParseResourceCount(t *testing.T) {
+	state := `{
+  "version": 4,
+  "resources": [
+    {"mode": "managed", "type": "aws_s3_bucket", "name": "b", "instances": [{}]},
+    {"mode": "managed", "type": "aws_instance", "name": "a", "instances": [{"index_key": 0}, {"index_key": 1, "status": "tainted"}]},
+    {"module": "module.storage", "mode": "managed", "type": "aws_s3_bucket", "name": "logs", "instances": [{"index_key": "audit"}]},
+    {"mode": "managed", "type": "aws_eip", "name": "unused", "instances": []},
+    {"mode": "data", "type": "aws_region", "name": "current", "instances": [{}]}
+  ]
+}`
+	count, resourceTypes, err := ParseResourceCount([]byte(state))
+	assert.NilError(t, err)
+	assert.Equal(t, int32(4), count)
+	assert.DeepEqual(t, map[string]int32{"aws_s3_bucket": 2, "aws_instance": 2}, resourceTypes)
+
+	count, resourceTypes, err = ParseResourceCount([]byte(`{"version": 4, "resources": []}`))
+	assert.NilError(t, err)
+	assert.Equal(t, int32(0), count)
+	assert.Equal(t, 0, len(resourceTypes))
+
+	_, _, err = ParseResourceCount([]byte("not json"))
+	assert.ErrorContains(t, err, "failed to parse the Terraform state")
+}
@@ -0,0 +1,167 @@
+package configuration
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// ApplyNowAnnotation is the annotation of a Configuration which requests an out-of-band apply immediately, regardless
+// of whether the Configuration changes or spec.ApplySchedule. Its value is a token like a timestamp, and the annotation
+// is removed once the apply is triggered.
+const ApplyNowAnnotation = "terraform.core.oam.dev/apply-now"
+
+// CriticalDriftAnnotation is the annotation of a Configuration which corrects the drift by spec.SelfHeal immediately if
+// it's `true`, instead of at the next time of spec.ApplySchedule. It stays in effect until it's removed.
+const CriticalDriftAnnotation = "terraform.core.oam.dev/critical-drift"
+
+// IsCriticalDrift checks whether the drift of the Configuration is flagged by CriticalDriftAnnotation
+func IsCriticalDrift(configuration *v1beta2.Configuration) bool {
+	critical, _ := strconv.ParseBool(configuration.Annotations[CriticalDriftAnnotation])
+	return critical
+}
+
+// scheduleSearchYears bounds the search of the next run time, schedules like `0 0 29 2 *` fire once in four years
+const scheduleSearchYears = 5
+
+// Schedule is a cron schedule with the standard five fields: minute, hour, day of month, month and day of week
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domRestricted and dowRestricted mark whether the day of month and the day of week don't start with `*`. If both
+	// of them are restricted, a day matches when either of them matches, which is the same as cron.
+	domRestricted, dowRestricted bool
+}
+
+type scheduleField struct {
+	name     string
+	min, max int
+}
+
+var scheduleFields = []scheduleField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	// both 0 and 7 are Sunday
+	{name: "day of week", min: 0, max: 7},
+}
+
+// ParseSchedule parses a cron schedule like `0 2 * * 1-5`. Each field is `*`, a value, a range like `1-5`, a step like
+// `*/15` or `0-30/10`, or a comma separated list of them.
+func ParseSchedule(spec string) (*Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(scheduleFields) {
+		return nil, errors.Errorf("schedule %q should have %d fields: minute, hour, day of month, month and day of week", spec, len(scheduleFields))
+	}
+	var bits [5]uint64
+	for i, f := range scheduleFields {
+		b, err := parseScheduleField(fields[i], f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "schedule %q is not valid", spec)
+		}
+		bits[i] = b
+	}
+	// fold Sunday 7 to 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+	s := &Schedule{
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: !strings.HasPrefix(fields[2], "*"),
+		dowRestricted: !strings.HasPrefix(fields[4], "*"),
+	}
+	if s.Next(time.Now().UTC()).IsZero() {
+		return nil, errors.Errorf("schedule %q never fires", spec)
+	}
+	return s, nil
+}
+
+func parseScheduleField(value string, f scheduleField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(value, ",") {
+		var (
+			rng       = item
+			step      = 1
+			low, high int
+			err       error
+		)
+		if i := strings.Index(item, "/"); i >= 0 {
+			rng = item[:i]
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, errors.Errorf("step %q of the %s should be a positive number", item[i+1:], f.name)
+			}
+		}
+		switch {
+		case rng == "*":
+			low, high = f.min, f.max
+		case strings.Contains(rng, "-"):
+			parts := strings.SplitN(rng, "-", 2)
+			if low, err = strconv.Atoi(parts[0]); err != nil {
+				return 0, errors.Errorf("%q of the %s is not a number", parts[0], f.name)
+			}
+			if high, err = strconv.Atoi(parts[1]); err != nil {
+				return 0, errors.Errorf("%q of the %s is not a number", parts[1], f.name)
+			}
+		default:
+			if low, err = strconv.Atoi(rng); err != nil {
+				return 0, errors.Errorf("%q of the %s is not a number", rng, f.name)
+			}
+			high = low
+			// `5/10` means from 5 to the max with a step of 10
+			if strings.Contains(item, "/") {
+				high = f.max
+			}
+		}
+		if low < f.min || high > f.max || low > high {
+			return 0, errors.Errorf("%s of the %s should be in the range %d-%d", rng, f.name, f.min, f.max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next gets the first time after t which matches the schedule, in the location of t. It returns the zero time if the
+// schedule doesn't fire in the following years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(scheduleSearchYears, 0, 0)
+	for t.Before(end) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+	domMatched := s.dom&(1<<uint(t.Day())) != 0
+	dowMatched := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatched || dowMatched
+	}
+	return domMatched && dowMatched
+}
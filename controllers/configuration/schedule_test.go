@@ -0,0 +1,113 @@
+package configuration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	testcases := []struct {
+		name   string
+		spec   string
+		errMsg string
+	}{
+		{
+			name: "every minute",
+			spec: "* * * * *",
+		},
+		{
+			name: "lists, ranges and steps",
+			spec: "*/15 0-6/2 1,15 * 1-5",
+		},
+		{
+			name: "Sunday is 7",
+			spec: "0 0 * * 7",
+		},
+		{
+			name:   "not enough fields",
+			spec:   "0 2 * *",
+			errMsg: "should have 5 fields",
+		},
+		{
+			name:   "out of range",
+			spec:   "60 * * * *",
+			errMsg: "60 of the minute should be in the range 0-59",
+		},
+		{
+			name:   "not a number",
+			spec:   "0 x * * *",
+			errMsg: `"x" of the hour is not a number`,
+		},
+		{
+			name:   "invalid step",
+			spec:   "*/0 * * * *",
+			errMsg: `step "0" of the minute should be a positive number`,
+		},
+		{
+			name:   "never fires",
+			spec:   "0 0 31 2 *",
+			errMsg: "never fires",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseSchedule(tc.spec)
+			if tc.errMsg == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.Contains(t, err.Error(), tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2022, 3, 16, 10, 30, 20, 0, time.UTC)
+
+	testcases := []struct {
+		spec string
+		want time.Time
+	}{
+		{
+			spec: "* * * * *",
+			want: time.Date(2022, 3, 16, 10, 31, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 2 * * *",
+			want: time.Date(2022, 3, 17, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "*/20 10 * * *",
+			want: time.Date(2022, 3, 16, 10, 40, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 0 * * 0",
+			want: time.Date(2022, 3, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 0 1 * *",
+			want: time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// either the 1st day of the month or Friday
+			spec: "0 0 1 * 5",
+			want: time.Date(2022, 3, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 0 29 2 *",
+			want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tc.spec)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, schedule.Next(now))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package configuration
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// minSensitiveValueLength is the minimum length of a sensitive value which is searched in the outputs, the shorter
+// values like `1` or `true` appear in the outputs by chance
+const minSensitiveValueLength = 4
+
+// GetSensitiveVariableValues gets the values in spec.Variable of the variables which are declared sensitive in spec.HCL
+func GetSensitiveVariableValues(configuration *v1beta2.Configuration) ([]string, error) {
+	declarations := parseVariableDeclarations(configuration.Spec.HCL)
+	variables, err := RawExtension2Map(configuration.Spec.Variable)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for name, declaration := range declarations {
+		value, ok := variables[name]
+		if !declaration.Sensitive || !ok {
+			continue
+		}
+		leaves, err := sensitiveLeaves(value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, leaves...)
+	}
+	return values, nil
+}
+
+// sensitiveLeaves gets the primitive values in a value of a variable, the elements of a list or an object are rendered
+// into the outputs separately
+func sensitiveLeaves(value interface{}) ([]string, error) {
+	var leaves []string
+	switch v := value.(type) {
+	case nil:
+	case []interface{}:
+		for _, item := range v {
+			itemLeaves, err := sensitiveLeaves(item)
+			if err != nil {
+				return nil, err
+			}
+			leaves = append(leaves, itemLeaves...)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			itemLeaves, err := sensitiveLeaves(item)
+			if err != nil {
+				return nil, err
+			}
+			leaves = append(leaves, itemLeaves...)
+		}
+	default:
+		s, err := Interface2String(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(s) >= minSensitiveValueLength {
+			leaves = append(leaves, s)
+		}
+	}
+	return leaves, nil
+}
+
+// FindSensitiveLeaks gets the names of the non-sensitive outputs whose values contain any of the sensitive values, in
+// order
+func FindSensitiveLeaks(sensitiveValues []string, outputs map[string]string) []string {
+	var leaked []string
+	for name, output := range outputs {
+		for _, value := range sensitiveValues {
+			if strings.Contains(output, value) {
+				leaked = append(leaked, name)
+				break
+			}
+		}
+	}
+	sort.Strings(leaked)
+	return leaked
+}
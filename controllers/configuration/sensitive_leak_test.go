@@ -0,0 +1,52 @@
+package configuration
+
+import (
+	"sort"
+	"testing"
+
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestGetSensitiveVariableValues(t *testing.T) {
+	configuration := &v1beta2.Configuration{
+		Spec: v1beta2.ConfigurationSpec{
+			HCL: `
+variable "password" {
+  type      = string
+  sensitive = true
+}
+
+variable "tokens" {
+  sensitive = true
+}
+
+variable "pin" {
+  sensitive = true
+}
+
+variable "name" {
+  default = "my-bucket"
+}
+`,
+			Variable: &runtime.RawExtension{Raw: []byte(`{"password": "s3cr3t-pass", "tokens": {"a": "token-a", "b": ["token-b"]}, "pin": 42, "name": "my-bucket"}`)},
+		},
+	}
+	values, err := GetSensitiveVariableValues(configuration)
+	assert.NilError(t, err)
+	sort.Strings(values)
+	// the short values like the pin are not searched
+	assert.DeepEqual(t, []string{"s3cr3t-pass", "token-a", "token-b"}, values)
+}
+
+func TestFindSensitiveLeaks(t *testing.T) {
+	outputs := map[string]string{
+		"dsn":    "admin:s3cr3t-pass@db",
+		"url":    `["https://example.com?token=token-a"]`,
+		"bucket": "my-bucket",
+	}
+	assert.DeepEqual(t, []string{"dsn", "url"}, FindSensitiveLeaks([]string{"s3cr3t-pass", "token-a"}, outputs))
+	assert.Assert(t, FindSensitiveLeaks(nil, outputs) == nil)
+}
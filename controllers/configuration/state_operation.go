@@ -0,0 +1,116 @@
+package configuration
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// moduleAddressPattern accepts the address of a module or its instance, like `module.storage` or
+// `module.network.module.subnet["a"]`, which is moved or removed with all of its resources. It's also matched by
+// resourceAddressPattern.
+var moduleAddressPattern = regexp.MustCompile(`^module\.[A-Za-z_][\w-]*(\[(\d+|"[\w.-]+")\])?(\.module\.[A-Za-z_][\w-]*(\[(\d+|"[\w.-]+")\])?)*$`)
+
+// importIDPattern accepts the ID of a cloud resource without quotes and control characters, as it's quoted in the
+// command of the executor
+var importIDPattern = regexp.MustCompile(`^[^'"\x00-\x1f\x7f]+$`)
+
+// StateOperationKey is the key of a state operation in status.stateOperations, like `mv module.a module.b`
+func StateOperationKey(operation v1beta2.StateOperation) string {
+	switch operation.Type {
+	case v1beta2.StateOperationMove:
+		return fmt.Sprintf("mv %s %s", operation.Address, operation.Destination)
+	case v1beta2.StateOperationImport:
+		return fmt.Sprintf("import %s %s", operation.Address, operation.ID)
+	default:
+		return fmt.Sprintf("%s %s", operation.Type, operation.Address)
+	}
+}
+
+// GetPendingStateOperations gets the operations of spec.StateOperations which haven't completed, in order
+func GetPendingStateOperations(configuration *v1beta2.Configuration) []v1beta2.StateOperation {
+	var completed []string
+	if configuration.Status.StateOperations != nil {
+		completed = configuration.Status.StateOperations.Completed
+	}
+	var pending []v1beta2.StateOperation
+	for _, operation := range configuration.Spec.StateOperations {
+		if !containsString(completed, StateOperationKey(operation)) {
+			pending = append(pending, operation)
+		}
+	}
+	return pending
+}
+
+// validStateOperations checks whether the addresses and IDs of the state operations are valid for their types, and no
+// operation is duplicated, as an operation is recorded by its key once it completes
+func validStateOperations(operations []v1beta2.StateOperation) error {
+	isAddress := func(address string) bool {
+		return resourceAddressPattern.MatchString(address) || moduleAddressPattern.MatchString(address)
+	}
+	var keys []string
+	for i, operation := range operations {
+		switch operation.Type {
+		case v1beta2.StateOperationMove:
+			if !isAddress(operation.Address) || !isAddress(operation.Destination) {
+				return fmt.Errorf("spec.StateOperations[%d]: mv needs the address and destination of a resource or module", i)
+			}
+			if moduleAddressPattern.MatchString(operation.Address) != moduleAddressPattern.MatchString(operation.Destination) {
+				return fmt.Errorf("spec.StateOperations[%d]: mv could not move between a resource and a module", i)
+			}
+			if operation.ID != "" {
+				return fmt.Errorf("spec.StateOperations[%d]: id could only be set for import", i)
+			}
+		case v1beta2.StateOperationRemove:
+			if !isAddress(operation.Address) {
+				return fmt.Errorf("spec.StateOperations[%d]: rm needs the address of a resource or module", i)
+			}
+			if operation.Destination != "" || operation.ID != "" {
+				return fmt.Errorf("spec.StateOperations[%d]: destination and id could not be set for rm", i)
+			}
+		case v1beta2.StateOperationImport:
+			if !resourceAddressPattern.MatchString(operation.Address) || moduleAddressPattern.MatchString(operation.Address) {
+				return fmt.Errorf("spec.StateOperations[%d]: import needs the address of a resource", i)
+			}
+			if !importIDPattern.MatchString(operation.ID) {
+				return fmt.Errorf("spec.StateOperations[%d]: import needs the id of the cloud resource without quotes", i)
+			}
+			if operation.Destination != "" {
+				return fmt.Errorf("spec.StateOperations[%d]: destination could only be set for mv", i)
+			}
+		default:
+			return fmt.Errorf("spec.StateOperations[%d]: type %s is not supported, it should be one of mv, rm and import", i, operation.Type)
+		}
+		key := StateOperationKey(operation)
+		if containsString(keys, key) {
+			return fmt.Errorf("spec.StateOperations[%d]: %s is duplicated", i, key)
+		}
+		keys = append(keys, key)
+	}
+	return nil
+}
+
+// validMovedBlocks checks whether the moved blocks move between the valid addresses of the same kind, and no address is
+// moved twice, which Terraform refuses
+func validMovedBlocks(moved []v1beta2.MovedBlock) error {
+	var froms []string
+	for i, m := range moved {
+		for _, address := range []string{m.From, m.To} {
+			if !resourceAddressPattern.MatchString(address) && !moduleAddressPattern.MatchString(address) {
+				return fmt.Errorf("spec.Moved[%d]: %s is not a valid address of a resource or module", i, address)
+			}
+		}
+		if moduleAddressPattern.MatchString(m.From) != moduleAddressPattern.MatchString(m.To) {
+			return fmt.Errorf("spec.Moved[%d]: could not move between a resource and a module", i)
+		}
+		if m.From == m.To {
+			return fmt.Errorf("spec.Moved[%d]: from and to are the same address %s", i, m.From)
+		}
+		if containsString(froms, m.From) {
+			return fmt.Errorf("spec.Moved[%d]: %s is moved more than once", i, m.From)
+		}
+		froms = append(froms, m.From)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package configuration
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestGetPendingStateOperations(t *testing.T) {
+	configuration := &v1beta2.Configuration{}
+	assert.Assert(t, GetPendingStateOperations(configuration) == nil)
+
+	configuration.Spec.StateOperations = []v1beta2.StateOperation{
+		{Type: v1beta2.StateOperationMove, Address: "module.a", Destination: "module.b"},
+		{Type: v1beta2.StateOperationRemove, Address: "aws_s3_bucket.logs"},
+		{Type: v1beta2.StateOperationImport, Address: "aws_s3_bucket.data", ID: "data-bucket"},
+	}
+	configuration.Status.StateOperations = &v1beta2.ConfigurationStateOperationsStatus{
+		Completed: []string{"mv module.a module.b", "import aws_s3_bucket.data another-bucket"},
+	}
+	assert.DeepEqual(t, configuration.Spec.StateOperations[1:], GetPendingStateOperations(configuration))
+	assert.Equal(t, "rm aws_s3_bucket.logs", StateOperationKey(configuration.Spec.StateOperations[1]))
+}
+
+func TestValidStateOperations(t *testing.T) {
+	testcases := []struct {
+		name       string
+		operations []v1beta2.StateOperation
+		errMsg     string
+	}{
+		{
+			name: "valid operations",
+			operations: []v1beta2.StateOperation{
+				{Type: v1beta2.StateOperationMove, Address: "aws_s3_bucket.b", Destination: `module.storage.aws_s3_bucket.b["logs"]`},
+				{Type: v1beta2.StateOperationMove, Address: "module.network", Destination: "module.vpc.module.network"},
+				{Type: v1beta2.StateOperationRemove, Address: "module.legacy"},
+				{Type: v1beta2.StateOperationImport, Address: "aws_iam_role.r[0]", ID: "arn:aws:iam::123456789012:role/r"},
+			},
+		},
+		{
+			name:       "unknown type",
+			operations: []v1beta2.StateOperation{{Type: "replace", Address: "aws_s3_bucket.b"}},
+			errMsg:     "spec.StateOperations[0]: type replace is not supported, it should be one of mv, rm and import",
+		},
+		{
+			name:       "mv without destination",
+			operations: []v1beta2.StateOperation{{Type: v1beta2.StateOperationMove, Address: "aws_s3_bucket.b"}},
+			errMsg:     "spec.StateOperations[0]: mv needs the address and destination of a resource or module",
+		},
+		{
+			name:       "mv from a resource to a module",
+			operations: []v1beta2.StateOperation{{Type: v1beta2.StateOperationMove, Address: "aws_s3_bucket.b", Destination: "module.b"}},
+			errMsg:     "spec.StateOperations[0]: mv could not move between a resource and a module",
+		},
+		{
+			name:       "rm with shell meta characters",
+			operations: []v1beta2.StateOperation{{Type: v1beta2.StateOperationRemove, Address: "aws_s3_bucket.b'; rm -rf /"}},
+			errMsg:     "spec.StateOperations[0]: rm needs the address of a resource or module",
+		},
+		{
+			name:       "rm with id",
+			operations: []v1beta2.StateOperation{{Type: v1beta2.StateOperationRemove, Address: "aws_s3_bucket.b", ID: "b"}},
+			errMsg:     "spec.StateOperations[0]: destination and id could not be set for rm",
+		},
+		{
+			name:       "import a module",
+			operations: []v1beta2.StateOperation{{Type: v1beta2.StateOperationImport, Address: "module.b", ID: "b"}},
+			errMsg:     "spec.StateOperations[0]: import needs the address of a resource",
+		},
+		{
+			name:       "import with a quoted id",
+			operations: []v1beta2.StateOperation{{Type: v1beta2.StateOperationImport, Address: "aws_s3_bucket.b", ID: "b' && exit 1 '"}},
+			errMsg:     "spec.StateOperations[0]: import needs the id of the cloud resource without quotes",
+		},
+		{
+			name: "duplicated operations",
+			operations: []v1beta2.StateOperation{
+				{Type: v1beta2.StateOperationRemove, Address: "aws_s3_bucket.b"},
+				{Type: v1beta2.StateOperationRemove, Address: "aws_s3_bucket.b"},
+			},
+			errMsg: "spec.StateOperations[1]: rm aws_s3_bucket.b is duplicated",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validStateOperations(tc.operations)
+			if tc.errMsg != "" {
+				assert.Error(t, err, tc.errMsg)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestValidMovedBlocks(t *testing.T) {
+	testcases := []struct {
+		name   string
+		moved  []v1beta2.MovedBlock
+		errMsg string
+	}{
+		{
+			name: "valid moves",
+			moved: []v1beta2.MovedBlock{
+				{From: "aws_s3_bucket.logs", To: `module.storage.aws_s3_bucket.logs["a"]`},
+				{From: "module.network", To: "module.vpc.module.network"},
+			},
+		},
+		{
+			name:   "invalid address",
+			moved:  []v1beta2.MovedBlock{{From: "aws_s3_bucket", To: "aws_s3_bucket.b"}},
+			errMsg: "spec.Moved[0]: aws_s3_bucket is not a valid address of a resource or module",
+		},
+		{
+			name:   "move a resource to a module",
+			moved:  []v1beta2.MovedBlock{{From: "aws_s3_bucket.b", To: "module.b"}},
+			errMsg: "spec.Moved[0]: could not move between a resource and a module",
+		},
+		{
+			name:   "move to the same address",
+			moved:  []v1beta2.MovedBlock{{From: "module.b", To: "module.b"}},
+			errMsg: "spec.Moved[0]: from and to are the same address module.b",
+		},
+		{
+			name: "move an address twice",
+			moved: []v1beta2.MovedBlock{
+				{From: "module.a", To: "module.b"},
+				{From: "module.a", To: "module.c"},
+			},
+			errMsg: "spec.Moved[1]: module.a is moved more than once",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validMovedBlocks(tc.moved)
+			if tc.errMsg != "" {
+				assert.Error(t, err, tc.errMsg)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// UntaintAnnotation is the annotation of a Configuration which untaints the resources before the next apply, so they
+// are not replaced. Its value is a comma-separated list of resource addresses, like those in status.taintedResources,
+// and the annotation is removed once the apply is triggered.
+const UntaintAnnotation = "terraform.core.oam.dev/untaint"
+
+// taintedStatus is the status of a resource instance in the state, which is tainted
+const taintedStatus = "tainted"
+
+type stateResource struct {
+	Module    string                  `json:"module,omitempty"`
+	Mode      string                  `json:"mode"`
+	Type      string                  `json:"type"`
+	Name      string                  `json:"name"`
+	Instances []stateResourceInstance `json:"instances"`
+}
+
+type stateResourceInstance struct {
+	IndexKey interface{} `json:"index_key,omitempty"`
+	Status   string      `json:"status,omitempty"`
+}
+
+// GetUntaintResources gets the resource addresses in the annotation UntaintAnnotation
+func GetUntaintResources(configuration *v1beta2.Configuration) []string {
+	var addresses []string
+	for _, address := range strings.Split(configuration.Annotations[UntaintAnnotation], ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// ParseTaintedResources parses the addresses of the tainted resource instances, like `aws_instance.a[0]`, from the
+// Terraform state
+func ParseTaintedResources(stateJSON []byte) ([]string, error) {
+	var state struct {
+		Resources []stateResource `json:"resources"`
+	}
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the Terraform state")
+	}
+	var addresses []string
+	for _, r := range state.Resources {
+		address := r.Type + "." + r.Name
+		if r.Mode == "data" {
+			address = "data." + address
+		}
+		if r.Module != "" {
+			address = r.Module + "." + address
+		}
+		for _, instance := range r.Instances {
+			if instance.Status != taintedStatus {
+				continue
+			}
+			switch key := instance.IndexKey.(type) {
+			case nil:
+				addresses = append(addresses, address)
+			case string:
+				addresses = append(addresses, fmt.Sprintf("%s[%q]", address, key))
+			default:
+				addresses = append(addresses, fmt.Sprintf("%s[%v]", address, key))
+			}
+		}
+	}
+	return addresses, nil
+}
@@ -0,0 +1,39 @@
+package configuration
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestGetUntaintResources(t *testing.T) {
+	configuration := &v1beta2.Configuration{}
+	assert.Assert(t, GetUntaintResources(configuration) == nil)
+
+	configuration.Annotations = map[string]string{UntaintAnnotation: ` aws_instance.a[0], ,module.vpc.aws_vpc.this`}
+	assert.DeepEqual(t, []string{"aws_instance.a[0]", "module.vpc.aws_vpc.this"}, GetUntaintResources(configuration))
+}
+
+func TestParseTaintedResources(t *testing.T) {
+	state := `{
+  "version": 4,
+  "resources": [
+    {"mode": "managed", "type": "aws_s3_bucket", "name": "b", "instances": [{"status": "tainted"}]},
+    {"mode": "managed", "type": "aws_instance", "name": "a", "instances": [{"index_key": 0}, {"index_key": 1, "status": "tainted"}]},
+    {"module": "module.storage", "mode": "managed", "type": "aws_s3_bucket", "name": "logs", "instances": [{"index_key": "audit", "status": "tainted"}]},
+    {"mode": "data", "type": "aws_region", "name": "current", "instances": [{}]}
+  ]
+}`
+	tainted, err := ParseTaintedResources([]byte(state))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"aws_s3_bucket.b", "aws_instance.a[1]", `module.storage.aws_s3_bucket.logs["audit"]`}, tainted)
+
+	tainted, err = ParseTaintedResources([]byte(`{"version": 4, "resources": []}`))
+	assert.NilError(t, err)
+	assert.Assert(t, tainted == nil)
+
+	_, err = ParseTaintedResources([]byte("not json"))
+	assert.ErrorContains(t, err, "failed to parse the Terraform state")
+}
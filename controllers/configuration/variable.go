@@ -0,0 +1,251 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+var (
+	variableBlock       = regexp.MustCompile(`(?m)^\s*variable\s+"([A-Za-z_][A-Za-z0-9_-]*)"\s*\{`)
+	variableTypePattern = regexp.MustCompile(`(?m)^\s*type\s*=\s*([a-z]+)`)
+	variableDefault     = regexp.MustCompile(`(?m)^\s*default\s*=`)
+	variableSensitive   = regexp.MustCompile(`(?m)^\s*sensitive\s*=\s*true\b`)
+)
+
+// variableDeclaration is a variable block declared in the HCL
+type variableDeclaration struct {
+	// Type is the type keyword, like `string` or `list` for `list(string)`, it's empty if the type is not declared
+	Type      string
+	Required  bool
+	Sensitive bool
+}
+
+// parseVariableDeclarations gets the variable blocks declared in the HCL
+func parseVariableDeclarations(hcl string) map[string]variableDeclaration {
+	declarations := map[string]variableDeclaration{}
+	for _, loc := range variableBlock.FindAllStringSubmatchIndex(hcl, -1) {
+		body := blockBody(hcl[loc[1]:])
+		var declaration variableDeclaration
+		if m := variableTypePattern.FindStringSubmatch(body); m != nil {
+			declaration.Type = m[1]
+		}
+		declaration.Required = !variableDefault.MatchString(body)
+		declaration.Sensitive = variableSensitive.MatchString(body)
+		declarations[hcl[loc[2]:loc[3]]] = declaration
+	}
+	return declarations
+}
+
+// blockBody gets the content of a block until the closing brace, the opening brace is already consumed
+func blockBody(hcl string) string {
+	depth := 1
+	for i, c := range hcl {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return hcl[:i]
+			}
+		}
+	}
+	return hcl
+}
+
+// ValidVariables validates spec.Variable against the variables declared in spec.HCL: the required variables should be
+// set, and the values should be able to convert to the declared types
+func ValidVariables(configuration *v1beta2.Configuration) error {
+	declarations := parseVariableDeclarations(configuration.Spec.HCL)
+	if len(declarations) == 0 {
+		return nil
+	}
+	variables, err := RawExtension2Map(configuration.Spec.Variable)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(declarations))
+	for name := range declarations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		declaration := declarations[name]
+		value, ok := variables[name]
+		if !ok {
+			if declaration.Required {
+				return fmt.Errorf("variable %s is required but is not set in spec.Variable", name)
+			}
+			continue
+		}
+		if !convertibleTo(value, declaration.Type) {
+			// the value is not in the error, which may be sensitive
+			return fmt.Errorf("variable %s should be %s, but got %s", name, declaration.Type, jsonKind(value))
+		}
+	}
+	return nil
+}
+
+// jsonKind describes the kind of a JSON value
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a bool"
+	case []interface{}:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
+
+// convertibleTo checks whether Terraform could convert the JSON value to the type, the primitive types are converted to
+// each other like Terraform does
+func convertibleTo(value interface{}, variableType string) bool {
+	if value == nil {
+		return true
+	}
+	switch variableType {
+	case "string":
+		switch value.(type) {
+		case string, float64, bool:
+			return true
+		}
+		return false
+	case "number":
+		switch v := value.(type) {
+		case float64:
+			return true
+		case string:
+			_, err := strconv.ParseFloat(v, 64)
+			return err == nil
+		}
+		return false
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			return true
+		case string:
+			return v == "true" || v == "false"
+		}
+		return false
+	case "list", "set", "tuple":
+		_, ok := value.([]interface{})
+		return ok
+	case "map", "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	default:
+		// `any` or the type is not declared
+		return true
+	}
+}
+
+// variableTemplateVars are the values which the templates in the string values of spec.Variable could reference, like
+// `ami-{{.Region}}`
+type variableTemplateVars struct {
+	// Region is the region of the Configuration, which is resolved like SetRegion does
+	Region string
+}
+
+// validVariableTemplates checks the templates in spec.Variable are valid and only reference variableTemplateVars
+func validVariableTemplates(configuration *v1beta2.Configuration) error {
+	if !hasVariableTemplates(configuration) {
+		return nil
+	}
+	variables, err := RawExtension2Map(configuration.Spec.Variable)
+	if err != nil {
+		return err
+	}
+	_, err = renderVariableTemplates("", variables, variableTemplateVars{Region: "region"})
+	return err
+}
+
+// RenderVariables substitutes the templates in the string values of spec.Variable with the region resolved by
+// ResolveRegion. It's done before the hash of the Configuration is computed, so a Configuration
+// targets another region by just changing the region.
+func RenderVariables(configuration *v1beta2.Configuration, providerObj *v1beta1.Provider) error {
+	if !hasVariableTemplates(configuration) {
+		return nil
+	}
+	variables, err := RawExtension2Map(configuration.Spec.Variable)
+	if err != nil {
+		return err
+	}
+	var vars variableTemplateVars
+	vars.Region, _ = ResolveRegion(configuration, providerObj)
+	rendered, err := renderVariableTemplates("", variables, vars)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		return err
+	}
+	configuration.Spec.Variable = &runtime.RawExtension{Raw: data}
+	return nil
+}
+
+func hasVariableTemplates(configuration *v1beta2.Configuration) bool {
+	variable := configuration.Spec.Variable
+	return variable != nil && bytes.Contains(variable.Raw, []byte("{{"))
+}
+
+// renderVariableTemplates renders the templates in the strings of a value, including the strings nested in the lists
+// and objects. The values are not in the errors, which may be sensitive.
+func renderVariableTemplates(name string, value interface{}, vars variableTemplateVars) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("the template of variable %s is not valid: %w", name, err)
+		}
+		var wr bytes.Buffer
+		if err := tmpl.Execute(&wr, vars); err != nil {
+			return nil, fmt.Errorf("the template of variable %s could only reference {{.Region}}: %w", name, err)
+		}
+		return wr.String(), nil
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if rendered[i], err = renderVariableTemplates(fmt.Sprintf("%s[%d]", name, i), item, vars); err != nil {
+				return nil, err
+			}
+		}
+		return rendered, nil
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			itemName := k
+			if name != "" {
+				itemName = name + "." + k
+			}
+			var err error
+			if rendered[k], err = renderVariableTemplates(itemName, item, vars); err != nil {
+				return nil, err
+			}
+		}
+		return rendered, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,180 @@
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestParseVariableDeclarations(t *testing.T) {
+	hcl := `
+variable "name" {
+  description = "The name of the bucket"
+  type        = string
+}
+
+variable "tags" {
+  type = map(string)
+  default = {
+    env = "dev"
+  }
+}
+
+variable "count" {}
+`
+	assert.Equal(t, map[string]variableDeclaration{
+		"name":  {Type: "string", Required: true},
+		"tags":  {Type: "map", Required: false},
+		"count": {Type: "", Required: true},
+	}, parseVariableDeclarations(hcl))
+}
+
+func TestValidVariables(t *testing.T) {
+	hcl := `
+variable "name" {
+  type = string
+}
+
+variable "size" {
+  type    = number
+  default = 20
+}
+
+variable "encrypted" {
+  type    = bool
+  default = false
+}
+
+variable "zones" {
+  type    = list(string)
+  default = []
+}
+`
+	testcases := []struct {
+		name     string
+		hcl      string
+		variable string
+		errMsg   string
+	}{
+		{
+			name:     "no variable is declared",
+			hcl:      `resource "random_id" "id" {}`,
+			variable: `{"name": 1}`,
+		},
+		{
+			name:     "variables are valid",
+			hcl:      hcl,
+			variable: `{"name": "rds", "size": "40", "encrypted": "true", "zones": ["a", "b"]}`,
+		},
+		{
+			name:     "required variable is not set",
+			hcl:      hcl,
+			variable: `{"size": 40}`,
+			errMsg:   "variable name is required but is not set in spec.Variable",
+		},
+		{
+			name:     "number is not a number",
+			hcl:      hcl,
+			variable: `{"name": "rds", "size": "large"}`,
+			errMsg:   "variable size should be number, but got a string",
+		},
+		{
+			name:     "list is a string",
+			hcl:      hcl,
+			variable: `{"name": "rds", "zones": "a"}`,
+			errMsg:   "variable zones should be list, but got a string",
+		},
+		{
+			name:     "string is a map",
+			hcl:      hcl,
+			variable: `{"name": {"a": "b"}}`,
+			errMsg:   "variable name should be string, but got an object",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			configuration := &v1beta2.Configuration{
+				Spec: v1beta2.ConfigurationSpec{
+					HCL:      tc.hcl,
+					Variable: &runtime.RawExtension{Raw: []byte(tc.variable)},
+				},
+			}
+			err := ValidVariables(configuration)
+			if tc.errMsg == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.errMsg)
+		})
+	}
+}
+
+func TestRenderVariables(t *testing.T) {
+	providerObj := &v1beta1.Provider{Spec: v1beta1.ProviderSpec{Region: "us-west-2"}}
+	testcases := []struct {
+		name     string
+		variable string
+		region   string
+		provider *v1beta1.Provider
+		want     string
+		errMsg   string
+	}{
+		{
+			name:     "no template",
+			variable: `{"name": "rds"}`,
+			provider: providerObj,
+			want:     `{"name": "rds"}`,
+		},
+		{
+			name:     "region of the Provider",
+			variable: `{"ami": "ami-{{.Region}}", "zones": ["{{.Region}}a", "{{.Region}}b"], "tags": {"region": "{{.Region}}"}, "size": 20}`,
+			provider: providerObj,
+			want:     `{"ami":"ami-us-west-2","size":20,"tags":{"region":"us-west-2"},"zones":["us-west-2a","us-west-2b"]}`,
+		},
+		{
+			name:     "region of the Configuration",
+			variable: `{"ami": "ami-{{.Region}}"}`,
+			region:   "eu-west-1",
+			provider: providerObj,
+			want:     `{"ami":"ami-eu-west-1"}`,
+		},
+		{
+			name:     "unknown field",
+			variable: `{"zones": ["{{.Zone}}"]}`,
+			provider: providerObj,
+			errMsg:   "the template of variable zones[0] could only reference {{.Region}}",
+		},
+		{
+			name:     "invalid template",
+			variable: `{"tags": {"region": "{{.Region"}}`,
+			provider: providerObj,
+			errMsg:   "the template of variable tags.region is not valid",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			configuration := &v1beta2.Configuration{
+				Spec: v1beta2.ConfigurationSpec{
+					HCL:                   "abc",
+					Variable:              &runtime.RawExtension{Raw: []byte(tc.variable)},
+					BaseConfigurationSpec: v1beta2.BaseConfigurationSpec{Region: tc.region},
+				},
+			}
+			err := RenderVariables(configuration, tc.provider)
+			if tc.errMsg != "" {
+				assert.Contains(t, err.Error(), tc.errMsg)
+				_, err = ValidConfigurationObject(configuration)
+				assert.Contains(t, err.Error(), tc.errMsg)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, string(configuration.Spec.Variable.Raw))
+		})
+	}
+}
@@ -25,10 +25,13 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
@@ -47,6 +50,7 @@ import (
 	tfcfg "github.com/oam-dev/terraform-controller/controllers/configuration"
 	"github.com/oam-dev/terraform-controller/controllers/provider"
 	"github.com/oam-dev/terraform-controller/controllers/terraform"
+	"github.com/oam-dev/terraform-controller/controllers/tracing"
 	"github.com/oam-dev/terraform-controller/controllers/util"
 )
 
@@ -62,9 +66,27 @@ const (
 	InputTFConfigurationVolumeMountPath = "/opt/tf-configuration"
 	// BackendVolumeMountPath is the volume mount path for Terraform backend
 	BackendVolumeMountPath = "/opt/tf-backend"
+	// VariablesVolumeName is the volume name for the variables file from the variable secret
+	VariablesVolumeName = "tf-variables"
+	// CredentialsFileVolumeName is the volume name for the credentials file of the Provider from the variable secret
+	CredentialsFileVolumeName = "tf-credentials-file"
+	// VendoredModulesVolumeName is the volume name for the PersistentVolumeClaim of spec.VendoredModules
+	VendoredModulesVolumeName = "tf-vendored-modules"
+	// VendoredModulesVolumeMountPath is the volume mount path for the PersistentVolumeClaim of spec.VendoredModules
+	VendoredModulesVolumeMountPath = "/opt/tf-modules"
+	// NetrcVolumeName is the volume name for the .netrc in the Secret of spec.NetrcSecretRef
+	NetrcVolumeName = "tf-netrc"
+	// NetrcVolumeMountPath is the volume mount path for the .netrc in the Secret of spec.NetrcSecretRef
+	NetrcVolumeMountPath = "/opt/tf-netrc"
+	// PluginCacheVolumeName is the volume name for the PersistentVolumeClaim of the provider plugin cache
+	PluginCacheVolumeName = "tf-plugin-cache"
+	// PluginCacheVolumeMountPath is the volume mount path for the PersistentVolumeClaim of the provider plugin cache
+	PluginCacheVolumeMountPath = "/opt/tf-plugin-cache"
 	// terraformContainerName is the name of the container that executes the terraform in the pod
 	terraformContainerName     = "terraform-executor"
 	terraformInitContainerName = "terraform-init"
+	// defaultRegistryPreflightEndpoint is requested by the registry preflight if spec.RegistryPreflight.Endpoint is not set
+	defaultRegistryPreflightEndpoint = "https://registry.terraform.io/.well-known/terraform.json"
 )
 
 const (
@@ -76,6 +98,8 @@ const (
 	TFVariableSecret = "variable-%s"
 	// TFBackendSecret is the Secret name for Kubernetes backend
 	TFBackendSecret = "tfstate-%s-%s"
+	// tfstateSecretSuffixLabel is the label of the secret suffix on the secret of the kubernetes backend
+	tfstateSecretSuffixLabel = "tfstateSecretSuffix"
 )
 
 // TerraformExecutionType is the type for Terraform execution
@@ -86,14 +110,66 @@ const (
 	TerraformApply TerraformExecutionType = "apply"
 	// TerraformDestroy is the name to mark `terraform destroy`
 	TerraformDestroy TerraformExecutionType = "destroy"
+	// TerraformDestroyPreview is the name to mark `terraform plan -destroy`, which previews the destroy
+	TerraformDestroyPreview TerraformExecutionType = "destroy-preview"
+	// TerraformPlan is the name to mark `terraform plan -out`, which saves the plan for approval
+	TerraformPlan TerraformExecutionType = "plan"
+	// TerraformDriftCheck is the name to mark `terraform plan`, which checks the drift of the cloud resources
+	TerraformDriftCheck TerraformExecutionType = "drift-check"
 )
 
+// planConfigurationHashAnnotation is the annotation of the plan job, which is the hash of the inputs of the plan
+const planConfigurationHashAnnotation = "terraform.core.oam.dev/configuration-hash"
+
+// savedPlanFile is the file of the saved plan in the working directory
+const savedPlanFile = "tfplan"
+
+// warningsCheckFile is the JSON output of the plan in the working directory, which is checked for the warnings
+const warningsCheckFile = "warnings-check.json"
+
 const (
+	// configurationFinalizer is the default of FinalizerEnv
 	configurationFinalizer = "configuration.finalizers.terraform-controller"
 	// ClusterRoleName is the name of the ClusterRole for Terraform Job
 	ClusterRoleName = "tf-executor-clusterrole"
 	// ServiceAccountName is the name of the ServiceAccount for Terraform Job
 	ServiceAccountName = "tf-executor-service-account"
+	// ExecutorServiceAccountEnv is the env of the default ServiceAccount for Terraform Job, which has to be created by
+	// users in the namespace of the Configuration
+	ExecutorServiceAccountEnv = "TERRAFORM_EXECUTOR_SERVICE_ACCOUNT"
+	// PluginCacheClaimEnv is the env of the default PersistentVolumeClaim of the provider plugin cache, which has to be
+	// created by users in the namespace of the Configuration
+	PluginCacheClaimEnv = "TERRAFORM_PLUGIN_CACHE_CLAIM"
+	// pluginCacheDir is the directory of TF_PLUGIN_CACHE_DIR in the PersistentVolumeClaim of the plugin cache, and
+	// pluginCacheLockFile is locked by `terraform init`, as the cache isn't safe for the concurrent writes
+	pluginCacheDir      = "plugins"
+	pluginCacheLockFile = ".lock"
+)
+
+const (
+	// MaxConcurrentJobsEnv is the env which limits the number of running Terraform jobs in the cluster
+	MaxConcurrentJobsEnv = "TERRAFORM_MAX_CONCURRENT_JOBS"
+	// MaxConcurrentJobsPerProviderEnv is the env which limits the number of running Terraform jobs of a Provider, or of
+	// a cloud account if the Provider has one
+	MaxConcurrentJobsPerProviderEnv = "TERRAFORM_MAX_CONCURRENT_JOBS_PER_PROVIDER"
+	// CredentialsExpiryThresholdEnv is the env of the duration, like `15m`, before the temporary credentials of the
+	// Provider expire, in which no apply starts
+	CredentialsExpiryThresholdEnv = "TERRAFORM_CREDENTIALS_EXPIRY_THRESHOLD"
+	// defaultCredentialsExpiryThreshold is the default of CredentialsExpiryThresholdEnv
+	defaultCredentialsExpiryThreshold = 15 * time.Minute
+	// CancelGracePeriodEnv is the env of the duration, like `2m`, for which an interrupted apply or destroy job stops
+	// gracefully before it's killed
+	CancelGracePeriodEnv = "TERRAFORM_CANCEL_GRACE_PERIOD"
+	// defaultCancelGracePeriod is the default of CancelGracePeriodEnv
+	defaultCancelGracePeriod = 2 * time.Minute
+
+	// jobCreatedByLabel marks the Terraform jobs created by the controller
+	jobCreatedByLabel = "terraform.core.oam.dev/created-by"
+	// jobProviderNameLabel and jobProviderNamespaceLabel mark the Provider which a Terraform job uses
+	jobProviderNameLabel      = "terraform.core.oam.dev/provider-name"
+	jobProviderNamespaceLabel = "terraform.core.oam.dev/provider-namespace"
+	// jobAccountLabel marks the cloud account of the Provider which a Terraform job uses
+	jobAccountLabel = "terraform.core.oam.dev/account"
 )
 
 // ConfigurationReconciler reconciles a Configuration object.
@@ -108,8 +184,10 @@ type ConfigurationReconciler struct {
 // +kubebuilder:rbac:groups=terraform.core.oam.dev,resources=configurations/status,verbs=get;update;patch
 
 // Reconcile will reconcile periodically
-func (r *ConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *ConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	klog.InfoS("reconciling Terraform Configuration...", "NamespacedName", req.NamespacedName)
+	ctx, span := tracing.StartSpan(ctx, "Reconcile", req.Namespace, req.Name)
+	defer func() { tracing.EndSpan(span, err) }()
 
 	configuration, err := tfcfg.Get(ctx, r.Client, req.NamespacedName)
 	if err != nil {
@@ -117,35 +195,106 @@ func (r *ConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	}
 
 	meta := initTFConfigurationMeta(req, configuration)
+	defer func() {
+		if err != nil {
+			meta.LastReconcileReason = types.ReconcileError
+		}
+		if updateErr := meta.updateLastReconcileReason(ctx, r.Client); updateErr != nil {
+			klog.ErrorS(updateErr, "Failed to update the reason of the reconcile", "NamespacedName", req.NamespacedName)
+		}
+		meta.V(2).InfoS("reconciled Terraform Configuration", "NamespacedName", req.NamespacedName,
+			"Reason", meta.LastReconcileReason, "RequeueAfter", result.RequeueAfter)
+	}()
+	// the default Provider could be overridden by the namespace of the Configuration
+	if meta.ProviderReference, err = tfcfg.ResolveProviderReference(ctx, r.Client, configuration); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	// add finalizer
+	finalizer := GetConfigurationFinalizer()
 	var isDeleting = !configuration.ObjectMeta.DeletionTimestamp.IsZero()
 	if !isDeleting {
-		if !controllerutil.ContainsFinalizer(&configuration, configurationFinalizer) {
-			controllerutil.AddFinalizer(&configuration, configurationFinalizer)
+		if !controllerutil.ContainsFinalizer(&configuration, finalizer) {
+			controllerutil.AddFinalizer(&configuration, finalizer)
 			if err := r.Update(ctx, &configuration); err != nil {
 				return ctrl.Result{RequeueAfter: 3 * time.Second}, errors.Wrap(err, "failed to add finalizer")
 			}
 		}
+	} else if !controllerutil.ContainsFinalizer(&configuration, finalizer) {
+		// the deletion waits for the finalizers of the other installations, which destroy the cloud resources
+		klog.InfoS("Configuration is deleting without the finalizer of the controller, skip destroying",
+			"NamespacedName", req.NamespacedName, "Finalizer", finalizer)
+		return ctrl.Result{}, nil
+	}
+
+	if err := meta.updateProviderReadyCondition(ctx, r.Client); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !isDeleting && r.isUpToDate(ctx, &configuration, meta) {
+		klog.InfoS("Configuration is identical and healthy, skip reconciling", "NamespacedName", req.NamespacedName)
+		meta.LastReconcileReason = types.ReconcileUpToDate
+		// nothing triggers a reconcile when the next drift check is due
+		if wait, ok := driftCheckWait(&configuration, time.Now()); ok {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		return ctrl.Result{}, nil
 	}
 
 	// pre-check Configuration
 	if err := r.preCheck(ctx, &configuration, meta); err != nil && !isDeleting {
+		// granting the verb to the controller doesn't trigger a reconcile of the Configuration
+		if err.Error() == types.ErrSecretCopyForbidden {
+			meta.LastReconcileReason = types.ReconcileSecretCopyForbidden
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+		// neither does changing the Provider to allow the Configuration
+		var deniedErr *provider.AccessDeniedError
+		if errors.As(err, &deniedErr) {
+			meta.LastReconcileReason = types.ReconcileProviderAccessDenied
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+		// setting the annotation of the acknowledgement or reverting the region triggers another reconcile
+		var regionErr *tfcfg.RegionChangeError
+		if errors.As(err, &regionErr) {
+			meta.LastReconcileReason = types.ReconcileRegionChangeBlocked
+			return ctrl.Result{}, nil
+		}
 		return ctrl.Result{}, err
 	}
+	meta.V(4).InfoS("pre-checked Terraform Configuration", "NamespacedName", req.NamespacedName,
+		"ConfigurationChanged", meta.ConfigurationChanged, "EnvChanged", meta.EnvChanged, "ConfigurationHash", meta.ConfigurationHash)
+
+	// the secret of the variables is refreshed by the pre-check
+	if meta.RefreshSecretsToken != "" && !isDeleting {
+		if err := meta.removeRefreshSecretsAnnotation(ctx, r.Client); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	var tfExecutionJob = &batchv1.Job{}
 	if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.ApplyJobName, Namespace: meta.Namespace}, tfExecutionJob); err == nil {
-		if !meta.EnvChanged && tfExecutionJob.Status.Succeeded == int32(1) {
+		if err := meta.recordStateOperations(ctx, r.Client, tfExecutionJob); err != nil {
+			return ctrl.Result{}, err
+		}
+		// the outputs of the apply job which still waits for spec.PostApplyDelay are read by terraformApply later
+		if !meta.EnvChanged && tfExecutionJob.Status.Succeeded == int32(1) && meta.postApplyWait(tfExecutionJob, time.Now()) <= 0 {
 			if err := meta.updateApplyStatus(ctx, r.Client, types.Available, types.MessageCloudResourceDeployed); err != nil {
 				return ctrl.Result{}, err
 			}
+			if err := meta.storeJobLogs(ctx, r.Client, &configuration, meta.ApplyJobName, TerraformApply); err != nil {
+				klog.ErrorS(err, "Failed to store the logs of the Terraform apply job")
+			}
+			if err := meta.recordChangeSummary(ctx, r.Client, tfExecutionJob); err != nil {
+				klog.ErrorS(err, "Failed to record the changes of the Terraform apply job")
+			}
 		}
 	}
 
 	if isDeleting {
 		// terraform destroy
 		klog.InfoS("performing Configuration Destroy", "Namespace", req.Namespace, "Name", req.Name, "JobName", meta.DestroyJobName)
+		meta.LastReconcileReason = types.ReconcileDestroying
 
 		_, err := terraform.GetTerraformStatus(ctx, meta.Namespace, meta.DestroyJobName, terraformContainerName, terraformInitContainerName)
 		if err != nil {
@@ -153,12 +302,27 @@ func (r *ConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			if updateErr := meta.updateDestroyStatus(ctx, r.Client, types.ConfigurationDestroyFailed, err.Error()); updateErr != nil {
 				return ctrl.Result{}, updateErr
 			}
+			if err := meta.storeJobLogs(ctx, r.Client, &configuration, meta.DestroyJobName, TerraformDestroy); err != nil {
+				klog.ErrorS(err, "Failed to store the logs of the Terraform destroy job")
+			}
 		}
 
 		if err := r.terraformDestroy(ctx, req.Namespace, configuration, meta); err != nil {
-			if err.Error() == types.MessageDestroyJobNotCompleted {
+			if err.Error() == types.MessageDestroyJobNotCompleted || err.Error() == types.ErrApplyCancelling {
 				return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
 			}
+			if err.Error() == types.MessageConcurrencyLimitReached {
+				return ctrl.Result{RequeueAfter: concurrencyRequeueInterval(meta.Priority)}, nil
+			}
+			// removing the label triggers another reconcile
+			if err.Error() == types.MessageDeletionProtected {
+				return ctrl.Result{}, nil
+			}
+			// the deletion of the dependents doesn't trigger a reconcile of the Configuration
+			var blockedErr *tfcfg.DestroyBlockedError
+			if errors.As(err, &blockedErr) {
+				return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+			}
 			return ctrl.Result{RequeueAfter: 3 * time.Second}, errors.Wrap(err, "continue reconciling to destroy cloud resource")
 		}
 
@@ -166,8 +330,9 @@ func (r *ConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		if err != nil {
 			return ctrl.Result{}, client.IgnoreNotFound(err)
 		}
-		if controllerutil.ContainsFinalizer(&configuration, configurationFinalizer) {
-			controllerutil.RemoveFinalizer(&configuration, configurationFinalizer)
+		// only the finalizer of the controller is removed, the others are kept for their owners
+		if controllerutil.ContainsFinalizer(&configuration, finalizer) {
+			controllerutil.RemoveFinalizer(&configuration, finalizer)
 			if err := r.Update(ctx, &configuration); err != nil {
 				return ctrl.Result{RequeueAfter: 3 * time.Second}, errors.Wrap(err, "failed to remove finalizer")
 			}
@@ -175,51 +340,674 @@ func (r *ConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, nil
 	}
 
+	if meta.isDestroyPreviewPending(&configuration) {
+		completed, err := r.previewDestroy(ctx, meta)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !completed {
+			meta.LastReconcileReason = types.ReconcileDestroyPreviewRunning
+			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		}
+	}
+
+	if len(configuration.Spec.DependsOn) > 0 {
+		satisfied, err := r.checkDependencies(ctx, &configuration, meta)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !satisfied {
+			meta.LastReconcileReason = types.ReconcileWaitingForDependency
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+	}
+
+	if meta.ApplyNowToken != "" {
+		triggered, err := r.applyNow(ctx, &configuration, meta)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !triggered {
+			meta.LastReconcileReason = types.ReconcileWaitingForApplyNow
+			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		}
+	}
+
+	if len(meta.UntaintResources) > 0 {
+		triggered, err := r.untaint(ctx, &configuration, meta)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !triggered {
+			meta.LastReconcileReason = types.ReconcileWaitingForUntaint
+			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		}
+	}
+
+	if meta.SelfHealToken != "" {
+		triggered, err := r.selfHeal(ctx, &configuration, meta)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !triggered {
+			meta.LastReconcileReason = types.ReconcileSelfHealTriggered
+			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		}
+	}
+
+	if configuration.Spec.ApplySchedule != "" {
+		wait, err := r.checkApplySchedule(ctx, &configuration, meta)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if wait > 0 {
+			meta.LastReconcileReason = types.ReconcilePendingScheduledApply
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	if wait, ok := driftCheckWait(&configuration, time.Now()); ok && wait <= 0 {
+		completed, err := r.checkDrift(ctx, &configuration, meta)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !completed {
+			meta.LastReconcileReason = types.ReconcileDriftCheckRunning
+			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		}
+	}
+
 	// Terraform apply (create or update)
 	klog.InfoS("performing Terraform Apply (cloud resource create/update)", "Namespace", req.Namespace, "Name", req.Name)
 	if err := r.terraformApply(ctx, req.Namespace, configuration, meta); err != nil {
 		if err.Error() == types.MessageApplyJobNotCompleted {
+			meta.LastReconcileReason = types.ReconcileApplyInProgress
 			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
 		}
+		if err.Error() == types.MessageConcurrencyLimitReached {
+			meta.LastReconcileReason = types.ReconcilePendingOnConcurrency
+			return ctrl.Result{RequeueAfter: concurrencyRequeueInterval(meta.Priority)}, nil
+		}
+		// the refreshed credentials don't trigger a reconcile of the Configuration
+		if err.Error() == types.ErrCredentialsExpiringSoon {
+			meta.LastReconcileReason = types.ReconcileCredentialsExpiring
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+		if err.Error() == types.MessagePlanRunning {
+			meta.LastReconcileReason = types.ReconcilePlanRunning
+			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		}
+		if err.Error() == types.ErrWaitingForPostApplyDelay {
+			meta.LastReconcileReason = types.ReconcileApplyInProgress
+			return ctrl.Result{RequeueAfter: meta.PostApplyWait}, nil
+		}
+		// setting the annotation of the approval triggers another reconcile
+		if err.Error() == types.ErrPlanNotApproved {
+			meta.LastReconcileReason = types.ReconcileWaitingForApproval
+			return ctrl.Result{}, nil
+		}
+		// changing the Configuration or setting the annotation apply-now triggers another reconcile
+		if err.Error() == types.ErrFatalApplyError {
+			meta.LastReconcileReason = types.ReconcileApplyFailed
+			return ctrl.Result{}, nil
+		}
 		return ctrl.Result{RequeueAfter: 3 * time.Second}, errors.Wrap(err, "failed to create/update cloud resource")
 	}
 	state, err := terraform.GetTerraformStatus(ctx, meta.Namespace, meta.ApplyJobName, terraformContainerName, terraformInitContainerName)
 	if err != nil {
 		klog.ErrorS(err, "Terraform apply failed")
-		if updateErr := meta.updateApplyStatus(ctx, r.Client, state, err.Error()); updateErr != nil {
+		if state != types.ConfigurationProvisioningAndChecking {
+			meta.LastReconcileReason = types.ReconcileApplyFailed
+		}
+		meta.ApplyDiagnostics = toDiagnostics(err)
+		message := err.Error()
+		if state == types.FatalApplyError {
+			message = fmt.Sprintf(types.MessageFatalApplyError, message)
+		}
+		if updateErr := meta.updateApplyStatus(ctx, r.Client, state, message); updateErr != nil {
 			return ctrl.Result{}, updateErr
 		}
+		if err := meta.storeJobLogs(ctx, r.Client, &configuration, meta.ApplyJobName, TerraformApply); err != nil {
+			klog.ErrorS(err, "Failed to store the logs of the Terraform apply job")
+		}
+		// the warnings are reported again by the retries, until the Configuration changes
+		if state == types.FatalApplyError || state == types.TerraformWarnings {
+			if err := meta.pauseApplyJob(ctx, r.Client); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if state == types.PlanStale {
+			if err := r.discardStalePlan(ctx, meta); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		}
 	}
 
 	return ctrl.Result{}, nil
 }
 
+// checkDependencies checks whether all the Configurations in spec.DependsOn are Available. If not, the Configuration
+// is marked as waiting for the dependency, or as having a dependency cycle which will never be satisfied.
+func (r *ConfigurationReconciler) checkDependencies(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) (bool, error) {
+	cycle, err := tfcfg.FindDependencyCycle(ctx, r.Client, configuration)
+	if err != nil {
+		return false, err
+	}
+	if cycle != "" {
+		message := fmt.Sprintf(types.MessageDependencyCycleDetected, cycle)
+		klog.InfoS(message, "Namespace", configuration.Namespace, "Name", configuration.Name)
+		return false, meta.updateApplyStatus(ctx, r.Client, types.DependencyCycleDetected, message)
+	}
+	dependency, err := tfcfg.GetUnavailableDependency(ctx, r.Client, configuration)
+	if err != nil {
+		return false, err
+	}
+	if dependency != "" {
+		message := fmt.Sprintf(types.MessageWaitingForDependency, dependency)
+		klog.InfoS(message, "Namespace", configuration.Namespace, "Name", configuration.Name)
+		return false, meta.updateApplyStatus(ctx, r.Client, types.WaitingForDependency, message)
+	}
+	return true, nil
+}
+
+// isUpToDate checks whether the Configuration is identical to the one whose cloud resources were deployed, and is
+// still healthy. If so, there is no need to render and check the Configuration again.
+func (r *ConfigurationReconciler) isUpToDate(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) bool {
+	status := configuration.Status
+	if meta.ApplyNowToken != "" || meta.RefreshSecretsToken != "" || len(meta.UntaintResources) > 0 ||
+		meta.isDestroyPreviewPending(configuration) || meta.SelfHealToken != "" {
+		return false
+	}
+	if wait, ok := driftCheckWait(configuration, time.Now()); ok && wait <= 0 {
+		return false
+	}
+	if status.ObservedGeneration != configuration.Generation || status.Apply.State != types.Available {
+		return false
+	}
+	deployedHash, err := meta.getConfigurationHash(ctx, r.Client, configuration)
+	if err != nil || deployedHash == "" {
+		return false
+	}
+	p, err := provider.GetProviderFromConfiguration(ctx, r.Client, configuration, meta.ProviderReference.Namespace, meta.ProviderReference.Name)
+	if err != nil || p == nil {
+		return false
+	}
+	// the hash of the deployed Configuration is computed with the templates of spec.Variable substituted
+	rendered := configuration.DeepCopy()
+	if err := tfcfg.RenderVariables(rendered, p); err != nil {
+		return false
+	}
+	hash, err := tfcfg.ComputeConfigurationHash(rendered, p)
+	if err != nil {
+		klog.ErrorS(err, "failed to compute the hash of Configuration", "Name", configuration.Name)
+		return false
+	}
+	if hash != deployedHash {
+		return false
+	}
+	// the credentials and the secrets referenced by the variables aren't in the hash, the rotated ones are picked up by
+	// the check of the env
+	return !meta.isEnvChanged(ctx, r.Client, rendered, p)
+}
+
+// isEnvChanged checks whether the secret of the variables is stale against the credentials and the variables resolved
+// right now, without changing the meta of the reconcile. An error counts as a change, so the pre-check reports it.
+func (meta *TFConfigurationMeta) isEnvChanged(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration, providerObj *v1beta1.Provider) bool {
+	desired := *meta
+	desired.ProviderProfile = configuration.Spec.ProviderProfile
+	desired.Region, _ = tfcfg.ResolveRegion(configuration, providerObj)
+	if err := desired.resolveCredentials(ctx, k8sClient, providerObj); err != nil {
+		return true
+	}
+	if err := desired.prepareTFVariables(configuration); err != nil {
+		return true
+	}
+	var variableInSecret v1.Secret
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.VariableSecretName, Namespace: meta.Namespace}, &variableInSecret); err != nil {
+		return true
+	}
+	return isSecretDataStale(variableInSecret.Data, desired.VariableSecretData)
+}
+
+// applyNow handles the out-of-band apply requested by the annotation ApplyNowAnnotation, regardless of whether the
+// Configuration changes or spec.ApplySchedule. The apply job which isn't triggered by the token of the annotation is
+// deleted, so a new one is created. Once the job of the token exists, the annotation is removed. As the job records
+// the token, the same token doesn't trigger another apply even if the annotation fails to be removed.
+func (r *ConfigurationReconciler) applyNow(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) (bool, error) {
+	var job batchv1.Job
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.ApplyJobName, Namespace: meta.Namespace}, &job); err != nil {
+		if kerrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if job.Annotations[tfcfg.ApplyNowAnnotation] != meta.ApplyNowToken {
+		klog.InfoS("Deleting the apply job for the out-of-band apply", "Name", job.Name, "Namespace", job.Namespace)
+		if err := r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return false, nil
+	}
+
+	latest, err := tfcfg.Get(ctx, r.Client, client.ObjectKey{Name: configuration.Name, Namespace: configuration.Namespace})
+	if err != nil {
+		return false, err
+	}
+	delete(latest.Annotations, tfcfg.ApplyNowAnnotation)
+	if err := r.Client.Update(ctx, &latest); err != nil {
+		return false, errors.Wrap(err, "failed to remove the annotation of the out-of-band apply")
+	}
+	meta.ApplyNowToken = ""
+	return true, nil
+}
+
+// untaint handles the resources of the annotation UntaintAnnotation, which are untainted by the next apply job. Like
+// applyNow, the apply job which doesn't untaint them is deleted, and the annotation is removed once the job which
+// untaints them exists.
+func (r *ConfigurationReconciler) untaint(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) (bool, error) {
+	var job batchv1.Job
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.ApplyJobName, Namespace: meta.Namespace}, &job); err != nil {
+		if kerrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if job.Annotations[tfcfg.UntaintAnnotation] != meta.UntaintToken {
+		klog.InfoS("Deleting the apply job to untaint the resources", "Name", job.Name, "Namespace", job.Namespace, "Resources", meta.UntaintResources)
+		if err := r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return false, nil
+	}
+
+	latest, err := tfcfg.Get(ctx, r.Client, client.ObjectKey{Name: configuration.Name, Namespace: configuration.Namespace})
+	if err != nil {
+		return false, err
+	}
+	delete(latest.Annotations, tfcfg.UntaintAnnotation)
+	if err := r.Client.Update(ctx, &latest); err != nil {
+		return false, errors.Wrap(err, "failed to remove the annotation of the resources to untaint")
+	}
+	meta.UntaintResources, meta.UntaintToken = nil, ""
+	return true, nil
+}
+
+// isDestroyPreviewPending checks whether the destroy preview requested by the annotation DestroyPreviewAnnotation
+// hasn't completed. The token and the state of the preview are always kept in the status, even if the bookkeeping is
+// offloaded.
+func (meta *TFConfigurationMeta) isDestroyPreviewPending(configuration *v1beta2.Configuration) bool {
+	if meta.DestroyPreviewToken == "" {
+		return false
+	}
+	preview := configuration.Status.DestroyPreview
+	return preview == nil || preview.Token != meta.DestroyPreviewToken || preview.State == types.DestroyPreviewRunning
+}
+
+// previewDestroy runs `terraform plan -destroy` for the token of the annotation DestroyPreviewAnnotation, and records
+// the resources which would be destroyed in the status. Nothing is destroyed, and the apply status is not changed, so
+// neither is whether the Configuration is deletable. It returns true once the preview completes or fails.
+func (r *ConfigurationReconciler) previewDestroy(ctx context.Context, meta *TFConfigurationMeta) (bool, error) {
+	var job batchv1.Job
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.DestroyPreviewJobName, Namespace: meta.Namespace}, &job); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return false, err
+		}
+		klog.InfoS("Previewing the destroy", "Name", meta.Name, "Namespace", meta.Namespace, "Token", meta.DestroyPreviewToken)
+		if err := meta.assembleAndTriggerJob(ctx, r.Client, TerraformDestroyPreview); err != nil {
+			return false, err
+		}
+		return false, meta.updateDestroyPreviewStatus(ctx, r.Client, &v1beta2.ConfigurationDestroyPreviewStatus{
+			Token:   meta.DestroyPreviewToken,
+			State:   types.DestroyPreviewRunning,
+			Message: types.MessageDestroyPreviewRunning,
+		})
+	}
+	if job.Annotations[tfcfg.DestroyPreviewAnnotation] != meta.DestroyPreviewToken {
+		klog.InfoS("Deleting the job of the previous destroy preview", "Name", job.Name, "Namespace", job.Namespace)
+		return false, client.IgnoreNotFound(r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+	}
+
+	preview := &v1beta2.ConfigurationDestroyPreviewStatus{Token: meta.DestroyPreviewToken}
+	if job.Status.Succeeded == int32(1) {
+		logs, err := terraform.GetTerraformLogs(ctx, meta.Namespace, job.Name, terraformContainerName, terraformInitContainerName)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get the logs of the destroy preview")
+		}
+		preview.Resources = terraform.ParsePlannedDeletions(logs)
+		preview.State = types.DestroyPreviewCompleted
+		preview.Message = fmt.Sprintf(types.MessageDestroyPreviewCompleted, len(preview.Resources))
+	} else {
+		state, err := terraform.GetTerraformStatus(ctx, meta.Namespace, job.Name, terraformContainerName, terraformInitContainerName)
+		if err == nil || state == types.ConfigurationProvisioningAndChecking {
+			return false, nil
+		}
+		preview.State = types.DestroyPreviewFailed
+		preview.Message = err.Error()
+	}
+	if err := meta.updateDestroyPreviewStatus(ctx, r.Client, preview); err != nil {
+		return false, err
+	}
+	if err := r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return true, nil
+}
+
+// checkSavedPlan checks whether the saved plan is approved when spec.SavedPlan is true. The changes are planned by a
+// plan job for the current inputs, and the plan waits for its checksum to be approved by the annotation
+// PlanApprovalAnnotation. Once approved, the checksum is passed to the apply job, which refuses to apply changes
+// differing from it. A plan whose inputs change before the approval is stale, and the changes are planned again.
+func (r *ConfigurationReconciler) checkSavedPlan(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) error {
+	plan, err := meta.getPlanStatus(ctx, r.Client, configuration)
+	if err != nil {
+		return err
+	}
+	if plan != nil && plan.ConfigurationHash == meta.ConfigurationHash {
+		switch {
+		case plan.State == types.PlanApproved ||
+			plan.State == types.PlanPendingApproval && configuration.Annotations[tfcfg.PlanApprovalAnnotation] == plan.Checksum:
+			meta.ApprovedPlanChecksum = plan.Checksum
+			if plan.State == types.PlanApproved {
+				return nil
+			}
+			approved := plan.DeepCopy()
+			approved.State = types.PlanApproved
+			approved.Message = fmt.Sprintf(types.MessagePlanApproved, plan.Checksum)
+			return meta.updatePlanStatus(ctx, r.Client, approved)
+		case plan.State == types.PlanPendingApproval:
+			return errors.New(types.ErrPlanNotApproved)
+		}
+	}
+
+	var job batchv1.Job
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.PlanJobName, Namespace: meta.Namespace}, &job); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return err
+		}
+		state, message := types.PlanRunning, types.MessagePlanRunning
+		if plan != nil && plan.State == types.PlanPendingApproval {
+			state, message = types.PlanStale, fmt.Sprintf(types.MessagePlanStale, plan.Checksum)
+		} else if configuration.Status.Apply.State == types.PlanStale {
+			state, message = types.PlanStale, configuration.Status.Apply.Message
+		}
+		klog.InfoS("Planning the changes for approval", "Name", meta.Name, "Namespace", meta.Namespace)
+		if err := meta.assembleAndTriggerJob(ctx, r.Client, TerraformPlan); err != nil {
+			return err
+		}
+		if err := meta.updatePlanStatus(ctx, r.Client, &v1beta2.ConfigurationPlanStatus{
+			ConfigurationHash: meta.ConfigurationHash,
+			State:             types.PlanRunning,
+			Message:           types.MessagePlanRunning,
+		}); err != nil {
+			return err
+		}
+		if err := meta.updateApplyStatus(ctx, r.Client, state, message); err != nil {
+			return err
+		}
+		return errors.New(types.MessagePlanRunning)
+	}
+	if job.Annotations[planConfigurationHashAnnotation] != meta.ConfigurationHash {
+		klog.InfoS("Deleting the job of the stale plan", "Name", job.Name, "Namespace", job.Namespace)
+		if err := r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		return errors.New(types.MessagePlanRunning)
+	}
+
+	plan = &v1beta2.ConfigurationPlanStatus{ConfigurationHash: meta.ConfigurationHash}
+	if job.Status.Succeeded == int32(1) {
+		logs, err := terraform.GetTerraformLogs(ctx, meta.Namespace, job.Name, terraformContainerName, terraformInitContainerName)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the logs of the plan")
+		}
+		plan.Checksum = terraform.ParsePlanChecksum(logs)
+		plan.Changes = terraform.ParsePlannedChanges(logs)
+		if meta.CostEstimator != nil {
+			if plan.CostEstimate, err = terraform.ParseCostEstimate(logs, meta.CostEstimator); err != nil {
+				klog.ErrorS(err, "Failed to estimate the cost of the plan", "Name", meta.Name, "Namespace", meta.Namespace)
+			}
+		}
+		plan.State = types.PlanPendingApproval
+		plan.Message = fmt.Sprintf(types.MessagePlanPendingApproval, plan.Checksum)
+		if plan.Checksum == "" {
+			plan.State = types.PlanFailed
+			plan.Message = "the checksum of the plan is not found in the logs of the plan job"
+		}
+		if warnings := terraform.ParseTerraformWarnings(logs); meta.WarningsAsErrors && len(warnings) > 0 {
+			plan.State = types.PlanFailed
+			plan.Message = (&terraform.WarningsError{Warnings: warnings}).Error()
+		}
+	} else {
+		state, err := terraform.GetTerraformStatus(ctx, meta.Namespace, job.Name, terraformContainerName, terraformInitContainerName)
+		if err == nil || state == types.ConfigurationProvisioningAndChecking {
+			return errors.New(types.MessagePlanRunning)
+		}
+		plan.State = types.PlanFailed
+		plan.Message = err.Error()
+	}
+	if err := meta.updatePlanStatus(ctx, r.Client, plan); err != nil {
+		return err
+	}
+	if err := meta.updateApplyStatus(ctx, r.Client, plan.State, plan.Message); err != nil {
+		return err
+	}
+	if err := r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if plan.State == types.PlanFailed {
+		return errors.New(plan.Message)
+	}
+	return errors.New(types.ErrPlanNotApproved)
+}
+
+// discardStalePlan discards the approved plan and the apply job when the changes planned by the apply job differ from
+// the plan, so the changes are planned again for approval
+func (r *ConfigurationReconciler) discardStalePlan(ctx context.Context, meta *TFConfigurationMeta) error {
+	if err := meta.updatePlanStatus(ctx, r.Client, nil); err != nil {
+		return err
+	}
+	var job batchv1.Job
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.ApplyJobName, Namespace: meta.Namespace}, &job); err == nil {
+		if err := r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+	}
+	return nil
+}
+
+// checkApplySchedule checks whether the changes of the Configuration could be applied now according to
+// spec.ApplySchedule. If not, the Configuration is marked as pending until the next time of the schedule, and the
+// duration to wait is returned.
+func (r *ConfigurationReconciler) checkApplySchedule(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) (time.Duration, error) {
+	// the self-heal is deferred to the time of the schedule in advance, or the drift is critical
+	if meta.ApplyNowToken != "" || meta.SelfHealToken != "" {
+		return 0, nil
+	}
+	needed, err := meta.isApplyNeeded(ctx, r.Client)
+	if err != nil || !needed {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	next := configuration.Status.Apply.NextScheduledApplyTime
+	if next == nil {
+		schedule, err := tfcfg.ParseSchedule(configuration.Spec.ApplySchedule)
+		if err != nil {
+			return 0, err
+		}
+		t := metav1.NewTime(schedule.Next(now))
+		next = &t
+	}
+	if !now.Before(next.Time) {
+		return 0, nil
+	}
+
+	meta.NextScheduledApplyTime = next
+	msg := fmt.Sprintf(types.MessagePendingScheduledApply, next.UTC().Format(time.RFC3339))
+	if err := meta.updateApplyStatus(ctx, r.Client, types.PendingScheduledApply, msg); err != nil {
+		return 0, err
+	}
+	return next.Sub(now), nil
+}
+
+// isApplyNeeded checks whether a Terraform apply job will be created, which is when the job doesn't exist, or the
+// Configuration or its variables change
+func (meta *TFConfigurationMeta) isApplyNeeded(ctx context.Context, k8sClient client.Client) (bool, error) {
+	if meta.EnvChanged || meta.ConfigurationChanged {
+		return true, nil
+	}
+	var job batchv1.Job
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.ApplyJobName, Namespace: meta.Namespace}, &job); err != nil {
+		if kerrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
+
 // TFConfigurationMeta is all the metadata of a Configuration
 type TFConfigurationMeta struct {
 	Name                  string
 	Namespace             string
 	ConfigurationType     types.ConfigurationType
 	CompleteConfiguration string
-	RemoteGit             string
-	RemoteGitPath         string
-	ConfigurationChanged  bool
-	EnvChanged            bool
-	ConfigurationCMName   string
-	BackendSecretName     string
-	ApplyJobName          string
-	DestroyJobName        string
-	Envs                  []v1.EnvVar
-	ProviderReference     *crossplane.Reference
-	VariableSecretName    string
-	VariableSecretData    map[string][]byte
-	DeleteResource        bool
-	Credentials           map[string]string
+	// DefaultTagsFileName and DefaultTagsConfiguration are the file name and content of the provider block which
+	// carries the default tags of the Provider
+	DefaultTagsFileName      string
+	DefaultTagsConfiguration string
+	RemoteGit                string
+	RemoteGitPath            string
+	ConfigurationChanged     bool
+	EnvChanged               bool
+	ConfigurationHash        string
+	ConfigurationCMName      string
+	BackendSecretName        string
+	ApplyJobName             string
+	DestroyJobName           string
+	DestroyPreviewJobName    string
+	PlanJobName              string
+	DriftCheckJobName        string
+	PreDestroyHookJobName    string
+	Envs                     []v1.EnvVar
+	ProviderReference        *crossplane.Reference
+	VariableSecretName       string
+	VariableSecretData       map[string][]byte
+	DeleteResource           bool
+	Credentials              map[string]string
+	ExtraApplyArgs           []string
+	ExtraDestroyArgs         []string
+	SkipDestroy              []string
+	LockTimeout              string
+	// UntaintResources are the resources of the annotation UntaintAnnotation which are untainted before the apply, and
+	// UntaintToken is the value of the annotation
+	UntaintResources []string
+	UntaintToken     string
+	// LogLevel is the log level of the annotation LogLevelAnnotation, which is TF_LOG of the Terraform jobs, and
+	// LogVerbosity is the verbosity of the logs of the reconcile for it
+	LogLevel     string
+	LogVerbosity klog.Level
+	// PostApplyDelay is spec.PostApplyDelay, and PostApplyWait is how long the outputs still wait for it after the
+	// apply job completes
+	PostApplyDelay time.Duration
+	PostApplyWait  time.Duration
+	// StateOperations are the operations of spec.StateOperations which haven't completed, they run before the apply
+	StateOperations []v1beta2.StateOperation
+
+	// SavedPlan is spec.SavedPlan, ApprovedPlanChecksum is the checksum of the approved plan which the apply job applies
+	SavedPlan            bool
+	ApprovedPlanChecksum string
+	// WarningsAsErrors is spec.WarningsAsErrors
+	WarningsAsErrors bool
+
+	// RegistryPreflightEndpoint is the endpoint requested before `terraform init`, no check runs if it's empty
+	RegistryPreflightEndpoint string
+
+	// WorkingDirectoryCleanupPolicy determines when the working directory of the executor is wiped
+	WorkingDirectoryCleanupPolicy v1beta2.WorkingDirectoryCleanupPolicy
+	// VendoredModules is the pre-populated modules which `terraform init` uses instead of fetching them
+	VendoredModules *v1beta2.VendoredModules
+	// PluginCacheClaimName is the PersistentVolumeClaim of the provider plugin cache, no cache is used if it's empty
+	PluginCacheClaimName string
+	// WorkingDirectoryClaimName is the PersistentVolumeClaim of the persisted working directory of the apply and
+	// destroy jobs, which is an emptyDir if it's empty
+	WorkingDirectoryClaimName string
+	// WorkingDirectoryHash is the hash of the source and the providers which the persisted working directory is
+	// initialized with
+	WorkingDirectoryHash string
+
+	// ProviderLockFile is the dependency lock file of Terraform from spec.ProviderLockConfigMapRef
+	ProviderLockFile string
+	// ProviderChecksumAllowlist is the checksums of the allowlist ConfigMap ProviderChecksumAllowlistRef of
+	// ProviderChecksumAllowlistEnv, one per line. The provider plugins are not verified if it's empty.
+	ProviderChecksumAllowlist    string
+	ProviderChecksumAllowlistRef string
+	// ProviderConfigFileName and ProviderConfiguration are the file name and content of the verbatim provider config
+	// of the Provider
+	ProviderConfigFileName string
+	ProviderConfiguration  string
+	// NetrcSecretName is the Secret of spec.NetrcSecretRef, and NetrcSecrets are the logins, passwords and accounts in
+	// its .netrc, which are redacted from the logs
+	NetrcSecretName string
+	NetrcSecrets    []string
+
+	// LastReconcileReason explains the outcome of the reconcile, it's recorded to status.LastReconcileReason
+	LastReconcileReason types.ReconcileReason
+
+	// RemoteGitRef is the ref of the git repo to check out, and RemoteGitCredentialsSecret is the secret of the
+	// username and password to clone the git repo
+	RemoteGitRef               string
+	RemoteGitCredentialsSecret string
+
+	// ApplyDiagnostics are the errors parsed from the output of the failed `terraform apply`
+	ApplyDiagnostics []v1beta2.Diagnostic
+	// NextScheduledApplyTime is the time when the pending changes are applied according to spec.ApplySchedule
+	NextScheduledApplyTime *metav1.Time
+	// ApplyPhase is the phase reached by the running apply job
+	ApplyPhase types.ApplyPhase
+	// Region is the resolved region of the Configuration, which is recorded to the status once the cloud resources
+	// are deployed
+	Region string
+	// ApplyNowToken is the value of the annotation which requests an out-of-band apply
+	ApplyNowToken string
+	// RefreshSecretsToken is the value of the annotation which requests to refresh the secret of the variables
+	RefreshSecretsToken string
+	// BookkeepingStorage is where the hash of the deployed Configuration, the saved plan and the destroy preview are
+	// stored
+	BookkeepingStorage string
+	// DestroyPreviewToken is the value of the annotation which requests a preview of the destroy
+	DestroyPreviewToken string
+	// SelfHealToken marks the apply job which corrects the latest drift, it's empty unless status.drift is SelfHealing
+	SelfHealToken string
+
+	// MaxConcurrentJobs and MaxConcurrentJobsPerProvider limit the number of running Terraform jobs, 0 means no limit
+	MaxConcurrentJobs            int
+	MaxConcurrentJobsPerProvider int
+	// ProviderAccount is the cloud account of the Provider, the jobs of the same account share the limit of a Provider
+	ProviderAccount string
+	// ProviderProfile is the credential profile of the Provider, the default credentials are used if it's empty
+	ProviderProfile string
+	// Priority is the priority of the Configuration to get a job slot when the concurrency limit is reached
+	Priority v1beta2.Priority
+	// CredentialsExpiration is the expiration of the temporary credentials, it's nil if the credentials don't expire
+	CredentialsExpiration *metav1.Time
+	// CredentialsExpiryThreshold is the duration before CredentialsExpiration in which no apply starts
+	CredentialsExpiryThreshold time.Duration
+	// CancelGracePeriod is the termination grace period of the apply and destroy jobs, in which Terraform stops
+	// gracefully after it's interrupted
+	CancelGracePeriod time.Duration
+	// CostEstimator estimates the cost of the saved plan in the plan job, the cost is not estimated if it's nil
+	CostEstimator terraform.CostEstimator
 
 	// TerraformImage is the Terraform image which can run `terraform init/plan/apply`
 	TerraformImage            string
 	TerraformBackendNamespace string
 	BusyboxImage              string
 	GitImage                  string
+	// ServiceAccountName is the ServiceAccount of the Terraform job, the ServiceAccount created by the controller is
+	// used if it's empty
+	ServiceAccountName string
 
 	// Resources series Variables are for Setting Compute Resources required by this container
 	ResourcesLimitsCPU              string
@@ -234,45 +1022,81 @@ type TFConfigurationMeta struct {
 
 func initTFConfigurationMeta(req ctrl.Request, configuration v1beta2.Configuration) *TFConfigurationMeta {
 	var meta = &TFConfigurationMeta{
-		Namespace:           req.Namespace,
-		Name:                req.Name,
-		ConfigurationCMName: fmt.Sprintf(TFInputConfigMapName, req.Name),
-		VariableSecretName:  fmt.Sprintf(TFVariableSecret, req.Name),
-		ApplyJobName:        req.Name + "-" + string(TerraformApply),
-		DestroyJobName:      req.Name + "-" + string(TerraformDestroy),
+		Namespace:             req.Namespace,
+		Name:                  req.Name,
+		ConfigurationCMName:   fmt.Sprintf(TFInputConfigMapName, req.Name),
+		VariableSecretName:    fmt.Sprintf(TFVariableSecret, req.Name),
+		ApplyJobName:          req.Name + "-" + string(TerraformApply),
+		DestroyJobName:        req.Name + "-" + string(TerraformDestroy),
+		DestroyPreviewJobName: req.Name + "-" + string(TerraformDestroyPreview),
+		PlanJobName:           req.Name + "-" + string(TerraformPlan),
+		DriftCheckJobName:     req.Name + "-" + string(TerraformDriftCheck),
+		PreDestroyHookJobName: req.Name + "-pre-destroy",
 	}
 
 	// githubBlocked mark whether GitHub is blocked in the cluster
-	githubBlockedStr := os.Getenv("GITHUB_BLOCKED")
-	if githubBlockedStr == "" {
-		githubBlockedStr = "false"
+	meta.RemoteGitPath = "."
+	if gitRemote := tfcfg.GetGitRemote(&configuration); gitRemote != nil {
+		meta.RemoteGit = tfcfg.ReplaceTerraformSource(gitRemote.URL, tfcfg.GetGithubBlocked())
+		meta.RemoteGitRef = gitRemote.Ref
+		if gitRemote.Path != "" {
+			meta.RemoteGitPath = gitRemote.Path
+		}
+		if gitRemote.CredentialsSecretRef != nil {
+			meta.RemoteGitCredentialsSecret = gitRemote.CredentialsSecretRef.Name
+		}
 	}
-
-	meta.RemoteGit = tfcfg.ReplaceTerraformSource(configuration.Spec.Remote, githubBlockedStr)
 	meta.DeleteResource = configuration.Spec.DeleteResource
-	if configuration.Spec.Path == "" {
-		meta.RemoteGitPath = "."
-	} else {
-		meta.RemoteGitPath = configuration.Spec.Path
+	meta.ApplyNowToken = configuration.Annotations[tfcfg.ApplyNowAnnotation]
+	meta.RefreshSecretsToken = configuration.Annotations[tfcfg.RefreshSecretsAnnotation]
+	meta.BookkeepingStorage = GetBookkeepingStorage()
+	meta.DestroyPreviewToken = configuration.Annotations[tfcfg.DestroyPreviewAnnotation]
+	meta.SelfHealToken = getSelfHealToken(&configuration)
+	meta.SavedPlan = configuration.Spec.SavedPlan
+	meta.WarningsAsErrors = configuration.Spec.WarningsAsErrors
+	meta.ExtraApplyArgs = extraArgsToStrings(configuration.Spec.ExtraApplyArgs)
+	meta.ExtraDestroyArgs = extraArgsToStrings(configuration.Spec.ExtraDestroyArgs)
+	meta.SkipDestroy = configuration.Spec.SkipDestroy
+	meta.UntaintResources = tfcfg.GetUntaintResources(&configuration)
+	meta.UntaintToken = configuration.Annotations[tfcfg.UntaintAnnotation]
+	meta.StateOperations = tfcfg.GetPendingStateOperations(&configuration)
+	if preflight := configuration.Spec.RegistryPreflight; preflight != nil {
+		meta.RegistryPreflightEndpoint = preflight.Endpoint
+		if meta.RegistryPreflightEndpoint == "" {
+			meta.RegistryPreflightEndpoint = defaultRegistryPreflightEndpoint
+		}
+	}
+	meta.LockTimeout = configuration.Spec.LockTimeout
+	// an unknown log level fails the static check
+	if level := tfcfg.GetLogLevel(&configuration); level != "" {
+		if verbosity, ok := tfcfg.LogVerbosity(level); ok {
+			meta.LogLevel = level
+			meta.LogVerbosity = verbosity
+		}
+	}
+	// spec.PostApplyDelay is validated in the static check
+	meta.PostApplyDelay, _ = time.ParseDuration(configuration.Spec.PostApplyDelay)
+	meta.WorkingDirectoryCleanupPolicy = configuration.Spec.WorkingDirectoryCleanupPolicy
+	meta.Priority = configuration.Spec.Priority
+	meta.VendoredModules = configuration.Spec.VendoredModules
+	meta.WorkingDirectoryClaimName = configuration.Spec.WorkingDirectoryClaimName
+	if configuration.Spec.NetrcSecretRef != nil {
+		meta.NetrcSecretName = configuration.Spec.NetrcSecretRef.Name
 	}
 
 	meta.ProviderReference = tfcfg.GetProviderNamespacedName(configuration)
 
 	// Check the existence of Terraform state secret which is used to store TF state file. For detailed information,
 	// please refer to https://www.terraform.io/docs/language/settings/backends/kubernetes.html#configuration-variables
-	var backendSecretSuffix string
-	if configuration.Spec.Backend != nil && configuration.Spec.Backend.SecretSuffix != "" {
-		backendSecretSuffix = configuration.Spec.Backend.SecretSuffix
-	} else {
-		backendSecretSuffix = configuration.Name
-	}
 	// Secrets will be named in the format: tfstate-{workspace}-{secret_suffix}
-	meta.BackendSecretName = fmt.Sprintf(TFBackendSecret, terraformWorkspace, backendSecretSuffix)
+	meta.BackendSecretName = fmt.Sprintf(TFBackendSecret, terraformWorkspace, tfcfg.BackendSecretSuffix(&configuration))
 
 	return meta
 }
 
-func (r *ConfigurationReconciler) terraformApply(ctx context.Context, namespace string, configuration v1beta2.Configuration, meta *TFConfigurationMeta) error {
+func (r *ConfigurationReconciler) terraformApply(ctx context.Context, namespace string, configuration v1beta2.Configuration, meta *TFConfigurationMeta) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "TerraformApply", meta.Namespace, meta.Name)
+	defer func() { tracing.EndSpan(span, err) }()
 	klog.InfoS("terraform apply job", "Namespace", namespace, "Name", meta.ApplyJobName)
 
 	var (
@@ -282,24 +1106,74 @@ func (r *ConfigurationReconciler) terraformApply(ctx context.Context, namespace
 
 	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.ApplyJobName, Namespace: namespace}, &tfExecutionJob); err != nil {
 		if kerrors.IsNotFound(err) {
+			if err := meta.checkBackendCollision(ctx, k8sClient, &configuration); err != nil {
+				return err
+			}
+			if err := meta.migrateState(ctx, k8sClient, &configuration); err != nil {
+				return err
+			}
+			if err := meta.checkExistingState(ctx, k8sClient, &configuration); err != nil {
+				return err
+			}
+			if meta.isCredentialsExpiringSoon() {
+				msg := fmt.Sprintf(types.MessageCredentialsExpiringSoon, meta.CredentialsExpiration.Format(time.RFC3339))
+				if err := meta.updateApplyStatus(ctx, k8sClient, types.CredentialsExpiringSoon, msg); err != nil {
+					return err
+				}
+				return errors.New(types.ErrCredentialsExpiringSoon)
+			}
+			reached, err := meta.isConcurrencyLimitReached(ctx, k8sClient)
+			if err != nil {
+				return err
+			}
+			if reached {
+				if err := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationPendingOnConcurrency, types.MessageConcurrencyLimitReached); err != nil {
+					return err
+				}
+				return errors.New(types.MessageConcurrencyLimitReached)
+			}
+			if meta.SavedPlan {
+				if err := r.checkSavedPlan(ctx, &configuration, meta); err != nil {
+					return err
+				}
+			}
+			meta.LastReconcileReason = types.ReconcileApplyTriggered
 			return meta.assembleAndTriggerJob(ctx, k8sClient, TerraformApply)
 		}
 	}
 
+	meta.V(4).InfoS("found the Terraform apply job", "Name", meta.ApplyJobName, "Namespace", namespace,
+		"Active", tfExecutionJob.Status.Active, "Succeeded", tfExecutionJob.Status.Succeeded, "Failed", tfExecutionJob.Status.Failed)
 	if err := meta.updateTerraformJobIfNeeded(ctx, k8sClient, tfExecutionJob); err != nil {
 		klog.ErrorS(err, types.ErrUpdateTerraformApplyJob, "Name", meta.ApplyJobName)
 		return errors.Wrap(err, types.ErrUpdateTerraformApplyJob)
 	}
+	// the paused job is replaced once the Configuration changes or the annotation apply-now is set
+	if !meta.EnvChanged && !meta.ConfigurationChanged && isJobPaused(&tfExecutionJob) {
+		return errors.New(types.ErrFatalApplyError)
+	}
 
 	if !meta.EnvChanged && tfExecutionJob.Status.Succeeded == int32(1) {
+		if wait := meta.postApplyWait(&tfExecutionJob, time.Now()); wait > 0 {
+			meta.PostApplyWait = wait
+			msg := fmt.Sprintf(types.MessageWaitingForPostApplyDelay, wait.Round(time.Second))
+			if err := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationProvisioningAndChecking, msg); err != nil {
+				return err
+			}
+			return errors.New(types.ErrWaitingForPostApplyDelay)
+		}
+		meta.LastReconcileReason = types.ReconcileNoChange
 		if err := meta.updateApplyStatus(ctx, k8sClient, types.Available, types.MessageCloudResourceDeployed); err != nil {
 			return err
 		}
 	} else {
+		meta.LastReconcileReason = types.ReconcileApplyInProgress
+		meta.ApplyPhase = meta.getApplyPhase(ctx, &configuration)
 		// start provisioning and check the status of the provision
 		// If the state is types.InvalidRegion, no need to continue checking
-		if configuration.Status.Apply.State != types.ConfigurationProvisioningAndChecking &&
-			configuration.Status.Apply.State != types.InvalidRegion {
+		state := configuration.Status.Apply.State
+		if (state != types.ConfigurationProvisioningAndChecking && state != types.InvalidRegion) ||
+			(state == types.ConfigurationProvisioningAndChecking && meta.ApplyPhase != configuration.Status.Apply.Phase) {
 			if err := meta.updateApplyStatus(ctx, r.Client, types.ConfigurationProvisioningAndChecking, types.MessageCloudResourceProvisioningAndChecking); err != nil {
 				return err
 			}
@@ -308,7 +1182,9 @@ func (r *ConfigurationReconciler) terraformApply(ctx context.Context, namespace
 	return nil
 }
 
-func (r *ConfigurationReconciler) terraformDestroy(ctx context.Context, namespace string, configuration v1beta2.Configuration, meta *TFConfigurationMeta) error {
+func (r *ConfigurationReconciler) terraformDestroy(ctx context.Context, namespace string, configuration v1beta2.Configuration, meta *TFConfigurationMeta) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "TerraformDestroy", meta.Namespace, meta.Name)
+	defer func() { tracing.EndSpan(span, err) }()
 	var (
 		destroyJob batchv1.Job
 		k8sClient  = r.Client
@@ -316,15 +1192,55 @@ func (r *ConfigurationReconciler) terraformDestroy(ctx context.Context, namespac
 
 	deletable, err := tfcfg.IsDeletable(ctx, k8sClient, &configuration)
 	if err != nil {
+		var blockedErr *tfcfg.DestroyBlockedError
+		switch {
+		case err.Error() == types.MessageDeletionProtected:
+			if updateErr := meta.updateDestroyStatus(ctx, k8sClient, types.ConfigurationDestroyBlocked, err.Error()); updateErr != nil {
+				return updateErr
+			}
+		case errors.As(err, &blockedErr):
+			if updateErr := meta.updateDestroyStatus(ctx, k8sClient, blockedErr.State, blockedErr.Message); updateErr != nil {
+				return updateErr
+			}
+		}
 		return err
 	}
 
 	deleteConfigurationDirectly := deletable || !meta.DeleteResource
 
 	if !deleteConfigurationDirectly {
+		if err := meta.checkDestroyBackend(ctx, k8sClient, &configuration); err != nil {
+			var blockedErr *tfcfg.DestroyBlockedError
+			if errors.As(err, &blockedErr) {
+				if updateErr := meta.updateDestroyStatus(ctx, k8sClient, blockedErr.State, blockedErr.Message); updateErr != nil {
+					return updateErr
+				}
+			}
+			return err
+		}
 		if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.DestroyJobName, Namespace: meta.Namespace}, &destroyJob); err != nil {
 			if kerrors.IsNotFound(err) {
+				if err := meta.cancelApplyJob(ctx, k8sClient); err != nil {
+					return err
+				}
 				if err := r.Client.Get(ctx, client.ObjectKey{Name: configuration.Name, Namespace: configuration.Namespace}, &v1beta2.Configuration{}); err == nil {
+					completed, err := meta.runPreDestroyHook(ctx, k8sClient, &configuration)
+					if err != nil {
+						return err
+					}
+					if !completed {
+						return errors.New(types.MessageDestroyJobNotCompleted)
+					}
+					reached, err := meta.isConcurrencyLimitReached(ctx, k8sClient)
+					if err != nil {
+						return err
+					}
+					if reached {
+						if err := meta.updateDestroyStatus(ctx, k8sClient, types.ConfigurationPendingOnConcurrency, types.MessageConcurrencyLimitReached); err != nil {
+							return err
+						}
+						return errors.New(types.MessageConcurrencyLimitReached)
+					}
 					if err = meta.assembleAndTriggerJob(ctx, k8sClient, TerraformDestroy); err != nil {
 						return err
 					}
@@ -342,11 +1258,21 @@ func (r *ConfigurationReconciler) terraformDestroy(ctx context.Context, namespac
 		return err
 	}
 
-	// When the deletion Job process succeeded, clean up work is starting.
+	// When the deletion Job process succeeded, clean up work is starting. There is no destroy job if the Configuration
+	// is deleted directly.
 	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.DestroyJobName, Namespace: meta.Namespace}, &destroyJob); err != nil {
-		return err
+		if !deleteConfigurationDirectly || !kerrors.IsNotFound(err) {
+			return err
+		}
 	}
 	if destroyJob.Status.Succeeded == int32(1) || deleteConfigurationDirectly {
+		if destroyJob.Status.Succeeded == int32(1) && len(meta.SkipDestroy) > 0 {
+			klog.InfoS("Resources are orphaned instead of destroyed", "Namespace", meta.Namespace, "Name", meta.Name, "Resources", meta.SkipDestroy)
+			if err := meta.updateOrphanedResources(ctx, k8sClient); err != nil {
+				return err
+			}
+		}
+
 		// 1. delete Terraform input Configuration ConfigMap
 		if err := meta.deleteConfigMap(ctx, k8sClient); err != nil {
 			return err
@@ -377,7 +1303,35 @@ func (r *ConfigurationReconciler) terraformDestroy(ctx context.Context, namespac
 			}
 		}
 
-		// 5. delete secret which stores variables
+		// 5. delete pre-destroy hook job
+		var hookJob batchv1.Job
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.PreDestroyHookJobName, Namespace: meta.Namespace}, &hookJob); err == nil {
+			if err := r.Client.Delete(ctx, &hookJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+				return err
+			}
+		}
+
+		// 6. delete destroy preview, plan and drift check job
+		var previewJob batchv1.Job
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.DestroyPreviewJobName, Namespace: meta.Namespace}, &previewJob); err == nil {
+			if err := r.Client.Delete(ctx, &previewJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+				return err
+			}
+		}
+		var planJob batchv1.Job
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.PlanJobName, Namespace: meta.Namespace}, &planJob); err == nil {
+			if err := r.Client.Delete(ctx, &planJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+				return err
+			}
+		}
+		var driftJob batchv1.Job
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.DriftCheckJobName, Namespace: meta.Namespace}, &driftJob); err == nil {
+			if err := r.Client.Delete(ctx, &driftJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+				return err
+			}
+		}
+
+		// 7. delete secret which stores variables
 		klog.InfoS("Deleting the secret which stores variables", "Name", meta.VariableSecretName)
 		var variableSecret v1.Secret
 		if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.VariableSecretName, Namespace: meta.Namespace}, &variableSecret); err == nil {
@@ -386,12 +1340,28 @@ func (r *ConfigurationReconciler) terraformDestroy(ctx context.Context, namespac
 			}
 		}
 
-		// 6. delete Kubernetes backend secret
-		klog.InfoS("Deleting the secret which stores Kubernetes backend", "Name", meta.BackendSecretName)
-		var kubernetesBackendSecret v1.Secret
-		if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.BackendSecretName, Namespace: meta.TerraformBackendNamespace}, &kubernetesBackendSecret); err == nil {
-			if err := r.Client.Delete(ctx, &kubernetesBackendSecret); err != nil {
-				return err
+		// 8. delete the Role and RoleBinding of the ServiceAccount set by users
+		if err := deleteTerraformExecutorRole(ctx, k8sClient, meta); err != nil {
+			return err
+		}
+
+		// 9. delete Kubernetes backend secret, there is none if the state is stored locally. The secret which stores the
+		// state of another Configuration is kept.
+		if !tfcfg.IsLocalBackend(&configuration) {
+			var kubernetesBackendSecret v1.Secret
+			if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.BackendSecretName, Namespace: meta.TerraformBackendNamespace}, &kubernetesBackendSecret); err == nil {
+				owned, err := meta.ownsBackendSecret(ctx, k8sClient, &configuration, &kubernetesBackendSecret)
+				if err != nil {
+					return err
+				}
+				if !owned {
+					klog.InfoS("Keeping the secret of Kubernetes backend which is not owned by the Configuration", "Name", meta.BackendSecretName)
+					return nil
+				}
+				klog.InfoS("Deleting the secret which stores Kubernetes backend", "Name", meta.BackendSecretName)
+				if err := r.Client.Delete(ctx, &kubernetesBackendSecret); err != nil {
+					return err
+				}
 			}
 		}
 		return nil
@@ -399,6 +1369,114 @@ func (r *ConfigurationReconciler) terraformDestroy(ctx context.Context, namespac
 	return errors.New(types.MessageDestroyJobNotCompleted)
 }
 
+// runPreDestroyHook runs the Job of spec.PreDestroyHook before the destroy job is created. It returns true if there is
+// no hook or the hook Job succeeded. The destroy is blocked if the hook Job failed.
+func (meta *TFConfigurationMeta) runPreDestroyHook(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) (bool, error) {
+	hook := configuration.Spec.PreDestroyHook
+	if hook == nil {
+		return true, nil
+	}
+
+	var job batchv1.Job
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.PreDestroyHookJobName, Namespace: meta.Namespace}, &job); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return false, err
+		}
+		job = batchv1.Job{
+			ObjectMeta: *hook.Template.ObjectMeta.DeepCopy(),
+			Spec:       *hook.Template.Spec.DeepCopy(),
+		}
+		job.Name = meta.PreDestroyHookJobName
+		job.Namespace = meta.Namespace
+		if job.Labels == nil {
+			job.Labels = map[string]string{}
+		}
+		for k, v := range meta.jobLabels() {
+			job.Labels[k] = v
+		}
+		// the hook job is garbage collected with the Configuration, in case it's deleted without the finalizer
+		if configuration.UID != "" {
+			job.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: v1beta2.GroupVersion.String(),
+				Kind:       "Configuration",
+				Name:       configuration.Name,
+				UID:        configuration.UID,
+			}}
+		}
+		klog.InfoS("Creating the pre-destroy hook job", "Name", job.Name, "Namespace", job.Namespace)
+		if err := k8sClient.Create(ctx, &job); err != nil {
+			return false, errors.Wrap(err, "failed to create the pre-destroy hook job")
+		}
+		return false, meta.updateDestroyStatus(ctx, k8sClient, types.ConfigurationDestroying, types.MessagePreDestroyHookRunning)
+	}
+
+	if job.Status.Succeeded > 0 {
+		return true, nil
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == v1.ConditionTrue {
+			message := fmt.Sprintf(types.MessagePreDestroyHookFailed, job.Name, condition.Message)
+			if err := meta.updateDestroyStatus(ctx, k8sClient, types.ConfigurationDestroyBlocked, message); err != nil {
+				return false, err
+			}
+			return false, &tfcfg.DestroyBlockedError{State: types.ConfigurationDestroyBlocked, Message: message}
+		}
+	}
+	return false, meta.updateDestroyStatus(ctx, k8sClient, types.ConfigurationDestroying, types.MessagePreDestroyHookRunning)
+}
+
+func (r *ConfigurationReconciler) preCheckConcurrencySetting(meta *TFConfigurationMeta) error {
+	for env, limit := range map[string]*int{
+		MaxConcurrentJobsEnv:            &meta.MaxConcurrentJobs,
+		MaxConcurrentJobsPerProviderEnv: &meta.MaxConcurrentJobsPerProvider,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			errMsg := fmt.Sprintf("failed to parse env variable %s into a non-negative integer", env)
+			klog.ErrorS(err, errMsg)
+			return errors.New(errMsg)
+		}
+		*limit = parsed
+	}
+	return nil
+}
+
+func (r *ConfigurationReconciler) preCheckCredentialsExpirySetting(meta *TFConfigurationMeta) error {
+	meta.CredentialsExpiryThreshold = defaultCredentialsExpiryThreshold
+	value := os.Getenv(CredentialsExpiryThresholdEnv)
+	if value == "" {
+		return nil
+	}
+	threshold, err := time.ParseDuration(value)
+	if err != nil || threshold < 0 {
+		errMsg := fmt.Sprintf("failed to parse env variable %s into a non-negative duration", CredentialsExpiryThresholdEnv)
+		klog.ErrorS(err, errMsg)
+		return errors.New(errMsg)
+	}
+	meta.CredentialsExpiryThreshold = threshold
+	return nil
+}
+
+func (r *ConfigurationReconciler) preCheckCancelGracePeriodSetting(meta *TFConfigurationMeta) error {
+	meta.CancelGracePeriod = defaultCancelGracePeriod
+	value := os.Getenv(CancelGracePeriodEnv)
+	if value == "" {
+		return nil
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period < 0 {
+		errMsg := fmt.Sprintf("failed to parse env variable %s into a non-negative duration", CancelGracePeriodEnv)
+		klog.ErrorS(err, errMsg)
+		return errors.New(errMsg)
+	}
+	meta.CancelGracePeriod = period
+	return nil
+}
+
 func (r *ConfigurationReconciler) preCheckResourcesSetting(meta *TFConfigurationMeta) error {
 
 	meta.ResourcesLimitsCPU = os.Getenv("RESOURCES_LIMITS_CPU")
@@ -444,19 +1522,21 @@ func (r *ConfigurationReconciler) preCheckResourcesSetting(meta *TFConfiguration
 	return nil
 }
 
-func (r *ConfigurationReconciler) preCheck(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) error {
+func (r *ConfigurationReconciler) preCheck(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "PreCheck", meta.Namespace, meta.Name)
+	defer func() { tracing.EndSpan(span, err) }()
 	var k8sClient = r.Client
 
 	meta.TerraformImage = os.Getenv("TERRAFORM_IMAGE")
 	if meta.TerraformImage == "" {
 		meta.TerraformImage = "oamdev/docker-terraform:1.1.2"
 	}
-
-	meta.TerraformBackendNamespace = os.Getenv("TERRAFORM_BACKEND_NAMESPACE")
-	if meta.TerraformBackendNamespace == "" {
-		meta.TerraformBackendNamespace = "vela-system"
+	if configuration.Spec.RunnerImage != "" {
+		meta.TerraformImage = configuration.Spec.RunnerImage
 	}
 
+	meta.TerraformBackendNamespace = tfcfg.GetBackendNamespace()
+
 	meta.BusyboxImage = os.Getenv("BUSYBOX_IMAGE")
 	if meta.BusyboxImage == "" {
 		meta.BusyboxImage = "busybox:latest"
@@ -470,6 +1550,24 @@ func (r *ConfigurationReconciler) preCheck(ctx context.Context, configuration *v
 		return err
 	}
 
+	if err := r.preCheckConcurrencySetting(meta); err != nil {
+		return err
+	}
+
+	if err := r.preCheckCredentialsExpirySetting(meta); err != nil {
+		return err
+	}
+
+	if err := r.preCheckCancelGracePeriodSetting(meta); err != nil {
+		return err
+	}
+
+	estimator, err := terraform.GetCostEstimator()
+	if err != nil {
+		return err
+	}
+	meta.CostEstimator = estimator
+
 	// Validation: 1) validate Configuration itself
 	configurationType, err := tfcfg.ValidConfigurationObject(configuration)
 	if err != nil {
@@ -480,15 +1578,151 @@ func (r *ConfigurationReconciler) preCheck(ctx context.Context, configuration *v
 	}
 	meta.ConfigurationType = configurationType
 
+	if err := tfcfg.ValidNamespaceScope(configuration, meta.ProviderReference.Namespace); err != nil {
+		if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+
+	if configuration.Spec.ApplySchedule != "" {
+		if _, err := tfcfg.ParseSchedule(configuration.Spec.ApplySchedule); err != nil {
+			if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+				return updateErr
+			}
+			return err
+		}
+	}
+
+	if configuration.Spec.ValidateVariables && configurationType == types.ConfigurationHCL {
+		if err := tfcfg.ValidVariables(configuration); err != nil {
+			if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.VariableTypeMismatch, err.Error()); updateErr != nil {
+				return updateErr
+			}
+			return err
+		}
+	}
+
+	meta.ServiceAccountName = os.Getenv(ExecutorServiceAccountEnv)
+	if configuration.Spec.ServiceAccountName != "" {
+		meta.ServiceAccountName = configuration.Spec.ServiceAccountName
+	}
+	meta.PluginCacheClaimName = os.Getenv(PluginCacheClaimEnv)
+	if configuration.Spec.PluginCacheClaimName != "" {
+		meta.PluginCacheClaimName = configuration.Spec.PluginCacheClaimName
+	}
+	if err := meta.checkServiceAccount(ctx, k8sClient); err != nil {
+		if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+
 	// TODO(zzxwill) Need to find an alternative to check whether there is an state backend in the Configuration
 
+	// The provider is needed to render its default tags, the result is checked after the configuration is stored
+	p, getProviderErr := provider.GetProviderFromConfiguration(ctx, k8sClient, configuration, meta.ProviderReference.Namespace, meta.ProviderReference.Name)
+	if p != nil {
+		meta.ProviderAccount = p.Spec.Account
+	}
+
+	if err := tfcfg.SetBackend(ctx, k8sClient, configuration, p); err != nil {
+		if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+
+	// The region in the templates of spec.Variable is substituted before the hash is computed, so changing the region
+	// changes the hash
+	if err := tfcfg.RenderVariables(configuration, p); err != nil {
+		if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+
+	// The hash is computed before rendering, which sets the default values of the Configuration
+	if meta.ConfigurationHash, err = tfcfg.ComputeConfigurationHash(configuration, p); err != nil {
+		return err
+	}
+
 	// Render configuration with backend
+	_, renderSpan := tracing.StartSpan(ctx, "RenderConfiguration", meta.Namespace, meta.Name)
 	completeConfiguration, err := tfcfg.RenderConfiguration(configuration, meta.TerraformBackendNamespace, configurationType)
+	tracing.EndSpan(renderSpan, err)
 	if err != nil {
+		if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+			return updateErr
+		}
 		return err
 	}
 	meta.CompleteConfiguration = completeConfiguration
 
+	providerConfigFileName, providerConfiguration, err := tfcfg.RenderProviderConfig(p, configuration.Spec.HCL)
+	if err != nil {
+		if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+	meta.ProviderConfigFileName = providerConfigFileName
+	meta.ProviderConfiguration = providerConfiguration
+
+	// the provider block of the provider config is also a declared one, which the default tags are merged into
+	defaultTagsFileName, defaultTagsConfiguration, err := tfcfg.RenderProviderDefaultTags(p, configuration.Spec.HCL+providerConfiguration)
+	if err != nil {
+		if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+	meta.DefaultTagsFileName = defaultTagsFileName
+	meta.DefaultTagsConfiguration = defaultTagsConfiguration
+
+	if configuration.Spec.ProviderLockConfigMapRef != nil {
+		lockFile, err := meta.getProviderLockFile(ctx, k8sClient, configuration.Spec.ProviderLockConfigMapRef.Name)
+		if err != nil {
+			if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+				return updateErr
+			}
+			return err
+		}
+		meta.ProviderLockFile = lockFile
+	}
+
+	if ref, err := GetProviderChecksumAllowlistRef(); err != nil || ref != nil {
+		var allowlist string
+		if err == nil {
+			allowlist, err = meta.getProviderChecksumAllowlist(ctx, k8sClient, *ref)
+		}
+		if err != nil {
+			if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+				return updateErr
+			}
+			return err
+		}
+		meta.ProviderChecksumAllowlist = allowlist
+		meta.ProviderChecksumAllowlistRef = ref.String()
+	}
+
+	if meta.NetrcSecretName != "" {
+		secrets, err := meta.getNetrc(ctx, k8sClient)
+		if err != nil {
+			if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, err.Error()); updateErr != nil {
+				return updateErr
+			}
+			return err
+		}
+		meta.NetrcSecrets = secrets
+	}
+
+	if meta.WorkingDirectoryClaimName != "" {
+		if meta.WorkingDirectoryHash, err = meta.computeWorkingDirectoryHash(p); err != nil {
+			return err
+		}
+	}
+
 	if err := meta.storeTFConfiguration(ctx, k8sClient); err != nil {
 		return err
 	}
@@ -508,11 +1742,17 @@ func (r *ConfigurationReconciler) preCheck(ctx context.Context, configuration *v
 	}
 
 	// Check provider
-	p, err := provider.GetProviderFromConfiguration(ctx, k8sClient, meta.ProviderReference.Namespace, meta.ProviderReference.Name)
+	var deniedErr *provider.AccessDeniedError
+	if errors.As(getProviderErr, &deniedErr) {
+		if updateStatusErr := meta.updateApplyStatus(ctx, k8sClient, types.ProviderAccessDenied, deniedErr.Error()); updateStatusErr != nil {
+			return errors.Wrap(updateStatusErr, deniedErr.Error())
+		}
+		return deniedErr
+	}
 	if p == nil {
 		msg := types.ErrProviderNotFound
-		if err != nil {
-			msg = err.Error()
+		if getProviderErr != nil {
+			msg = getProviderErr.Error()
 		}
 		if updateStatusErr := meta.updateApplyStatus(ctx, k8sClient, types.Authorizing, msg); updateStatusErr != nil {
 			return errors.Wrap(updateStatusErr, msg)
@@ -520,7 +1760,33 @@ func (r *ConfigurationReconciler) preCheck(ctx context.Context, configuration *v
 		return errors.New(msg)
 	}
 
+	if profile := configuration.Spec.ProviderProfile; profile != "" {
+		profiles, err := provider.GetProviderProfiles(ctx, k8sClient, p)
+		if err != nil {
+			return err
+		}
+		var found bool
+		for _, name := range profiles {
+			found = found || name == profile
+		}
+		if !found {
+			msg := fmt.Sprintf("the profile %s is not found in Provider %s/%s, available profiles are %s", profile,
+				p.Namespace, p.Name, strings.Join(profiles, ", "))
+			if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationStaticCheckFailed, msg); updateErr != nil {
+				return updateErr
+			}
+			return errors.New(msg)
+		}
+		meta.ProviderProfile = profile
+	}
+
 	if err := meta.getCredentials(ctx, k8sClient, p); err != nil {
+		var regionErr *tfcfg.RegionChangeError
+		if errors.As(err, &regionErr) {
+			if updateErr := meta.updateApplyStatus(ctx, k8sClient, types.RegionChangeNotAcknowledged, regionErr.Error()); updateErr != nil {
+				return updateErr
+			}
+		}
 		return err
 	}
 
@@ -529,9 +1795,25 @@ func (r *ConfigurationReconciler) preCheck(ctx context.Context, configuration *v
 		return err
 	}
 
+	if err := meta.prepareVariableSecret(ctx, k8sClient); err != nil {
+		return err
+	}
+
+	// Apply ClusterRole
+	return createTerraformExecutorClusterRole(ctx, k8sClient, fmt.Sprintf("%s-%s", meta.Namespace, ClusterRoleName))
+}
+
+// prepareVariableSecret copies the variables and the credentials of the Provider to the secret of the variables, which
+// is an env source of the Terraform jobs. The job's env is changed if the secret is stale.
+func (meta *TFConfigurationMeta) prepareVariableSecret(ctx context.Context, k8sClient client.Client) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "PrepareVariableSecret", meta.Namespace, meta.Name)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	var variableInSecret v1.Secret
 	err = k8sClient.Get(ctx, client.ObjectKey{Name: meta.VariableSecretName, Namespace: meta.Namespace}, &variableInSecret)
 	switch {
+	case kerrors.IsForbidden(err):
+		return meta.secretCopyForbidden(ctx, k8sClient, "get")
 	case kerrors.IsNotFound(err):
 		var secret = v1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
@@ -543,75 +1825,625 @@ func (r *ConfigurationReconciler) preCheck(ctx context.Context, configuration *v
 		}
 
 		if err := k8sClient.Create(ctx, &secret); err != nil {
+			if kerrors.IsForbidden(err) {
+				return meta.secretCopyForbidden(ctx, k8sClient, "create")
+			}
 			return err
 		}
 	case err == nil:
-		for k, v := range meta.VariableSecretData {
-			if val, ok := variableInSecret.Data[k]; !ok || !bytes.Equal(v, val) {
-				meta.EnvChanged = true
-				klog.Info("Job's env changed")
-				if err := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationReloading, types.ConfigurationReloadingAsVariableChanged); err != nil {
-					return err
+		if isSecretDataStale(variableInSecret.Data, meta.VariableSecretData) {
+			meta.EnvChanged = true
+			klog.Info("Job's env changed")
+			// the stale secret is updated, so a new job doesn't run with the stale credentials
+			variableInSecret.Data = meta.VariableSecretData
+			if err := k8sClient.Update(ctx, &variableInSecret); err != nil {
+				if kerrors.IsForbidden(err) {
+					return meta.secretCopyForbidden(ctx, k8sClient, "update")
 				}
-				break
+				return errors.Wrap(err, "failed to update the secret of the variables")
+			}
+			if err := meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationReloading, types.ConfigurationReloadingAsVariableChanged); err != nil {
+				return err
 			}
 		}
-	default:
-		return err
+	default:
+		return err
+	}
+	return nil
+}
+
+// secretCopyForbidden records the verb on the secret of the variables which the controller is forbidden to, so the
+// missing permission is clear from the status instead of a retried error
+func (meta *TFConfigurationMeta) secretCopyForbidden(ctx context.Context, k8sClient client.Client, verb string) error {
+	msg := fmt.Sprintf(types.MessageSecretCopyForbidden, verb, meta.VariableSecretName, meta.Namespace)
+	klog.InfoS(msg, "Name", meta.Name, "Namespace", meta.Namespace)
+	if err := meta.updateApplyStatus(ctx, k8sClient, types.SecretCopyForbidden, msg); err != nil {
+		return err
+	}
+	return errors.New(types.ErrSecretCopyForbidden)
+}
+
+// isSecretDataStale checks whether the data of a secret differs from the desired data, including the keys which are
+// not desired anymore
+func isSecretDataStale(data, desired map[string][]byte) bool {
+	if len(data) != len(desired) {
+		return true
+	}
+	for k, v := range desired {
+		if val, ok := data[k]; !ok || !bytes.Equal(v, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeRefreshSecretsAnnotation removes the annotation RefreshSecretsAnnotation once the secrets are refreshed, if
+// the token is not changed in the meantime
+func (meta *TFConfigurationMeta) removeRefreshSecretsAnnotation(ctx context.Context, k8sClient client.Client) error {
+	latest, err := tfcfg.Get(ctx, k8sClient, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace})
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if latest.Annotations[tfcfg.RefreshSecretsAnnotation] != meta.RefreshSecretsToken {
+		return nil
+	}
+	delete(latest.Annotations, tfcfg.RefreshSecretsAnnotation)
+	if err := k8sClient.Update(ctx, &latest); err != nil {
+		return errors.Wrap(err, "failed to remove the annotation of refreshing the secrets")
+	}
+	meta.RefreshSecretsToken = ""
+	return nil
+}
+
+func (meta *TFConfigurationMeta) updateApplyStatus(ctx context.Context, k8sClient client.Client, state types.ConfigurationState, message string) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "UpdateApplyStatus", meta.Namespace, meta.Name)
+	defer func() { tracing.EndSpan(span, err) }()
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err == nil {
+		configuration.Status.Apply = v1beta2.ConfigurationApplyStatus{
+			State:                  state,
+			Message:                message,
+			Diagnostics:            meta.ApplyDiagnostics,
+			NextScheduledApplyTime: meta.NextScheduledApplyTime,
+		}
+		// the phase is only recorded while the apply job runs, so it's cleared once the apply completes or fails
+		if state == types.ConfigurationProvisioningAndChecking {
+			configuration.Status.Apply.Phase = meta.ApplyPhase
+		}
+		configuration.Status.ObservedGeneration = configuration.Generation
+		if state == types.Available {
+			leaked := meta.findSensitiveLeaks(ctx, k8sClient, &configuration)
+			tfcfg.SetSensitiveLeakCondition(&configuration, leaked)
+			if len(leaked) > 0 && configuration.Spec.SensitiveLeakCheck == v1beta2.SensitiveLeakBlock {
+				// the outputs are not published, so the leaked values don't reach the connection secret and the
+				// output targets
+				state = types.SensitiveLeak
+				configuration.Status.Apply.State = state
+				configuration.Status.Apply.Message = fmt.Sprintf(types.MessageSensitiveLeak, strings.Join(leaked, ", "))
+			}
+		}
+		if state == types.Available {
+			outputs, err := meta.getTFOutputs(ctx, k8sClient, configuration)
+			if err != nil {
+				configuration.Status.Apply = v1beta2.ConfigurationApplyStatus{
+					State:   types.GeneratingOutputs,
+					Message: types.ErrGenerateOutputs + ": " + err.Error(),
+				}
+			} else {
+				configuration.Status.Apply.Outputs = outputs
+				configuration.Status.ConfigurationHash = meta.ConfigurationHash
+				if meta.isBookkeepingOffloaded() {
+					if err := meta.writeBookkeeping(ctx, k8sClient, &configuration, bookkeepingHashKey, meta.ConfigurationHash); err != nil {
+						return err
+					}
+					configuration.Status.ConfigurationHash = ""
+				}
+				configuration.Status.StateSecretRef = meta.stateSecretRef(&configuration)
+				if meta.Region != "" {
+					configuration.Status.Region = meta.Region
+				}
+			}
+		}
+		if state == types.Available || state == types.ConfigurationApplyFailed || state == types.RetryableApplyError ||
+			state == types.FatalApplyError {
+			meta.updateTaintedResources(ctx, k8sClient, &configuration)
+			meta.updateResourceCount(ctx, k8sClient, &configuration)
+		}
+		tfcfg.SetCondition(&configuration, tfcfg.ConditionApplied, configuration.Status.Apply.State, configuration.Status.Apply.Message)
+
+		return k8sClient.Status().Update(ctx, &configuration)
+	}
+	return nil
+}
+
+// updateProviderReadyCondition echoes the readiness of the Provider to the ProviderReady condition of the
+// Configuration, so the Provider doesn't need to be inspected separately
+func (meta *TFConfigurationMeta) updateProviderReadyCondition(ctx context.Context, k8sClient client.Client) error {
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	var deniedErr *provider.AccessDeniedError
+	p, err := provider.GetProviderFromConfiguration(ctx, k8sClient, &configuration, meta.ProviderReference.Namespace, meta.ProviderReference.Name)
+	if err != nil && !errors.As(err, &deniedErr) {
+		return err
+	}
+	var changed bool
+	if deniedErr != nil {
+		changed = tfcfg.SetProviderAccessDeniedCondition(&configuration, deniedErr.Error())
+	} else {
+		changed = tfcfg.SetProviderReadyCondition(&configuration, p)
+	}
+	if !changed {
+		return nil
+	}
+	return k8sClient.Status().Update(ctx, &configuration)
+}
+
+// updateLastReconcileReason records the outcome of the reconcile to the status, if it changes
+func (meta *TFConfigurationMeta) updateLastReconcileReason(ctx context.Context, k8sClient client.Client) error {
+	if meta.LastReconcileReason == "" {
+		return nil
+	}
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if configuration.Status.LastReconcileReason == meta.LastReconcileReason {
+		return nil
+	}
+	configuration.Status.LastReconcileReason = meta.LastReconcileReason
+	return k8sClient.Status().Update(ctx, &configuration)
+}
+
+// toDiagnostics gets the diagnostics from the error of GetTerraformStatus, it returns nil if the error doesn't carry any
+func toDiagnostics(err error) []v1beta2.Diagnostic {
+	var (
+		diagnosticsErr *terraform.DiagnosticsError
+		warningsErr    *terraform.WarningsError
+		parsed         []terraform.Diagnostic
+	)
+	switch {
+	case errors.As(err, &diagnosticsErr):
+		parsed = diagnosticsErr.Diagnostics
+	case errors.As(err, &warningsErr):
+		parsed = warningsErr.Warnings
+	default:
+		return nil
+	}
+	diagnostics := make([]v1beta2.Diagnostic, 0, len(parsed))
+	for _, d := range parsed {
+		diagnostics = append(diagnostics, v1beta2.Diagnostic{
+			Address: d.Address,
+			Summary: d.Summary,
+			Detail:  d.Detail,
+		})
+	}
+	return diagnostics
+}
+
+// updateDestroyPreviewStatus records the result of the destroy preview, the apply and destroy status are not changed
+func (meta *TFConfigurationMeta) updateDestroyPreviewStatus(ctx context.Context, k8sClient client.Client, preview *v1beta2.ConfigurationDestroyPreviewStatus) error {
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	configuration.Status.DestroyPreview = preview
+	if meta.isBookkeepingOffloaded() {
+		if err := meta.writeBookkeepingJSON(ctx, k8sClient, &configuration, bookkeepingDestroyPreviewKey, preview); err != nil {
+			return err
+		}
+		// the token and the state of the preview are kept in the status, the resources are in the bookkeeping
+		if preview != nil {
+			configuration.Status.DestroyPreview = preview.DeepCopy()
+			configuration.Status.DestroyPreview.Resources = nil
+		}
+	}
+	return k8sClient.Status().Update(ctx, &configuration)
+}
+
+func (meta *TFConfigurationMeta) updatePlanStatus(ctx context.Context, k8sClient client.Client, plan *v1beta2.ConfigurationPlanStatus) error {
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	configuration.Status.Plan = plan
+	if meta.isBookkeepingOffloaded() {
+		if err := meta.writeBookkeepingJSON(ctx, k8sClient, &configuration, bookkeepingPlanKey, plan); err != nil {
+			return err
+		}
+		// only the state and the checksum of the plan are kept in the status, the inputs and the changes are in the
+		// bookkeeping
+		if plan != nil {
+			configuration.Status.Plan = plan.DeepCopy()
+			configuration.Status.Plan.ConfigurationHash = ""
+			configuration.Status.Plan.Changes = nil
+		}
+	}
+	return k8sClient.Status().Update(ctx, &configuration)
+}
+
+func (meta *TFConfigurationMeta) updateDestroyStatus(ctx context.Context, k8sClient client.Client, state types.ConfigurationState, message string) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "UpdateDestroyStatus", meta.Namespace, meta.Name)
+	defer func() { tracing.EndSpan(span, err) }()
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err == nil {
+		configuration.Status.Destroy = v1beta2.ConfigurationDestroyStatus{
+			State:   state,
+			Message: message,
+		}
+		// the resources which are not destroyed are still managed by the Configuration
+		if state == types.ConfigurationDestroyFailed {
+			meta.updateResourceCount(ctx, k8sClient, &configuration)
+		}
+		tfcfg.SetCondition(&configuration, tfcfg.ConditionDestroyed, state, message)
+		return k8sClient.Status().Update(ctx, &configuration)
+	}
+	return nil
+}
+
+// updateOrphanedResources records the resources in spec.SkipDestroy, which are removed from the state instead of
+// destroyed, to the destroy status
+func (meta *TFConfigurationMeta) updateOrphanedResources(ctx context.Context, k8sClient client.Client) error {
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	configuration.Status.Destroy.OrphanedResources = meta.SkipDestroy
+	return k8sClient.Status().Update(ctx, &configuration)
+}
+
+// checkExistingState checks whether the Terraform state in the Kubernetes backend is left by another Configuration,
+// which means it's created before the Configuration. Applying with the state will take over the existing cloud
+// resources, so it's only allowed when spec.AdoptExistingState is true.
+func (meta *TFConfigurationMeta) checkExistingState(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) error {
+	if tfcfg.IsLocalBackend(configuration) {
+		return nil
+	}
+	var backendSecret v1.Secret
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.BackendSecretName, Namespace: meta.TerraformBackendNamespace}, &backendSecret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !backendSecret.CreationTimestamp.Before(&configuration.CreationTimestamp) {
+		return nil
+	}
+	if configuration.Spec.AdoptExistingState {
+		klog.InfoS("Adopting the existing Terraform state", "Namespace", meta.TerraformBackendNamespace, "Name", meta.BackendSecretName)
+		return nil
+	}
+	message := fmt.Sprintf(types.MessageExistingStateFound, meta.TerraformBackendNamespace, meta.BackendSecretName)
+	if err := meta.updateApplyStatus(ctx, k8sClient, types.ExistingStateFound, message); err != nil {
+		return err
+	}
+	return errors.New(message)
+}
+
+// ownsBackendSecret checks whether the Terraform state in the backend secret is the one of the Configuration, which is
+// recorded in status.stateSecretRef, adopted by spec.AdoptExistingState, or created after the Configuration. The state
+// shared with an older Configuration is kept by the older one.
+func (meta *TFConfigurationMeta) ownsBackendSecret(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration, secret *v1.Secret) (bool, error) {
+	collision, err := tfcfg.FindBackendCollision(ctx, k8sClient, configuration)
+	if err != nil || collision != "" {
+		return false, err
+	}
+	if ref := configuration.Status.StateSecretRef; ref != nil {
+		return ref.Name == secret.Name && ref.Namespace == secret.Namespace, nil
+	}
+	if configuration.Spec.AdoptExistingState {
+		return true, nil
+	}
+	return !secret.CreationTimestamp.Before(&configuration.CreationTimestamp), nil
+}
+
+// checkDestroyBackend migrates the Terraform state to the changed backend before the destroy, like migrateState before
+// an apply. The destroy is blocked if the state can't be migrated, as the state in the changed backend is the one of
+// another Configuration, whose cloud resources the destroy job would destroy instead.
+func (meta *TFConfigurationMeta) checkDestroyBackend(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) error {
+	previous := configuration.Status.StateSecretRef
+	current := meta.stateSecretRef(configuration)
+	if previous == nil || (current != nil && *previous == *current) {
+		return nil
+	}
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: previous.Name, Namespace: previous.Namespace}, &v1.Secret{}); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if current != nil {
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: current.Name, Namespace: current.Namespace}, &v1.Secret{})
+		if kerrors.IsNotFound(err) {
+			return meta.migrateState(ctx, k8sClient, configuration)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return &tfcfg.DestroyBlockedError{
+		State:   types.BackendMigrationRequired,
+		Message: fmt.Sprintf(types.MessageDestroyBackendChanged, previous.Namespace, previous.Name),
+	}
+}
+
+// checkBackendCollision checks whether another Configuration already stores its Terraform state in the backend
+// secret of the Configuration, which would corrupt the state of both
+func (meta *TFConfigurationMeta) checkBackendCollision(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) error {
+	collision, err := tfcfg.FindBackendCollision(ctx, k8sClient, configuration)
+	if err != nil || collision == "" {
+		return err
+	}
+	message := fmt.Sprintf(types.MessageBackendKeyCollision, meta.TerraformBackendNamespace, meta.BackendSecretName, collision)
+	if err := meta.updateApplyStatus(ctx, k8sClient, types.BackendKeyCollision, message); err != nil {
+		return err
+	}
+	return errors.New(message)
+}
+
+// stateSecretRef is the secret of the kubernetes backend which stores the Terraform state, there is none if the state
+// is stored locally
+func (meta *TFConfigurationMeta) stateSecretRef(configuration *v1beta2.Configuration) *crossplane.SecretReference {
+	if tfcfg.IsLocalBackend(configuration) {
+		return nil
+	}
+	return &crossplane.SecretReference{Name: meta.BackendSecretName, Namespace: meta.TerraformBackendNamespace}
+}
+
+// migrateState moves the Terraform state to the backend secret of the Configuration, if spec.Backend or the backend
+// namespace changes since the cloud resources were deployed. Every Terraform job starts in a new working directory
+// which doesn't know the previous backend, so `terraform init -migrate-state` can't do it. The migration is blocked if
+// it would overwrite an existing state, or discard the state as the backend changes to `local`.
+func (meta *TFConfigurationMeta) migrateState(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) error {
+	previous := configuration.Status.StateSecretRef
+	current := meta.stateSecretRef(configuration)
+	if previous == nil || (current != nil && *previous == *current) {
+		return nil
+	}
+	var state v1.Secret
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: previous.Name, Namespace: previous.Namespace}, &state); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	var message string
+	if current == nil {
+		message = fmt.Sprintf(types.MessageStateDiscardedByLocalBackend, previous.Namespace, previous.Name)
+	} else if err := k8sClient.Get(ctx, client.ObjectKey{Name: current.Name, Namespace: current.Namespace}, &v1.Secret{}); err == nil {
+		message = fmt.Sprintf(types.MessageStateMigrationTargetExists, previous.Namespace, previous.Name, current.Namespace, current.Name)
+	} else if !kerrors.IsNotFound(err) {
+		return err
+	}
+	if message != "" {
+		if err := meta.updateApplyStatus(ctx, k8sClient, types.BackendMigrationRequired, message); err != nil {
+			return err
+		}
+		return errors.New(message)
+	}
+
+	klog.InfoS("Migrating the Terraform state", "From", previous.Namespace+"/"+previous.Name,
+		"To", current.Namespace+"/"+current.Name)
+	labels := make(map[string]string, len(state.Labels))
+	for k, v := range state.Labels {
+		labels[k] = v
+	}
+	// the kubernetes backend finds the state by the label of the secret suffix
+	labels[tfstateSecretSuffixLabel] = tfcfg.BackendSecretSuffix(configuration)
+	migrated := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        current.Name,
+			Namespace:   current.Namespace,
+			Labels:      labels,
+			Annotations: state.Annotations,
+		},
+		Type: state.Type,
+		Data: state.Data,
+	}
+	if err := k8sClient.Create(ctx, &migrated); err != nil {
+		return errors.Wrap(err, "failed to migrate the Terraform state")
+	}
+	if err := k8sClient.Delete(ctx, &state); err != nil {
+		return errors.Wrap(err, "failed to delete the Terraform state which is migrated")
+	}
+
+	var latest v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: configuration.Name, Namespace: configuration.Namespace}, &latest); err != nil {
+		return err
+	}
+	latest.Status.StateSecretRef = current
+	return k8sClient.Status().Update(ctx, &latest)
+}
+
+// jobLabels are the labels of the Terraform job, which are used to count the running jobs of a Provider
+func (meta *TFConfigurationMeta) jobLabels() map[string]string {
+	labels := map[string]string{jobCreatedByLabel: "terraform-controller"}
+	if meta.ProviderReference != nil {
+		labels[jobProviderNameLabel] = meta.ProviderReference.Name
+		labels[jobProviderNamespaceLabel] = meta.ProviderReference.Namespace
+	}
+	if meta.ProviderAccount != "" {
+		labels[jobAccountLabel] = meta.ProviderAccount
+	}
+	return labels
+}
+
+// isSameProvider checks whether the job uses the same Provider as labels, the Providers of the same cloud account are
+// the same one
+func isSameProvider(jobLabels, labels map[string]string) bool {
+	if account := labels[jobAccountLabel]; account != "" {
+		return jobLabels[jobAccountLabel] == account
+	}
+	return jobLabels[jobProviderNameLabel] == labels[jobProviderNameLabel] &&
+		jobLabels[jobProviderNamespaceLabel] == labels[jobProviderNamespaceLabel]
+}
+
+// pendingProviderLabels gets the Provider labels of the job which a pending Configuration is going to create, the
+// Provider is resolved the same way as Reconcile does
+func pendingProviderLabels(ctx context.Context, k8sClient client.Client, configuration v1beta2.Configuration) (map[string]string, error) {
+	ref, err := tfcfg.ResolveProviderReference(ctx, k8sClient, configuration)
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{
+		jobProviderNameLabel:      ref.Name,
+		jobProviderNamespaceLabel: ref.Namespace,
+	}
+	var p v1beta1.Provider
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, &p); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return nil, errors.Wrap(err, "failed to get the Provider of a pending Configuration")
+		}
+	} else if p.Spec.Account != "" {
+		labels[jobAccountLabel] = p.Spec.Account
+	}
+	return labels, nil
+}
+
+// priorityRank orders the priorities of Configurations, the empty priority is PriorityNormal
+func priorityRank(priority v1beta2.Priority) int {
+	switch priority {
+	case v1beta2.PriorityHigh:
+		return 2
+	case v1beta2.PriorityLow:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// concurrencyRequeueInterval is the interval to check the job slots again for a Configuration pending on concurrency,
+// the Configurations of a higher priority check more often
+func concurrencyRequeueInterval(priority v1beta2.Priority) time.Duration {
+	switch priority {
+	case v1beta2.PriorityHigh:
+		return 5 * time.Second
+	case v1beta2.PriorityLow:
+		return 20 * time.Second
+	default:
+		return 10 * time.Second
+	}
+}
+
+// countPendingOfHigherPriority counts the Configurations which are pending on concurrency and have a higher priority,
+// in the cluster and of the same Provider
+func (meta *TFConfigurationMeta) countPendingOfHigherPriority(ctx context.Context, k8sClient client.Client) (int, int, error) {
+	if meta.Priority == v1beta2.PriorityHigh {
+		return 0, 0, nil
+	}
+	var configurations v1beta2.ConfigurationList
+	if err := k8sClient.List(ctx, &configurations); err != nil {
+		return 0, 0, errors.Wrap(err, "failed to list Configurations")
+	}
+	labels := meta.jobLabels()
+	var pending, pendingOfProvider int
+	for _, c := range configurations.Items {
+		if priorityRank(c.Spec.Priority) <= priorityRank(meta.Priority) {
+			continue
+		}
+		if c.Status.Apply.State != types.ConfigurationPendingOnConcurrency && c.Status.Destroy.State != types.ConfigurationPendingOnConcurrency {
+			continue
+		}
+		pending++
+		if meta.ProviderReference == nil {
+			continue
+		}
+		pendingLabels, err := pendingProviderLabels(ctx, k8sClient, c)
+		if err != nil {
+			return 0, 0, err
+		}
+		if isSameProvider(pendingLabels, labels) {
+			pendingOfProvider++
+		}
 	}
-
-	// Apply ClusterRole
-	return createTerraformExecutorClusterRole(ctx, k8sClient, fmt.Sprintf("%s-%s", meta.Namespace, ClusterRoleName))
+	return pending, pendingOfProvider, nil
 }
 
-func (meta *TFConfigurationMeta) updateApplyStatus(ctx context.Context, k8sClient client.Client, state types.ConfigurationState, message string) error {
-	var configuration v1beta2.Configuration
-	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err == nil {
-		configuration.Status.Apply = v1beta2.ConfigurationApplyStatus{
-			State:   state,
-			Message: message,
+// isConcurrencyLimitReached checks whether a new Terraform job has to wait as the number of running jobs in the cluster,
+// or the number of running jobs of the same Provider or cloud account, reaches the limit
+func (meta *TFConfigurationMeta) isConcurrencyLimitReached(ctx context.Context, k8sClient client.Client) (bool, error) {
+	if meta.MaxConcurrentJobs == 0 && meta.MaxConcurrentJobsPerProvider == 0 {
+		return false, nil
+	}
+	var jobs batchv1.JobList
+	if err := k8sClient.List(ctx, &jobs, client.MatchingLabels{jobCreatedByLabel: "terraform-controller"}); err != nil {
+		return false, errors.Wrap(err, "failed to list Terraform jobs")
+	}
+	labels := meta.jobLabels()
+	var running, runningOfProvider int
+	for i, job := range jobs.Items {
+		// the pods of a paused job are deleted, it runs again once it's resumed
+		if job.Status.Succeeded > 0 || (isJobPaused(&jobs.Items[i]) && job.Status.Active == 0) {
+			continue
 		}
-		configuration.Status.ObservedGeneration = configuration.Generation
-		if state == types.Available {
-			outputs, err := meta.getTFOutputs(ctx, k8sClient, configuration)
-			if err != nil {
-				configuration.Status.Apply = v1beta2.ConfigurationApplyStatus{
-					State:   types.GeneratingOutputs,
-					Message: types.ErrGenerateOutputs + ": " + err.Error(),
-				}
-			} else {
-				configuration.Status.Apply.Outputs = outputs
-			}
+		running++
+		if isSameProvider(job.Labels, labels) {
+			runningOfProvider++
 		}
+	}
+	// the slots are kept for the pending Configurations of a higher priority
+	pending, pendingOfProvider, err := meta.countPendingOfHigherPriority(ctx, k8sClient)
+	if err != nil {
+		return false, err
+	}
+	running += pending
+	runningOfProvider += pendingOfProvider
+	if meta.MaxConcurrentJobs > 0 && running >= meta.MaxConcurrentJobs {
+		klog.InfoS("The number of running Terraform jobs reaches the limit", "Running", running, "Limit", meta.MaxConcurrentJobs)
+		return true, nil
+	}
+	if meta.MaxConcurrentJobsPerProvider > 0 && runningOfProvider >= meta.MaxConcurrentJobsPerProvider {
+		klog.InfoS("The number of running Terraform jobs of the Provider reaches the limit", "Provider",
+			labels[jobProviderNameLabel], "Account", meta.ProviderAccount, "Running", runningOfProvider, "Limit", meta.MaxConcurrentJobsPerProvider)
+		return true, nil
+	}
+	return false, nil
+}
 
-		return k8sClient.Status().Update(ctx, &configuration)
+// executorServiceAccount gets the ServiceAccount of the Terraform job
+func (meta *TFConfigurationMeta) executorServiceAccount() string {
+	if meta.ServiceAccountName != "" {
+		return meta.ServiceAccountName
 	}
-	return nil
+	return ServiceAccountName
 }
 
-func (meta *TFConfigurationMeta) updateDestroyStatus(ctx context.Context, k8sClient client.Client, state types.ConfigurationState, message string) error {
-	var configuration v1beta2.Configuration
-	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err == nil {
-		configuration.Status.Destroy = v1beta2.ConfigurationDestroyStatus{
-			State:   state,
-			Message: message,
+// checkServiceAccount checks whether the ServiceAccount set by users exists in the namespace of the Configuration. The
+// ServiceAccount `default`, which every pod of the namespace runs as by default, is never granted the access to the
+// backend secret.
+func (meta *TFConfigurationMeta) checkServiceAccount(ctx context.Context, k8sClient client.Client) error {
+	if meta.ServiceAccountName == "" {
+		return nil
+	}
+	if meta.ServiceAccountName == "default" {
+		return errors.New("the ServiceAccount default could not be the ServiceAccount of the Terraform executor, which is shared by the pods of the namespace")
+	}
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.ServiceAccountName, Namespace: meta.Namespace}, &v1.ServiceAccount{}); err != nil {
+		if kerrors.IsNotFound(err) {
+			return fmt.Errorf("ServiceAccount %s is not found in namespace %s", meta.ServiceAccountName, meta.Namespace)
 		}
-		return k8sClient.Status().Update(ctx, &configuration)
+		return errors.Wrap(err, "failed to get the ServiceAccount of the Terraform executor")
 	}
 	return nil
 }
 
-func (meta *TFConfigurationMeta) assembleAndTriggerJob(ctx context.Context, k8sClient client.Client, executionType TerraformExecutionType) error {
-	// apply rbac
-	if err := createTerraformExecutorServiceAccount(ctx, k8sClient, meta.Namespace, ServiceAccountName); err != nil {
-		return err
-	}
-	if err := createTerraformExecutorClusterRoleBinding(ctx, k8sClient, meta.Namespace, fmt.Sprintf("%s-%s", meta.Namespace, ClusterRoleName), ServiceAccountName); err != nil {
+func (meta *TFConfigurationMeta) assembleAndTriggerJob(ctx context.Context, k8sClient client.Client, executionType TerraformExecutionType) (err error) {
+	// init, plan and apply run in the job, so the span only covers the creation of the job
+	ctx, span := tracing.StartSpan(ctx, "TriggerJob", meta.Namespace, meta.Name, attribute.String("execution.type", string(executionType)))
+	defer func() { tracing.EndSpan(span, err) }()
+	// apply rbac, the ServiceAccount set by users isn't created, and is only granted the access to the backend secret
+	// of the Configuration
+	serviceAccountName := meta.executorServiceAccount()
+	if serviceAccountName == ServiceAccountName {
+		if err := createTerraformExecutorServiceAccount(ctx, k8sClient, meta.Namespace, serviceAccountName); err != nil {
+			return err
+		}
+		if err := createTerraformExecutorClusterRoleBinding(ctx, k8sClient, meta.Namespace, fmt.Sprintf("%s-%s", meta.Namespace, ClusterRoleName), serviceAccountName); err != nil {
+			return err
+		}
+	} else if err := createTerraformExecutorRole(ctx, k8sClient, meta); err != nil {
 		return err
 	}
 
 	job := meta.assembleTerraformJob(executionType)
-	return k8sClient.Create(ctx, job)
+	// the job could have been created by the previous leader before a failover, it's the same job
+	if err := k8sClient.Create(ctx, job); err != nil && !kerrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
 }
 
 // updateTerraformJob will set deletion finalizer to the Terraform job if its envs are changed, which will result in
@@ -636,6 +2468,84 @@ func (meta *TFConfigurationMeta) updateTerraformJobIfNeeded(ctx context.Context,
 	return nil
 }
 
+// getApplyPhase gets the phase reached by the running apply job. The phase recorded in the status is kept if the pod
+// can't be inspected, like right after the controller restarts, so the progress isn't lost.
+func (meta *TFConfigurationMeta) getApplyPhase(ctx context.Context, configuration *v1beta2.Configuration) types.ApplyPhase {
+	phase, err := terraform.GetApplyPhase(ctx, meta.Namespace, meta.ApplyJobName, terraformContainerName, terraformInitContainerName)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get the phase of the Terraform apply job", "Name", meta.ApplyJobName)
+		return configuration.Status.Apply.Phase
+	}
+	return phase
+}
+
+// pauseApplyJob stops the retries of the apply job after a fatal error by scaling its parallelism to 0, so the running
+// pod is deleted and no new one is created
+func (meta *TFConfigurationMeta) pauseApplyJob(ctx context.Context, k8sClient client.Client) error {
+	var job batchv1.Job
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.ApplyJobName, Namespace: meta.Namespace}, &job); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if isJobPaused(&job) {
+		return nil
+	}
+	klog.InfoS("Pausing the apply job after a fatal error", "Name", job.Name, "Namespace", job.Namespace)
+	paused := int32(0)
+	job.Spec.Parallelism = &paused
+	return k8sClient.Update(ctx, &job)
+}
+
+// V logs at the verbosity like klog.V, the logs within the verbosity of the annotation LogLevelAnnotation are logged
+// regardless of the verbosity of the controller
+func (meta *TFConfigurationMeta) V(level klog.Level) klog.Verbose {
+	if level <= meta.LogVerbosity {
+		return klog.V(0)
+	}
+	return klog.V(level)
+}
+
+// assembleLogLevelEnv assembles TF_LOG of the Terraform jobs for the annotation LogLevelAnnotation
+func (meta *TFConfigurationMeta) assembleLogLevelEnv() v1.EnvVar {
+	return v1.EnvVar{Name: "TF_LOG", Value: meta.LogLevel}
+}
+
+// postApplyWait returns how long the outputs still wait for spec.PostApplyDelay after the apply job completes
+func (meta *TFConfigurationMeta) postApplyWait(job *batchv1.Job, now time.Time) time.Duration {
+	if meta.PostApplyDelay <= 0 || job.Status.CompletionTime == nil {
+		return 0
+	}
+	return job.Status.CompletionTime.Add(meta.PostApplyDelay).Sub(now)
+}
+
+// cancelApplyJob interrupts the running apply job before the destroy, so they don't race for the state. The job is
+// deleted in the foreground, its pods stop gracefully in the termination grace period, and the job is gone once they
+// stop. It returns ErrApplyCancelling until then.
+func (meta *TFConfigurationMeta) cancelApplyJob(ctx context.Context, k8sClient client.Client) error {
+	var job batchv1.Job
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.ApplyJobName, Namespace: meta.Namespace}, &job); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if job.DeletionTimestamp != nil {
+		return errors.New(types.ErrApplyCancelling)
+	}
+	if job.Status.Active == 0 {
+		return nil
+	}
+	klog.InfoS("Interrupting the running apply job", "Namespace", meta.Namespace, "Name", meta.ApplyJobName)
+	if err := k8sClient.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationForeground)); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if err := meta.updateApplyStatus(ctx, k8sClient, types.ApplyCancelled, types.MessageApplyCancelled); err != nil {
+		return err
+	}
+	return errors.New(types.ErrApplyCancelling)
+}
+
+// isJobPaused checks whether the job is paused by pauseApplyJob
+func isJobPaused(job *batchv1.Job) bool {
+	return job.Spec.Parallelism != nil && *job.Spec.Parallelism == 0
+}
+
 func (meta *TFConfigurationMeta) assembleTerraformJob(executionType TerraformExecutionType) *batchv1.Job {
 	var (
 		initContainer           v1.Container
@@ -670,7 +2580,7 @@ func (meta *TFConfigurationMeta) assembleTerraformJob(executionType TerraformExe
 		Command: []string{
 			"sh",
 			"-c",
-			fmt.Sprintf("cp %s/* %s", InputTFConfigurationVolumeMountPath, WorkingVolumeMountPath),
+			meta.assembleWorkingDirectoryCheckCommand(executionType) + meta.assembleCopyInputCommand(),
 		},
 		VolumeMounts: initContainerVolumeMounts,
 	}
@@ -688,13 +2598,17 @@ func (meta *TFConfigurationMeta) assembleTerraformJob(executionType TerraformExe
 				Command: []string{
 					"sh",
 					"-c",
-					fmt.Sprintf("git clone %s %s && cp -r %s/* %s", meta.RemoteGit, BackendVolumeMountPath,
-						hclPath, WorkingVolumeMountPath),
+					meta.assembleReuseGitCloneCommand(executionType, meta.assembleGitCloneCommand(executionType, hclPath)+meta.assembleCloneCleanupCommand()),
 				},
+				Env:          meta.assembleGitCredentialsEnvs(),
 				VolumeMounts: initContainerVolumeMounts,
 			})
 	}
 
+	if meta.VendoredModules != nil {
+		initContainers = append(initContainers, meta.assembleVendoredModulesContainer())
+	}
+
 	// run `terraform init`
 	tfPreApplyInitContainer = v1.Container{
 		Name:            terraformInitContainerName,
@@ -703,10 +2617,23 @@ func (meta *TFConfigurationMeta) assembleTerraformJob(executionType TerraformExe
 		Command: []string{
 			"sh",
 			"-c",
-			"terraform init",
+			meta.assembleNetrcCommand() + meta.assembleReuseWorkingDirectoryCommand(executionType,
+				meta.assemblePreflightCommand()+meta.assembleInitCommand()+meta.assembleProviderChecksumCommand()+meta.assembleWorkingDirectoryHashCommand(executionType)),
 		},
 		VolumeMounts: initContainerVolumeMounts,
 	}
+	netrcVolumeMount := v1.VolumeMount{Name: NetrcVolumeName, MountPath: NetrcVolumeMountPath, ReadOnly: true}
+	if meta.NetrcSecretName != "" {
+		tfPreApplyInitContainer.VolumeMounts = append(append([]v1.VolumeMount{}, initContainerVolumeMounts...), netrcVolumeMount)
+	}
+	pluginCacheVolumeMount := v1.VolumeMount{Name: PluginCacheVolumeName, MountPath: PluginCacheVolumeMountPath}
+	if meta.PluginCacheClaimName != "" {
+		tfPreApplyInitContainer.VolumeMounts = append(append([]v1.VolumeMount{}, tfPreApplyInitContainer.VolumeMounts...), pluginCacheVolumeMount)
+		tfPreApplyInitContainer.Env = []v1.EnvVar{meta.assemblePluginCacheEnv()}
+	}
+	if meta.LogLevel != "" {
+		tfPreApplyInitContainer.Env = append(append([]v1.EnvVar{}, tfPreApplyInitContainer.Env...), meta.assembleLogLevelEnv())
+	}
 	initContainers = append(initContainers, tfPreApplyInitContainer)
 
 	container := v1.Container{
@@ -716,7 +2643,7 @@ func (meta *TFConfigurationMeta) assembleTerraformJob(executionType TerraformExe
 		Command: []string{
 			"bash",
 			"-c",
-			fmt.Sprintf("terraform init && terraform %s -lock=false -auto-approve", executionType),
+			meta.assembleNetrcCommand() + meta.assembleCleanupCommand(executionType, meta.assembleInterruptibleCommand(executionType)),
 		},
 		VolumeMounts: []v1.VolumeMount{
 			{
@@ -730,6 +2657,30 @@ func (meta *TFConfigurationMeta) assembleTerraformJob(executionType TerraformExe
 		},
 		Env: meta.Envs,
 	}
+	if meta.NetrcSecretName != "" {
+		container.VolumeMounts = append(container.VolumeMounts, netrcVolumeMount)
+	}
+	if meta.PluginCacheClaimName != "" {
+		container.VolumeMounts = append(container.VolumeMounts, pluginCacheVolumeMount)
+		container.Env = append(append([]v1.EnvVar{}, meta.Envs...), meta.assemblePluginCacheEnv())
+	}
+	if meta.LogLevel != "" {
+		container.Env = append(append([]v1.EnvVar{}, container.Env...), meta.assembleLogLevelEnv())
+	}
+	if meta.hasVariablesFile() {
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      VariablesVolumeName,
+			MountPath: filepath.Join(WorkingVolumeMountPath, types.TerraformVariablesFileName),
+			SubPath:   types.TerraformVariablesFileName,
+		})
+	}
+	if meta.hasCredentialsFile() {
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      CredentialsFileVolumeName,
+			MountPath: filepath.Dir(provider.CredentialsFilePath),
+			ReadOnly:  true,
+		})
+	}
 
 	if meta.ResourcesLimitsCPU != "" || meta.ResourcesLimitsMemory != "" ||
 		meta.ResourcesRequestsCPU != "" || meta.ResourcesRequestsMemory != "" {
@@ -755,14 +2706,41 @@ func (meta *TFConfigurationMeta) assembleTerraformJob(executionType TerraformExe
 		container.Resources = resourceRequirements
 	}
 
-	return &batchv1.Job{
+	// the tokens of the out-of-band apply and the destroy preview mark the jobs which are triggered by them
+	var jobAnnotations map[string]string
+	switch {
+	case executionType == TerraformApply && meta.ApplyNowToken != "":
+		jobAnnotations = map[string]string{tfcfg.ApplyNowAnnotation: meta.ApplyNowToken}
+	case executionType == TerraformApply && meta.SelfHealToken != "":
+		jobAnnotations = map[string]string{selfHealJobAnnotation: meta.SelfHealToken}
+	case executionType == TerraformDestroyPreview:
+		jobAnnotations = map[string]string{tfcfg.DestroyPreviewAnnotation: meta.DestroyPreviewToken}
+	case executionType == TerraformPlan:
+		jobAnnotations = map[string]string{planConfigurationHashAnnotation: meta.ConfigurationHash}
+	}
+	if executionType == TerraformApply && meta.UntaintToken != "" {
+		if jobAnnotations == nil {
+			jobAnnotations = map[string]string{}
+		}
+		jobAnnotations[tfcfg.UntaintAnnotation] = meta.UntaintToken
+	}
+	if executionType == TerraformApply && len(meta.StateOperations) > 0 {
+		if jobAnnotations == nil {
+			jobAnnotations = map[string]string{}
+		}
+		jobAnnotations[stateOperationsJobAnnotation] = strings.Join(meta.stateOperationKeys(), "\n")
+	}
+
+	job := &batchv1.Job{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Job",
 			APIVersion: "batch/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      meta.Name + "-" + string(executionType),
-			Namespace: meta.Namespace,
+			Name:        meta.Name + "-" + string(executionType),
+			Namespace:   meta.Namespace,
+			Labels:      meta.jobLabels(),
+			Annotations: jobAnnotations,
 		},
 		Spec: batchv1.JobSpec{
 			Parallelism:  &parallelism,
@@ -784,13 +2762,308 @@ func (meta *TFConfigurationMeta) assembleTerraformJob(executionType TerraformExe
 					// Container terraform-executor will first copy predefined terraform.d to working directory, and
 					// then run terraform init/apply.
 					Containers:         []v1.Container{container},
-					ServiceAccountName: ServiceAccountName,
+					ServiceAccountName: meta.executorServiceAccount(),
 					Volumes:            executorVolumes,
 					RestartPolicy:      v1.RestartPolicyOnFailure,
 				},
 			},
 		},
 	}
+	if (executionType == TerraformApply || executionType == TerraformDestroy) && meta.CancelGracePeriod > 0 {
+		gracePeriod := int64(meta.CancelGracePeriod.Seconds())
+		job.Spec.Template.Spec.TerminationGracePeriodSeconds = &gracePeriod
+	}
+	if meta.persistsWorkingDirectory(executionType) {
+		meta.persistWorkingDirectory(executionType, &job.Spec.Template.Spec)
+	}
+	return job
+}
+
+// gitCredentialHelper passes the username and password in the envs to git, so they don't appear in the command
+const gitCredentialHelper = `-c credential.helper='!f() { echo "username=${GIT_USERNAME}"; echo "password=${GIT_PASSWORD}"; }; f'`
+
+// assembleGitCloneCommand clones the git repo, checks out the ref, and copies the hcl files in hclPath to the working
+// directory
+func (meta *TFConfigurationMeta) assembleGitCloneCommand(executionType TerraformExecutionType, hclPath string) string {
+	git := "git"
+	if meta.RemoteGitCredentialsSecret != "" {
+		git += " " + gitCredentialHelper
+	}
+	// spec.Remote is checked not to contain the shell metacharacters, the URL is single-quoted all the same
+	command := fmt.Sprintf("%s clone '%s' %s", git, meta.RemoteGit, BackendVolumeMountPath)
+	if meta.RemoteGitRef != "" {
+		command += fmt.Sprintf(" && git -C %s checkout %s", BackendVolumeMountPath, meta.RemoteGitRef)
+	}
+	command += meta.assembleGitCommitCheckCommand(executionType)
+	return command + fmt.Sprintf(" && cp -r %s/* %s", hclPath, WorkingVolumeMountPath) + meta.assembleGitCommitRecordCommand(executionType)
+}
+
+// assembleGitCredentialsEnvs gets the username and password to clone the git repo from the credentials secret
+func (meta *TFConfigurationMeta) assembleGitCredentialsEnvs() []v1.EnvVar {
+	if meta.RemoteGitCredentialsSecret == "" {
+		return nil
+	}
+	secretEnv := func(name, key string) v1.EnvVar {
+		return v1.EnvVar{
+			Name: name,
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: meta.RemoteGitCredentialsSecret},
+					Key:                  key,
+				},
+			},
+		}
+	}
+	return []v1.EnvVar{secretEnv("GIT_USERNAME", "username"), secretEnv("GIT_PASSWORD", "password")}
+}
+
+// assembleCopyInputCommand copies the input Terraform configuration to the working directory. The lock file is copied
+// explicitly, as `*` doesn't match the hidden files.
+func (meta *TFConfigurationMeta) assembleCopyInputCommand() string {
+	command := fmt.Sprintf("cp %s/* %s", InputTFConfigurationVolumeMountPath, WorkingVolumeMountPath)
+	if meta.ProviderLockFile != "" {
+		command += fmt.Sprintf(" && cp %s/%s %s", InputTFConfigurationVolumeMountPath, types.TerraformLockFileName, WorkingVolumeMountPath)
+	}
+	return command
+}
+
+// assembleInitCommand assembles the command of `terraform init`, which doesn't update the lock file from
+// spec.ProviderLockConfigMapRef
+func (meta *TFConfigurationMeta) assembleInitCommand() string {
+	command := "terraform init"
+	if meta.LockTimeout != "" {
+		command += " -lock-timeout=" + meta.LockTimeout
+	}
+	if meta.ProviderLockFile != "" {
+		command += " -lockfile=readonly"
+	}
+	if meta.VendoredModules != nil {
+		command += " -get=false"
+	}
+	if meta.PluginCacheClaimName != "" {
+		// the jobs sharing the plugin cache run `terraform init` one at a time, the other commands only read the
+		// providers linked from the cache
+		command = fmt.Sprintf("mkdir -p %s && flock %s %s", filepath.Join(PluginCacheVolumeMountPath, pluginCacheDir),
+			filepath.Join(PluginCacheVolumeMountPath, pluginCacheLockFile), command)
+	}
+	return command
+}
+
+// assemblePluginCacheEnv assembles TF_PLUGIN_CACHE_DIR of the PersistentVolumeClaim of the provider plugin cache
+func (meta *TFConfigurationMeta) assemblePluginCacheEnv() v1.EnvVar {
+	return v1.EnvVar{Name: "TF_PLUGIN_CACHE_DIR", Value: filepath.Join(PluginCacheVolumeMountPath, pluginCacheDir)}
+}
+
+// assembleVendoredModulesContainer assembles the init container which copies spec.VendoredModules to the modules
+// directory of Terraform, where `terraform init -get=false` resolves the modules
+func (meta *TFConfigurationMeta) assembleVendoredModulesContainer() v1.Container {
+	modulesDir := filepath.Join(WorkingVolumeMountPath, ".terraform", "modules")
+	container := v1.Container{
+		Name:            "prepare-vendored-modules",
+		ImagePullPolicy: v1.PullIfNotPresent,
+		VolumeMounts: []v1.VolumeMount{
+			{
+				Name:      meta.Name,
+				MountPath: WorkingVolumeMountPath,
+			},
+		},
+	}
+	source := filepath.Join("/", meta.VendoredModules.Path)
+	if meta.VendoredModules.ClaimName != "" {
+		container.Image = meta.BusyboxImage
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      VendoredModulesVolumeName,
+			MountPath: VendoredModulesVolumeMountPath,
+			ReadOnly:  true,
+		})
+		source = filepath.Join(VendoredModulesVolumeMountPath, meta.VendoredModules.Path)
+	} else {
+		container.Image = meta.VendoredModules.Image
+	}
+	container.Command = []string{
+		"sh",
+		"-c",
+		fmt.Sprintf("mkdir -p %[2]s && cp -r %[1]s/. %[2]s", strings.TrimSuffix(source, "/"), modulesDir),
+	}
+	return container
+}
+
+// assemblePreflightCommand checks whether the provider registry can be reached before `terraform init`. If not, the
+// endpoint is printed with types.RegistryUnreachableLogPrefix and the init container fails.
+func (meta *TFConfigurationMeta) assemblePreflightCommand() string {
+	if meta.RegistryPreflightEndpoint == "" {
+		return ""
+	}
+	message := fmt.Sprintf(types.MessageRegistryUnreachable, meta.RegistryPreflightEndpoint)
+	return fmt.Sprintf(`{ wget -q -T 10 --spider '%s' || { echo '%s%s'; exit 1; }; } && `,
+		meta.RegistryPreflightEndpoint, types.RegistryUnreachableLogPrefix, message)
+}
+
+// getProviderLockFile gets the dependency lock file of Terraform from the ConfigMap
+func (meta *TFConfigurationMeta) getProviderLockFile(ctx context.Context, k8sClient client.Client, name string) (string, error) {
+	var cm v1.ConfigMap
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: meta.Namespace}, &cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return "", fmt.Errorf("the provider lock ConfigMap %s is not found in namespace %s", name, meta.Namespace)
+		}
+		return "", errors.Wrap(err, "failed to get the provider lock ConfigMap")
+	}
+	lockFile, ok := cm.Data[types.TerraformLockFileName]
+	if !ok {
+		return "", fmt.Errorf("the provider lock ConfigMap %s doesn't have the key %s", name, types.TerraformLockFileName)
+	}
+	return lockFile, nil
+}
+
+// assembleExecutionCommand assembles the command of `terraform apply/destroy` with the extra arguments, which are
+// validated against the allowlist in advance
+func (meta *TFConfigurationMeta) assembleExecutionCommand(executionType TerraformExecutionType) string {
+	if executionType == TerraformDestroyPreview {
+		// the plan doesn't write the state, so it doesn't wait for the lock held by a running apply
+		return meta.assembleInitCommand() + " && terraform plan -destroy -lock=false -json"
+	}
+	if executionType == TerraformDriftCheck {
+		return meta.assembleInitCommand() + " && terraform plan -input=false -lock=false -json"
+	}
+	planFile := filepath.Join(WorkingVolumeMountPath, savedPlanFile)
+	checksum := fmt.Sprintf("$(terraform show -no-color %s | sha256sum | cut -d' ' -f1)", planFile)
+	if executionType == TerraformPlan {
+		command := fmt.Sprintf("%s && terraform plan -input=false -lock=false -out=%s -json && echo \"%s%s\"",
+			meta.assembleInitCommand(), planFile, types.PlanChecksumLogPrefix, checksum)
+		if meta.CostEstimator != nil {
+			// the plan is saved regardless of the estimation, which is only reported if it fails
+			command += fmt.Sprintf(" && { %s || echo 'failed to estimate the cost of the plan by %s' >&2; }",
+				meta.CostEstimator.Command(planFile), meta.CostEstimator.Name())
+		}
+		return command
+	}
+	lockArg := "-lock=false"
+	if meta.LockTimeout != "" {
+		lockArg = "-lock-timeout=" + meta.LockTimeout
+	}
+	command := meta.assembleInitCommand()
+	if executionType == TerraformDestroy {
+		command += meta.assembleStateRmCommand(lockArg)
+	}
+	if executionType == TerraformApply {
+		command += meta.assembleStateOperationsCommand(lockArg) + meta.assembleUntaintCommand(lockArg)
+	}
+	// The saved plan is checked for the warnings by the plan job
+	if executionType == TerraformApply && meta.WarningsAsErrors && meta.ApprovedPlanChecksum == "" {
+		command += meta.assembleWarningsCheckCommand(lockArg)
+	}
+	// The changes are planned again in the executor, and only applied if they are identical to the approved plan
+	if executionType == TerraformApply && meta.ApprovedPlanChecksum != "" {
+		stale := types.PlanStaleLogPrefix + fmt.Sprintf(types.MessagePlannedChangesStale, meta.ApprovedPlanChecksum)
+		command += fmt.Sprintf(" && terraform plan -input=false %s -out=%s -json && sum=%s && { [ \"$sum\" = \"%s\" ] || { echo '%s'; exit 1; }; }",
+			lockArg, planFile, checksum, meta.ApprovedPlanChecksum, stale)
+	}
+	// The JSON output is parsed to get the diagnostics when the execution fails
+	command = fmt.Sprintf("%s && terraform %s %s -auto-approve -json", command, executionType, lockArg)
+	var extraArgs []string
+	switch executionType {
+	case TerraformApply:
+		extraArgs = meta.ExtraApplyArgs
+	case TerraformDestroy:
+		extraArgs = meta.ExtraDestroyArgs
+	}
+	if len(extraArgs) > 0 {
+		command += " " + strings.Join(extraArgs, " ")
+	}
+	if executionType == TerraformApply && meta.ApprovedPlanChecksum != "" {
+		command += " " + planFile
+	}
+	return command
+}
+
+func extraArgsToStrings(args []v1beta2.ExtraArg) []string {
+	var strs []string
+	for _, arg := range args {
+		strs = append(strs, string(arg))
+	}
+	return strs
+}
+
+// assembleInterruptibleCommand runs the command of the apply and destroy in the background, so the SIGTERM sent to the
+// executor when its pod is deleted is turned into the SIGINT of Terraform, like Ctrl-C. Terraform stops after the
+// running operations complete and are written to the state, and it's killed after the termination grace period.
+func (meta *TFConfigurationMeta) assembleInterruptibleCommand(executionType TerraformExecutionType) string {
+	command := meta.assembleExecutionCommand(executionType)
+	if executionType != TerraformApply && executionType != TerraformDestroy {
+		return command
+	}
+	return fmt.Sprintf("(%s) & pid=$!; trap 'kill -INT 0' TERM; trap : INT; wait $pid; code=$?; "+
+		"while kill -0 $pid 2>/dev/null; do wait $pid; code=$?; done; trap - TERM INT; (exit $code)", command)
+}
+
+// sensitiveFiles are the files in the working directory which may contain secrets. The state of the local backend is
+// only removed after a successful execution, as the restarted executor continues with it.
+var sensitiveFiles = []string{"terraform.tfstate.backup", "errored.tfstate", ".terraform/terraform.tfstate", savedPlanFile}
+
+// assembleCleanupCommand wraps the command of the executor to remove the sensitive files, and to wipe the working
+// directory according to spec.WorkingDirectoryCleanupPolicy after the command exits, unless the working directory is
+// persisted by spec.WorkingDirectoryClaimName. The exit code of the command is kept. The input configuration files are
+// not wiped, which are needed when the executor is restarted.
+func (meta *TFConfigurationMeta) assembleCleanupCommand(executionType TerraformExecutionType, command string) string {
+	files := make([]string, 0, len(sensitiveFiles))
+	for _, f := range sensitiveFiles {
+		files = append(files, filepath.Join(WorkingVolumeMountPath, f))
+	}
+	state := filepath.Join(WorkingVolumeMountPath, "terraform.tfstate")
+	cleanup := fmt.Sprintf("for f in %s; do [ -f $f ] && (shred -u $f 2>/dev/null || rm -f $f); done", strings.Join(files, " "))
+	cleanup += fmt.Sprintf("; if [ $code -eq 0 ] && [ -f %[1]s ]; then shred -u %[1]s 2>/dev/null || rm -f %[1]s; fi", state)
+	wipe := "rm -rf " + filepath.Join(WorkingVolumeMountPath, ".terraform")
+	switch {
+	case meta.WorkingDirectoryClaimName != "":
+		// the persisted working directory is reused by the next job, until the cloud resources are destroyed
+		cleanup += meta.assembleWorkingDirectoryRemoveCommand(executionType)
+	case meta.WorkingDirectoryCleanupPolicy == v1beta2.CleanupAlways:
+		cleanup += "; " + wipe
+	case meta.WorkingDirectoryCleanupPolicy == v1beta2.CleanupNever:
+	default:
+		cleanup += fmt.Sprintf("; if [ $code -eq 0 ]; then %s; fi", wipe)
+	}
+	return fmt.Sprintf("%s; code=$?; %s; exit $code", command, cleanup)
+}
+
+// assembleCloneCleanupCommand removes the cloned git repo after the hcl files are copied to the working directory,
+// unless spec.WorkingDirectoryCleanupPolicy is `Never`
+func (meta *TFConfigurationMeta) assembleCloneCleanupCommand() string {
+	if meta.WorkingDirectoryCleanupPolicy == v1beta2.CleanupNever {
+		return ""
+	}
+	return fmt.Sprintf(" && find %s -mindepth 1 -delete", BackendVolumeMountPath)
+}
+
+// assembleStateRmCommand removes the resources in spec.SkipDestroy from the state before `terraform destroy`. The
+// resources which are not in the state, like the ones removed by a previous destroy, are skipped. If the state can't
+// be listed or a resource can't be removed, the destroy doesn't run, or the resource would be destroyed.
+func (meta *TFConfigurationMeta) assembleStateRmCommand(lockArg string) string {
+	var command string
+	for _, address := range meta.SkipDestroy {
+		command += fmt.Sprintf(` && found=$(terraform state list '%[1]s') && if [ -n "$found" ]; then terraform state rm %[2]s '%[1]s'; fi`,
+			address, lockArg)
+	}
+	return command
+}
+
+// assembleUntaintCommand untaints the resources of the annotation UntaintAnnotation before `terraform apply`. A resource
+// which isn't tainted fails `terraform untaint`, which doesn't fail the apply.
+func (meta *TFConfigurationMeta) assembleUntaintCommand(lockArg string) string {
+	var command string
+	for _, address := range meta.UntaintResources {
+		command += fmt.Sprintf(` && { terraform untaint -allow-missing %[2]s '%[1]s' || echo '%[1]s is not untainted'; }`, address, lockArg)
+	}
+	return command
+}
+
+// assembleWarningsCheckCommand plans the changes before `terraform apply` when spec.WarningsAsErrors is true, and
+// refuses the apply if the plan reports any warning. The JSON output of the plan is printed, so the warnings are parsed
+// from the logs.
+func (meta *TFConfigurationMeta) assembleWarningsCheckCommand(lockArg string) string {
+	output := filepath.Join(WorkingVolumeMountPath, warningsCheckFile)
+	return fmt.Sprintf(` && { terraform plan -input=false %[1]s -json > %[2]s; code=$?; cat %[2]s; [ $code -eq 0 ]; } && { ! grep -q '"severity":"warning"' %[2]s || { echo '%[3]s%[4]s'; exit 1; }; }`,
+		lockArg, output, types.TerraformWarningsLogPrefix, types.MessageTerraformWarnings)
 }
 
 func (meta *TFConfigurationMeta) assembleExecutorVolumes() []v1.Volume {
@@ -798,7 +3071,65 @@ func (meta *TFConfigurationMeta) assembleExecutorVolumes() []v1.Volume {
 	workingVolume.EmptyDir = &v1.EmptyDirVolumeSource{}
 	inputTFConfigurationVolume := meta.createConfigurationVolume()
 	tfBackendVolume := meta.createTFBackendVolume()
-	return []v1.Volume{workingVolume, inputTFConfigurationVolume, tfBackendVolume}
+	volumes := []v1.Volume{workingVolume, inputTFConfigurationVolume, tfBackendVolume}
+	if meta.hasVariablesFile() {
+		volumes = append(volumes, meta.createVariablesVolume())
+	}
+	if meta.hasCredentialsFile() {
+		volumes = append(volumes, meta.createCredentialsFileVolume())
+	}
+	if meta.NetrcSecretName != "" {
+		volumes = append(volumes, meta.createNetrcVolume())
+	}
+	if meta.VendoredModules != nil && meta.VendoredModules.ClaimName != "" {
+		modulesVolume := v1.Volume{Name: VendoredModulesVolumeName}
+		modulesVolume.PersistentVolumeClaim = &v1.PersistentVolumeClaimVolumeSource{
+			ClaimName: meta.VendoredModules.ClaimName,
+			ReadOnly:  true,
+		}
+		volumes = append(volumes, modulesVolume)
+	}
+	if meta.PluginCacheClaimName != "" {
+		cacheVolume := v1.Volume{Name: PluginCacheVolumeName}
+		cacheVolume.PersistentVolumeClaim = &v1.PersistentVolumeClaimVolumeSource{ClaimName: meta.PluginCacheClaimName}
+		volumes = append(volumes, cacheVolume)
+	}
+	return volumes
+}
+
+// hasVariablesFile checks whether there are variables of the list or object types in the variable secret
+func (meta *TFConfigurationMeta) hasVariablesFile() bool {
+	_, ok := meta.VariableSecretData[types.TerraformVariablesFileName]
+	return ok
+}
+
+// createVariablesVolume creates the volume of the variables file in the variable secret
+func (meta *TFConfigurationMeta) createVariablesVolume() v1.Volume {
+	variablesVolume := v1.Volume{Name: VariablesVolumeName}
+	variablesVolume.Secret = &v1.SecretVolumeSource{
+		SecretName: meta.VariableSecretName,
+		Items:      []v1.KeyToPath{{Key: types.TerraformVariablesFileName, Path: types.TerraformVariablesFileName}},
+	}
+	return variablesVolume
+}
+
+// hasCredentialsFile checks whether the credentials of the Provider are a file of spec.Credentials.File
+func (meta *TFConfigurationMeta) hasCredentialsFile() bool {
+	_, ok := meta.VariableSecretData[provider.CredentialsFileKey]
+	return ok
+}
+
+// createCredentialsFileVolume creates the volume of the credentials file in the variable secret, which is only
+// readable by the owner
+func (meta *TFConfigurationMeta) createCredentialsFileVolume() v1.Volume {
+	mode := int32(0400)
+	credentialsVolume := v1.Volume{Name: CredentialsFileVolumeName}
+	credentialsVolume.Secret = &v1.SecretVolumeSource{
+		SecretName:  meta.VariableSecretName,
+		Items:       []v1.KeyToPath{{Key: provider.CredentialsFileKey, Path: filepath.Base(provider.CredentialsFilePath)}},
+		DefaultMode: &mode,
+	}
+	return credentialsVolume
 }
 
 func (meta *TFConfigurationMeta) createConfigurationVolume() v1.Volume {
@@ -818,8 +3149,9 @@ func (meta *TFConfigurationMeta) createTFBackendVolume() v1.Volume {
 
 // TfStateProperty is the tf state property for an output
 type TfStateProperty struct {
-	Value interface{} `json:"value,omitempty"`
-	Type  interface{} `json:"type,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+	Type      interface{} `json:"type,omitempty"`
+	Sensitive bool        `json:"sensitive,omitempty"`
 }
 
 // ToProperty converts TfStateProperty type to Property
@@ -843,8 +3175,12 @@ type TFState struct {
 	Outputs map[string]TfStateProperty `json:"outputs"`
 }
 
-//nolint:funlen
-func (meta *TFConfigurationMeta) getTFOutputs(ctx context.Context, k8sClient client.Client, configuration v1beta2.Configuration) (map[string]v1beta2.Property, error) {
+// getTFStateJSON gets the Terraform state from the kubernetes backend. The local state is discarded along with the
+// Terraform job, so nil is returned for the local backend.
+func (meta *TFConfigurationMeta) getTFStateJSON(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) ([]byte, error) {
+	if tfcfg.IsLocalBackend(configuration) {
+		return nil, nil
+	}
 	var s = v1.Secret{}
 	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.BackendSecretName, Namespace: meta.TerraformBackendNamespace}, &s); err != nil {
 		return nil, errors.Wrap(err, "terraform state file backend secret is not generated")
@@ -858,6 +3194,80 @@ func (meta *TFConfigurationMeta) getTFOutputs(ctx context.Context, k8sClient cli
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to decompress state secret data")
 	}
+	return tfStateJSON, nil
+}
+
+// updateTaintedResources records the tainted resources in the state to the status and the ResourcesTainted
+// condition. The status is kept if the state fails to be read.
+func (meta *TFConfigurationMeta) updateTaintedResources(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) {
+	tfStateJSON, err := meta.getTFStateJSON(ctx, k8sClient, configuration)
+	if err != nil || tfStateJSON == nil {
+		return
+	}
+	tainted, err := tfcfg.ParseTaintedResources(tfStateJSON)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get the tainted resources", "Name", meta.Name, "Namespace", meta.Namespace)
+		return
+	}
+	configuration.Status.TaintedResources = tainted
+	tfcfg.SetResourcesTaintedCondition(configuration, tainted)
+}
+
+// updateResourceCount records the number of the managed resources in the state to the status, it's not changed if the
+// state fails to be read
+func (meta *TFConfigurationMeta) updateResourceCount(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) {
+	tfStateJSON, err := meta.getTFStateJSON(ctx, k8sClient, configuration)
+	if err != nil || tfStateJSON == nil {
+		return
+	}
+	count, resourceTypes, err := tfcfg.ParseResourceCount(tfStateJSON)
+	if err != nil {
+		klog.ErrorS(err, "Failed to count the managed resources", "Name", meta.Name, "Namespace", meta.Namespace)
+		return
+	}
+	configuration.Status.ResourceCount = &count
+	configuration.Status.ResourceTypes = resourceTypes
+}
+
+// findSensitiveLeaks finds the non-sensitive outputs in the state which contain the values of the sensitive variables,
+// when spec.SensitiveLeakCheck is set. Nothing is found if the state fails to be read.
+func (meta *TFConfigurationMeta) findSensitiveLeaks(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) []string {
+	if configuration.Spec.SensitiveLeakCheck == "" {
+		return nil
+	}
+	sensitiveValues, err := tfcfg.GetSensitiveVariableValues(configuration)
+	if err != nil || len(sensitiveValues) == 0 {
+		return nil
+	}
+	tfStateJSON, err := meta.getTFStateJSON(ctx, k8sClient, configuration)
+	if err != nil || tfStateJSON == nil {
+		return nil
+	}
+	var tfState TFState
+	if err := json.Unmarshal(tfStateJSON, &tfState); err != nil {
+		klog.ErrorS(err, "Failed to check the outputs for the sensitive variables", "Name", meta.Name, "Namespace", meta.Namespace)
+		return nil
+	}
+	outputs := map[string]string{}
+	for name, output := range tfState.Outputs {
+		if output.Sensitive {
+			continue
+		}
+		property, err := output.ToProperty()
+		if err != nil {
+			continue
+		}
+		outputs[name] = property.Value
+	}
+	return tfcfg.FindSensitiveLeaks(sensitiveValues, outputs)
+}
+
+//nolint:funlen
+func (meta *TFConfigurationMeta) getTFOutputs(ctx context.Context, k8sClient client.Client, configuration v1beta2.Configuration) (map[string]v1beta2.Property, error) {
+	tfStateJSON, err := meta.getTFStateJSON(ctx, k8sClient, &configuration)
+	if err != nil || tfStateJSON == nil {
+		return nil, err
+	}
 
 	var tfState TFState
 	if err := json.Unmarshal(tfStateJSON, &tfState); err != nil {
@@ -871,6 +3281,9 @@ func (meta *TFConfigurationMeta) getTFOutputs(ctx context.Context, k8sClient cli
 		}
 		outputs[k] = property
 	}
+	if err := meta.writeOutputTargets(ctx, k8sClient, &configuration, tfState.Outputs); err != nil {
+		return outputs, err
+	}
 	writeConnectionSecretToReference := configuration.Spec.WriteConnectionSecretToReference
 	if writeConnectionSecretToReference == nil || writeConnectionSecretToReference.Name == "" {
 		return outputs, nil
@@ -902,10 +3315,24 @@ func (meta *TFConfigurationMeta) getTFOutputs(ctx context.Context, k8sClient cli
 				TypeMeta: metav1.TypeMeta{Kind: "Secret"},
 				Data:     data,
 			}
+			// An owner reference can't cross namespaces, the secret in another namespace is cleaned up by its labels
+			if ns == configuration.Namespace && configuration.UID != "" {
+				secret.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: v1beta2.GroupVersion.String(),
+					Kind:       "Configuration",
+					Name:       configurationName,
+					UID:        configuration.UID,
+				}}
+			}
 			err = k8sClient.Create(ctx, &secret)
-			if kerrors.IsAlreadyExists(err) {
+			switch {
+			case kerrors.IsAlreadyExists(err):
 				return nil, fmt.Errorf("secret(%s) already exists", name)
-			} else if err != nil {
+			case kerrors.IsNotFound(err):
+				return nil, errors.Wrapf(err, "the namespace %s of spec.WriteConnectionSecretToRef doesn't exist", ns)
+			case kerrors.IsForbidden(err):
+				return nil, errors.Wrapf(err, "the connection secret is not allowed to be written to namespace %s", ns)
+			case err != nil:
 				return nil, err
 			}
 		}
@@ -949,7 +3376,14 @@ func (meta *TFConfigurationMeta) prepareTFVariables(configuration *v1beta2.Confi
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("failed to get Terraform JSON variables from Configuration Variables %v", configuration.Spec.Variable))
 	}
+	complexVariables := map[string]interface{}{}
 	for k, v := range tfVariable {
+		switch v.(type) {
+		case []interface{}, map[string]interface{}:
+			// the values of the list or object types are written to a JSON variables file, so the types are preserved
+			complexVariables[strings.TrimPrefix(k, "TF_VAR_")] = v
+			continue
+		}
 		envValue, err := tfcfg.Interface2String(v)
 		if err != nil {
 			return err
@@ -959,12 +3393,23 @@ func (meta *TFConfigurationMeta) prepareTFVariables(configuration *v1beta2.Confi
 		valueFrom.SecretKeyRef.Name = meta.VariableSecretName
 		envs = append(envs, v1.EnvVar{Name: k, ValueFrom: valueFrom})
 	}
+	if len(complexVariables) > 0 {
+		variablesFile, err := json.Marshal(complexVariables)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the variables of the list or object types")
+		}
+		data[types.TerraformVariablesFileName] = variablesFile
+	}
 
 	if meta.Credentials == nil {
 		return errors.New(provider.ErrCredentialNotRetrieved)
 	}
 	for k, v := range meta.Credentials {
 		data[k] = []byte(v)
+		// the credentials file is mounted from the secret
+		if k == provider.CredentialsFileKey {
+			continue
+		}
 		valueFrom := &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{Key: k}}
 		valueFrom.SecretKeyRef.Name = meta.VariableSecretName
 		envs = append(envs, v1.EnvVar{Name: k, ValueFrom: valueFrom})
@@ -1057,6 +3502,18 @@ func (meta *TFConfigurationMeta) prepareTFInputConfigurationData() map[string]st
 		dataName = "terraform-backend.tf"
 	}
 	data := map[string]string{dataName: meta.CompleteConfiguration, "kubeconfig": ""}
+	if meta.DefaultTagsFileName != "" {
+		data[meta.DefaultTagsFileName] = meta.DefaultTagsConfiguration
+	}
+	if meta.ProviderConfigFileName != "" {
+		data[meta.ProviderConfigFileName] = meta.ProviderConfiguration
+	}
+	if meta.ProviderLockFile != "" {
+		data[types.TerraformLockFileName] = meta.ProviderLockFile
+	}
+	if meta.ProviderChecksumAllowlist != "" {
+		data[providerChecksumAllowlistFileName] = meta.ProviderChecksumAllowlist
+	}
 	return data
 }
 
@@ -1074,10 +3531,28 @@ func (meta *TFConfigurationMeta) CheckWhetherConfigurationChanges(ctx context.Co
 	}
 
 	var configurationChanged bool
+	defaultTagsChanged := meta.DefaultTagsFileName != "" && cm.Data[meta.DefaultTagsFileName] != meta.DefaultTagsConfiguration
+	if defaultTagsChanged {
+		klog.InfoS("Provider default tags changed", "ConfigMap", cm.Data[meta.DefaultTagsFileName],
+			"RenderedDefaultTags", meta.DefaultTagsConfiguration)
+	}
+	providerConfigChanged := cm.Data[tfcfg.ProviderConfigFileName] != meta.ProviderConfiguration
+	if providerConfigChanged {
+		klog.InfoS("Provider config changed", "Name", meta.ConfigurationCMName)
+	}
+	providerLockChanged := cm.Data[types.TerraformLockFileName] != meta.ProviderLockFile
+	if providerLockChanged {
+		klog.InfoS("Provider lock file changed", "Name", meta.ConfigurationCMName)
+	}
+	// the plugins are verified again by a new job, so a plugin removed from the allowlist no longer runs
+	if cm.Data[providerChecksumAllowlistFileName] != meta.ProviderChecksumAllowlist {
+		klog.InfoS("Provider checksum allowlist changed", "Name", meta.ConfigurationCMName)
+		providerLockChanged = true
+	}
 	switch configurationType {
 	case types.ConfigurationHCL:
 		configurationChanged = cm.Data[types.TerraformHCLConfigurationName] != meta.CompleteConfiguration
-		meta.ConfigurationChanged = configurationChanged
+		meta.ConfigurationChanged = configurationChanged || defaultTagsChanged || providerConfigChanged || providerLockChanged
 		if configurationChanged {
 			klog.InfoS("Configuration HCL changed", "ConfigMap", cm.Data[types.TerraformHCLConfigurationName],
 				"RenderedCompletedConfiguration", meta.CompleteConfiguration)
@@ -1085,7 +3560,12 @@ func (meta *TFConfigurationMeta) CheckWhetherConfigurationChanges(ctx context.Co
 
 		return nil
 	case types.ConfigurationRemote:
-		meta.ConfigurationChanged = false
+		// the rendered terraform block and the moved blocks of spec.Moved go along with the remote module
+		configurationChanged = cm.Data["terraform-backend.tf"] != meta.CompleteConfiguration
+		meta.ConfigurationChanged = configurationChanged || defaultTagsChanged || providerConfigChanged || providerLockChanged
+		if configurationChanged {
+			klog.InfoS("Configuration for the remote module changed", "Name", meta.ConfigurationCMName)
+		}
 		return nil
 	default:
 		return errors.New("unsupported configuration type, only HCL or Remote is supported")
@@ -1093,18 +3573,49 @@ func (meta *TFConfigurationMeta) CheckWhetherConfigurationChanges(ctx context.Co
 }
 
 // getCredentials will get credentials from secret of the Provider
-func (meta *TFConfigurationMeta) getCredentials(ctx context.Context, k8sClient client.Client, providerObj *v1beta1.Provider) error {
-	region, err := tfcfg.SetRegion(ctx, k8sClient, meta.Namespace, meta.Name, providerObj)
+func (meta *TFConfigurationMeta) getCredentials(ctx context.Context, k8sClient client.Client, providerObj *v1beta1.Provider) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "GetCredentials", meta.Namespace, meta.Name)
+	defer func() { tracing.EndSpan(span, err) }()
+	region, source, err := tfcfg.SetRegion(ctx, k8sClient, meta.Namespace, meta.Name, providerObj)
 	if err != nil {
 		return err
 	}
-	credentials, err := provider.GetProviderCredentials(ctx, k8sClient, providerObj, region)
+	meta.V(4).InfoS("resolved the region of the Configuration", "Name", meta.Name, "Namespace", meta.Namespace,
+		"Region", region, "Source", source)
+	meta.Region = region
+	return meta.resolveCredentials(ctx, k8sClient, providerObj)
+}
+
+// resolveCredentials resolves the credentials of the profile of the Provider in meta.Region
+func (meta *TFConfigurationMeta) resolveCredentials(ctx context.Context, k8sClient client.Client, providerObj *v1beta1.Provider) error {
+	credentials, err := provider.GetProviderProfileCredentials(ctx, k8sClient, providerObj, meta.Region, meta.ProviderProfile)
 	if err != nil {
 		return err
 	}
 	if credentials == nil {
 		return errors.New(provider.ErrCredentialNotRetrieved)
 	}
+	providerConfigSecrets, err := provider.GetProviderConfigSecrets(ctx, k8sClient, providerObj)
+	if err != nil {
+		return err
+	}
+	for k, v := range providerConfigSecrets {
+		credentials[k] = v
+	}
 	meta.Credentials = credentials
+	expiration, err := provider.GetProviderProfileCredentialsExpiration(ctx, k8sClient, providerObj, meta.ProviderProfile)
+	if err != nil {
+		return err
+	}
+	meta.CredentialsExpiration = expiration
 	return nil
 }
+
+// isCredentialsExpiringSoon checks whether the temporary credentials expire in CredentialsExpiryThreshold, so an apply
+// starting with them may fail halfway
+func (meta *TFConfigurationMeta) isCredentialsExpiringSoon() bool {
+	if meta.CredentialsExpiration == nil {
+		return false
+	}
+	return time.Until(meta.CredentialsExpiration.Time) < meta.CredentialsExpiryThreshold
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/agiledragon/gomonkey/v2"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -34,7 +37,9 @@ import (
 	runtimetypes "github.com/oam-dev/terraform-controller/api/types/crossplane-runtime"
 	"github.com/oam-dev/terraform-controller/api/v1beta1"
 	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	tfcfg "github.com/oam-dev/terraform-controller/controllers/configuration"
 	"github.com/oam-dev/terraform-controller/controllers/provider"
+	"github.com/oam-dev/terraform-controller/controllers/terraform"
 )
 
 func TestInitTFConfigurationMeta(t *testing.T) {
@@ -463,6 +468,1659 @@ func TestConfigurationReconcile(t *testing.T) {
 	}
 }
 
+func TestIsUpToDate(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+	corev1.AddToScheme(s)
+
+	patches := gomonkey.ApplyMethod(reflect.TypeOf(&sts.Client{}), "GetCallerIdentity", func(_ *sts.Client, request *sts.GetCallerIdentityRequest) (response *sts.GetCallerIdentityResponse, err error) {
+		return nil, nil
+	})
+	defer patches.Reset()
+
+	credentials, _ := json.Marshal(&provider.AlibabaCloudCredentials{AccessKeyID: "aaaa", AccessKeySecret: "bbbbb"})
+	credentialsSecret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "default", Namespace: "default"},
+		Data:       map[string][]byte{"credentials": credentials},
+	}
+	providerObj := &v1beta1.Provider{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "default",
+			Namespace: "default",
+		},
+		Spec: v1beta1.ProviderSpec{
+			Provider: "alibaba",
+			Credentials: v1beta1.ProviderCredentials{
+				Source: "Secret",
+				SecretRef: &crossplane.SecretKeySelector{
+					SecretReference: crossplane.SecretReference{Name: "default", Namespace: "default"},
+					Key:             "credentials",
+				},
+			},
+			Region: "cn-beijing",
+		},
+	}
+	r := &ConfigurationReconciler{
+		Client: fake.NewClientBuilder().WithScheme(s).WithObjects(credentialsSecret, providerObj).Build(),
+	}
+	meta := &TFConfigurationMeta{
+		Name:               "abc",
+		Namespace:          "default",
+		VariableSecretName: "variable-abc",
+		ProviderReference: &crossplane.Reference{
+			Name:      "default",
+			Namespace: "default",
+		},
+	}
+
+	newConfiguration := func() *v1beta2.Configuration {
+		configuration := &v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{
+				Name:       "abc",
+				Generation: 2,
+			},
+			Spec: v1beta2.ConfigurationSpec{
+				HCL: "abc",
+			},
+		}
+		hash, err := tfcfg.ComputeConfigurationHash(configuration, providerObj)
+		assert.Nil(t, err)
+		configuration.Status = v1beta2.ConfigurationStatus{
+			ObservedGeneration: 2,
+			ConfigurationHash:  hash,
+			Apply: v1beta2.ConfigurationApplyStatus{
+				State: types.Available,
+			},
+		}
+		return configuration
+	}
+
+	// the secret of the variables copied by the last reconcile
+	deployed := *meta
+	deployed.Region = "cn-beijing"
+	assert.Nil(t, deployed.resolveCredentials(ctx, r.Client, providerObj))
+	assert.Nil(t, deployed.prepareTFVariables(newConfiguration()))
+	variableSecret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "variable-abc", Namespace: "default"},
+		Data:       deployed.VariableSecretData,
+	}
+	assert.Nil(t, r.Client.Create(ctx, variableSecret))
+
+	assert.True(t, r.isUpToDate(ctx, newConfiguration(), meta))
+
+	generationChanged := newConfiguration()
+	generationChanged.Generation = 3
+	assert.False(t, r.isUpToDate(ctx, generationChanged, meta))
+
+	notAvailable := newConfiguration()
+	notAvailable.Status.Apply.State = types.ConfigurationApplyFailed
+	assert.False(t, r.isUpToDate(ctx, notAvailable, meta))
+
+	hashChanged := newConfiguration()
+	hashChanged.Spec.HCL = "def"
+	assert.False(t, r.isUpToDate(ctx, hashChanged, meta))
+
+	noHash := newConfiguration()
+	noHash.Status.ConfigurationHash = ""
+	assert.False(t, r.isUpToDate(ctx, noHash, meta))
+
+	providerNotFound := &TFConfigurationMeta{
+		ProviderReference: &crossplane.Reference{
+			Name:      "xxx",
+			Namespace: "default",
+		},
+	}
+	assert.False(t, r.isUpToDate(ctx, newConfiguration(), providerNotFound))
+
+	// the rotated credentials of the Provider are not in the hash
+	rotated, _ := json.Marshal(&provider.AlibabaCloudCredentials{AccessKeyID: "cccc", AccessKeySecret: "ddddd"})
+	credentialsSecret.Data = map[string][]byte{"credentials": rotated}
+	assert.Nil(t, r.Client.Update(ctx, credentialsSecret))
+	assert.False(t, r.isUpToDate(ctx, newConfiguration(), meta))
+	assert.Nil(t, meta.VariableSecretData)
+}
+
+func TestCheckExistingState(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	now := time.Now()
+	backendSecret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:              "tfstate-default-abc",
+			Namespace:         "vela-system",
+			CreationTimestamp: v1.NewTime(now.Add(-time.Hour)),
+		},
+	}
+	newConfiguration := func(createdAt time.Time, adopt bool) *v1beta2.Configuration {
+		return &v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{
+				Name:              "abc",
+				Namespace:         "default",
+				CreationTimestamp: v1.NewTime(createdAt),
+			},
+			Spec: v1beta2.ConfigurationSpec{
+				HCL:                "bbb",
+				AdoptExistingState: adopt,
+			},
+		}
+	}
+	meta := &TFConfigurationMeta{
+		Name:                      "abc",
+		Namespace:                 "default",
+		BackendSecretName:         "tfstate-default-abc",
+		TerraformBackendNamespace: "vela-system",
+	}
+
+	testcases := []struct {
+		name          string
+		objects       []client.Object
+		configuration *v1beta2.Configuration
+		errMsg        string
+	}{
+		{
+			name:          "no state",
+			configuration: newConfiguration(now, false),
+		},
+		{
+			name:          "state is created by the Configuration",
+			objects:       []client.Object{backendSecret},
+			configuration: newConfiguration(now.Add(-2*time.Hour), false),
+		},
+		{
+			name:          "existing state is adopted",
+			objects:       []client.Object{backendSecret},
+			configuration: newConfiguration(now, true),
+		},
+		{
+			name:          "existing state is not adopted",
+			objects:       []client.Object{backendSecret},
+			configuration: newConfiguration(now, false),
+			errMsg:        "Terraform state vela-system/tfstate-default-abc exists before the Configuration is created",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := append([]client.Object{tc.configuration}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			err := meta.checkExistingState(ctx, k8sClient, tc.configuration)
+			if tc.errMsg == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.Contains(t, err.Error(), tc.errMsg)
+			var configuration v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &configuration))
+			assert.Equal(t, types.ExistingStateFound, configuration.Status.Apply.State)
+		})
+	}
+}
+
+func TestMigrateState(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	newState := func(name, suffix string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        name,
+				Namespace:   "vela-system",
+				Labels:      map[string]string{"tfstate": "true", tfstateSecretSuffixLabel: suffix},
+				Annotations: map[string]string{"encoding": "gzip"},
+			},
+			Data: map[string][]byte{TerraformStateNameInSecret: []byte("xxx")},
+		}
+	}
+	newConfiguration := func(backend *v1beta2.Backend, previous *crossplane.SecretReference) *v1beta2.Configuration {
+		return &v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default"},
+			Spec: v1beta2.ConfigurationSpec{
+				HCL:     "bbb",
+				Backend: backend,
+			},
+			Status: v1beta2.ConfigurationStatus{StateSecretRef: previous},
+		}
+	}
+	previous := &crossplane.SecretReference{Name: "tfstate-default-abc", Namespace: "vela-system"}
+	newSuffix := &v1beta2.Backend{SecretSuffix: "def"}
+
+	testcases := []struct {
+		name          string
+		configuration *v1beta2.Configuration
+		objects       []client.Object
+		migrated      bool
+		errMsg        string
+	}{
+		{
+			name:          "state is not recorded",
+			configuration: newConfiguration(newSuffix, nil),
+			objects:       []client.Object{newState("tfstate-default-abc", "abc")},
+		},
+		{
+			name:          "backend doesn't change",
+			configuration: newConfiguration(nil, previous),
+			objects:       []client.Object{newState("tfstate-default-abc", "abc")},
+		},
+		{
+			name:          "previous state is not found",
+			configuration: newConfiguration(newSuffix, previous),
+		},
+		{
+			name:          "state is migrated",
+			configuration: newConfiguration(newSuffix, previous),
+			objects:       []client.Object{newState("tfstate-default-abc", "abc")},
+			migrated:      true,
+		},
+		{
+			name:          "state exists in the changed backend",
+			configuration: newConfiguration(newSuffix, previous),
+			objects:       []client.Object{newState("tfstate-default-abc", "abc"), newState("tfstate-default-def", "def")},
+			errMsg:        "can't be migrated to vela-system/tfstate-default-def which already exists",
+		},
+		{
+			name:          "backend changes to local",
+			configuration: newConfiguration(&v1beta2.Backend{Type: "local"}, previous),
+			objects:       []client.Object{newState("tfstate-default-abc", "abc")},
+			errMsg:        "is not used by the local backend",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := append([]client.Object{tc.configuration.DeepCopy()}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			meta := &TFConfigurationMeta{
+				Name:                      "abc",
+				Namespace:                 "default",
+				BackendSecretName:         fmt.Sprintf(TFBackendSecret, terraformWorkspace, tfcfg.BackendSecretSuffix(tc.configuration)),
+				TerraformBackendNamespace: "vela-system",
+			}
+
+			err := meta.migrateState(ctx, k8sClient, tc.configuration)
+			var configuration v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &configuration))
+			if tc.errMsg != "" {
+				assert.Contains(t, err.Error(), tc.errMsg)
+				assert.Equal(t, types.BackendMigrationRequired, configuration.Status.Apply.State)
+				return
+			}
+			assert.Nil(t, err)
+			if !tc.migrated {
+				return
+			}
+
+			var state corev1.Secret
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: "tfstate-default-abc", Namespace: "vela-system"}, &state)
+			assert.True(t, kerrors.IsNotFound(err))
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "tfstate-default-def", Namespace: "vela-system"}, &state))
+			assert.Equal(t, "def", state.Labels[tfstateSecretSuffixLabel])
+			assert.Equal(t, "gzip", state.Annotations["encoding"])
+			assert.Equal(t, []byte("xxx"), state.Data[TerraformStateNameInSecret])
+			assert.Equal(t, "tfstate-default-def", configuration.Status.StateSecretRef.Name)
+		})
+	}
+}
+
+func TestRunPreDestroyHook(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "abc",
+			Namespace: "default",
+			UID:       "abc-uid",
+		},
+		Spec: v1beta2.ConfigurationSpec{
+			HCL: "bbb",
+			PreDestroyHook: &v1beta2.PreDestroyHook{
+				Template: batchv1.JobTemplateSpec{
+					ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"app": "snapshot"}},
+					Spec: batchv1.JobSpec{
+						Template: corev1.PodTemplateSpec{
+							Spec: corev1.PodSpec{
+								Containers:    []corev1.Container{{Name: "snapshot", Image: "busybox"}},
+								RestartPolicy: corev1.RestartPolicyNever,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	meta := &TFConfigurationMeta{
+		Name:                  "abc",
+		Namespace:             "default",
+		PreDestroyHookJobName: "abc-pre-destroy",
+		ProviderReference:     &crossplane.Reference{Name: "default", Namespace: "default"},
+	}
+	newHookJob := func(status batchv1.JobStatus) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: v1.ObjectMeta{Name: "abc-pre-destroy", Namespace: "default"},
+			Status:     status,
+		}
+	}
+
+	testcases := []struct {
+		name          string
+		configuration *v1beta2.Configuration
+		objects       []client.Object
+		completed     bool
+		errMsg        string
+		state         types.ConfigurationState
+	}{
+		{
+			name:          "no hook",
+			configuration: &v1beta2.Configuration{ObjectMeta: configuration.ObjectMeta},
+			completed:     true,
+		},
+		{
+			name:          "hook job is created",
+			configuration: configuration,
+			state:         types.ConfigurationDestroying,
+		},
+		{
+			name:          "hook job is running",
+			configuration: configuration,
+			objects:       []client.Object{newHookJob(batchv1.JobStatus{Active: 1})},
+			state:         types.ConfigurationDestroying,
+		},
+		{
+			name:          "hook job succeeded",
+			configuration: configuration,
+			objects:       []client.Object{newHookJob(batchv1.JobStatus{Succeeded: 1})},
+			completed:     true,
+		},
+		{
+			name:          "hook job failed",
+			configuration: configuration,
+			objects: []client.Object{newHookJob(batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type:    batchv1.JobFailed,
+				Status:  corev1.ConditionTrue,
+				Message: "Job has reached the specified backoff limit",
+			}}})},
+			errMsg: "The pre-destroy hook Job abc-pre-destroy failed: Job has reached the specified backoff limit",
+			state:  types.ConfigurationDestroyBlocked,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := append([]client.Object{tc.configuration.DeepCopy()}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			completed, err := meta.runPreDestroyHook(ctx, k8sClient, tc.configuration)
+			assert.Equal(t, tc.completed, completed)
+			if tc.errMsg == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.Contains(t, err.Error(), tc.errMsg)
+				var blockedErr *tfcfg.DestroyBlockedError
+				assert.True(t, errors.As(err, &blockedErr))
+				assert.Equal(t, types.ConfigurationDestroyBlocked, blockedErr.State)
+			}
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			assert.Equal(t, tc.state, got.Status.Destroy.State)
+
+			if tc.configuration.Spec.PreDestroyHook != nil && len(tc.objects) == 0 {
+				var job batchv1.Job
+				assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc-pre-destroy", Namespace: "default"}, &job))
+				assert.Equal(t, "snapshot", job.Labels["app"])
+				assert.Equal(t, "terraform-controller", job.Labels[jobCreatedByLabel])
+				assert.Equal(t, "default", job.Labels[jobProviderNameLabel])
+				assert.Equal(t, "busybox", job.Spec.Template.Spec.Containers[0].Image)
+				assert.Equal(t, 1, len(job.OwnerReferences))
+				assert.Equal(t, "Configuration", job.OwnerReferences[0].Kind)
+				assert.Equal(t, k8stypes.UID("abc-uid"), job.OwnerReferences[0].UID)
+			}
+		})
+	}
+}
+
+func TestCheckApplySchedule(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	past := v1.NewTime(time.Now().Add(-time.Minute))
+	future := v1.NewTime(time.Now().Add(time.Hour).Truncate(time.Second))
+	newConfiguration := func(annotations map[string]string, next *v1.Time) *v1beta2.Configuration {
+		return &v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "abc",
+				Namespace:   "default",
+				Annotations: annotations,
+			},
+			Spec: v1beta2.ConfigurationSpec{
+				HCL:           "bbb",
+				ApplySchedule: "0 0 1 1 *",
+			},
+			Status: v1beta2.ConfigurationStatus{
+				Apply: v1beta2.ConfigurationApplyStatus{NextScheduledApplyTime: next},
+			},
+		}
+	}
+	applyJob := &batchv1.Job{ObjectMeta: v1.ObjectMeta{Name: "abc-apply", Namespace: "default"}}
+
+	testcases := []struct {
+		name          string
+		configuration *v1beta2.Configuration
+		objects       []client.Object
+		envChanged    bool
+		pending       bool
+		next          *v1.Time
+	}{
+		{
+			name:          "apply now",
+			configuration: newConfiguration(map[string]string{tfcfg.ApplyNowAnnotation: "1647426620"}, nil),
+		},
+		{
+			name:          "nothing to apply",
+			configuration: newConfiguration(nil, nil),
+			objects:       []client.Object{applyJob},
+		},
+		{
+			name:          "changes are queued",
+			configuration: newConfiguration(nil, nil),
+			objects:       []client.Object{applyJob},
+			envChanged:    true,
+			pending:       true,
+		},
+		{
+			name:          "changes are still pending",
+			configuration: newConfiguration(nil, &future),
+			pending:       true,
+			next:          &future,
+		},
+		{
+			name:          "scheduled time is reached",
+			configuration: newConfiguration(nil, &past),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := append([]client.Object{tc.configuration.DeepCopy()}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			r := &ConfigurationReconciler{Client: k8sClient}
+			meta := &TFConfigurationMeta{
+				Name:          "abc",
+				Namespace:     "default",
+				ApplyJobName:  "abc-apply",
+				EnvChanged:    tc.envChanged,
+				ApplyNowToken: tc.configuration.Annotations[tfcfg.ApplyNowAnnotation],
+			}
+
+			wait, err := r.checkApplySchedule(ctx, tc.configuration, meta)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.pending, wait > 0)
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			if !tc.pending {
+				assert.NotEqual(t, types.PendingScheduledApply, got.Status.Apply.State)
+				return
+			}
+			assert.Equal(t, types.PendingScheduledApply, got.Status.Apply.State)
+			assert.NotNil(t, got.Status.Apply.NextScheduledApplyTime)
+			if tc.next != nil {
+				assert.True(t, tc.next.Equal(got.Status.Apply.NextScheduledApplyTime))
+			} else {
+				assert.Equal(t, 1, got.Status.Apply.NextScheduledApplyTime.UTC().YearDay())
+			}
+		})
+	}
+}
+
+func TestApplyNow(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        "abc",
+			Namespace:   "default",
+			Annotations: map[string]string{tfcfg.ApplyNowAnnotation: "1647426620"},
+		},
+		Spec: v1beta2.ConfigurationSpec{
+			HCL: "bbb",
+		},
+	}
+	newApplyJob := func(annotations map[string]string) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: v1.ObjectMeta{Name: "abc-apply", Namespace: "default", Annotations: annotations}}
+	}
+
+	testcases := []struct {
+		name              string
+		objects           []client.Object
+		triggered         bool
+		jobDeleted        bool
+		annotationRemoved bool
+	}{
+		{
+			name:      "no apply job",
+			triggered: true,
+		},
+		{
+			name:       "apply job of the last apply",
+			objects:    []client.Object{newApplyJob(nil)},
+			jobDeleted: true,
+		},
+		{
+			name:       "apply job of another token",
+			objects:    []client.Object{newApplyJob(map[string]string{tfcfg.ApplyNowAnnotation: "1647420000"})},
+			jobDeleted: true,
+		},
+		{
+			name:              "apply job of the token",
+			objects:           []client.Object{newApplyJob(map[string]string{tfcfg.ApplyNowAnnotation: "1647426620"})},
+			triggered:         true,
+			annotationRemoved: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := append([]client.Object{configuration.DeepCopy()}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			r := &ConfigurationReconciler{Client: k8sClient}
+			meta := &TFConfigurationMeta{
+				Name:          "abc",
+				Namespace:     "default",
+				ApplyJobName:  "abc-apply",
+				ApplyNowToken: "1647426620",
+			}
+
+			triggered, err := r.applyNow(ctx, configuration.DeepCopy(), meta)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.triggered, triggered)
+
+			var job batchv1.Job
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: "abc-apply", Namespace: "default"}, &job)
+			assert.Equal(t, tc.jobDeleted, kerrors.IsNotFound(err) && len(tc.objects) > 0)
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			_, ok := got.Annotations[tfcfg.ApplyNowAnnotation]
+			assert.Equal(t, tc.annotationRemoved, !ok)
+			if tc.annotationRemoved {
+				assert.Equal(t, "", meta.ApplyNowToken)
+			}
+		})
+	}
+}
+
+func TestRemoveRefreshSecretsAnnotation(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+
+	testcases := []struct {
+		name              string
+		token             string
+		annotationRemoved bool
+	}{
+		{
+			name:              "secrets are refreshed",
+			token:             "1647426620",
+			annotationRemoved: true,
+		},
+		{
+			name:  "token is changed in the meantime",
+			token: "1647420000",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			configuration := &v1beta2.Configuration{
+				ObjectMeta: v1.ObjectMeta{
+					Name:        "abc",
+					Namespace:   "default",
+					Annotations: map[string]string{tfcfg.RefreshSecretsAnnotation: "1647426620"},
+				},
+			}
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(configuration).Build()
+			meta := &TFConfigurationMeta{Name: "abc", Namespace: "default", RefreshSecretsToken: tc.token}
+
+			assert.Nil(t, meta.removeRefreshSecretsAnnotation(ctx, k8sClient))
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			_, ok := got.Annotations[tfcfg.RefreshSecretsAnnotation]
+			assert.Equal(t, tc.annotationRemoved, !ok)
+		})
+	}
+}
+
+func TestIsSecretDataStale(t *testing.T) {
+	desired := map[string][]byte{"ALICLOUD_ACCESS_KEY": []byte("a"), "ALICLOUD_SECRET_KEY": []byte("b")}
+	testcases := []struct {
+		name  string
+		data  map[string][]byte
+		stale bool
+	}{
+		{
+			name: "up to date",
+			data: map[string][]byte{"ALICLOUD_ACCESS_KEY": []byte("a"), "ALICLOUD_SECRET_KEY": []byte("b")},
+		},
+		{
+			name:  "value is changed",
+			data:  map[string][]byte{"ALICLOUD_ACCESS_KEY": []byte("a"), "ALICLOUD_SECRET_KEY": []byte("c")},
+			stale: true,
+		},
+		{
+			name:  "key is missing",
+			data:  map[string][]byte{"ALICLOUD_ACCESS_KEY": []byte("a")},
+			stale: true,
+		},
+		{
+			name:  "key is not desired anymore",
+			data:  map[string][]byte{"ALICLOUD_ACCESS_KEY": []byte("a"), "ALICLOUD_SECRET_KEY": []byte("b"), "ALICLOUD_SECURITY_TOKEN": []byte("d")},
+			stale: true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.stale, isSecretDataStale(tc.data, desired))
+		})
+	}
+}
+
+func TestPauseApplyJob(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	meta := &TFConfigurationMeta{Name: "a", Namespace: "default", ApplyJobName: "a-apply"}
+
+	k8sClient := fake.NewClientBuilder().WithScheme(s).Build()
+	assert.Nil(t, meta.pauseApplyJob(ctx, k8sClient))
+
+	job := meta.assembleTerraformJob(TerraformApply)
+	assert.False(t, isJobPaused(job))
+	k8sClient = fake.NewClientBuilder().WithScheme(s).WithObjects(job).Build()
+	assert.Nil(t, meta.pauseApplyJob(ctx, k8sClient))
+
+	var got batchv1.Job
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "a-apply", Namespace: "default"}, &got))
+	assert.True(t, isJobPaused(&got))
+	assert.Equal(t, int32(1), *got.Spec.Completions)
+	assert.Nil(t, meta.pauseApplyJob(ctx, k8sClient))
+}
+
+func TestAssembleLogLevel(t *testing.T) {
+	meta := &TFConfigurationMeta{Name: "a", Namespace: "default"}
+	job := meta.assembleTerraformJob(TerraformApply)
+	assert.Empty(t, job.Spec.Template.Spec.Containers[0].Env)
+	assert.True(t, meta.V(0).Enabled())
+	assert.False(t, meta.V(4).Enabled())
+
+	meta = initTFConfigurationMeta(ctrl.Request{NamespacedName: k8stypes.NamespacedName{Name: "a", Namespace: "default"}},
+		v1beta2.Configuration{ObjectMeta: v1.ObjectMeta{
+			Name:        "a",
+			Namespace:   "default",
+			Annotations: map[string]string{tfcfg.LogLevelAnnotation: "debug"},
+		}})
+	assert.Equal(t, "DEBUG", meta.LogLevel)
+	assert.True(t, meta.V(4).Enabled())
+	assert.False(t, meta.V(6).Enabled())
+	job = meta.assembleTerraformJob(TerraformApply)
+	tfLog := corev1.EnvVar{Name: "TF_LOG", Value: "DEBUG"}
+	assert.Contains(t, job.Spec.Template.Spec.Containers[0].Env, tfLog)
+	initContainers := job.Spec.Template.Spec.InitContainers
+	assert.Contains(t, initContainers[len(initContainers)-1].Env, tfLog)
+}
+
+func TestPostApplyWait(t *testing.T) {
+	now := time.Now()
+	job := &batchv1.Job{}
+	meta := &TFConfigurationMeta{}
+	assert.Equal(t, time.Duration(0), meta.postApplyWait(job, now))
+
+	meta.PostApplyDelay = time.Minute
+	assert.Equal(t, time.Duration(0), meta.postApplyWait(job, now))
+
+	job.Status.CompletionTime = &v1.Time{Time: now.Add(-20 * time.Second)}
+	assert.Equal(t, 40*time.Second, meta.postApplyWait(job, now))
+
+	job.Status.CompletionTime = &v1.Time{Time: now.Add(-2 * time.Minute)}
+	assert.True(t, meta.postApplyWait(job, now) < 0)
+}
+
+func TestReconcileWaitsForPostApplyDelay(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+	corev1.AddToScheme(s)
+	batchv1.AddToScheme(s)
+
+	patches := gomonkey.ApplyMethod(reflect.TypeOf(&sts.Client{}), "GetCallerIdentity", func(_ *sts.Client, request *sts.GetCallerIdentityRequest) (response *sts.GetCallerIdentityResponse, err error) {
+		return nil, nil
+	})
+	defer patches.Reset()
+
+	credentials, _ := json.Marshal(&provider.AlibabaCloudCredentials{AccessKeyID: "aaaa", AccessKeySecret: "bbbbb"})
+	credentialsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"},
+		Data:       map[string][]byte{"credentials": credentials},
+	}
+	providerObj := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"},
+		Spec: v1beta1.ProviderSpec{
+			Provider: "alibaba",
+			Credentials: v1beta1.ProviderCredentials{
+				Source: "Secret",
+				SecretRef: &crossplane.SecretKeySelector{
+					SecretReference: crossplane.SecretReference{Name: "default", Namespace: "default"},
+					Key:             "credentials",
+				},
+			},
+			Region: "xxx",
+		},
+		Status: v1beta1.ProviderStatus{State: types.ProviderIsReady},
+	}
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "b", Finalizers: []string{configurationFinalizer}},
+		Spec: v1beta2.ConfigurationSpec{
+			HCL:            "c",
+			PostApplyDelay: "10m",
+		},
+		Status: v1beta2.ConfigurationStatus{
+			Apply: v1beta2.ConfigurationApplyStatus{State: types.ConfigurationProvisioningAndChecking},
+		},
+	}
+	configuration.Spec.ProviderReference = &crossplane.Reference{Name: "default", Namespace: "default"}
+	configuration.Spec.WriteConnectionSecretToReference = &crossplane.SecretReference{Name: "db-conn", Namespace: "default"}
+	// the apply job has just completed
+	applyJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "a-apply", Namespace: "b"},
+		Status: batchv1.JobStatus{
+			Succeeded:      int32(1),
+			CompletionTime: &metav1.Time{Time: time.Now()},
+		},
+	}
+	stateData, _ := base64.StdEncoding.DecodeString("H4sIAAAAAAAA/0SMwa7CIBBF9/0KMutH80ArDb9ijKHDYEhqMQO4afrvBly4POfc3H0QAt7EOaYNrDj/NS7E7ELi5/1XQI3/o4beM3F0K1ihO65xI/egNsLThLPRWi6agkR/CVIppaSZJrfgbBx6//1ItbxqyWDFfnTBlFNlpKaut+EYPgEAAP//xUXpvZsAAAA=")
+	backendSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tfstate-default-a", Namespace: "vela-system"},
+		Data:       map[string][]byte{TerraformStateNameInSecret: stateData},
+	}
+	r := &ConfigurationReconciler{}
+	r.Client = fake.NewClientBuilder().WithScheme(s).WithObjects(credentialsSecret, providerObj, configuration, applyJob, backendSecret).Build()
+
+	req := ctrl.Request{NamespacedName: k8stypes.NamespacedName{Name: "a", Namespace: "b"}}
+	for i := 0; i < 3; i++ {
+		result, err := r.Reconcile(ctx, req)
+		assert.Nil(t, err)
+		assert.True(t, result.RequeueAfter > 9*time.Minute)
+
+		// the outputs are not read before the delay
+		var got v1beta2.Configuration
+		assert.Nil(t, r.Client.Get(ctx, req.NamespacedName, &got))
+		assert.Equal(t, types.ConfigurationProvisioningAndChecking, got.Status.Apply.State)
+		assert.Contains(t, got.Status.Apply.Message, "The apply succeeded, the outputs will be read in")
+		assert.Equal(t, "", got.Status.ConfigurationHash)
+		assert.True(t, kerrors.IsNotFound(r.Client.Get(ctx, client.ObjectKey{Name: "db-conn", Namespace: "default"}, &corev1.Secret{})))
+	}
+}
+
+// secretForbiddenClient is forbidden to the verb on the secrets
+type secretForbiddenClient struct {
+	client.Client
+	verb string
+}
+
+func (c *secretForbiddenClient) forbidden(verb string, obj client.Object) error {
+	if _, ok := obj.(*corev1.Secret); ok && verb == c.verb {
+		return kerrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, obj.GetName(), errors.New("RBAC: access denied"))
+	}
+	return nil
+}
+
+func (c *secretForbiddenClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if err := c.forbidden("get", obj); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *secretForbiddenClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.forbidden("create", obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *secretForbiddenClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.forbidden("update", obj); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestPrepareVariableSecretForbidden(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	for _, verb := range []string{"get", "create", "update"} {
+		t.Run(verb, func(t *testing.T) {
+			objects := []client.Object{&v1beta2.Configuration{ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default"}}}
+			if verb == "update" {
+				objects = append(objects, &corev1.Secret{
+					ObjectMeta: v1.ObjectMeta{Name: "variable-abc", Namespace: "default"},
+					Data:       map[string][]byte{"NAME": []byte("stale")},
+				})
+			}
+			k8sClient := &secretForbiddenClient{Client: fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build(), verb: verb}
+			meta := &TFConfigurationMeta{
+				Name:               "abc",
+				Namespace:          "default",
+				VariableSecretName: "variable-abc",
+				VariableSecretData: map[string][]byte{"NAME": []byte("abc")},
+			}
+			err := meta.prepareVariableSecret(ctx, k8sClient)
+			assert.EqualError(t, err, types.ErrSecretCopyForbidden)
+
+			var configuration v1beta2.Configuration
+			assert.Nil(t, k8sClient.Client.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &configuration))
+			assert.Equal(t, types.SecretCopyForbidden, configuration.Status.Apply.State)
+			assert.Equal(t, fmt.Sprintf("The controller is forbidden to %[1]s the secret variable-abc in namespace default, grant the verb %[1]s on the resource secrets in namespace default to the controller by a Role", verb),
+				configuration.Status.Apply.Message)
+		})
+	}
+}
+
+func TestUntaint(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        "abc",
+			Namespace:   "default",
+			Annotations: map[string]string{tfcfg.UntaintAnnotation: "aws_instance.a[0]"},
+		},
+		Spec: v1beta2.ConfigurationSpec{
+			HCL: "bbb",
+		},
+	}
+	newApplyJob := func(annotations map[string]string) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: v1.ObjectMeta{Name: "abc-apply", Namespace: "default", Annotations: annotations}}
+	}
+
+	testcases := []struct {
+		name              string
+		objects           []client.Object
+		triggered         bool
+		jobDeleted        bool
+		annotationRemoved bool
+	}{
+		{
+			name:      "no apply job",
+			triggered: true,
+		},
+		{
+			name:       "apply job which doesn't untaint the resources",
+			objects:    []client.Object{newApplyJob(nil)},
+			jobDeleted: true,
+		},
+		{
+			name:              "apply job which untaints the resources",
+			objects:           []client.Object{newApplyJob(map[string]string{tfcfg.UntaintAnnotation: "aws_instance.a[0]"})},
+			triggered:         true,
+			annotationRemoved: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := append([]client.Object{configuration.DeepCopy()}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			r := &ConfigurationReconciler{Client: k8sClient}
+			meta := initTFConfigurationMeta(ctrl.Request{NamespacedName: k8stypes.NamespacedName{Name: "abc", Namespace: "default"}}, *configuration)
+			assert.Equal(t, []string{"aws_instance.a[0]"}, meta.UntaintResources)
+
+			triggered, err := r.untaint(ctx, configuration.DeepCopy(), meta)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.triggered, triggered)
+
+			var job batchv1.Job
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: "abc-apply", Namespace: "default"}, &job)
+			assert.Equal(t, tc.jobDeleted, kerrors.IsNotFound(err) && len(tc.objects) > 0)
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			_, ok := got.Annotations[tfcfg.UntaintAnnotation]
+			assert.Equal(t, tc.annotationRemoved, !ok)
+			if tc.annotationRemoved {
+				assert.Nil(t, meta.UntaintResources)
+			}
+		})
+	}
+
+	meta := &TFConfigurationMeta{Name: "abc", UntaintResources: []string{"aws_instance.a[0]"}, UntaintToken: "aws_instance.a[0]"}
+	assert.Equal(t, "terraform init && { terraform untaint -allow-missing -lock=false 'aws_instance.a[0]' || echo 'aws_instance.a[0] is not untainted'; } && "+
+		"terraform apply -lock=false -auto-approve -json", meta.assembleExecutionCommand(TerraformApply))
+	assert.Equal(t, "terraform init && terraform destroy -lock=false -auto-approve -json", meta.assembleExecutionCommand(TerraformDestroy))
+	assert.Equal(t, "aws_instance.a[0]", meta.assembleTerraformJob(TerraformApply).Annotations[tfcfg.UntaintAnnotation])
+}
+
+func TestUpdateTaintedResources(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	var state bytes.Buffer
+	w := gzip.NewWriter(&state)
+	_, err := w.Write([]byte(`{"version": 4, "resources": [{"mode": "managed", "type": "aws_s3_bucket", "name": "b", "instances": [{"status": "tainted"}]}]}`))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "tfstate-default-abc", Namespace: "vela-system"},
+		Data:       map[string][]byte{TerraformStateNameInSecret: state.Bytes()},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(secret).Build()
+	meta := &TFConfigurationMeta{Name: "abc", Namespace: "default", BackendSecretName: "tfstate-default-abc", TerraformBackendNamespace: "vela-system"}
+
+	configuration := &v1beta2.Configuration{Spec: v1beta2.ConfigurationSpec{HCL: "bbb"}}
+	meta.updateTaintedResources(ctx, k8sClient, configuration)
+	assert.Equal(t, []string{"aws_s3_bucket.b"}, configuration.Status.TaintedResources)
+	assert.Equal(t, v1.ConditionTrue, tfcfg.GetCondition(configuration, tfcfg.ConditionResourcesTainted).Status)
+
+	// the status is kept if the state can't be read
+	meta.BackendSecretName = "tfstate-default-xyz"
+	meta.updateTaintedResources(ctx, k8sClient, configuration)
+	assert.Equal(t, []string{"aws_s3_bucket.b"}, configuration.Status.TaintedResources)
+}
+
+func TestUpdateResourceCount(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	var state bytes.Buffer
+	w := gzip.NewWriter(&state)
+	_, err := w.Write([]byte(`{"version": 4, "resources": [{"mode": "managed", "type": "aws_s3_bucket", "name": "b", "instances": [{}, {}]}]}`))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "tfstate-default-abc", Namespace: "vela-system"},
+		Data:       map[string][]byte{TerraformStateNameInSecret: state.Bytes()},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(secret).Build()
+	meta := &TFConfigurationMeta{Name: "abc", Namespace: "default", BackendSecretName: "tfstate-default-abc", TerraformBackendNamespace: "vela-system"}
+
+	configuration := &v1beta2.Configuration{Spec: v1beta2.ConfigurationSpec{HCL: "bbb"}}
+	meta.updateResourceCount(ctx, k8sClient, configuration)
+	assert.Equal(t, int32(2), *configuration.Status.ResourceCount)
+	assert.Equal(t, map[string]int32{"aws_s3_bucket": 2}, configuration.Status.ResourceTypes)
+
+	// the status is kept if the state can't be read
+	meta.BackendSecretName = "tfstate-default-xyz"
+	meta.updateResourceCount(ctx, k8sClient, configuration)
+	assert.Equal(t, int32(2), *configuration.Status.ResourceCount)
+}
+
+func TestUpdateApplyStatusWithSensitiveLeak(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	var state bytes.Buffer
+	w := gzip.NewWriter(&state)
+	_, err := w.Write([]byte(`{"version": 4, "outputs": {"dsn": {"value": "admin:s3cr3t-pass@db", "type": "string"}, ` +
+		`"password": {"value": "s3cr3t-pass", "type": "string", "sensitive": true}}}`))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "tfstate-default-abc", Namespace: "vela-system"},
+		Data:       map[string][]byte{TerraformStateNameInSecret: state.Bytes()},
+	}
+	meta := &TFConfigurationMeta{Name: "abc", Namespace: "default", BackendSecretName: "tfstate-default-abc", TerraformBackendNamespace: "vela-system"}
+
+	testcases := []struct {
+		check   v1beta2.SensitiveLeakCheck
+		state   types.ConfigurationState
+		reason  string
+		outputs bool
+	}{
+		{check: v1beta2.SensitiveLeakWarn, state: types.Available, reason: "SensitiveLeakWarned", outputs: true},
+		{check: v1beta2.SensitiveLeakBlock, state: types.SensitiveLeak, reason: "SensitiveLeakBlocked", outputs: false},
+	}
+	for _, tc := range testcases {
+		t.Run(string(tc.check), func(t *testing.T) {
+			configuration := &v1beta2.Configuration{
+				ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default"},
+				Spec: v1beta2.ConfigurationSpec{
+					HCL:                "variable \"password\" {\n  sensitive = true\n}",
+					Variable:           &runtime.RawExtension{Raw: []byte(`{"password": "s3cr3t-pass"}`)},
+					SensitiveLeakCheck: tc.check,
+				},
+			}
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(secret, configuration).Build()
+			assert.Nil(t, meta.updateApplyStatus(ctx, k8sClient, types.Available, types.MessageCloudResourceDeployed))
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			assert.Equal(t, tc.state, got.Status.Apply.State)
+			assert.Equal(t, tc.outputs, got.Status.Apply.Outputs != nil)
+			condition := tfcfg.GetCondition(&got, tfcfg.ConditionSensitiveLeak)
+			assert.Equal(t, v1.ConditionTrue, condition.Status)
+			assert.Equal(t, tc.reason, condition.Reason)
+			assert.Equal(t, "The values of the sensitive variables appear in the non-sensitive outputs: dsn", condition.Message)
+		})
+	}
+}
+
+func TestPreviewDestroy(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	corev1.AddToScheme(s)
+	rbacv1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        "abc",
+			Namespace:   "default",
+			Annotations: map[string]string{tfcfg.DestroyPreviewAnnotation: "1647426620"},
+		},
+		Spec: v1beta2.ConfigurationSpec{
+			HCL: "bbb",
+		},
+		Status: v1beta2.ConfigurationStatus{
+			Apply: v1beta2.ConfigurationApplyStatus{State: types.Available},
+		},
+	}
+	newPreviewJob := func(token string, succeeded int32) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "abc-destroy-preview",
+				Namespace:   "default",
+				Annotations: map[string]string{tfcfg.DestroyPreviewAnnotation: token},
+			},
+			Status: batchv1.JobStatus{Succeeded: succeeded},
+		}
+	}
+
+	patches := gomonkey.ApplyFunc(terraform.GetTerraformLogs, func(ctx context.Context, namespace, jobName, containerName, initContainerName string) (string, error) {
+		return `{"@level":"info","@message":"aws_s3_bucket.b: Plan to delete","change":{"resource":{"addr":"aws_s3_bucket.b"},"action":"delete"},"type":"planned_change"}`, nil
+	})
+	defer patches.Reset()
+	var executionErr error
+	patches.ApplyFunc(terraform.GetTerraformStatus, func(ctx context.Context, namespace, jobName, containerName, initContainerName string) (types.ConfigurationState, error) {
+		if executionErr != nil {
+			return types.ConfigurationApplyFailed, executionErr
+		}
+		return types.ConfigurationProvisioningAndChecking, errors.New("pod is not started")
+	})
+
+	testcases := []struct {
+		name         string
+		objects      []client.Object
+		executionErr error
+		completed    bool
+		jobExists    bool
+		state        types.ConfigurationState
+		resources    []string
+	}{
+		{
+			name:      "preview job is created",
+			jobExists: true,
+			state:     types.DestroyPreviewRunning,
+		},
+		{
+			name:    "preview job of another token",
+			objects: []client.Object{newPreviewJob("1647420000", 1)},
+		},
+		{
+			name:      "preview job is running",
+			objects:   []client.Object{newPreviewJob("1647426620", 0)},
+			jobExists: true,
+		},
+		{
+			name:      "preview completes",
+			objects:   []client.Object{newPreviewJob("1647426620", 1)},
+			completed: true,
+			state:     types.DestroyPreviewCompleted,
+			resources: []string{"aws_s3_bucket.b"},
+		},
+		{
+			name:         "preview fails",
+			objects:      []client.Object{newPreviewJob("1647426620", 0)},
+			executionErr: errors.New("Error: Invalid Alibaba Cloud region"),
+			completed:    true,
+			state:        types.DestroyPreviewFailed,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			executionErr = tc.executionErr
+			objects := append([]client.Object{configuration.DeepCopy()}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			r := &ConfigurationReconciler{Client: k8sClient}
+			meta := initTFConfigurationMeta(ctrl.Request{NamespacedName: k8stypes.NamespacedName{Name: "abc", Namespace: "default"}}, *configuration)
+			assert.True(t, meta.isDestroyPreviewPending(configuration))
+
+			completed, err := r.previewDestroy(ctx, meta)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.completed, completed)
+
+			var job batchv1.Job
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: "abc-destroy-preview", Namespace: "default"}, &job)
+			assert.Equal(t, tc.jobExists, err == nil)
+			if tc.jobExists {
+				assert.Equal(t, "1647426620", job.Annotations[tfcfg.DestroyPreviewAnnotation])
+			}
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			assert.Equal(t, types.Available, got.Status.Apply.State)
+			if tc.state == "" {
+				assert.Nil(t, got.Status.DestroyPreview)
+				return
+			}
+			assert.Equal(t, "1647426620", got.Status.DestroyPreview.Token)
+			assert.Equal(t, tc.state, got.Status.DestroyPreview.State)
+			assert.Equal(t, tc.resources, got.Status.DestroyPreview.Resources)
+			assert.Equal(t, tc.state == types.DestroyPreviewRunning, meta.isDestroyPreviewPending(&got))
+		})
+	}
+
+	meta := &TFConfigurationMeta{Name: "abc", DestroyPreviewToken: "1647426620", ExtraDestroyArgs: []string{"-parallelism=5"}}
+	assert.Equal(t, "terraform init && terraform plan -destroy -lock=false -json", meta.assembleExecutionCommand(TerraformDestroyPreview))
+	assert.False(t, (&TFConfigurationMeta{}).isDestroyPreviewPending(configuration))
+}
+
+func TestCheckSavedPlan(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	corev1.AddToScheme(s)
+	rbacv1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	newConfiguration := func(annotation string, plan *v1beta2.ConfigurationPlanStatus) *v1beta2.Configuration {
+		configuration := &v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default"},
+			Spec:       v1beta2.ConfigurationSpec{HCL: "bbb", SavedPlan: true},
+			Status:     v1beta2.ConfigurationStatus{Plan: plan},
+		}
+		if annotation != "" {
+			configuration.Annotations = map[string]string{tfcfg.PlanApprovalAnnotation: annotation}
+		}
+		return configuration
+	}
+	newPlanJob := func(hash string, succeeded int32) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "abc-plan",
+				Namespace:   "default",
+				Annotations: map[string]string{planConfigurationHashAnnotation: hash},
+			},
+			Status: batchv1.JobStatus{Succeeded: succeeded},
+		}
+	}
+	pending := &v1beta2.ConfigurationPlanStatus{Checksum: "3a7bd3e2", ConfigurationHash: "h1", State: types.PlanPendingApproval}
+
+	patches := gomonkey.ApplyFunc(terraform.GetTerraformLogs, func(ctx context.Context, namespace, jobName, containerName, initContainerName string) (string, error) {
+		return `{"@level":"info","@message":"aws_s3_bucket.b: Plan to create","change":{"resource":{"addr":"aws_s3_bucket.b"},"action":"create"},"type":"planned_change"}
+PlanChecksum: 3a7bd3e2`, nil
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(terraform.GetTerraformStatus, func(ctx context.Context, namespace, jobName, containerName, initContainerName string) (types.ConfigurationState, error) {
+		return types.ConfigurationProvisioningAndChecking, errors.New("pod is not started")
+	})
+
+	testcases := []struct {
+		name          string
+		configuration *v1beta2.Configuration
+		objects       []client.Object
+		err           string
+		jobExists     bool
+		planState     types.ConfigurationState
+		applyState    types.ConfigurationState
+		approved      string
+	}{
+		{
+			name:          "plan job is created",
+			configuration: newConfiguration("", nil),
+			err:           types.MessagePlanRunning,
+			jobExists:     true,
+			planState:     types.PlanRunning,
+			applyState:    types.PlanRunning,
+		},
+		{
+			name:          "plan job is running",
+			configuration: newConfiguration("", nil),
+			objects:       []client.Object{newPlanJob("h1", 0)},
+			err:           types.MessagePlanRunning,
+			jobExists:     true,
+		},
+		{
+			name:          "plan job of other inputs",
+			configuration: newConfiguration("", nil),
+			objects:       []client.Object{newPlanJob("h0", 1)},
+			err:           types.MessagePlanRunning,
+		},
+		{
+			name:          "plan completes",
+			configuration: newConfiguration("", nil),
+			objects:       []client.Object{newPlanJob("h1", 1)},
+			err:           types.ErrPlanNotApproved,
+			planState:     types.PlanPendingApproval,
+			applyState:    types.PlanPendingApproval,
+		},
+		{
+			name:          "plan is not approved",
+			configuration: newConfiguration("0000", pending.DeepCopy()),
+			err:           types.ErrPlanNotApproved,
+			planState:     types.PlanPendingApproval,
+		},
+		{
+			name:          "plan is approved",
+			configuration: newConfiguration("3a7bd3e2", pending.DeepCopy()),
+			planState:     types.PlanApproved,
+			approved:      "3a7bd3e2",
+		},
+		{
+			name:          "inputs change after the plan",
+			configuration: newConfiguration("3a7bd3e2", &v1beta2.ConfigurationPlanStatus{Checksum: "3a7bd3e2", ConfigurationHash: "h0", State: types.PlanPendingApproval}),
+			err:           types.MessagePlanRunning,
+			jobExists:     true,
+			planState:     types.PlanRunning,
+			applyState:    types.PlanStale,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := append([]client.Object{tc.configuration.DeepCopy()}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			r := &ConfigurationReconciler{Client: k8sClient}
+			meta := initTFConfigurationMeta(ctrl.Request{NamespacedName: k8stypes.NamespacedName{Name: "abc", Namespace: "default"}}, *tc.configuration)
+			meta.ConfigurationHash = "h1"
+
+			err := r.checkSavedPlan(ctx, tc.configuration, meta)
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+			assert.Equal(t, tc.approved, meta.ApprovedPlanChecksum)
+
+			var job batchv1.Job
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: "abc-plan", Namespace: "default"}, &job)
+			assert.Equal(t, tc.jobExists, err == nil)
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			assert.Equal(t, tc.applyState, got.Status.Apply.State)
+			if tc.planState == "" {
+				assert.Nil(t, got.Status.Plan)
+				return
+			}
+			assert.Equal(t, tc.planState, got.Status.Plan.State)
+			assert.Equal(t, "h1", got.Status.Plan.ConfigurationHash)
+			if tc.planState == types.PlanPendingApproval {
+				assert.Equal(t, "3a7bd3e2", got.Status.Plan.Checksum)
+			}
+		})
+	}
+}
+
+func TestPreCheckConcurrencySetting(t *testing.T) {
+	r := &ConfigurationReconciler{}
+
+	meta := &TFConfigurationMeta{}
+	t.Setenv(MaxConcurrentJobsEnv, "10")
+	t.Setenv(MaxConcurrentJobsPerProviderEnv, "")
+	assert.Nil(t, r.preCheckConcurrencySetting(meta))
+	assert.Equal(t, 10, meta.MaxConcurrentJobs)
+	assert.Equal(t, 0, meta.MaxConcurrentJobsPerProvider)
+
+	t.Setenv(MaxConcurrentJobsPerProviderEnv, "-1")
+	err := r.preCheckConcurrencySetting(&TFConfigurationMeta{})
+	assert.EqualError(t, err, "failed to parse env variable TERRAFORM_MAX_CONCURRENT_JOBS_PER_PROVIDER into a non-negative integer")
+}
+
+func TestPreCheckCredentialsExpirySetting(t *testing.T) {
+	r := &ConfigurationReconciler{}
+
+	meta := &TFConfigurationMeta{}
+	t.Setenv(CredentialsExpiryThresholdEnv, "")
+	assert.Nil(t, r.preCheckCredentialsExpirySetting(meta))
+	assert.Equal(t, 15*time.Minute, meta.CredentialsExpiryThreshold)
+
+	t.Setenv(CredentialsExpiryThresholdEnv, "1h")
+	assert.Nil(t, r.preCheckCredentialsExpirySetting(meta))
+	assert.Equal(t, time.Hour, meta.CredentialsExpiryThreshold)
+
+	t.Setenv(CredentialsExpiryThresholdEnv, "an hour")
+	err := r.preCheckCredentialsExpirySetting(&TFConfigurationMeta{})
+	assert.EqualError(t, err, "failed to parse env variable TERRAFORM_CREDENTIALS_EXPIRY_THRESHOLD into a non-negative duration")
+}
+
+func TestIsCredentialsExpiringSoon(t *testing.T) {
+	meta := &TFConfigurationMeta{CredentialsExpiryThreshold: 15 * time.Minute}
+	assert.False(t, meta.isCredentialsExpiringSoon())
+
+	meta.CredentialsExpiration = &metav1.Time{Time: time.Now().Add(time.Hour)}
+	assert.False(t, meta.isCredentialsExpiringSoon())
+
+	meta.CredentialsExpiration = &metav1.Time{Time: time.Now().Add(5 * time.Minute)}
+	assert.True(t, meta.isCredentialsExpiringSoon())
+
+	meta.CredentialsExpiration = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+	assert.True(t, meta.isCredentialsExpiringSoon())
+}
+
+func TestIsConcurrencyLimitReached(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	newJob := func(name, namespace, providerName string, succeeded int32) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					jobCreatedByLabel:         "terraform-controller",
+					jobProviderNameLabel:      providerName,
+					jobProviderNamespaceLabel: "default",
+				},
+			},
+			Status: batchv1.JobStatus{
+				Succeeded: succeeded,
+			},
+		}
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		newJob("a-apply", "ns1", "aws", 0),
+		newJob("b-apply", "ns2", "aws", 0),
+		newJob("c-apply", "ns1", "alibaba", 0),
+		newJob("d-apply", "ns1", "alibaba", 1),
+	).Build()
+	accountJob := newJob("e-apply", "ns1", "aws-prod", 0)
+	accountJob.Labels[jobAccountLabel] = "123456789012"
+	assert.Nil(t, k8sClient.Create(ctx, accountJob))
+	// the pods of the paused job are deleted
+	pausedJob := newJob("f-apply", "ns2", "aws", 0)
+	paused := int32(0)
+	pausedJob.Spec.Parallelism = &paused
+	assert.Nil(t, k8sClient.Create(ctx, pausedJob))
+
+	newMeta := func(providerName string, limit, limitPerProvider int) *TFConfigurationMeta {
+		return &TFConfigurationMeta{
+			ProviderReference: &crossplane.Reference{
+				Name:      providerName,
+				Namespace: "default",
+			},
+			MaxConcurrentJobs:            limit,
+			MaxConcurrentJobsPerProvider: limitPerProvider,
+		}
+	}
+
+	testcases := []struct {
+		name    string
+		meta    *TFConfigurationMeta
+		reached bool
+	}{
+		{
+			name:    "no limit",
+			meta:    newMeta("aws", 0, 0),
+			reached: false,
+		},
+		{
+			name:    "cluster limit reached",
+			meta:    newMeta("aws", 3, 0),
+			reached: true,
+		},
+		{
+			name:    "succeeded and paused jobs are not counted",
+			meta:    newMeta("aws", 5, 0),
+			reached: false,
+		},
+		{
+			name:    "provider limit reached",
+			meta:    newMeta("aws", 0, 2),
+			reached: true,
+		},
+		{
+			name:    "another provider is not starved",
+			meta:    newMeta("alibaba", 0, 2),
+			reached: false,
+		},
+		{
+			name: "providers of the same account share the limit",
+			meta: &TFConfigurationMeta{
+				ProviderReference:            &crossplane.Reference{Name: "aws-dev", Namespace: "default"},
+				ProviderAccount:              "123456789012",
+				MaxConcurrentJobsPerProvider: 1,
+			},
+			reached: true,
+		},
+		{
+			name: "provider of another account is not starved",
+			meta: &TFConfigurationMeta{
+				ProviderReference:            &crossplane.Reference{Name: "aws", Namespace: "default"},
+				ProviderAccount:              "210987654321",
+				MaxConcurrentJobsPerProvider: 1,
+			},
+			reached: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			reached, err := tc.meta.isConcurrencyLimitReached(ctx, k8sClient)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.reached, reached)
+		})
+	}
+}
+
+func TestIsConcurrencyLimitReachedWithPriority(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	newConfiguration := func(name string, priority v1beta2.Priority, state types.ConfigurationState) *v1beta2.Configuration {
+		configuration := &v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: v1beta2.ConfigurationSpec{
+				Priority: priority,
+			},
+		}
+		configuration.Spec.ProviderReference = &crossplane.Reference{Name: "aws", Namespace: "default"}
+		configuration.Status.Apply.State = state
+		return configuration
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		&batchv1.Job{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "a-apply",
+				Namespace: "default",
+				Labels: map[string]string{
+					jobCreatedByLabel:         "terraform-controller",
+					jobProviderNameLabel:      "aws",
+					jobProviderNamespaceLabel: "default",
+				},
+			},
+		},
+		newConfiguration("prod", v1beta2.PriorityHigh, types.ConfigurationPendingOnConcurrency),
+		newConfiguration("staging", v1beta2.PriorityHigh, types.Available),
+		newConfiguration("dev", "", types.ConfigurationPendingOnConcurrency),
+	).Build()
+
+	newMeta := func(providerName string, priority v1beta2.Priority, limit, limitPerProvider int) *TFConfigurationMeta {
+		return &TFConfigurationMeta{
+			ProviderReference: &crossplane.Reference{
+				Name:      providerName,
+				Namespace: "default",
+			},
+			Priority:                     priority,
+			MaxConcurrentJobs:            limit,
+			MaxConcurrentJobsPerProvider: limitPerProvider,
+		}
+	}
+
+	testcases := []struct {
+		name    string
+		meta    *TFConfigurationMeta
+		reached bool
+	}{
+		{
+			name:    "the slot is kept for the pending Configuration of a higher priority",
+			meta:    newMeta("aws", v1beta2.PriorityNormal, 2, 0),
+			reached: true,
+		},
+		{
+			name:    "the pending Configurations of the same priority don't keep the slot",
+			meta:    newMeta("aws", v1beta2.PriorityHigh, 2, 0),
+			reached: false,
+		},
+		{
+			name:    "the pending Configurations of higher priorities keep the slots",
+			meta:    newMeta("aws", v1beta2.PriorityLow, 3, 0),
+			reached: true,
+		},
+		{
+			name:    "the free slots are more than the pending Configurations",
+			meta:    newMeta("aws", v1beta2.PriorityNormal, 3, 0),
+			reached: false,
+		},
+		{
+			name:    "the slot of the Provider is kept for the pending Configuration of a higher priority",
+			meta:    newMeta("aws", v1beta2.PriorityNormal, 0, 2),
+			reached: true,
+		},
+		{
+			name:    "the pending Configurations of another Provider don't keep the slot of the Provider",
+			meta:    newMeta("alibaba", v1beta2.PriorityLow, 0, 1),
+			reached: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			reached, err := tc.meta.isConcurrencyLimitReached(ctx, k8sClient)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.reached, reached)
+		})
+	}
+}
+
+func TestCountPendingOfHigherPriority(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	newProvider := func(name, account string) *v1beta1.Provider {
+		return &v1beta1.Provider{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1beta1.ProviderSpec{Account: account},
+		}
+	}
+	newConfiguration := func(name, namespace string, ref *crossplane.Reference) *v1beta2.Configuration {
+		configuration := &v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: v1beta2.ConfigurationSpec{
+				Priority: v1beta2.PriorityHigh,
+			},
+		}
+		configuration.Spec.ProviderReference = ref
+		configuration.Status.Apply.State = types.ConfigurationPendingOnConcurrency
+		return configuration
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		&corev1.Namespace{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "team",
+				Annotations: map[string]string{tfcfg.DefaultProviderAnnotation: "default/aws-prod"},
+			},
+		},
+		newProvider("aws-prod", "123456"),
+		newProvider("aws-dev", "123456"),
+		newProvider("alibaba", ""),
+		newConfiguration("a", "team", nil),
+		newConfiguration("b", "default", &crossplane.Reference{Name: "alibaba", Namespace: "default"}),
+	).Build()
+
+	testcases := []struct {
+		name              string
+		meta              *TFConfigurationMeta
+		pendingOfProvider int
+	}{
+		{
+			name: "the Provider is resolved by the default provider annotation of the namespace",
+			meta: &TFConfigurationMeta{
+				ProviderReference: &crossplane.Reference{Name: "aws-prod", Namespace: "default"},
+			},
+			pendingOfProvider: 1,
+		},
+		{
+			name: "the Providers of the same cloud account are the same one",
+			meta: &TFConfigurationMeta{
+				ProviderReference: &crossplane.Reference{Name: "aws-dev", Namespace: "default"},
+				ProviderAccount:   "123456",
+			},
+			pendingOfProvider: 1,
+		},
+		{
+			name: "the Provider without an account is compared by its name",
+			meta: &TFConfigurationMeta{
+				ProviderReference: &crossplane.Reference{Name: "alibaba", Namespace: "default"},
+			},
+			pendingOfProvider: 1,
+		},
+		{
+			name: "the pending Configurations of other Providers are not counted for the Provider",
+			meta: &TFConfigurationMeta{
+				ProviderReference: &crossplane.Reference{Name: "default", Namespace: "default"},
+			},
+			pendingOfProvider: 0,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pending, pendingOfProvider, err := tc.meta.countPendingOfHigherPriority(ctx, k8sClient)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, pending)
+			assert.Equal(t, tc.pendingOfProvider, pendingOfProvider)
+		})
+	}
+}
+
 func TestPreCheckResourcesSetting(t *testing.T) {
 	r := &ConfigurationReconciler{}
 	s := runtime.NewScheme()
@@ -676,7 +2334,8 @@ func TestPreCheck(t *testing.T) {
 	}
 
 	type want struct {
-		errMsg string
+		errMsg         string
+		terraformImage string
 	}
 
 	type prepare func(*testing.T)
@@ -910,6 +2569,31 @@ func TestPreCheck(t *testing.T) {
 			},
 			want: want{},
 		},
+		{
+			name: "runner image overrides the default image",
+			args: args{
+				r: r,
+				configuration: &v1beta2.Configuration{
+					ObjectMeta: v1.ObjectMeta{
+						Name: "abc",
+					},
+					Spec: v1beta2.ConfigurationSpec{
+						HCL:         "bbb",
+						RunnerImage: "registry.example.com/infra/docker-terraform:1.1.2",
+					},
+				},
+				meta: &TFConfigurationMeta{
+					ConfigurationCMName: "abc",
+					ProviderReference: &crossplane.Reference{
+						Namespace: "default",
+						Name:      "default",
+					},
+				},
+			},
+			want: want{
+				terraformImage: "registry.example.com/infra/docker-terraform:1.1.2",
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -920,6 +2604,9 @@ func TestPreCheck(t *testing.T) {
 					t.Errorf("preCheck() error = %v, wantErr %v", err, tc.want.errMsg)
 				}
 			}
+			if tc.want.terraformImage != "" {
+				assert.Equal(t, tc.want.terraformImage, tc.args.meta.TerraformImage)
+			}
 		})
 	}
 }
@@ -1104,86 +2791,804 @@ func TestTerraformDestroy(t *testing.T) {
 		VariableSecretName: "c",
 	}
 
-	type args struct {
-		r             *ConfigurationReconciler
-		namespace     string
-		configuration *v1beta2.Configuration
-		meta          *TFConfigurationMeta
-	}
-	type want struct {
-		errMsg string
-	}
+	type args struct {
+		r             *ConfigurationReconciler
+		namespace     string
+		configuration *v1beta2.Configuration
+		meta          *TFConfigurationMeta
+	}
+	type want struct {
+		errMsg string
+	}
+	testcases := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "provider is not ready",
+			args: args{
+				r:             r1,
+				configuration: &v1beta2.Configuration{},
+				meta: &TFConfigurationMeta{
+					ConfigurationCMName: "tf-abc",
+					Namespace:           "default",
+				},
+			},
+			want: want{},
+		},
+		{
+			name: "referenced provider is not available",
+			args: args{
+				r:             r2,
+				configuration: configuration,
+				meta: &TFConfigurationMeta{
+					ConfigurationCMName: "tf-abc",
+					Namespace:           "default",
+					DeleteResource:      true,
+				},
+			},
+			want: want{},
+		},
+		{
+			name: "could not directly remove resources, and destroy job completes",
+			args: args{
+				r:             r4,
+				configuration: configuration4,
+				meta:          meta4,
+			},
+			want: want{},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.args.r.terraformDestroy(ctx, tc.args.namespace, *tc.args.configuration, tc.args.meta)
+			if err != nil || tc.want.errMsg != "" {
+				if !strings.Contains(err.Error(), tc.want.errMsg) {
+					t.Errorf("terraformDestroy() error = %v, wantErr %v", err, tc.want.errMsg)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestTerraformDestroyExistingStateFound(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+	corev1.AddToScheme(s)
+	batchv1.AddToScheme(s)
+
+	created := metav1.NewTime(time.Now())
+	providerObj := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"},
+		Status:     v1beta1.ProviderStatus{State: types.ProviderIsReady},
+	}
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "default", CreationTimestamp: created},
+		Status: v1beta2.ConfigurationStatus{
+			Apply: v1beta2.ConfigurationApplyStatus{State: types.ExistingStateFound},
+		},
+	}
+	configuration.Spec.ProviderReference = &crossplane.Reference{Name: "default", Namespace: "default"}
+	// the state is left by a previous Configuration
+	backendSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "tfstate-default-abc",
+			Namespace:         "vela-system",
+			CreationTimestamp: metav1.NewTime(created.Add(-time.Hour)),
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(providerObj, configuration, backendSecret).Build()
+	r := &ConfigurationReconciler{Client: k8sClient}
+	meta := &TFConfigurationMeta{
+		Name:                      "abc",
+		Namespace:                 "default",
+		ConfigurationCMName:       "tf-abc",
+		DestroyJobName:            "abc-destroy",
+		DeleteResource:            true,
+		BackendSecretName:         "tfstate-default-abc",
+		TerraformBackendNamespace: "vela-system",
+		ProviderReference:         &crossplane.Reference{Name: "default", Namespace: "default"},
+	}
+
+	assert.Nil(t, r.terraformDestroy(ctx, "default", *configuration, meta))
+	var job batchv1.Job
+	assert.True(t, kerrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKey{Name: "abc-destroy", Namespace: "default"}, &job)))
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "tfstate-default-abc", Namespace: "vela-system"}, &corev1.Secret{}))
+
+	// the state created by the Configuration itself is deleted
+	configuration.Status.Apply.State = types.Available
+	owned, err := meta.ownsBackendSecret(ctx, k8sClient, configuration, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created}})
+	assert.Nil(t, err)
+	assert.True(t, owned)
+	owned, _ = meta.ownsBackendSecret(ctx, k8sClient, configuration, backendSecret)
+	assert.False(t, owned)
+	configuration.Status.StateSecretRef = &crossplane.SecretReference{Name: "tfstate-default-abc", Namespace: "vela-system"}
+	owned, _ = meta.ownsBackendSecret(ctx, k8sClient, configuration, backendSecret)
+	assert.True(t, owned)
+}
+
+func TestTerraformDestroyBackendKeyCollision(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+	corev1.AddToScheme(s)
+	batchv1.AddToScheme(s)
+
+	created := metav1.NewTime(time.Now())
+	providerObj := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"},
+		Status:     v1beta1.ProviderStatus{State: types.ProviderIsReady},
+	}
+	// both store the state in tfstate-default-abc, the older one keeps it
+	older := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "team-a", UID: "a", CreationTimestamp: metav1.NewTime(created.Add(-time.Hour))},
+	}
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "team-b", UID: "b", CreationTimestamp: created},
+		Status: v1beta2.ConfigurationStatus{
+			Apply: v1beta2.ConfigurationApplyStatus{State: types.BackendKeyCollision},
+		},
+	}
+	configuration.Spec.ProviderReference = &crossplane.Reference{Name: "default", Namespace: "default"}
+	backendSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tfstate-default-abc", Namespace: "vela-system", CreationTimestamp: created},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(providerObj, older, configuration, backendSecret).Build()
+	r := &ConfigurationReconciler{Client: k8sClient}
+	meta := &TFConfigurationMeta{
+		Name:                      "abc",
+		Namespace:                 "team-b",
+		ConfigurationCMName:       "tf-abc",
+		DestroyJobName:            "abc-destroy",
+		DeleteResource:            true,
+		BackendSecretName:         "tfstate-default-abc",
+		TerraformBackendNamespace: "vela-system",
+		ProviderReference:         &crossplane.Reference{Name: "default", Namespace: "default"},
+	}
+
+	assert.Nil(t, r.terraformDestroy(ctx, "team-b", *configuration, meta))
+	var job batchv1.Job
+	assert.True(t, kerrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKey{Name: "abc-destroy", Namespace: "team-b"}, &job)))
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "tfstate-default-abc", Namespace: "vela-system"}, &corev1.Secret{}))
+}
+
+func TestCheckDestroyBackend(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+	corev1.AddToScheme(s)
+
+	previous := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tfstate-default-abc", Namespace: "vela-system"}}
+	target := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tfstate-default-abc", Namespace: "terraform"}}
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "default"},
+		Status: v1beta2.ConfigurationStatus{
+			StateSecretRef: &crossplane.SecretReference{Name: "tfstate-default-abc", Namespace: "vela-system"},
+		},
+	}
+	meta := &TFConfigurationMeta{
+		Name:                      "abc",
+		Namespace:                 "default",
+		BackendSecretName:         "tfstate-default-abc",
+		TerraformBackendNamespace: "terraform",
+	}
+
+	// the state in the changed backend belongs to another Configuration
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(previous, target, configuration).Build()
+	err := meta.checkDestroyBackend(ctx, k8sClient, configuration)
+	var blockedErr *tfcfg.DestroyBlockedError
+	assert.True(t, errors.As(err, &blockedErr))
+	assert.Equal(t, types.BackendMigrationRequired, blockedErr.State)
+	assert.Equal(t, "Terraform state vela-system/tfstate-default-abc is not migrated to the changed backend yet, revert spec.backend to destroy the cloud resources in it",
+		blockedErr.Message)
+
+	// the state is migrated if nothing is in the changed backend
+	k8sClient = fake.NewClientBuilder().WithScheme(s).WithObjects(previous, configuration).Build()
+	assert.Nil(t, meta.checkDestroyBackend(ctx, k8sClient, configuration))
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "tfstate-default-abc", Namespace: "terraform"}, &corev1.Secret{}))
+
+	// the backend is not changed
+	meta.TerraformBackendNamespace = "vela-system"
+	assert.Nil(t, meta.checkDestroyBackend(ctx, k8sClient, configuration))
+}
+
+func TestAssembleTerraformJob(t *testing.T) {
+	meta := &TFConfigurationMeta{
+		Name:                "a",
+		ConfigurationCMName: "b",
+		BusyboxImage:        "c",
+		GitImage:            "d",
+		Namespace:           "e",
+		TerraformImage:      "f",
+		RemoteGit:           "g",
+	}
+	job := meta.assembleTerraformJob(TerraformApply)
+	containers := job.Spec.Template.Spec.InitContainers
+	assert.Equal(t, containers[0].Image, "c")
+	assert.Equal(t, containers[1].Image, "d")
+	assert.Equal(t, ServiceAccountName, job.Spec.Template.Spec.ServiceAccountName)
+
+	meta.ServiceAccountName = "workload-identity"
+	job = meta.assembleTerraformJob(TerraformApply)
+	assert.Equal(t, "workload-identity", job.Spec.Template.Spec.ServiceAccountName)
+	assert.Nil(t, job.Annotations)
+
+	meta.ApplyNowToken = "1647426620"
+	job = meta.assembleTerraformJob(TerraformApply)
+	assert.Equal(t, "1647426620", job.Annotations[tfcfg.ApplyNowAnnotation])
+	job = meta.assembleTerraformJob(TerraformDestroy)
+	assert.Nil(t, job.Annotations)
+}
+
+func TestPrepareComplexTFVariables(t *testing.T) {
+	configuration := &v1beta2.Configuration{
+		Spec: v1beta2.ConfigurationSpec{
+			Variable: &runtime.RawExtension{
+				Raw: []byte(`{"name":"abc","zones":["a","b"],"tags":{"env":"prod","count":2}}`),
+			},
+		},
+	}
+	meta := &TFConfigurationMeta{
+		Name:               "a",
+		VariableSecretName: "variable-a",
+		ProviderReference:  &crossplane.Reference{Name: "default", Namespace: "default"},
+		Credentials:        map[string]string{"ALICLOUD_ACCESS_KEY": "xxx"},
+	}
+	assert.Nil(t, meta.prepareTFVariables(configuration))
+	assert.Equal(t, []byte("abc"), meta.VariableSecretData["TF_VAR_name"])
+	assert.NotContains(t, meta.VariableSecretData, "TF_VAR_zones")
+	assert.JSONEq(t, `{"zones":["a","b"],"tags":{"env":"prod","count":2}}`,
+		string(meta.VariableSecretData[types.TerraformVariablesFileName]))
+	for _, env := range meta.Envs {
+		assert.NotEqual(t, "TF_VAR_tags", env.Name)
+	}
+
+	job := meta.assembleTerraformJob(TerraformApply)
+	volumes := job.Spec.Template.Spec.Volumes
+	assert.Equal(t, VariablesVolumeName, volumes[len(volumes)-1].Name)
+	assert.Equal(t, "variable-a", volumes[len(volumes)-1].Secret.SecretName)
+	mounts := job.Spec.Template.Spec.Containers[0].VolumeMounts
+	assert.Equal(t, "/data/variables.auto.tfvars.json", mounts[len(mounts)-1].MountPath)
+	assert.Equal(t, types.TerraformVariablesFileName, mounts[len(mounts)-1].SubPath)
+
+	configuration.Spec.Variable = &runtime.RawExtension{Raw: []byte(`{"name":"abc"}`)}
+	assert.Nil(t, meta.prepareTFVariables(configuration))
+	assert.False(t, meta.hasVariablesFile())
+	assert.Equal(t, 3, len(meta.assembleExecutorVolumes()))
+}
+
+func TestPrepareCredentialsFile(t *testing.T) {
+	configuration := &v1beta2.Configuration{
+		Spec: v1beta2.ConfigurationSpec{
+			Variable: &runtime.RawExtension{Raw: []byte(`{"name":"abc"}`)},
+		},
+	}
+	key := `{"type":"service_account","private_key":"s3cr3t-key"}`
+	meta := &TFConfigurationMeta{
+		Name:               "a",
+		VariableSecretName: "variable-a",
+		ProviderReference:  &crossplane.Reference{Name: "default", Namespace: "default"},
+		Credentials: map[string]string{
+			provider.CredentialsFileKey:      key,
+			"GOOGLE_APPLICATION_CREDENTIALS": provider.CredentialsFilePath,
+		},
+	}
+	assert.Nil(t, meta.prepareTFVariables(configuration))
+	assert.Equal(t, []byte(key), meta.VariableSecretData[provider.CredentialsFileKey])
+	var envs []string
+	for _, env := range meta.Envs {
+		envs = append(envs, env.Name)
+	}
+	assert.ElementsMatch(t, []string{"TF_VAR_name", "GOOGLE_APPLICATION_CREDENTIALS"}, envs)
+
+	job := meta.assembleTerraformJob(TerraformApply)
+	volumes := job.Spec.Template.Spec.Volumes
+	credentialsVolume := volumes[len(volumes)-1]
+	assert.Equal(t, CredentialsFileVolumeName, credentialsVolume.Name)
+	assert.Equal(t, "variable-a", credentialsVolume.Secret.SecretName)
+	assert.Equal(t, []corev1.KeyToPath{{Key: provider.CredentialsFileKey, Path: "credentials"}}, credentialsVolume.Secret.Items)
+	assert.Equal(t, int32(0400), *credentialsVolume.Secret.DefaultMode)
+	assert.Contains(t, job.Spec.Template.Spec.Containers[0].VolumeMounts,
+		corev1.VolumeMount{Name: CredentialsFileVolumeName, MountPath: "/opt/tf-credentials", ReadOnly: true})
+}
+
+func TestCheckServiceAccount(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(&corev1.ServiceAccount{
+		ObjectMeta: v1.ObjectMeta{Name: "workload-identity", Namespace: "default"},
+	}).Build()
+
+	meta := &TFConfigurationMeta{Namespace: "default"}
+	assert.Nil(t, meta.checkServiceAccount(ctx, k8sClient))
+
+	meta.ServiceAccountName = "workload-identity"
+	assert.Nil(t, meta.checkServiceAccount(ctx, k8sClient))
+
+	meta.Namespace = "ns1"
+	assert.EqualError(t, meta.checkServiceAccount(ctx, k8sClient), "ServiceAccount workload-identity is not found in namespace ns1")
+
+	meta.ServiceAccountName = "default"
+	assert.Contains(t, meta.checkServiceAccount(ctx, k8sClient).Error(), "the ServiceAccount default could not be")
+}
+
+func TestAssembleAndTriggerJobWithServiceAccount(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	batchv1.AddToScheme(s)
+	rbacv1.AddToScheme(s)
+	k8sClient := fake.NewClientBuilder().WithScheme(s).Build()
+
+	meta := &TFConfigurationMeta{
+		Name:                      "a",
+		Namespace:                 "default",
+		ApplyJobName:              "a-apply",
+		BackendSecretName:         "tfstate-default-a",
+		TerraformBackendNamespace: "vela-system",
+		ServiceAccountName:        "workload-identity",
+	}
+	assert.Nil(t, meta.assembleAndTriggerJob(ctx, k8sClient, TerraformApply))
+
+	// the ServiceAccount set by users is not bound to the ClusterRole
+	var clusterRoleBindings rbacv1.ClusterRoleBindingList
+	assert.Nil(t, k8sClient.List(ctx, &clusterRoleBindings))
+	assert.Equal(t, 0, len(clusterRoleBindings.Items))
+
+	name := executorRoleName("default", "a")
+	var role rbacv1.Role
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: "vela-system"}, &role))
+	assert.Equal(t, []string{"tfstate-default-a"}, role.Rules[0].ResourceNames)
+	assert.Equal(t, []string{"lock-tfstate-default-a"}, role.Rules[2].ResourceNames)
+	for _, rule := range role.Rules {
+		assert.NotContains(t, rule.Verbs, "list")
+	}
+	var roleBinding rbacv1.RoleBinding
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: "vela-system"}, &roleBinding))
+	assert.Equal(t, "workload-identity", roleBinding.Subjects[0].Name)
+	assert.Equal(t, "default", roleBinding.Subjects[0].Namespace)
+
+	// the binding follows the change of spec.ServiceAccountName
+	assert.Nil(t, k8sClient.Delete(ctx, &batchv1.Job{ObjectMeta: v1.ObjectMeta{Name: "a-apply", Namespace: "default"}}))
+	meta.ServiceAccountName = "another"
+	assert.Nil(t, meta.assembleAndTriggerJob(ctx, k8sClient, TerraformApply))
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: "vela-system"}, &roleBinding))
+	assert.Equal(t, "another", roleBinding.Subjects[0].Name)
+
+	// the names don't collide for the namespaces and the names which join into the same string
+	assert.NotEqual(t, executorRoleName("a-b", "c"), executorRoleName("a", "b-c"))
+
+	assert.Nil(t, deleteTerraformExecutorRole(ctx, k8sClient, meta))
+	assert.True(t, kerrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: "vela-system"}, &role)))
+	assert.True(t, kerrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: "vela-system"}, &roleBinding)))
+}
+
+func TestAssembleExecutionCommand(t *testing.T) {
+	meta := &TFConfigurationMeta{
+		ExtraApplyArgs:   []string{"-compact-warnings", "-parallelism=5"},
+		ExtraDestroyArgs: []string{"-lock-timeout=30s"},
+	}
+	assert.Equal(t, "terraform init && terraform apply -lock=false -auto-approve -json -compact-warnings -parallelism=5",
+		meta.assembleExecutionCommand(TerraformApply))
+	assert.Equal(t, "terraform init && terraform destroy -lock=false -auto-approve -json -lock-timeout=30s",
+		meta.assembleExecutionCommand(TerraformDestroy))
+
+	job := (&TFConfigurationMeta{Name: "a"}).assembleTerraformJob(TerraformApply)
+	assert.True(t, strings.HasPrefix(job.Spec.Template.Spec.Containers[0].Command[2], "(terraform init && terraform apply -lock=false -auto-approve -json) & pid=$!; "))
+
+	meta = &TFConfigurationMeta{Name: "a", LockTimeout: "30s"}
+	assert.Equal(t, "terraform init -lock-timeout=30s && terraform destroy -lock-timeout=30s -auto-approve -json",
+		meta.assembleExecutionCommand(TerraformDestroy))
+	job = meta.assembleTerraformJob(TerraformApply)
+	initContainers := job.Spec.Template.Spec.InitContainers
+	assert.Equal(t, "terraform init -lock-timeout=30s", initContainers[len(initContainers)-1].Command[2])
+
+	meta.SkipDestroy = []string{"aws_s3_bucket.data"}
+	assert.Equal(t, "terraform init -lock-timeout=30s && found=$(terraform state list 'aws_s3_bucket.data') && "+
+		`if [ -n "$found" ]; then terraform state rm -lock-timeout=30s 'aws_s3_bucket.data'; fi && `+
+		"terraform destroy -lock-timeout=30s -auto-approve -json",
+		meta.assembleExecutionCommand(TerraformDestroy))
+	assert.Equal(t, "terraform init -lock-timeout=30s && terraform apply -lock-timeout=30s -auto-approve -json",
+		meta.assembleExecutionCommand(TerraformApply))
+}
+
+func TestAssembleExecutionCommandWithSavedPlan(t *testing.T) {
+	meta := &TFConfigurationMeta{ExtraApplyArgs: []string{"-parallelism=5"}}
+	checksum := "$(terraform show -no-color /data/tfplan | sha256sum | cut -d' ' -f1)"
+	assert.Equal(t, `terraform init && terraform plan -input=false -lock=false -out=/data/tfplan -json && echo "PlanChecksum: `+checksum+`"`,
+		meta.assembleExecutionCommand(TerraformPlan))
+
+	meta.ApprovedPlanChecksum = "3a7bd3e2"
+	assert.Equal(t, "terraform init && terraform plan -input=false -lock=false -out=/data/tfplan -json && sum="+checksum+
+		` && { [ "$sum" = "3a7bd3e2" ] || { echo 'PlanStale: The planned changes differ from the approved plan 3a7bd3e2, the changes are planned again'; exit 1; }; }`+
+		" && terraform apply -lock=false -auto-approve -json -parallelism=5 /data/tfplan",
+		meta.assembleExecutionCommand(TerraformApply))
+	assert.Equal(t, "terraform init && terraform destroy -lock=false -auto-approve -json", meta.assembleExecutionCommand(TerraformDestroy))
+
+	t.Setenv(terraform.CostEstimatorEnv, terraform.CostEstimatorInfracost)
+	estimator, err := terraform.GetCostEstimator()
+	assert.Nil(t, err)
+	meta.CostEstimator = estimator
+	assert.Equal(t, `terraform init && terraform plan -input=false -lock=false -out=/data/tfplan -json && echo "PlanChecksum: `+checksum+`"`+
+		` && { terraform show -json /data/tfplan > /data/tfplan.json && estimate=$(infracost breakdown --path /data/tfplan.json --format json --log-level error | tr -d '\n')`+
+		` && echo "CostEstimate: $estimate" || echo 'failed to estimate the cost of the plan by infracost' >&2; }`,
+		meta.assembleExecutionCommand(TerraformPlan))
+}
+
+func TestAssembleInterruptibleCommand(t *testing.T) {
+	meta := &TFConfigurationMeta{Name: "a"}
+	assert.Equal(t, "(terraform init && terraform apply -lock=false -auto-approve -json) & pid=$!; trap 'kill -INT 0' TERM; trap : INT; "+
+		"wait $pid; code=$?; while kill -0 $pid 2>/dev/null; do wait $pid; code=$?; done; trap - TERM INT; (exit $code)",
+		meta.assembleInterruptibleCommand(TerraformApply))
+	assert.Equal(t, meta.assembleExecutionCommand(TerraformDriftCheck), meta.assembleInterruptibleCommand(TerraformDriftCheck))
+	assert.Nil(t, meta.assembleTerraformJob(TerraformApply).Spec.Template.Spec.TerminationGracePeriodSeconds)
+
+	meta.CancelGracePeriod = 5 * time.Minute
+	assert.Equal(t, int64(300), *meta.assembleTerraformJob(TerraformDestroy).Spec.Template.Spec.TerminationGracePeriodSeconds)
+	assert.Nil(t, meta.assembleTerraformJob(TerraformPlan).Spec.Template.Spec.TerminationGracePeriodSeconds)
+}
+
+func TestCancelApplyJob(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+	batchv1.AddToScheme(s)
+	configuration := &v1beta2.Configuration{ObjectMeta: v1.ObjectMeta{Name: "a", Namespace: "default"}}
+	meta := &TFConfigurationMeta{Name: "a", Namespace: "default", ApplyJobName: "a-apply"}
+
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(configuration).Build()
+	assert.Nil(t, meta.cancelApplyJob(ctx, k8sClient))
+
+	completed := meta.assembleTerraformJob(TerraformApply)
+	completed.Status.Succeeded = 1
+	k8sClient = fake.NewClientBuilder().WithScheme(s).WithObjects(configuration, completed).Build()
+	assert.Nil(t, meta.cancelApplyJob(ctx, k8sClient))
+
+	running := meta.assembleTerraformJob(TerraformApply)
+	running.Status.Active = 1
+	k8sClient = fake.NewClientBuilder().WithScheme(s).WithObjects(configuration, running).Build()
+	assert.EqualError(t, meta.cancelApplyJob(ctx, k8sClient), types.ErrApplyCancelling)
+	var got v1beta2.Configuration
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "a", Namespace: "default"}, &got))
+	assert.Equal(t, types.ApplyCancelled, got.Status.Apply.State)
+	assert.Equal(t, types.MessageApplyCancelled, got.Status.Apply.Message)
+	assert.True(t, kerrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKey{Name: "a-apply", Namespace: "default"}, &batchv1.Job{})))
+
+	stopping := meta.assembleTerraformJob(TerraformApply)
+	stopping.Finalizers = []string{"foregroundDeletion"}
+	k8sClient = fake.NewClientBuilder().WithScheme(s).WithObjects(configuration, stopping).Build()
+	assert.Nil(t, k8sClient.Delete(ctx, stopping))
+	assert.EqualError(t, meta.cancelApplyJob(ctx, k8sClient), types.ErrApplyCancelling)
+}
+
+func TestAssembleCleanupCommand(t *testing.T) {
+	removeSensitiveFiles := "for f in /data/terraform.tfstate.backup /data/errored.tfstate /data/.terraform/terraform.tfstate /data/tfplan; " +
+		"do [ -f $f ] && (shred -u $f 2>/dev/null || rm -f $f); done; " +
+		"if [ $code -eq 0 ] && [ -f /data/terraform.tfstate ]; then shred -u /data/terraform.tfstate 2>/dev/null || rm -f /data/terraform.tfstate; fi"
+
 	testcases := []struct {
-		name string
-		args args
-		want want
+		policy       v1beta2.WorkingDirectoryCleanupPolicy
+		command      string
+		cloneCleanup string
 	}{
 		{
-			name: "provider is not ready",
-			args: args{
-				r:             r1,
-				configuration: &v1beta2.Configuration{},
-				meta: &TFConfigurationMeta{
-					ConfigurationCMName: "tf-abc",
-					Namespace:           "default",
-				},
-			},
-			want: want{
-				errMsg: "jobs.batch \"\" not found",
-			},
+			policy:       "",
+			command:      "terraform apply; code=$?; " + removeSensitiveFiles + "; if [ $code -eq 0 ]; then rm -rf /data/.terraform; fi; exit $code",
+			cloneCleanup: " && find /opt/tf-backend -mindepth 1 -delete",
 		},
 		{
-			name: "referenced provider is not available",
-			args: args{
-				r:             r2,
-				configuration: configuration,
-				meta: &TFConfigurationMeta{
-					ConfigurationCMName: "tf-abc",
-					Namespace:           "default",
-					DeleteResource:      true,
-				},
-			},
-			want: want{
-				errMsg: "jobs.batch \"\" not found",
-			},
+			policy:       v1beta2.CleanupOnSuccess,
+			command:      "terraform apply; code=$?; " + removeSensitiveFiles + "; if [ $code -eq 0 ]; then rm -rf /data/.terraform; fi; exit $code",
+			cloneCleanup: " && find /opt/tf-backend -mindepth 1 -delete",
 		},
 		{
-			name: "could not directly remove resources, and destroy job completes",
-			args: args{
-				r:             r4,
-				configuration: configuration4,
-				meta:          meta4,
-			},
-			want: want{},
+			policy:       v1beta2.CleanupAlways,
+			command:      "terraform apply; code=$?; " + removeSensitiveFiles + "; rm -rf /data/.terraform; exit $code",
+			cloneCleanup: " && find /opt/tf-backend -mindepth 1 -delete",
+		},
+		{
+			policy:  v1beta2.CleanupNever,
+			command: "terraform apply; code=$?; " + removeSensitiveFiles + "; exit $code",
 		},
 	}
 	for _, tc := range testcases {
-		t.Run(tc.name, func(t *testing.T) {
-			err := tc.args.r.terraformDestroy(ctx, tc.args.namespace, *tc.args.configuration, tc.args.meta)
-			if err != nil || tc.want.errMsg != "" {
-				if !strings.Contains(err.Error(), tc.want.errMsg) {
-					t.Errorf("terraformDestroy() error = %v, wantErr %v", err, tc.want.errMsg)
-					return
-				}
-			}
+		t.Run(string(tc.policy), func(t *testing.T) {
+			meta := &TFConfigurationMeta{WorkingDirectoryCleanupPolicy: tc.policy}
+			assert.Equal(t, tc.command, meta.assembleCleanupCommand(TerraformApply, "terraform apply"))
+			assert.Equal(t, tc.cloneCleanup, meta.assembleCloneCleanupCommand())
 		})
 	}
 }
 
-func TestAssembleTerraformJob(t *testing.T) {
+func TestAssemblePreflightCommand(t *testing.T) {
+	meta := initTFConfigurationMeta(ctrl.Request{NamespacedName: k8stypes.NamespacedName{Name: "a", Namespace: "default"}},
+		v1beta2.Configuration{Spec: v1beta2.ConfigurationSpec{HCL: "abc"}})
+	assert.Equal(t, "", meta.assemblePreflightCommand())
+
+	meta = initTFConfigurationMeta(ctrl.Request{NamespacedName: k8stypes.NamespacedName{Name: "a", Namespace: "default"}},
+		v1beta2.Configuration{Spec: v1beta2.ConfigurationSpec{HCL: "abc", RegistryPreflight: &v1beta2.RegistryPreflight{}}})
+	assert.Equal(t, "{ wget -q -T 10 --spider 'https://registry.terraform.io/.well-known/terraform.json' || "+
+		"{ echo 'RegistryUnreachable: The provider registry https://registry.terraform.io/.well-known/terraform.json "+
+		"is unreachable from the Terraform executor'; exit 1; }; } && ", meta.assemblePreflightCommand())
+
+	meta.RegistryPreflightEndpoint = "https://mirror.example.com/providers/"
+	job := meta.assembleTerraformJob(TerraformApply)
+	initContainers := job.Spec.Template.Spec.InitContainers
+	assert.Equal(t, "{ wget -q -T 10 --spider 'https://mirror.example.com/providers/' || "+
+		"{ echo 'RegistryUnreachable: The provider registry https://mirror.example.com/providers/ "+
+		"is unreachable from the Terraform executor'; exit 1; }; } && terraform init", initContainers[len(initContainers)-1].Command[2])
+}
+
+func TestProviderLockFile(t *testing.T) {
+	ctx := context.Background()
+	lockFile := `provider "registry.terraform.io/hashicorp/alicloud" {
+  version = "1.190.0"
+}`
+	k8sClient := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "lock", Namespace: "default"},
+			Data:       map[string]string{types.TerraformLockFileName: lockFile},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "no-lock", Namespace: "default"},
+			Data:       map[string]string{"main.tf": ""},
+		},
+	).Build()
+
+	meta := &TFConfigurationMeta{Name: "a", Namespace: "default"}
+	got, err := meta.getProviderLockFile(ctx, k8sClient, "lock")
+	assert.Nil(t, err)
+	assert.Equal(t, lockFile, got)
+
+	_, err = meta.getProviderLockFile(ctx, k8sClient, "no-lock")
+	assert.Contains(t, err.Error(), "doesn't have the key .terraform.lock.hcl")
+
+	_, err = meta.getProviderLockFile(ctx, k8sClient, "not-existing")
+	assert.Contains(t, err.Error(), "is not found in namespace default")
+
+	assert.Equal(t, "cp /opt/tf-configuration/* /data", meta.assembleCopyInputCommand())
+	assert.Equal(t, "terraform init", meta.assembleInitCommand())
+
+	meta.ProviderLockFile = got
+	meta.LockTimeout = "30s"
+	assert.Equal(t, "cp /opt/tf-configuration/* /data && cp /opt/tf-configuration/.terraform.lock.hcl /data",
+		meta.assembleCopyInputCommand())
+	assert.Equal(t, "terraform init -lock-timeout=30s -lockfile=readonly", meta.assembleInitCommand())
+	assert.Equal(t, lockFile, meta.prepareTFInputConfigurationData()[types.TerraformLockFileName])
+}
+
+func TestProviderConfigChanged(t *testing.T) {
+	ctx := context.Background()
 	meta := &TFConfigurationMeta{
-		Name:                "a",
-		ConfigurationCMName: "b",
-		BusyboxImage:        "c",
-		GitImage:            "d",
-		Namespace:           "e",
-		TerraformImage:      "f",
-		RemoteGit:           "g",
+		Name:                   "a",
+		Namespace:              "default",
+		ConfigurationType:      types.ConfigurationHCL,
+		ConfigurationCMName:    "tf-a",
+		CompleteConfiguration:  "hcl",
+		ProviderConfigFileName: tfcfg.ProviderConfigFileName,
+		ProviderConfiguration:  `provider "vault" {}`,
+	}
+	data := meta.prepareTFInputConfigurationData()
+	assert.Equal(t, `provider "vault" {}`, data[tfcfg.ProviderConfigFileName])
+	k8sClient := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "tf-a", Namespace: "default"},
+		Data:       data,
+	}).Build()
+
+	assert.Nil(t, meta.CheckWhetherConfigurationChanges(ctx, k8sClient, types.ConfigurationHCL))
+	assert.False(t, meta.ConfigurationChanged)
+
+	meta.ProviderConfigFileName = ""
+	meta.ProviderConfiguration = ""
+	assert.Nil(t, meta.CheckWhetherConfigurationChanges(ctx, k8sClient, types.ConfigurationHCL))
+	assert.True(t, meta.ConfigurationChanged)
+}
+
+func TestAssembleGitCloneCommand(t *testing.T) {
+	meta := &TFConfigurationMeta{
+		Name:          "a",
+		RemoteGit:     "https://github.com/a/b.git",
+		RemoteGitPath: ".",
 	}
+	assert.Equal(t, "git clone 'https://github.com/a/b.git' /opt/tf-backend && cp -r /opt/tf-backend/* /data",
+		meta.assembleGitCloneCommand(TerraformApply, "/opt/tf-backend"))
+	assert.Nil(t, meta.assembleGitCredentialsEnvs())
+
+	meta.RemoteGitRef = "v1.0.0"
+	meta.RemoteGitCredentialsSecret = "git-credentials"
+	assert.Equal(t, "git "+gitCredentialHelper+" clone 'https://github.com/a/b.git' /opt/tf-backend && "+
+		"git -C /opt/tf-backend checkout v1.0.0 && cp -r /opt/tf-backend/rds/* /data",
+		meta.assembleGitCloneCommand(TerraformApply, "/opt/tf-backend/rds"))
+
 	job := meta.assembleTerraformJob(TerraformApply)
-	containers := job.Spec.Template.Spec.InitContainers
-	assert.Equal(t, containers[0].Image, "c")
-	assert.Equal(t, containers[1].Image, "d")
+	gitContainer := job.Spec.Template.Spec.InitContainers[1]
+	assert.Equal(t, "git-configuration", gitContainer.Name)
+	assert.Equal(t, 2, len(gitContainer.Env))
+	assert.Equal(t, "GIT_PASSWORD", gitContainer.Env[1].Name)
+	assert.Equal(t, "git-credentials", gitContainer.Env[1].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, "password", gitContainer.Env[1].ValueFrom.SecretKeyRef.Key)
+}
+
+func TestAssembleVendoredModulesContainer(t *testing.T) {
+	meta := &TFConfigurationMeta{
+		Name:            "a",
+		BusyboxImage:    "busybox:latest",
+		VendoredModules: &v1beta2.VendoredModules{ClaimName: "tf-modules", Path: "vpc"},
+	}
+	assert.Equal(t, "terraform init -get=false", meta.assembleInitCommand())
+
+	job := meta.assembleTerraformJob(TerraformApply)
+	initContainers := job.Spec.Template.Spec.InitContainers
+	assert.Equal(t, 3, len(initContainers))
+	container := initContainers[1]
+	assert.Equal(t, "prepare-vendored-modules", container.Name)
+	assert.Equal(t, "busybox:latest", container.Image)
+	assert.Equal(t, "mkdir -p /data/.terraform/modules && cp -r /opt/tf-modules/vpc/. /data/.terraform/modules", container.Command[2])
+	assert.Equal(t, VendoredModulesVolumeName, container.VolumeMounts[1].Name)
+	assert.True(t, container.VolumeMounts[1].ReadOnly)
+	volumes := job.Spec.Template.Spec.Volumes
+	assert.Equal(t, "tf-modules", volumes[len(volumes)-1].PersistentVolumeClaim.ClaimName)
+
+	meta.VendoredModules = &v1beta2.VendoredModules{Image: "registry.local/tf-modules:v1"}
+	container = meta.assembleVendoredModulesContainer()
+	assert.Equal(t, "registry.local/tf-modules:v1", container.Image)
+	assert.Equal(t, "mkdir -p /data/.terraform/modules && cp -r /. /data/.terraform/modules", container.Command[2])
+	assert.Equal(t, 1, len(container.VolumeMounts))
+	for _, volume := range meta.assembleExecutorVolumes() {
+		assert.NotEqual(t, VendoredModulesVolumeName, volume.Name)
+	}
+}
+
+func TestAssemblePluginCache(t *testing.T) {
+	meta := &TFConfigurationMeta{
+		Name:                 "a",
+		Envs:                 []corev1.EnvVar{{Name: "ALICLOUD_REGION", Value: "cn-beijing"}},
+		PluginCacheClaimName: "tf-plugin-cache",
+	}
+	assert.Equal(t, "mkdir -p /opt/tf-plugin-cache/plugins && flock /opt/tf-plugin-cache/.lock terraform init", meta.assembleInitCommand())
+
+	job := meta.assembleTerraformJob(TerraformApply)
+	volumes := job.Spec.Template.Spec.Volumes
+	assert.Equal(t, PluginCacheVolumeName, volumes[len(volumes)-1].Name)
+	assert.Equal(t, "tf-plugin-cache", volumes[len(volumes)-1].PersistentVolumeClaim.ClaimName)
+	assert.False(t, volumes[len(volumes)-1].PersistentVolumeClaim.ReadOnly)
+
+	cacheEnv := corev1.EnvVar{Name: "TF_PLUGIN_CACHE_DIR", Value: "/opt/tf-plugin-cache/plugins"}
+	cacheVolumeMount := corev1.VolumeMount{Name: PluginCacheVolumeName, MountPath: PluginCacheVolumeMountPath}
+	initContainers := job.Spec.Template.Spec.InitContainers
+	initContainer := initContainers[len(initContainers)-1]
+	assert.Equal(t, terraformInitContainerName, initContainer.Name)
+	assert.Equal(t, []corev1.EnvVar{cacheEnv}, initContainer.Env)
+	assert.Contains(t, initContainer.VolumeMounts, cacheVolumeMount)
+	assert.NotContains(t, initContainers[0].VolumeMounts, cacheVolumeMount)
+
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Contains(t, container.Command[2], "flock /opt/tf-plugin-cache/.lock terraform init && terraform apply")
+	assert.Equal(t, append(meta.Envs, cacheEnv), container.Env)
+	assert.Equal(t, 1, len(meta.Envs))
+	assert.Contains(t, container.VolumeMounts, cacheVolumeMount)
+}
+
+func TestAssemblePersistedWorkingDirectory(t *testing.T) {
+	meta := &TFConfigurationMeta{
+		Name:                      "a",
+		RemoteGit:                 "https://github.com/kubevela-contrib/terraform-modules.git",
+		RemoteGitPath:             ".",
+		WorkingDirectoryClaimName: "tf-working-dir",
+		WorkingDirectoryHash:      "3a7bd3e2",
+	}
+	job := meta.assembleTerraformJob(TerraformApply)
+	podSpec := job.Spec.Template.Spec
+	assert.Equal(t, meta.Name, podSpec.Volumes[0].Name)
+	assert.Nil(t, podSpec.Volumes[0].EmptyDir)
+	assert.Equal(t, "tf-working-dir", podSpec.Volumes[0].PersistentVolumeClaim.ClaimName)
+	for _, container := range append(podSpec.InitContainers, podSpec.Containers...) {
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == meta.Name {
+				assert.Equal(t, meta.Name, mount.SubPath, container.Name)
+			}
+		}
+	}
+
+	initContainers := podSpec.InitContainers
+	assert.Equal(t, 3, len(initContainers))
+	assert.Equal(t, `{ [ "$(cat /data/.terraform-controller-hash 2>/dev/null)" = '3a7bd3e2' ] || find /data -mindepth 1 -delete; } && cp /opt/tf-configuration/* /data`,
+		initContainers[0].Command[2])
+	// the default branch may move, so it's cloned by every job
+	assert.Equal(t, "git clone 'https://github.com/kubevela-contrib/terraform-modules.git' /opt/tf-backend && "+
+		`commit=$(git -C /opt/tf-backend rev-parse HEAD) && { [ "$(cat /data/.terraform-controller-commit 2>/dev/null)" = "$commit" ] || `+
+		"{ find /data -mindepth 1 -delete && cp /opt/tf-configuration/* /data; }; } && cp -r /opt/tf-backend/* /data && "+
+		`echo "$commit" > /data/.terraform-controller-commit && find /opt/tf-backend -mindepth 1 -delete`,
+		initContainers[1].Command[2])
+	assert.Equal(t, "if [ -f /data/.terraform-controller-hash ]; then echo 'Reusing the working directory'; else "+
+		"terraform init && echo '3a7bd3e2' > /data/.terraform-controller-hash; fi", initContainers[2].Command[2])
+	assert.NotContains(t, podSpec.Containers[0].Command[2], "rm -rf /data/.terraform")
+	assert.NotContains(t, podSpec.Containers[0].Command[2], "rm -rf /opt/tf-working-directories/a")
+
+	// a commit never moves, so the clone is reused
+	meta.RemoteGitRef = "0123456789abcdef0123456789abcdef01234567"
+	initContainers = meta.assembleTerraformJob(TerraformApply).Spec.Template.Spec.InitContainers
+	assert.Equal(t, "if [ -f /data/.terraform-controller-hash ]; then echo 'Reusing the working directory'; else "+
+		"git clone 'https://github.com/kubevela-contrib/terraform-modules.git' /opt/tf-backend && "+
+		"git -C /opt/tf-backend checkout 0123456789abcdef0123456789abcdef01234567 && cp -r /opt/tf-backend/* /data && find /opt/tf-backend -mindepth 1 -delete; fi",
+		initContainers[1].Command[2])
+
+	// the destroy job removes the sub-directory of the Configuration once it succeeds
+	executor := meta.assembleTerraformJob(TerraformDestroy).Spec.Template.Spec.Containers[0]
+	assert.Contains(t, executor.VolumeMounts, corev1.VolumeMount{Name: meta.Name, MountPath: "/opt/tf-working-directories"})
+	assert.Contains(t, executor.Command[2], "; if [ $code -eq 0 ]; then rm -rf /opt/tf-working-directories/a; fi; exit $code")
+
+	// the other jobs, like the drift check, run in an emptyDir
+	job = meta.assembleTerraformJob(TerraformDriftCheck)
+	podSpec = job.Spec.Template.Spec
+	assert.NotNil(t, podSpec.Volumes[0].EmptyDir)
+	assert.Equal(t, "", podSpec.InitContainers[0].VolumeMounts[0].SubPath)
+	assert.Equal(t, "terraform init", podSpec.InitContainers[2].Command[2])
+}
+
+func TestComputeWorkingDirectoryHash(t *testing.T) {
+	meta := &TFConfigurationMeta{
+		RemoteGit:             "https://github.com/kubevela-contrib/terraform-modules.git",
+		RemoteGitRef:          "v1.0.0",
+		CompleteConfiguration: "terraform {\n  backend \"kubernetes\" {}\n}",
+	}
+	p := &v1beta1.Provider{Spec: v1beta1.ProviderSpec{Provider: "alibaba"}}
+	hash, err := meta.computeWorkingDirectoryHash(p)
+	assert.Nil(t, err)
+	again, err := meta.computeWorkingDirectoryHash(p)
+	assert.Nil(t, err)
+	assert.Equal(t, hash, again)
+
+	// the region of the Provider doesn't change the working directory
+	p.Spec.Region = "cn-hangzhou"
+	again, _ = meta.computeWorkingDirectoryHash(p)
+	assert.Equal(t, hash, again)
+
+	meta.RemoteGitRef = "v1.1.0"
+	changed, _ := meta.computeWorkingDirectoryHash(p)
+	assert.NotEqual(t, hash, changed)
+
+	meta.RemoteGitRef = "v1.0.0"
+	meta.ProviderLockFile = "# lock"
+	changed, _ = meta.computeWorkingDirectoryHash(p)
+	assert.NotEqual(t, hash, changed)
+}
+
+func TestToDiagnostics(t *testing.T) {
+	err := errors.Wrap(&terraform.DiagnosticsError{Diagnostics: []terraform.Diagnostic{
+		{Address: "aws_s3_bucket.b", Summary: "creating S3 Bucket", Detail: "BucketAlreadyExists"},
+	}}, "Terraform apply failed")
+	assert.Equal(t, []v1beta2.Diagnostic{
+		{Address: "aws_s3_bucket.b", Summary: "creating S3 Bucket", Detail: "BucketAlreadyExists"},
+	}, toDiagnostics(err))
+
+	assert.Nil(t, toDiagnostics(errors.New("31mError: failed")))
+
+	err = &terraform.WarningsError{Warnings: []terraform.Diagnostic{
+		{Address: "aws_s3_bucket.b", Summary: "Argument is deprecated", Detail: "Use the aws_s3_bucket_acl resource instead"},
+	}}
+	assert.Equal(t, []v1beta2.Diagnostic{
+		{Address: "aws_s3_bucket.b", Summary: "Argument is deprecated", Detail: "Use the aws_s3_bucket_acl resource instead"},
+	}, toDiagnostics(err))
+}
+
+func TestAssembleExecutionCommandWithWarningsAsErrors(t *testing.T) {
+	meta := &TFConfigurationMeta{WarningsAsErrors: true}
+	assert.Equal(t, `terraform init && { terraform plan -input=false -lock=false -json > /data/warnings-check.json; code=$?; cat /data/warnings-check.json; [ $code -eq 0 ]; }`+
+		` && { ! grep -q '"severity":"warning"' /data/warnings-check.json || { echo 'TerraformWarnings: The plan reports warnings, which are treated as errors by spec.WarningsAsErrors'; exit 1; }; }`+
+		" && terraform apply -lock=false -auto-approve -json",
+		meta.assembleExecutionCommand(TerraformApply))
+	assert.Equal(t, "terraform init && terraform destroy -lock=false -auto-approve -json", meta.assembleExecutionCommand(TerraformDestroy))
+
+	// the approved plan was checked by the plan job
+	meta.ApprovedPlanChecksum = "3a7bd3e2"
+	assert.NotContains(t, meta.assembleExecutionCommand(TerraformApply), "warnings-check.json")
 }
 
 func TestAssembleTerraformJobWithResourcesSetting(t *testing.T) {
@@ -1552,6 +3957,24 @@ func TestGetTFOutputs(t *testing.T) {
 				errMsg:   "terraform state file backend secret is not generated",
 			},
 		},
+		"local backend has no outputs": {
+			args: args{
+				ctx:       ctx,
+				k8sClient: k8sClient1,
+				configuration: v1beta2.Configuration{
+					Spec: v1beta2.ConfigurationSpec{
+						Backend: &v1beta2.Backend{
+							Type: "local",
+						},
+					},
+				},
+				meta: meta1,
+			},
+			want: want{
+				property: nil,
+				errMsg:   "",
+			},
+		},
 		"no data in a backend secret": {
 			args: args{
 				ctx:       ctx,
@@ -1650,6 +4073,57 @@ func TestGetTFOutputs(t *testing.T) {
 
 }
 
+func TestGetTFOutputsSetsOwnerOfConnectionSecret(t *testing.T) {
+	ctx := context.Background()
+	tfStateData, _ := base64.StdEncoding.DecodeString("H4sIAAAAAAAA/4SQzarbMBCF934KoXUdPKNf+1VKCWNp5AocO8hyaSl592KlcBd3cZfnHPHpY/52QshfXI68b3IS+tuVK5dCaS+P+8ci4TbcULb94JJplZPAFte8MS18PQrKBO8Q+xk59SHa1AMA9M4YmoN3FGJ8M/azPs96yElcCkLIsG+V8sblnqOc3uXlRuvZ0GxSSuiCRUYbw2gGHRFGPxitEgJYQDQ0a68I2ChNo1cAZJ2bR20UtW8bsv55NuJRS94W2erXe5X5QQs3A/FZ4fhJaOwUgZTVMRjto1HGpSGSQuuD955hdDDPcR6NY1ZpQJ/YwagTRAvBpsi8LXn7Pa1U+ahfWHX/zWThYz9L4Otg3390r+5fAAAA//8hmcuNuQEAAA==")
+	backendSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "a",
+			Namespace: "vela-system",
+		},
+		Data: map[string][]byte{
+			TerraformStateNameInSecret: tfStateData,
+		},
+	}
+	meta := &TFConfigurationMeta{
+		BackendSecretName:         "a",
+		TerraformBackendNamespace: "vela-system",
+	}
+	newConfiguration := func(secretNamespace string) v1beta2.Configuration {
+		return v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "abc",
+				Namespace: "default",
+				UID:       "123",
+			},
+			Spec: v1beta2.ConfigurationSpec{
+				BaseConfigurationSpec: v1beta2.BaseConfigurationSpec{
+					WriteConnectionSecretToReference: &runtimetypes.SecretReference{
+						Name:      "conn",
+						Namespace: secretNamespace,
+					},
+				},
+			},
+		}
+	}
+
+	k8sClient := fake.NewClientBuilder().WithObjects(backendSecret).Build()
+	_, err := meta.getTFOutputs(ctx, k8sClient, newConfiguration("default"))
+	assert.Nil(t, err)
+	var secret corev1.Secret
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "conn", Namespace: "default"}, &secret))
+	assert.Equal(t, 1, len(secret.OwnerReferences))
+	assert.Equal(t, "Configuration", secret.OwnerReferences[0].Kind)
+	assert.Equal(t, k8stypes.UID("123"), secret.OwnerReferences[0].UID)
+
+	_, err = meta.getTFOutputs(ctx, k8sClient, newConfiguration("app"))
+	assert.Nil(t, err)
+	var crossNamespaceSecret corev1.Secret
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "conn", Namespace: "app"}, &crossNamespaceSecret))
+	assert.Equal(t, 0, len(crossNamespaceSecret.OwnerReferences))
+	assert.Equal(t, "default", crossNamespaceSecret.Labels["terraform.core.oam.dev/owned-namespace"])
+}
+
 func TestUpdateApplyStatus(t *testing.T) {
 	type args struct {
 		k8sClient client.Client
@@ -1718,6 +4192,77 @@ func TestUpdateApplyStatus(t *testing.T) {
 	}
 }
 
+func TestUpdateApplyStatusWithApplyPhase(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+
+	configuration := &v1beta2.Configuration{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "b"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(configuration).Build()
+	meta := &TFConfigurationMeta{Name: "a", Namespace: "b", ApplyPhase: types.ApplyPhasePlanComplete}
+
+	assert.Nil(t, meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationProvisioningAndChecking, types.MessageCloudResourceProvisioningAndChecking))
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "a", Namespace: "b"}, configuration))
+	assert.Equal(t, types.ApplyPhasePlanComplete, configuration.Status.Apply.Phase)
+
+	assert.Nil(t, meta.updateApplyStatus(ctx, k8sClient, types.ConfigurationApplyFailed, "apply failed"))
+	var failed v1beta2.Configuration
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "a", Namespace: "b"}, &failed))
+	assert.Equal(t, types.ApplyPhase(""), failed.Status.Apply.Phase)
+}
+
+func TestUpdateLastReconcileReason(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "a",
+			Namespace: "b",
+		},
+		Status: v1beta2.ConfigurationStatus{
+			LastReconcileReason: types.ReconcileApplyInProgress,
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(configuration).Build()
+
+	testcases := []struct {
+		name string
+		meta *TFConfigurationMeta
+		want types.ReconcileReason
+	}{
+		{
+			name: "no reason",
+			meta: &TFConfigurationMeta{Name: "a", Namespace: "b"},
+			want: types.ReconcileApplyInProgress,
+		},
+		{
+			name: "reason changes",
+			meta: &TFConfigurationMeta{Name: "a", Namespace: "b", LastReconcileReason: types.ReconcilePendingScheduledApply},
+			want: types.ReconcilePendingScheduledApply,
+		},
+		{
+			name: "reason doesn't change",
+			meta: &TFConfigurationMeta{Name: "a", Namespace: "b", LastReconcileReason: types.ReconcilePendingScheduledApply},
+			want: types.ReconcilePendingScheduledApply,
+		},
+		{
+			name: "configuration is not found",
+			meta: &TFConfigurationMeta{Name: "z", Namespace: "b", LastReconcileReason: types.ReconcileUpToDate},
+			want: types.ReconcilePendingScheduledApply,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Nil(t, tc.meta.updateLastReconcileReason(ctx, k8sClient))
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "a", Namespace: "b"}, &got))
+			assert.Equal(t, tc.want, got.Status.LastReconcileReason)
+		})
+	}
+}
+
 func TestAssembleAndTriggerJob(t *testing.T) {
 	type prepare func(t *testing.T)
 	type args struct {
@@ -1764,6 +4309,27 @@ func TestAssembleAndTriggerJob(t *testing.T) {
 	}
 }
 
+func TestAssembleAndTriggerJobAfterFailover(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	batchv1.AddToScheme(s)
+	rbacv1.AddToScheme(s)
+	meta := &TFConfigurationMeta{
+		Name:         "a",
+		Namespace:    "b",
+		ApplyJobName: "a-apply",
+	}
+	// the previous leader created the job before the failover
+	job := meta.assembleTerraformJob(TerraformApply)
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(job).Build()
+
+	assert.Nil(t, meta.assembleAndTriggerJob(ctx, k8sClient, TerraformApply))
+	var jobs batchv1.JobList
+	assert.Nil(t, k8sClient.List(ctx, &jobs, client.InNamespace("b")))
+	assert.Equal(t, 1, len(jobs.Items))
+}
+
 func TestCheckWhetherConfigurationChanges(t *testing.T) {
 	type args struct {
 		k8sClient         client.Client
@@ -1771,7 +4337,8 @@ func TestCheckWhetherConfigurationChanges(t *testing.T) {
 		meta              *TFConfigurationMeta
 	}
 	type want struct {
-		errMsg string
+		configurationChanged bool
+		errMsg               string
 	}
 	ctx := context.Background()
 	cm := &corev1.ConfigMap{
@@ -1813,6 +4380,33 @@ func TestCheckWhetherConfigurationChanges(t *testing.T) {
 				errMsg: "not found",
 			},
 		},
+		"provider default tags changed": {
+			args: args{
+				meta: &TFConfigurationMeta{
+					ConfigurationCMName:      "a",
+					Namespace:                "b",
+					DefaultTagsFileName:      "provider_default_tags.tf",
+					DefaultTagsConfiguration: "tags",
+				},
+				configurationType: types.ConfigurationRemote,
+			},
+			want: want{
+				configurationChanged: true,
+			},
+		},
+		"moved blocks of the remote module changed": {
+			args: args{
+				meta: &TFConfigurationMeta{
+					ConfigurationCMName:   "a",
+					Namespace:             "b",
+					CompleteConfiguration: "moved {\n  from = module.a\n  to   = module.b\n}\n",
+				},
+				configurationType: types.ConfigurationRemote,
+			},
+			want: want{
+				configurationChanged: true,
+			},
+		},
 	}
 	for name, tc := range testcases {
 		t.Run(name, func(t *testing.T) {
@@ -1821,7 +4415,37 @@ func TestCheckWhetherConfigurationChanges(t *testing.T) {
 				if !strings.Contains(err.Error(), tc.want.errMsg) {
 					t.Errorf("CheckWhetherConfigurationChanges() error = %v, wantErr %v", err, tc.want.errMsg)
 				}
+				return
 			}
+			assert.Equal(t, tc.want.configurationChanged, tc.args.meta.ConfigurationChanged)
 		})
 	}
 }
+
+func TestUpdateProviderReadyConditionAccessDenied(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	providerObj := &v1beta1.Provider{
+		ObjectMeta: v1.ObjectMeta{Name: "default", Namespace: "default"},
+		Spec:       v1beta1.ProviderSpec{AllowedNamespaces: []string{"team-a"}},
+		Status:     v1beta1.ProviderStatus{State: types.ProviderIsReady},
+	}
+	configuration := &v1beta2.Configuration{ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "team-b"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(providerObj, configuration).Build()
+	meta := &TFConfigurationMeta{
+		Name:              "abc",
+		Namespace:         "team-b",
+		ProviderReference: &crossplane.Reference{Name: "default", Namespace: "default"},
+	}
+
+	assert.Nil(t, meta.updateProviderReadyCondition(ctx, k8sClient))
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "team-b"}, configuration))
+	condition := tfcfg.GetCondition(configuration, tfcfg.ConditionProviderReady)
+	assert.Equal(t, v1.ConditionFalse, condition.Status)
+	assert.Equal(t, "ProviderAccessDenied", condition.Reason)
+	assert.Equal(t, "Configuration team-b/abc is not allowed to use Provider default/default, its namespace is not in spec.allowedNamespaces",
+		condition.Message)
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	tfcfg "github.com/oam-dev/terraform-controller/controllers/configuration"
+	"github.com/oam-dev/terraform-controller/controllers/terraform"
+)
+
+// selfHealJobAnnotation is the annotation of the apply job which corrects the drift, it's the token of the self-heal
+const selfHealJobAnnotation = "terraform.core.oam.dev/self-heal"
+
+// selfHealInterval is the minimum interval between the applies of spec.SelfHeal, so the self-heal doesn't keep fighting
+// with another controller which changes the same cloud resources
+const selfHealInterval = 10 * time.Minute
+
+// driftCheckWait returns how long to wait for the next drift check of spec.DriftCheckInterval, and false if the drift
+// is not checked. The check is due if it never ran or it's still running. The drift whose self-heal is deferred is
+// checked again at the time it's deferred to, or at once if it's flagged as critical, so only the drift which still
+// exists is corrected.
+func driftCheckWait(configuration *v1beta2.Configuration, now time.Time) (time.Duration, bool) {
+	interval, err := time.ParseDuration(configuration.Spec.DriftCheckInterval)
+	if err != nil || interval <= 0 {
+		return 0, false
+	}
+	drift := configuration.Status.Drift
+	if drift == nil || drift.LastCheckTime == nil || drift.State == types.DriftCheckRunning {
+		return 0, true
+	}
+	wait := drift.LastCheckTime.Add(interval).Sub(now)
+	if drift.State == types.DriftDetectedPendingWindow && drift.NextSelfHealTime != nil {
+		if tfcfg.IsCriticalDrift(configuration) {
+			return 0, true
+		}
+		if w := drift.NextSelfHealTime.Sub(now); w < wait {
+			wait = w
+		}
+	}
+	return wait, true
+}
+
+// getSelfHealToken gets the token of the self-heal which hasn't completed, it's the time of the self-heal
+func getSelfHealToken(configuration *v1beta2.Configuration) string {
+	drift := configuration.Status.Drift
+	if drift == nil || drift.State != types.SelfHealing || drift.LastSelfHealTime == nil {
+		return ""
+	}
+	return strconv.FormatInt(drift.LastSelfHealTime.Unix(), 10)
+}
+
+// checkDrift runs `terraform plan` for the drift check of spec.DriftCheckInterval, and records the changes made to the
+// cloud resources outside of the Configuration in the status. The drift is checked only if the Configuration is
+// Available and unchanged, otherwise the check is postponed and the job of a stale check is deleted. It returns true
+// once the check completes or fails, or it's postponed.
+func (r *ConfigurationReconciler) checkDrift(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) (bool, error) {
+	checkable := configuration.Status.Apply.State == types.Available && !meta.EnvChanged && !meta.ConfigurationChanged
+	var job batchv1.Job
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.DriftCheckJobName, Namespace: meta.Namespace}, &job); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return false, err
+		}
+		if !checkable {
+			return true, nil
+		}
+		klog.InfoS("Checking the drift", "Name", meta.Name, "Namespace", meta.Namespace)
+		if err := meta.assembleAndTriggerJob(ctx, r.Client, TerraformDriftCheck); err != nil {
+			return false, err
+		}
+		drift := configuration.Status.Drift.DeepCopy()
+		if drift == nil {
+			drift = &v1beta2.ConfigurationDriftStatus{}
+		}
+		now := metav1.Now()
+		drift.State, drift.Message, drift.LastCheckTime, drift.Changes = types.DriftCheckRunning, types.MessageDriftCheckRunning, &now, nil
+		return false, meta.updateDriftStatus(ctx, r.Client, drift)
+	}
+	if !checkable {
+		klog.InfoS("Deleting the job of the stale drift check", "Name", job.Name, "Namespace", job.Namespace)
+		return true, client.IgnoreNotFound(r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+	}
+
+	drift := configuration.Status.Drift.DeepCopy()
+	if drift == nil {
+		drift = &v1beta2.ConfigurationDriftStatus{LastCheckTime: &job.CreationTimestamp}
+	}
+	if job.Status.Succeeded == int32(1) {
+		logs, err := terraform.GetTerraformLogs(ctx, meta.Namespace, job.Name, terraformContainerName, terraformInitContainerName)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get the logs of the drift check")
+		}
+		drift.Changes = terraform.ParsePlannedChanges(logs)
+		drift.State, drift.Message = types.NoDrift, types.MessageNoDrift
+		if len(drift.Changes) > 0 {
+			triggerSelfHeal(configuration, drift, time.Now())
+		} else {
+			drift.NextSelfHealTime = nil
+		}
+	} else {
+		state, err := terraform.GetTerraformStatus(ctx, meta.Namespace, job.Name, terraformContainerName, terraformInitContainerName)
+		if err == nil || state == types.ConfigurationProvisioningAndChecking {
+			return false, nil
+		}
+		drift.State, drift.Message = types.DriftCheckFailed, err.Error()
+	}
+	if drift.State == types.SelfHealing {
+		klog.InfoS("Self-healing the drift", "Name", meta.Name, "Namespace", meta.Namespace, "Changes", drift.Changes,
+			"SelfHealCount", drift.SelfHealCount)
+	}
+	if err := meta.updateDriftStatus(ctx, r.Client, drift); err != nil {
+		return false, err
+	}
+	if err := r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	// the apply of the self-heal starts in the next reconcile
+	return drift.State != types.SelfHealing, nil
+}
+
+// triggerSelfHeal marks the drift as SelfHealing if spec.SelfHeal is true and the latest self-heal is not within
+// selfHealInterval, otherwise the drift is only reported as DriftDetected. If spec.ApplySchedule is set, the self-heal
+// is deferred to the next time of the schedule as DriftDetectedPendingWindow, unless the drift is critical. The time
+// is kept by the later checks until the self-heal runs.
+func triggerSelfHeal(configuration *v1beta2.Configuration, drift *v1beta2.ConfigurationDriftStatus, now time.Time) {
+	drift.State = types.DriftDetected
+	if !configuration.Spec.SelfHeal {
+		drift.Message = fmt.Sprintf(types.MessageDriftDetected, len(drift.Changes))
+		return
+	}
+	if last := drift.LastSelfHealTime; last != nil && now.Sub(last.Time) < selfHealInterval {
+		drift.Message = fmt.Sprintf(types.MessageSelfHealRateLimited, len(drift.Changes), last.UTC().Format(time.RFC3339))
+		return
+	}
+	if configuration.Spec.ApplySchedule != "" && !tfcfg.IsCriticalDrift(configuration) {
+		// the schedule is validated by the pre-check
+		if schedule, err := tfcfg.ParseSchedule(configuration.Spec.ApplySchedule); err == nil && drift.NextSelfHealTime == nil {
+			next := metav1.NewTime(schedule.Next(now))
+			drift.NextSelfHealTime = &next
+		}
+		if next := drift.NextSelfHealTime; next != nil && now.Before(next.Time) {
+			drift.State = types.DriftDetectedPendingWindow
+			drift.Message = fmt.Sprintf(types.MessageDriftDetectedPendingWindow, len(drift.Changes), next.UTC().Format(time.RFC3339))
+			return
+		}
+	}
+	drift.NextSelfHealTime = nil
+	healTime := metav1.NewTime(now)
+	drift.State, drift.Message = types.SelfHealing, fmt.Sprintf(types.MessageSelfHealing, len(drift.Changes))
+	drift.LastSelfHealTime = &healTime
+	drift.SelfHealCount++
+}
+
+// selfHeal applies the Configuration again to correct the drift. Like applyNow, the apply job which isn't marked by the
+// token of the self-heal is deleted, so a new one is created. Once the job of the token succeeds, the drift is marked
+// as DriftCorrected.
+func (r *ConfigurationReconciler) selfHeal(ctx context.Context, configuration *v1beta2.Configuration, meta *TFConfigurationMeta) (bool, error) {
+	var job batchv1.Job
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: meta.ApplyJobName, Namespace: meta.Namespace}, &job); err != nil {
+		if kerrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if job.Annotations[selfHealJobAnnotation] != meta.SelfHealToken {
+		klog.InfoS("Deleting the apply job to correct the drift", "Name", job.Name, "Namespace", job.Namespace)
+		if err := r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return false, nil
+	}
+	if job.Status.Succeeded != int32(1) || configuration.Status.Drift == nil {
+		return true, nil
+	}
+
+	drift := configuration.Status.Drift.DeepCopy()
+	drift.State, drift.Message = types.DriftCorrected, types.MessageDriftCorrected
+	if err := meta.updateDriftStatus(ctx, r.Client, drift); err != nil {
+		return false, err
+	}
+	meta.SelfHealToken = ""
+	return true, nil
+}
+
+// updateDriftStatus records the result of the drift check, the apply and destroy status are not changed
+func (meta *TFConfigurationMeta) updateDriftStatus(ctx context.Context, k8sClient client.Client, drift *v1beta2.ConfigurationDriftStatus) error {
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	configuration.Status.Drift = drift
+	return k8sClient.Status().Update(ctx, &configuration)
+}
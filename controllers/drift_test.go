@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	tfcfg "github.com/oam-dev/terraform-controller/controllers/configuration"
+	"github.com/oam-dev/terraform-controller/controllers/terraform"
+)
+
+func TestDriftCheckWait(t *testing.T) {
+	now := time.Now()
+	checked := v1.NewTime(now.Add(-20 * time.Minute))
+	window := v1.NewTime(now.Add(10 * time.Minute))
+	newConfiguration := func(interval string, drift *v1beta2.ConfigurationDriftStatus) *v1beta2.Configuration {
+		return &v1beta2.Configuration{
+			Spec:   v1beta2.ConfigurationSpec{DriftCheckInterval: interval},
+			Status: v1beta2.ConfigurationStatus{Drift: drift},
+		}
+	}
+
+	testcases := []struct {
+		name          string
+		configuration *v1beta2.Configuration
+		wait          time.Duration
+		enabled       bool
+	}{
+		{
+			name:          "drift is not checked",
+			configuration: newConfiguration("", nil),
+		},
+		{
+			name:          "never checked",
+			configuration: newConfiguration("1h", nil),
+			enabled:       true,
+		},
+		{
+			name:          "check is running",
+			configuration: newConfiguration("1h", &v1beta2.ConfigurationDriftStatus{State: types.DriftCheckRunning, LastCheckTime: &checked}),
+			enabled:       true,
+		},
+		{
+			name:          "next check",
+			configuration: newConfiguration("1h", &v1beta2.ConfigurationDriftStatus{State: types.NoDrift, LastCheckTime: &checked}),
+			wait:          40 * time.Minute,
+			enabled:       true,
+		},
+		{
+			name:          "check is overdue",
+			configuration: newConfiguration("10m", &v1beta2.ConfigurationDriftStatus{State: types.NoDrift, LastCheckTime: &checked}),
+			wait:          -10 * time.Minute,
+			enabled:       true,
+		},
+		{
+			name: "self-heal is deferred",
+			configuration: newConfiguration("1h", &v1beta2.ConfigurationDriftStatus{State: types.DriftDetectedPendingWindow,
+				LastCheckTime: &checked, NextSelfHealTime: &window}),
+			wait:    10 * time.Minute,
+			enabled: true,
+		},
+		{
+			name: "critical drift",
+			configuration: func() *v1beta2.Configuration {
+				c := newConfiguration("1h", &v1beta2.ConfigurationDriftStatus{State: types.DriftDetectedPendingWindow,
+					LastCheckTime: &checked, NextSelfHealTime: &window})
+				c.Annotations = map[string]string{tfcfg.CriticalDriftAnnotation: "true"}
+				return c
+			}(),
+			enabled: true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			wait, enabled := driftCheckWait(tc.configuration, now)
+			assert.Equal(t, tc.enabled, enabled)
+			assert.Equal(t, tc.wait.Truncate(time.Second), wait.Truncate(time.Second))
+		})
+	}
+}
+
+func TestTriggerSelfHeal(t *testing.T) {
+	now := time.Date(2022, 3, 16, 10, 0, 0, 0, time.UTC)
+	recent := v1.NewTime(now.Add(-5 * time.Minute))
+	earlier := v1.NewTime(now.Add(-time.Hour))
+
+	testcases := []struct {
+		name     string
+		selfHeal bool
+		last     *v1.Time
+		state    types.ConfigurationState
+		message  string
+		count    int64
+	}{
+		{
+			name:    "self-heal is disabled",
+			state:   types.DriftDetected,
+			message: "1 resources drift from the Configuration, set spec.selfHeal to true to correct the drift automatically",
+			count:   1,
+		},
+		{
+			name:     "self-heal",
+			selfHeal: true,
+			last:     &earlier,
+			state:    types.SelfHealing,
+			message:  "Applying the Configuration again to correct the drift of 1 resources",
+			count:    2,
+		},
+		{
+			name:     "self-heal is rate limited",
+			selfHeal: true,
+			last:     &recent,
+			state:    types.DriftDetected,
+			message:  "1 resources drift from the Configuration, the self-heal is skipped as the latest one at 2022-03-16T09:55:00Z is too recent",
+			count:    1,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			configuration := &v1beta2.Configuration{Spec: v1beta2.ConfigurationSpec{DriftCheckInterval: "1m", SelfHeal: tc.selfHeal}}
+			drift := &v1beta2.ConfigurationDriftStatus{Changes: []string{"update aws_s3_bucket.b"}, LastSelfHealTime: tc.last, SelfHealCount: 1}
+			triggerSelfHeal(configuration, drift, now)
+			assert.Equal(t, tc.state, drift.State)
+			assert.Equal(t, tc.message, drift.Message)
+			assert.Equal(t, tc.count, drift.SelfHealCount)
+			if tc.state == types.SelfHealing {
+				assert.Equal(t, now, drift.LastSelfHealTime.Time)
+				assert.Equal(t, "1647424800", getSelfHealToken(&v1beta2.Configuration{Status: v1beta2.ConfigurationStatus{Drift: drift}}))
+			}
+		})
+	}
+}
+
+func TestTriggerSelfHealPendingWindow(t *testing.T) {
+	now := time.Date(2022, 3, 16, 10, 0, 0, 0, time.UTC)
+	configuration := &v1beta2.Configuration{Spec: v1beta2.ConfigurationSpec{DriftCheckInterval: "1h", SelfHeal: true}}
+	configuration.Spec.ApplySchedule = "0 2 * * *"
+	drift := &v1beta2.ConfigurationDriftStatus{Changes: []string{"update aws_s3_bucket.b"}}
+
+	triggerSelfHeal(configuration, drift, now)
+	assert.Equal(t, types.DriftDetectedPendingWindow, drift.State)
+	assert.Equal(t, "1 resources drift from the Configuration, the self-heal is deferred to 2022-03-17T02:00:00Z by spec.applySchedule, "+
+		"set the annotation terraform.core.oam.dev/critical-drift to true to correct the drift now", drift.Message)
+	assert.Equal(t, time.Date(2022, 3, 17, 2, 0, 0, 0, time.UTC), drift.NextSelfHealTime.Time)
+	assert.Equal(t, int64(0), drift.SelfHealCount)
+
+	// the later checks keep the time
+	triggerSelfHeal(configuration, drift, now.Add(time.Hour))
+	assert.Equal(t, types.DriftDetectedPendingWindow, drift.State)
+	assert.Equal(t, time.Date(2022, 3, 17, 2, 0, 0, 0, time.UTC), drift.NextSelfHealTime.Time)
+
+	// the self-heal runs once the time is reached
+	healTime := time.Date(2022, 3, 17, 2, 0, 5, 0, time.UTC)
+	triggerSelfHeal(configuration, drift, healTime)
+	assert.Equal(t, types.SelfHealing, drift.State)
+	assert.Nil(t, drift.NextSelfHealTime)
+	assert.Equal(t, healTime, drift.LastSelfHealTime.Time)
+	assert.Equal(t, int64(1), drift.SelfHealCount)
+
+	// the critical drift is corrected immediately
+	configuration.Annotations = map[string]string{tfcfg.CriticalDriftAnnotation: "true"}
+	drift = &v1beta2.ConfigurationDriftStatus{Changes: []string{"update aws_s3_bucket.b"}}
+	triggerSelfHeal(configuration, drift, now)
+	assert.Equal(t, types.SelfHealing, drift.State)
+	assert.Nil(t, drift.NextSelfHealTime)
+}
+
+func TestCheckDrift(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	corev1.AddToScheme(s)
+	rbacv1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	newConfiguration := func(state types.ConfigurationState, selfHeal bool) *v1beta2.Configuration {
+		return &v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default"},
+			Spec: v1beta2.ConfigurationSpec{
+				HCL:                "bbb",
+				DriftCheckInterval: "1h",
+				SelfHeal:           selfHeal,
+			},
+			Status: v1beta2.ConfigurationStatus{
+				Apply: v1beta2.ConfigurationApplyStatus{State: state},
+			},
+		}
+	}
+	newDriftJob := func(succeeded int32) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: v1.ObjectMeta{Name: "abc-drift-check", Namespace: "default", CreationTimestamp: v1.Now()},
+			Status:     batchv1.JobStatus{Succeeded: succeeded},
+		}
+	}
+
+	var logs string
+	patches := gomonkey.ApplyFunc(terraform.GetTerraformLogs, func(ctx context.Context, namespace, jobName, containerName, initContainerName string) (string, error) {
+		return logs, nil
+	})
+	defer patches.Reset()
+	var executionErr error
+	patches.ApplyFunc(terraform.GetTerraformStatus, func(ctx context.Context, namespace, jobName, containerName, initContainerName string) (types.ConfigurationState, error) {
+		if executionErr != nil {
+			return types.ConfigurationApplyFailed, executionErr
+		}
+		return types.ConfigurationProvisioningAndChecking, errors.New("pod is not started")
+	})
+	driftLogs := `{"@level":"info","@message":"aws_s3_bucket.b: Plan to update","change":{"resource":{"addr":"aws_s3_bucket.b"},"action":"update"},"type":"planned_change"}`
+
+	testcases := []struct {
+		name          string
+		configuration *v1beta2.Configuration
+		objects       []client.Object
+		logs          string
+		executionErr  error
+		completed     bool
+		jobExists     bool
+		state         types.ConfigurationState
+		changes       []string
+	}{
+		{
+			name:          "check job is created",
+			configuration: newConfiguration(types.Available, false),
+			jobExists:     true,
+			state:         types.DriftCheckRunning,
+		},
+		{
+			name:          "Configuration is not Available",
+			configuration: newConfiguration(types.ConfigurationProvisioningAndChecking, false),
+			completed:     true,
+		},
+		{
+			name:          "stale check job",
+			configuration: newConfiguration(types.ConfigurationApplyFailed, false),
+			objects:       []client.Object{newDriftJob(0)},
+			completed:     true,
+		},
+		{
+			name:          "check job is running",
+			configuration: newConfiguration(types.Available, false),
+			objects:       []client.Object{newDriftJob(0)},
+			jobExists:     true,
+		},
+		{
+			name:          "no drift",
+			configuration: newConfiguration(types.Available, false),
+			objects:       []client.Object{newDriftJob(1)},
+			completed:     true,
+			state:         types.NoDrift,
+		},
+		{
+			name:          "drift detected",
+			configuration: newConfiguration(types.Available, false),
+			objects:       []client.Object{newDriftJob(1)},
+			logs:          driftLogs,
+			completed:     true,
+			state:         types.DriftDetected,
+			changes:       []string{"update aws_s3_bucket.b"},
+		},
+		{
+			name:          "self-heal",
+			configuration: newConfiguration(types.Available, true),
+			objects:       []client.Object{newDriftJob(1)},
+			logs:          driftLogs,
+			state:         types.SelfHealing,
+			changes:       []string{"update aws_s3_bucket.b"},
+		},
+		{
+			name:          "check fails",
+			configuration: newConfiguration(types.Available, true),
+			objects:       []client.Object{newDriftJob(0)},
+			executionErr:  errors.New("Error: Invalid Alibaba Cloud region"),
+			completed:     true,
+			state:         types.DriftCheckFailed,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs, executionErr = tc.logs, tc.executionErr
+			objects := append([]client.Object{tc.configuration.DeepCopy()}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			r := &ConfigurationReconciler{Client: k8sClient}
+			meta := initTFConfigurationMeta(ctrl.Request{NamespacedName: k8stypes.NamespacedName{Name: "abc", Namespace: "default"}}, *tc.configuration)
+
+			completed, err := r.checkDrift(ctx, tc.configuration, meta)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.completed, completed)
+
+			var job batchv1.Job
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: "abc-drift-check", Namespace: "default"}, &job)
+			assert.Equal(t, tc.jobExists, err == nil)
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			if tc.state == "" {
+				assert.Nil(t, got.Status.Drift)
+				return
+			}
+			assert.Equal(t, tc.state, got.Status.Drift.State)
+			assert.Equal(t, tc.changes, got.Status.Drift.Changes)
+			assert.NotNil(t, got.Status.Drift.LastCheckTime)
+			assert.Equal(t, tc.state == types.SelfHealing, getSelfHealToken(&got) != "")
+		})
+	}
+
+	meta := &TFConfigurationMeta{Name: "abc", ExtraApplyArgs: []string{"-parallelism=5"}}
+	assert.Equal(t, "terraform init && terraform plan -input=false -lock=false -json", meta.assembleExecutionCommand(TerraformDriftCheck))
+}
+
+func TestSelfHeal(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	batchv1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+
+	healTime := v1.NewTime(time.Unix(1647426620, 0))
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default"},
+		Spec:       v1beta2.ConfigurationSpec{HCL: "bbb", DriftCheckInterval: "1h", SelfHeal: true},
+		Status: v1beta2.ConfigurationStatus{
+			Drift: &v1beta2.ConfigurationDriftStatus{State: types.SelfHealing, LastSelfHealTime: &healTime, SelfHealCount: 1},
+		},
+	}
+	newApplyJob := func(annotations map[string]string, succeeded int32) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: v1.ObjectMeta{Name: "abc-apply", Namespace: "default", Annotations: annotations},
+			Status:     batchv1.JobStatus{Succeeded: succeeded},
+		}
+	}
+
+	testcases := []struct {
+		name       string
+		objects    []client.Object
+		triggered  bool
+		jobDeleted bool
+		state      types.ConfigurationState
+	}{
+		{
+			name:      "no apply job",
+			triggered: true,
+			state:     types.SelfHealing,
+		},
+		{
+			name:       "apply job of the last apply",
+			objects:    []client.Object{newApplyJob(nil, 1)},
+			jobDeleted: true,
+			state:      types.SelfHealing,
+		},
+		{
+			name:      "apply job of the self-heal is running",
+			objects:   []client.Object{newApplyJob(map[string]string{selfHealJobAnnotation: "1647426620"}, 0)},
+			triggered: true,
+			state:     types.SelfHealing,
+		},
+		{
+			name:      "apply job of the self-heal succeeds",
+			objects:   []client.Object{newApplyJob(map[string]string{selfHealJobAnnotation: "1647426620"}, 1)},
+			triggered: true,
+			state:     types.DriftCorrected,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := append([]client.Object{configuration.DeepCopy()}, tc.objects...)
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+			r := &ConfigurationReconciler{Client: k8sClient}
+			meta := &TFConfigurationMeta{Name: "abc", Namespace: "default", ApplyJobName: "abc-apply", SelfHealToken: getSelfHealToken(configuration)}
+			assert.Equal(t, "1647426620", meta.SelfHealToken)
+
+			triggered, err := r.selfHeal(ctx, configuration, meta)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.triggered, triggered)
+
+			var job batchv1.Job
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: "abc-apply", Namespace: "default"}, &job)
+			assert.Equal(t, len(tc.objects) > 0 && !tc.jobDeleted, err == nil)
+
+			var got v1beta2.Configuration
+			assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "abc", Namespace: "default"}, &got))
+			assert.Equal(t, tc.state, got.Status.Drift.State)
+			assert.Equal(t, int64(1), got.Status.Drift.SelfHealCount)
+			assert.Equal(t, tc.state == types.SelfHealing, getSelfHealToken(&got) != "")
+		})
+	}
+}
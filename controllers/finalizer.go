@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// FinalizerEnv is the env of the finalizer which the controller adds to the Configurations, so the parallel
+// installations of the controller, like during a migration, don't remove each other's finalizers. It defaults to
+// `configuration.finalizers.terraform-controller`.
+const FinalizerEnv = "TERRAFORM_CONFIGURATION_FINALIZER"
+
+// GetConfigurationFinalizer gets the finalizer which the controller adds to the Configurations
+func GetConfigurationFinalizer() string {
+	if finalizer := os.Getenv(FinalizerEnv); finalizer != "" {
+		return finalizer
+	}
+	return configurationFinalizer
+}
+
+// ValidConfigurationFinalizer checks the env FinalizerEnv is a qualified name, which is required for a finalizer
+func ValidConfigurationFinalizer() error {
+	finalizer := GetConfigurationFinalizer()
+	if errs := validation.IsQualifiedName(finalizer); len(errs) > 0 {
+		return fmt.Errorf("%s %s is not a valid finalizer: %s", FinalizerEnv, finalizer, strings.Join(errs, "; "))
+	}
+	return nil
+}
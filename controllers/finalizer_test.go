@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestValidConfigurationFinalizer(t *testing.T) {
+	t.Setenv(FinalizerEnv, "")
+	assert.Nil(t, ValidConfigurationFinalizer())
+	assert.Equal(t, "configuration.finalizers.terraform-controller", GetConfigurationFinalizer())
+
+	t.Setenv(FinalizerEnv, "terraform.core.oam.dev/blue")
+	assert.Nil(t, ValidConfigurationFinalizer())
+	assert.Equal(t, "terraform.core.oam.dev/blue", GetConfigurationFinalizer())
+
+	t.Setenv(FinalizerEnv, "blue green")
+	assert.Contains(t, ValidConfigurationFinalizer().Error(), "TERRAFORM_CONFIGURATION_FINALIZER blue green is not a valid finalizer")
+}
+
+func TestReconcileWithoutOwnFinalizer(t *testing.T) {
+	t.Setenv(FinalizerEnv, "terraform.core.oam.dev/blue")
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+	batchv1.AddToScheme(s)
+	corev1.AddToScheme(s)
+
+	now := v1.Now()
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: v1.ObjectMeta{
+			Name:              "a",
+			Namespace:         "default",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{"terraform.core.oam.dev/green"},
+		},
+		Spec: v1beta2.ConfigurationSpec{HCL: "c"},
+	}
+	r := &ConfigurationReconciler{Client: fake.NewClientBuilder().WithScheme(s).WithObjects(configuration).Build()}
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "a", Namespace: "default"}})
+	assert.Nil(t, err)
+
+	// the Configuration is left to the other installation, which destroys it
+	var got v1beta2.Configuration
+	assert.Nil(t, r.Client.Get(ctx, client.ObjectKey{Name: "a", Namespace: "default"}, &got))
+	assert.Equal(t, []string{"terraform.core.oam.dev/green"}, got.Finalizers)
+	var jobs batchv1.JobList
+	assert.Nil(t, r.Client.List(ctx, &jobs))
+	assert.Empty(t, jobs.Items)
+}
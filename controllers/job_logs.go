@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	"github.com/oam-dev/terraform-controller/controllers/terraform"
+)
+
+const (
+	// StoreJobLogsEnv is the env which determines whether to store the logs of the Terraform jobs in a ConfigMap
+	StoreJobLogsEnv = "TERRAFORM_STORE_JOB_LOGS"
+	// TFJobLogsConfigMapName is the name of the ConfigMap which stores the logs of the last Terraform jobs
+	TFJobLogsConfigMapName = "%s-terraform-logs"
+	// jobRunAnnotation records the run of the job whose logs are stored, so the logs of a run are only fetched once.
+	// The job is retried in the same pod, so a run is identified by the pod and the restarts of its containers.
+	jobRunAnnotation = "terraform.core.oam.dev/%s-job-run"
+
+	// maxJobLogsSize bounds the size of the logs of a job, the beginning of the logs is dropped at a line boundary
+	maxJobLogsSize = 256 * 1024
+	// minRedactedLength is the minimal length of the variables which are redacted, so short values like `true` don't
+	// mess up the logs
+	minRedactedLength = 6
+	redactedValue     = "******"
+)
+
+// storeJobLogs stores the logs of the finished Terraform job in a ConfigMap owned by the Configuration, the values of
+// the variables and credentials are redacted. Only the logs of the last apply and destroy jobs are kept.
+func (meta *TFConfigurationMeta) storeJobLogs(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration, jobName string, executionType TerraformExecutionType) error {
+	if enabled, _ := strconv.ParseBool(os.Getenv(StoreJobLogsEnv)); !enabled {
+		return nil
+	}
+
+	var job batchv1.Job
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: jobName, Namespace: meta.Namespace}, &job); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	var (
+		cm         v1.ConfigMap
+		name       = fmt.Sprintf(TFJobLogsConfigMapName, meta.Name)
+		annotation = fmt.Sprintf(jobRunAnnotation, executionType)
+	)
+	run, err := terraform.GetTerraformJobRun(ctx, meta.Namespace, job.Name)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the pod of the Terraform job")
+	}
+	run = fmt.Sprintf("%s/%s", job.UID, run)
+
+	err = k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: meta.Namespace}, &cm)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get the ConfigMap of the job logs")
+	}
+	notFound := kerrors.IsNotFound(err)
+	if cm.Annotations[annotation] == run {
+		return nil
+	}
+
+	logs, err := terraform.GetTerraformLogs(ctx, meta.Namespace, job.Name, terraformContainerName, terraformInitContainerName)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the logs of the Terraform job")
+	}
+	logs = trimJobLogs(meta.redactLogs(logs))
+
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[annotation] = run
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[string(executionType)+".log"] = logs
+
+	klog.InfoS("Storing the logs of the Terraform job", "ConfigMap", name, "Job", job.Name)
+	if notFound {
+		cm.Name = name
+		cm.Namespace = meta.Namespace
+		cm.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: v1beta2.GroupVersion.String(),
+			Kind:       "Configuration",
+			Name:       configuration.Name,
+			UID:        configuration.UID,
+		}}
+		return errors.Wrap(k8sClient.Create(ctx, &cm), "failed to create the ConfigMap of the job logs")
+	}
+	return errors.Wrap(k8sClient.Update(ctx, &cm), "failed to update the ConfigMap of the job logs")
+}
+
+// trimJobLogs drops the beginning of the logs which are larger than maxJobLogsSize, the logs are cut at the beginning
+// of a line, or of a rune if the last line is too long
+func trimJobLogs(logs string) string {
+	if len(logs) <= maxJobLogsSize {
+		return logs
+	}
+	start := len(logs) - maxJobLogsSize
+	if logs[start-1] == '\n' {
+		return logs[start:]
+	}
+	if i := strings.IndexByte(logs[start:], '\n'); i >= 0 && start+i+1 < len(logs) {
+		return logs[start+i+1:]
+	}
+	for start < len(logs) && !utf8.RuneStart(logs[start]) {
+		start++
+	}
+	return logs[start:]
+}
+
+// redactLogs replaces the values of the variables and credentials, and the secrets of the .netrc in the logs
+func (meta *TFConfigurationMeta) redactLogs(logs string) string {
+	var values []string
+	for key, value := range meta.VariableSecretData {
+		// the variables of the list or object types are stored as one JSON file, the strings in them are redacted
+		if key == types.TerraformVariablesFileName {
+			var variables interface{}
+			if err := json.Unmarshal(value, &variables); err == nil {
+				values = append(values, stringLeaves(variables)...)
+				continue
+			}
+		}
+		values = append(values, string(value))
+	}
+	values = append(values, meta.NetrcSecrets...)
+	for _, value := range values {
+		if len(value) >= minRedactedLength {
+			logs = strings.ReplaceAll(logs, value, redactedValue)
+		}
+	}
+	return logs
+}
+
+// stringLeaves collects the strings in a decoded JSON value
+func stringLeaves(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var leaves []string
+		for _, item := range v {
+			leaves = append(leaves, stringLeaves(item)...)
+		}
+		return leaves
+	case map[string]interface{}:
+		var leaves []string
+		for _, item := range v {
+			leaves = append(leaves, stringLeaves(item)...)
+		}
+		return leaves
+	}
+	return nil
+}
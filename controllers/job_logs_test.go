@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	"github.com/oam-dev/terraform-controller/controllers/terraform"
+)
+
+func TestStoreJobLogs(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	batchv1.AddToScheme(s)
+
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default", UID: "configuration-uid"},
+	}
+	meta := &TFConfigurationMeta{
+		Name:      "abc",
+		Namespace: "default",
+		VariableSecretData: map[string][]byte{
+			"ALICLOUD_SECRET_KEY": []byte("s3cr3t-key"),
+			"TF_VAR_enabled":      []byte("true"),
+		},
+	}
+	newJob := func(uid k8stypes.UID) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: v1.ObjectMeta{Name: "abc-apply", Namespace: "default", UID: uid}}
+	}
+
+	var fetched int
+	logs := "enabled = true\nsecret_key = s3cr3t-key\nApply complete!"
+	patches := gomonkey.ApplyFunc(terraform.GetTerraformLogs, func(ctx context.Context, namespace, jobName, containerName, initContainerName string) (string, error) {
+		fetched++
+		return logs, nil
+	})
+	defer patches.Reset()
+	run := "abc-apply-x1/0"
+	patches.ApplyFunc(terraform.GetTerraformJobRun, func(ctx context.Context, namespace, jobName string) (string, error) {
+		return run, nil
+	})
+
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(newJob("job-1")).Build()
+	getConfigMap := func() (*corev1.ConfigMap, error) {
+		var cm corev1.ConfigMap
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: "abc-terraform-logs", Namespace: "default"}, &cm)
+		return &cm, err
+	}
+
+	// disabled by default
+	t.Setenv(StoreJobLogsEnv, "")
+	assert.Nil(t, meta.storeJobLogs(ctx, k8sClient, configuration, "abc-apply", TerraformApply))
+	_, err := getConfigMap()
+	assert.NotNil(t, err)
+
+	t.Setenv(StoreJobLogsEnv, "true")
+	assert.Nil(t, meta.storeJobLogs(ctx, k8sClient, configuration, "abc-apply", TerraformApply))
+	cm, err := getConfigMap()
+	assert.Nil(t, err)
+	assert.Equal(t, "enabled = true\nsecret_key = ******\nApply complete!", cm.Data["apply.log"])
+	assert.Equal(t, "job-1/abc-apply-x1/0", cm.Annotations["terraform.core.oam.dev/apply-job-run"])
+	assert.Equal(t, k8stypes.UID("configuration-uid"), cm.OwnerReferences[0].UID)
+
+	// the logs of the same job are only fetched once
+	assert.Nil(t, meta.storeJobLogs(ctx, k8sClient, configuration, "abc-apply", TerraformApply))
+	assert.Equal(t, 1, fetched)
+
+	// the logs of the retry of the same job are kept
+	run = "abc-apply-x1/1"
+	logs = "Error: timeout"
+	assert.Nil(t, meta.storeJobLogs(ctx, k8sClient, configuration, "abc-apply", TerraformApply))
+	assert.Equal(t, 2, fetched)
+	cm, err = getConfigMap()
+	assert.Nil(t, err)
+	assert.Equal(t, "Error: timeout", cm.Data["apply.log"])
+	assert.Equal(t, "job-1/abc-apply-x1/1", cm.Annotations["terraform.core.oam.dev/apply-job-run"])
+
+	// the logs of the last job are kept
+	assert.Nil(t, k8sClient.Delete(ctx, newJob("job-1")))
+	assert.Nil(t, k8sClient.Create(ctx, newJob("job-2")))
+	logs = strings.Repeat("x", maxJobLogsSize) + "Apply complete!"
+	assert.Nil(t, meta.storeJobLogs(ctx, k8sClient, configuration, "abc-apply", TerraformApply))
+	cm, err = getConfigMap()
+	assert.Nil(t, err)
+	assert.Equal(t, maxJobLogsSize, len(cm.Data["apply.log"]))
+	assert.True(t, strings.HasSuffix(cm.Data["apply.log"], "Apply complete!"))
+	assert.Equal(t, "job-2/abc-apply-x1/1", cm.Annotations["terraform.core.oam.dev/apply-job-run"])
+
+	// the job is not found
+	assert.Nil(t, meta.storeJobLogs(ctx, k8sClient, configuration, "abc-destroy", TerraformDestroy))
+}
+
+func TestTrimJobLogs(t *testing.T) {
+	testcases := []struct {
+		name string
+		logs string
+		want string
+	}{
+		{
+			name: "the logs are not larger than the limit",
+			logs: "Apply complete!",
+			want: "Apply complete!",
+		},
+		{
+			name: "the logs are cut at the beginning of a line",
+			logs: "Plan: 1 to add\n" + strings.Repeat("x", maxJobLogsSize-1) + "\n",
+			want: strings.Repeat("x", maxJobLogsSize-1) + "\n",
+		},
+		{
+			name: "the logs are cut at the next line",
+			logs: "Plan: 1 to add\n" + strings.Repeat("x", maxJobLogsSize) + "\nApply complete!",
+			want: "Apply complete!",
+		},
+		{
+			name: "the long line is cut at the beginning of a rune",
+			logs: "Plan: 1 to add\n" + strings.Repeat("中", maxJobLogsSize/3+1),
+			want: strings.Repeat("中", maxJobLogsSize/3),
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := trimJobLogs(tc.logs)
+			assert.Equal(t, tc.want, got)
+			assert.True(t, utf8.ValidString(got))
+		})
+	}
+}
+
+func TestRedactLogs(t *testing.T) {
+	meta := &TFConfigurationMeta{
+		VariableSecretData: map[string][]byte{
+			"TF_VAR_name":                    []byte("my-bucket"),
+			types.TerraformVariablesFileName: []byte(`{"tags":{"owner":"ops","token":"s3cr3t-token"},"zones":["cn-hangzhou-h"],"size":100000}`),
+		},
+		NetrcSecrets: []string{"s3cr3t-pass"},
+	}
+	logs := `bucket = "my-bucket"
+tags = {"owner" = "ops", "token" = "s3cr3t-token"}
+zones = ["cn-hangzhou-h"]
+password = s3cr3t-pass`
+	assert.Equal(t, `bucket = "******"
+tags = {"owner" = "ops", "token" = "******"}
+zones = ["******"]
+password = ******`, meta.redactLogs(logs))
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+)
+
+// netrcKeywords are the tokens of .netrc, and whether they're followed by a value
+var netrcKeywords = map[string]bool{
+	"machine":  true,
+	"default":  false,
+	"login":    true,
+	"password": true,
+	"account":  true,
+	"macdef":   true,
+}
+
+// parseNetrc checks whether the data consists of valid .netrc lines, and returns the logins, passwords and accounts in
+// it, which are redacted from the logs. The tokens are never put into the errors, as they may be the passwords.
+func parseNetrc(data string) ([]string, error) {
+	var (
+		secrets []string
+		// keyword is the keyword which waits for its value
+		keyword string
+		// entry is whether a machine or default has started
+		entry bool
+		// macro is whether the lines are the body of a macdef, which ends with an empty line
+		macro bool
+	)
+	for i, line := range strings.Split(data, "\n") {
+		if macro {
+			macro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, field := range fields {
+			if keyword != "" {
+				if keyword == "login" || keyword == "password" || keyword == "account" {
+					secrets = append(secrets, field)
+				}
+				macro = keyword == "macdef"
+				keyword = ""
+				if macro {
+					break
+				}
+				continue
+			}
+			hasValue, ok := netrcKeywords[field]
+			if !ok {
+				return nil, fmt.Errorf("line %d has an unknown token, it should be one of machine, default, login, password, account and macdef", i+1)
+			}
+			switch field {
+			case "machine", "default":
+				entry = true
+			case "macdef":
+			default:
+				if !entry {
+					return nil, fmt.Errorf("line %d: %s should follow a machine or default", i+1, field)
+				}
+			}
+			if hasValue {
+				keyword = field
+			}
+		}
+	}
+	if keyword != "" {
+		return nil, fmt.Errorf("%s is not followed by a value", keyword)
+	}
+	if !entry {
+		return nil, errors.New("no machine or default is found")
+	}
+	return secrets, nil
+}
+
+// getNetrc gets the .netrc in the Secret of spec.NetrcSecretRef, and checks whether it's valid. The logins, passwords
+// and accounts in it are returned to be redacted from the logs.
+func (meta *TFConfigurationMeta) getNetrc(ctx context.Context, k8sClient client.Client) ([]string, error) {
+	var secret v1.Secret
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.NetrcSecretName, Namespace: meta.Namespace}, &secret); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, fmt.Errorf("the netrc Secret %s is not found in namespace %s", meta.NetrcSecretName, meta.Namespace)
+		}
+		return nil, errors.Wrap(err, "failed to get the netrc Secret")
+	}
+	data, ok := secret.Data[types.NetrcFileName]
+	if !ok {
+		return nil, fmt.Errorf("the netrc Secret %s doesn't have the key %s", meta.NetrcSecretName, types.NetrcFileName)
+	}
+	secrets, err := parseNetrc(string(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "the %s of the netrc Secret %s is not valid", types.NetrcFileName, meta.NetrcSecretName)
+	}
+	return secrets, nil
+}
+
+// createNetrcVolume creates the volume of the .netrc in the Secret of spec.NetrcSecretRef, which is only readable by
+// the owner
+func (meta *TFConfigurationMeta) createNetrcVolume() v1.Volume {
+	mode := int32(0400)
+	netrcVolume := v1.Volume{Name: NetrcVolumeName}
+	netrcVolume.Secret = &v1.SecretVolumeSource{
+		SecretName:  meta.NetrcSecretName,
+		Items:       []v1.KeyToPath{{Key: types.NetrcFileName, Path: types.NetrcFileName}},
+		DefaultMode: &mode,
+	}
+	return netrcVolume
+}
+
+// assembleNetrcCommand writes the .netrc of spec.NetrcSecretRef to the home directory before the command which
+// downloads the modules and providers. A trap removes it when the shell exits, whether the command succeeds or not.
+func (meta *TFConfigurationMeta) assembleNetrcCommand() string {
+	if meta.NetrcSecretName == "" {
+		return ""
+	}
+	netrc := "$HOME/" + types.NetrcFileName
+	return fmt.Sprintf("trap 'shred -u %[1]s 2>/dev/null || rm -f %[1]s' EXIT; cp %[2]s %[1]s && chmod 600 %[1]s && ",
+		netrc, filepath.Join(NetrcVolumeMountPath, types.NetrcFileName))
+}
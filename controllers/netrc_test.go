@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseNetrc(t *testing.T) {
+	testcases := []struct {
+		name    string
+		data    string
+		secrets []string
+		errMsg  string
+	}{
+		{
+			name:    "machines",
+			data:    "# artifactory\nmachine artifactory.example.com login deployer password s3cr3t-pass\n\nmachine git.example.com\n  login bot\n  password t0ken-value\n",
+			secrets: []string{"deployer", "s3cr3t-pass", "bot", "t0ken-value"},
+		},
+		{
+			name:    "default and macdef",
+			data:    "default login anonymous password guest-pass\nmacdef init\ncd /pub\nbinary\n\nmachine mirror.example.com account acct-id",
+			secrets: []string{"anonymous", "guest-pass", "acct-id"},
+		},
+		{
+			name:   "unknown token",
+			data:   "machine artifactory.example.com login deployer s3cr3t-pass",
+			errMsg: "line 1 has an unknown token, it should be one of machine, default, login, password, account and macdef",
+		},
+		{
+			name:   "login without machine",
+			data:   "login deployer\npassword s3cr3t-pass",
+			errMsg: "line 1: login should follow a machine or default",
+		},
+		{
+			name:   "password without value",
+			data:   "machine artifactory.example.com login deployer password",
+			errMsg: "password is not followed by a value",
+		},
+		{
+			name:   "empty",
+			data:   "# nothing\n",
+			errMsg: "no machine or default is found",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			secrets, err := parseNetrc(tc.data)
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.secrets, secrets)
+		})
+	}
+}
+
+func TestGetNetrc(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+
+	newSecret := func(data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: v1.ObjectMeta{Name: "netrc", Namespace: "default"}, Data: data}
+	}
+	testcases := []struct {
+		name    string
+		objects []client.Object
+		secrets []string
+		errMsg  string
+	}{
+		{
+			name:    "valid netrc",
+			objects: []client.Object{newSecret(map[string][]byte{".netrc": []byte("machine artifactory.example.com login deployer password s3cr3t-pass")})},
+			secrets: []string{"deployer", "s3cr3t-pass"},
+		},
+		{
+			name:   "secret not found",
+			errMsg: "the netrc Secret netrc is not found in namespace default",
+		},
+		{
+			name:    "key not found",
+			objects: []client.Object{newSecret(map[string][]byte{"netrc": []byte("machine a")})},
+			errMsg:  "the netrc Secret netrc doesn't have the key .netrc",
+		},
+		{
+			name:    "invalid netrc",
+			objects: []client.Object{newSecret(map[string][]byte{".netrc": []byte("machine artifactory.example.com deployer:s3cr3t-pass")})},
+			errMsg:  "the .netrc of the netrc Secret netrc is not valid: line 1 has an unknown token",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(tc.objects...).Build()
+			meta := &TFConfigurationMeta{Name: "abc", Namespace: "default", NetrcSecretName: "netrc"}
+			secrets, err := meta.getNetrc(ctx, k8sClient)
+			if tc.errMsg != "" {
+				assert.Contains(t, err.Error(), tc.errMsg)
+				assert.NotContains(t, err.Error(), "s3cr3t-pass")
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.secrets, secrets)
+		})
+	}
+}
+
+func TestAssembleNetrc(t *testing.T) {
+	meta := &TFConfigurationMeta{Name: "a", Namespace: "e", TerraformImage: "f"}
+	job := meta.assembleTerraformJob(TerraformApply)
+	for _, volume := range job.Spec.Template.Spec.Volumes {
+		assert.NotEqual(t, NetrcVolumeName, volume.Name)
+	}
+	assert.False(t, strings.Contains(job.Spec.Template.Spec.Containers[0].Command[2], ".netrc"))
+
+	meta.NetrcSecretName = "netrc"
+	meta.NetrcSecrets = []string{"deployer", "s3cr3t-pass"}
+	job = meta.assembleTerraformJob(TerraformApply)
+	volumes := job.Spec.Template.Spec.Volumes
+	netrcVolume := volumes[len(volumes)-1]
+	assert.Equal(t, NetrcVolumeName, netrcVolume.Name)
+	assert.Equal(t, "netrc", netrcVolume.Secret.SecretName)
+	assert.Equal(t, int32(0400), *netrcVolume.Secret.DefaultMode)
+
+	netrcCommand := "trap 'shred -u $HOME/.netrc 2>/dev/null || rm -f $HOME/.netrc' EXIT; cp /opt/tf-netrc/.netrc $HOME/.netrc && chmod 600 $HOME/.netrc && "
+	initContainers := job.Spec.Template.Spec.InitContainers
+	initContainer := initContainers[len(initContainers)-1]
+	assert.Equal(t, terraformInitContainerName, initContainer.Name)
+	assert.Equal(t, netrcCommand+"terraform init", initContainer.Command[2])
+	assert.Contains(t, initContainer.VolumeMounts, corev1.VolumeMount{Name: NetrcVolumeName, MountPath: NetrcVolumeMountPath, ReadOnly: true})
+	// the other init containers don't mount the .netrc
+	assert.Equal(t, len(initContainer.VolumeMounts)-1, len(initContainers[0].VolumeMounts))
+
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.True(t, strings.HasPrefix(container.Command[2], netrcCommand+"(terraform init && terraform apply"))
+	assert.Contains(t, container.VolumeMounts, corev1.VolumeMount{Name: NetrcVolumeName, MountPath: NetrcVolumeMountPath, ReadOnly: true})
+
+	assert.Equal(t, "login ******, password ******", meta.redactLogs("login deployer, password s3cr3t-pass"))
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+// writeOutputTargets writes the outputs to spec.OutputTargets in the namespace of the Configuration. All the targets
+// are checked before any of them is written, so a sensitive output, which is only written to the connection secret,
+// doesn't leave the others half written.
+func (meta *TFConfigurationMeta) writeOutputTargets(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration, outputs map[string]TfStateProperty) error {
+	if len(configuration.Spec.OutputTargets) == 0 {
+		return nil
+	}
+
+	configMaps := map[string]map[string]string{}
+	var patches []v1beta2.OutputTarget
+	for _, target := range configuration.Spec.OutputTargets {
+		output, ok := outputs[target.Output]
+		if !ok {
+			return fmt.Errorf("the output %s of spec.OutputTargets is not found", target.Output)
+		}
+		if target.Patch != nil {
+			if output.Sensitive {
+				return fmt.Errorf("the sensitive output %s is not allowed to be written to %s %s", target.Output, target.Patch.Kind, target.Patch.Name)
+			}
+			patches = append(patches, target)
+			continue
+		}
+		if target.ConfigMap == nil {
+			continue
+		}
+		if output.Sensitive {
+			return fmt.Errorf("the sensitive output %s is not allowed to be written to ConfigMap %s", target.Output, target.ConfigMap.Name)
+		}
+		property, err := output.ToProperty()
+		if err != nil {
+			return err
+		}
+		key := target.ConfigMap.Key
+		if key == "" {
+			key = target.Output
+		}
+		if configMaps[target.ConfigMap.Name] == nil {
+			configMaps[target.ConfigMap.Name] = map[string]string{}
+		}
+		configMaps[target.ConfigMap.Name][key] = property.Value
+	}
+
+	names := make([]string, 0, len(configMaps))
+	for name := range configMaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := meta.writeOutputConfigMap(ctx, k8sClient, configuration, name, configMaps[name]); err != nil {
+			return err
+		}
+	}
+	for _, target := range patches {
+		if err := meta.patchOutputTarget(ctx, k8sClient, target.Patch, outputs[target.Output].Value); err != nil {
+			return errors.Wrapf(err, "failed to write the output %s to %s %s", target.Output, target.Patch.Kind, target.Patch.Name)
+		}
+	}
+	return nil
+}
+
+// writeOutputConfigMap writes the outputs to the keys of a ConfigMap, which is created with an owner reference to the
+// Configuration. The other keys of the ConfigMap are kept. A ConfigMap owned by another Configuration is not changed.
+func (meta *TFConfigurationMeta) writeOutputConfigMap(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration, name string, data map[string]string) error {
+	var cm v1.ConfigMap
+	err := k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: meta.Namespace}, &cm)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to get the ConfigMap %s of spec.OutputTargets", name)
+	}
+	if kerrors.IsNotFound(err) {
+		cm = v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: meta.Namespace,
+				Labels: map[string]string{
+					"terraform.core.oam.dev/created-by":      "terraform-controller",
+					"terraform.core.oam.dev/owned-by":        configuration.Name,
+					"terraform.core.oam.dev/owned-namespace": configuration.Namespace,
+				},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: v1beta2.GroupVersion.String(),
+					Kind:       "Configuration",
+					Name:       configuration.Name,
+					UID:        configuration.UID,
+				}},
+			},
+			Data: data,
+		}
+		klog.InfoS("Writing the outputs to a ConfigMap", "ConfigMap", name, "Namespace", meta.Namespace)
+		return errors.Wrapf(k8sClient.Create(ctx, &cm), "failed to create the ConfigMap %s of spec.OutputTargets", name)
+	}
+
+	ownerName := cm.Labels["terraform.core.oam.dev/owned-by"]
+	ownerNamespace := cm.Labels["terraform.core.oam.dev/owned-namespace"]
+	if (ownerName != "" && ownerName != configuration.Name) || (ownerNamespace != "" && ownerNamespace != configuration.Namespace) {
+		return fmt.Errorf("configuration(namespace: %s ; name: %s) cannot update ConfigMap %s whose owner is configuration(namespace: %s ; name: %s)",
+			configuration.Namespace, configuration.Name, name, ownerNamespace, ownerName)
+	}
+	changed := false
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	for k, v := range data {
+		if val, ok := cm.Data[k]; !ok || val != v {
+			cm.Data[k] = v
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	klog.InfoS("Writing the outputs to a ConfigMap", "ConfigMap", name, "Namespace", meta.Namespace)
+	return errors.Wrapf(k8sClient.Update(ctx, &cm), "failed to update the ConfigMap %s of spec.OutputTargets", name)
+}
+
+// patchOutputTarget adds the value of the output to a field of an object by a JSON patch, the type of the value is
+// kept, like a number or a list
+func (meta *TFConfigurationMeta) patchOutputTarget(ctx context.Context, k8sClient client.Client, target *v1beta2.PatchOutputTarget, value interface{}) error {
+	patch, err := json.Marshal([]map[string]interface{}{{"op": "add", "path": target.Path, "value": value}})
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(target.APIVersion)
+	obj.SetKind(target.Kind)
+	obj.SetName(target.Name)
+	obj.SetNamespace(meta.Namespace)
+	return k8sClient.Patch(ctx, obj, client.RawPatch(k8stypes.JSONPatchType, patch))
+}
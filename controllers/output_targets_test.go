@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestWriteOutputTargets(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	appsv1.AddToScheme(s)
+
+	outputs := map[string]TfStateProperty{
+		"url":      {Value: "https://db.example.com", Type: "string"},
+		"port":     {Value: float64(5432), Type: "number"},
+		"password": {Value: "s3cr3t", Type: "string", Sensitive: true},
+	}
+	newConfiguration := func(targets ...v1beta2.OutputTarget) *v1beta2.Configuration {
+		return &v1beta2.Configuration{
+			ObjectMeta: v1.ObjectMeta{Name: "abc", Namespace: "default", UID: "configuration-uid"},
+			Spec:       v1beta2.ConfigurationSpec{OutputTargets: targets},
+		}
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: "web", Namespace: "default"}}
+	ownedConfigMap := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "endpoints",
+			Namespace: "default",
+			Labels:    map[string]string{"terraform.core.oam.dev/owned-by": "another"},
+		},
+	}
+
+	testcases := []struct {
+		name          string
+		configuration *v1beta2.Configuration
+		objects       []client.Object
+		configMapData map[string]string
+		replicas      *int32
+		errMsg        string
+	}{
+		{
+			name: "write to a ConfigMap",
+			configuration: newConfiguration(
+				v1beta2.OutputTarget{Output: "url", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints"}},
+				v1beta2.OutputTarget{Output: "port", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints", Key: "db.port"}},
+			),
+			configMapData: map[string]string{"url": "https://db.example.com", "db.port": "5432"},
+		},
+		{
+			name: "patch an object",
+			configuration: newConfiguration(
+				v1beta2.OutputTarget{Output: "port", Patch: &v1beta2.PatchOutputTarget{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Path: "/spec/replicas"}},
+			),
+			objects:  []client.Object{deployment},
+			replicas: func() *int32 { r := int32(5432); return &r }(),
+		},
+		{
+			name: "sensitive output to a ConfigMap",
+			configuration: newConfiguration(
+				v1beta2.OutputTarget{Output: "url", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints"}},
+				v1beta2.OutputTarget{Output: "password", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints"}},
+			),
+			errMsg: "the sensitive output password is not allowed to be written to ConfigMap endpoints",
+		},
+		{
+			name: "sensitive output to an object",
+			configuration: newConfiguration(
+				v1beta2.OutputTarget{Output: "password", Patch: &v1beta2.PatchOutputTarget{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Path: "/metadata/annotations/password"}},
+			),
+			objects: []client.Object{deployment},
+			errMsg:  "the sensitive output password is not allowed to be written to Deployment web",
+		},
+		{
+			name: "output not found",
+			configuration: newConfiguration(
+				v1beta2.OutputTarget{Output: "name", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints"}},
+			),
+			errMsg: "the output name of spec.OutputTargets is not found",
+		},
+		{
+			name: "ConfigMap owned by another Configuration",
+			configuration: newConfiguration(
+				v1beta2.OutputTarget{Output: "url", ConfigMap: &v1beta2.ConfigMapOutputTarget{Name: "endpoints"}},
+			),
+			objects: []client.Object{ownedConfigMap},
+			errMsg:  "cannot update ConfigMap endpoints",
+		},
+		{
+			name: "object to patch not found",
+			configuration: newConfiguration(
+				v1beta2.OutputTarget{Output: "port", Patch: &v1beta2.PatchOutputTarget{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Path: "/spec/replicas"}},
+			),
+			errMsg: "failed to write the output port to Deployment web",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(tc.objects...).Build()
+			meta := &TFConfigurationMeta{Name: "abc", Namespace: "default"}
+
+			err := meta.writeOutputTargets(ctx, k8sClient, tc.configuration, outputs)
+			if tc.errMsg != "" {
+				assert.Contains(t, err.Error(), tc.errMsg)
+				var cm corev1.ConfigMap
+				err = k8sClient.Get(ctx, client.ObjectKey{Name: "endpoints", Namespace: "default"}, &cm)
+				assert.Equal(t, len(tc.objects) > 0 && tc.objects[0] == ownedConfigMap, err == nil)
+				return
+			}
+			assert.Nil(t, err)
+
+			if tc.configMapData != nil {
+				var cm corev1.ConfigMap
+				assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "endpoints", Namespace: "default"}, &cm))
+				assert.Equal(t, tc.configMapData, cm.Data)
+				assert.Equal(t, "configuration-uid", string(cm.OwnerReferences[0].UID))
+			}
+			if tc.replicas != nil {
+				var got appsv1.Deployment
+				assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "web", Namespace: "default"}, &got))
+				assert.Equal(t, *tc.replicas, *got.Spec.Replicas)
+			}
+		})
+	}
+}
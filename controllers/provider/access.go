@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+)
+
+// AccessDeniedError is the error when a Configuration references a Provider which it's not allowed to use by
+// spec.allowedNamespaces or spec.configurationSelector of the Provider
+type AccessDeniedError struct {
+	Message string
+}
+
+func (e *AccessDeniedError) Error() string {
+	return e.Message
+}
+
+// CheckProviderAccess checks whether the Configuration is allowed to use the Provider. The Configurations in the
+// namespace of the Provider are always allowed by spec.allowedNamespaces, but not by spec.configurationSelector.
+func CheckProviderAccess(provider *v1beta1.Provider, configuration metav1.Object) error {
+	denied := func(reason string) error {
+		return &AccessDeniedError{Message: fmt.Sprintf(types.MessageProviderAccessDenied, configuration.GetNamespace(),
+			configuration.GetName(), provider.Namespace, provider.Name, reason)}
+	}
+	if namespaces := provider.Spec.AllowedNamespaces; len(namespaces) > 0 && configuration.GetNamespace() != provider.Namespace {
+		var allowed bool
+		for _, namespace := range namespaces {
+			allowed = allowed || namespace == configuration.GetNamespace()
+		}
+		if !allowed {
+			return denied("its namespace is not in spec.allowedNamespaces")
+		}
+	}
+	if provider.Spec.ConfigurationSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(provider.Spec.ConfigurationSelector)
+		if err != nil {
+			return denied(fmt.Sprintf("spec.configurationSelector is invalid: %s", err.Error()))
+		}
+		if !selector.Matches(labels.Set(configuration.GetLabels())) {
+			return denied("its labels don't match spec.configurationSelector")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+)
+
+func TestCheckProviderAccess(t *testing.T) {
+	testcases := map[string]struct {
+		spec          v1beta1.ProviderSpec
+		configuration metav1.ObjectMeta
+		errMsg        string
+	}{
+		"no restriction": {
+			configuration: metav1.ObjectMeta{Name: "c", Namespace: "team-a"},
+		},
+		"allowed namespace": {
+			spec:          v1beta1.ProviderSpec{AllowedNamespaces: []string{"team-a", "team-b"}},
+			configuration: metav1.ObjectMeta{Name: "c", Namespace: "team-b"},
+		},
+		"namespace of the Provider": {
+			spec:          v1beta1.ProviderSpec{AllowedNamespaces: []string{"team-a"}},
+			configuration: metav1.ObjectMeta{Name: "c", Namespace: "default"},
+		},
+		"namespace is not allowed": {
+			spec:          v1beta1.ProviderSpec{AllowedNamespaces: []string{"team-a"}},
+			configuration: metav1.ObjectMeta{Name: "c", Namespace: "team-b"},
+			errMsg:        "Configuration team-b/c is not allowed to use Provider default/aws, its namespace is not in spec.allowedNamespaces",
+		},
+		"labels match": {
+			spec: v1beta1.ProviderSpec{
+				ConfigurationSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			},
+			configuration: metav1.ObjectMeta{Name: "c", Namespace: "team-a", Labels: map[string]string{"env": "prod"}},
+		},
+		"labels don't match": {
+			spec: v1beta1.ProviderSpec{
+				ConfigurationSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			},
+			configuration: metav1.ObjectMeta{Name: "c", Namespace: "default", Labels: map[string]string{"env": "dev"}},
+			errMsg:        "Configuration default/c is not allowed to use Provider default/aws, its labels don't match spec.configurationSelector",
+		},
+		"invalid selector": {
+			spec: v1beta1.ProviderSpec{
+				ConfigurationSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "env", Operator: "Unknown"},
+				}},
+			},
+			configuration: metav1.ObjectMeta{Name: "c", Namespace: "default"},
+			errMsg:        "spec.configurationSelector is invalid",
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			provider := &v1beta1.Provider{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: "default"},
+				Spec:       tc.spec,
+			}
+			err := CheckProviderAccess(provider, &tc.configuration)
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Contains(t, err.Error(), tc.errMsg)
+			var deniedErr *AccessDeniedError
+			assert.ErrorAs(t, err, &deniedErr)
+		})
+	}
+}
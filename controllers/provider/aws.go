@@ -23,6 +23,7 @@ type AWSCredentials struct {
 func getAWSCredentials(secretData []byte, name, namespace, region string) (map[string]string, error) {
 	var ak AWSCredentials
 	if err := yaml.Unmarshal(secretData, &ak); err != nil {
+		err = scrubSecretError(err)
 		klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
 		return nil, errors.Wrap(err, errConvertCredentials)
 	}
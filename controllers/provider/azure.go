@@ -24,6 +24,7 @@ type AzureCredentials struct {
 func getAzureCredentials(secretData []byte, name, namespace string) (map[string]string, error) {
 	var cred AzureCredentials
 	if err := yaml.Unmarshal(secretData, &cred); err != nil {
+		err = scrubSecretError(err)
 		klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
 		return nil, errors.Wrap(err, errConvertCredentials)
 	}
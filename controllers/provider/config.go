@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+)
+
+// providerConfigVariablePattern are the names of the variables of the secrets in the provider config, which are also
+// parts of the names of the environment variables
+var providerConfigVariablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidProviderConfig validates the verbatim provider config of a Provider
+func ValidProviderConfig(provider *v1beta1.Provider) error {
+	config := provider.Spec.ProviderConfig
+	if config == nil {
+		return nil
+	}
+	if strings.TrimSpace(config.Template) == "" {
+		return errors.New("the template of the provider config is empty")
+	}
+	for name, secretRef := range config.SecretRefs {
+		if !providerConfigVariablePattern.MatchString(name) {
+			return fmt.Errorf("the secret %s of the provider config should be named by letters, digits and underscores", name)
+		}
+		if secretRef.Name == "" || secretRef.Key == "" {
+			return fmt.Errorf("the secret %s of the provider config needs the name and key of the secret", name)
+		}
+	}
+	return nil
+}
+
+// GetProviderConfigSecrets gets the values of the secrets of the provider config, keyed by the environment variables
+// of the Terraform variables which they are injected as, like `TF_VAR_vault_token`
+func GetProviderConfigSecrets(ctx context.Context, k8sClient client.Client, provider *v1beta1.Provider) (map[string]string, error) {
+	config := provider.Spec.ProviderConfig
+	if config == nil || len(config.SecretRefs) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]string, len(config.SecretRefs))
+	for name, secretRef := range config.SecretRefs {
+		namespace := secretRef.Namespace
+		if namespace == "" {
+			namespace = provider.Namespace
+		}
+		var secret v1.Secret
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: secretRef.Name, Namespace: namespace}, &secret); err != nil {
+			errMsg := "failed to get the Secret of the provider config"
+			klog.ErrorS(err, errMsg, "Name", secretRef.Name, "Namespace", namespace)
+			return nil, errors.Wrap(err, errMsg)
+		}
+		value, ok := secret.Data[secretRef.Key]
+		if !ok {
+			return nil, errors.Errorf("in the provider %s, the key %s not found in the secret %s of the provider config",
+				provider.Name, secretRef.Key, secretRef.Name)
+		}
+		values["TF_VAR_"+name] = string(value)
+	}
+	return values, nil
+}
@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	types "github.com/oam-dev/terraform-controller/api/types/crossplane-runtime"
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+)
+
+func TestValidProviderConfig(t *testing.T) {
+	testcases := map[string]struct {
+		config *v1beta1.ProviderConfig
+		errMsg string
+	}{
+		"no provider config": {},
+		"valid provider config": {
+			config: &v1beta1.ProviderConfig{
+				Template: `provider "vault" {}`,
+				SecretRefs: map[string]types.SecretKeySelector{
+					"vault_token": {SecretReference: types.SecretReference{Name: "vault"}, Key: "token"},
+				},
+			},
+		},
+		"empty template": {
+			config: &v1beta1.ProviderConfig{Template: " \n"},
+			errMsg: "the template of the provider config is empty",
+		},
+		"invalid variable name": {
+			config: &v1beta1.ProviderConfig{
+				Template: `provider "vault" {}`,
+				SecretRefs: map[string]types.SecretKeySelector{
+					"vault-token": {SecretReference: types.SecretReference{Name: "vault"}, Key: "token"},
+				},
+			},
+			errMsg: "the secret vault-token of the provider config should be named by letters, digits and underscores",
+		},
+		"secret without key": {
+			config: &v1beta1.ProviderConfig{
+				Template: `provider "vault" {}`,
+				SecretRefs: map[string]types.SecretKeySelector{
+					"vault_token": {SecretReference: types.SecretReference{Name: "vault"}},
+				},
+			},
+			errMsg: "the secret vault_token of the provider config needs the name and key of the secret",
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidProviderConfig(&v1beta1.Provider{Spec: v1beta1.ProviderSpec{ProviderConfig: tc.config}})
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestGetProviderConfigSecrets(t *testing.T) {
+	ctx := context.TODO()
+	k8sClient := fake.NewClientBuilder().Build()
+	assert.Nil(t, k8sClient.Create(ctx, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("s.abc")},
+	}))
+	assert.Nil(t, k8sClient.Create(ctx, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube", Namespace: "infra"},
+		Data:       map[string][]byte{"kubeconfig": []byte("apiVersion: v1")},
+	}))
+	provider := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: "default"},
+		Spec:       v1beta1.ProviderSpec{Provider: string(custom)},
+	}
+
+	secrets, err := GetProviderConfigSecrets(ctx, k8sClient, provider)
+	assert.Nil(t, err)
+	assert.Nil(t, secrets)
+
+	provider.Spec.ProviderConfig = &v1beta1.ProviderConfig{
+		Template: `provider "vault" {}`,
+		SecretRefs: map[string]types.SecretKeySelector{
+			"vault_token": {SecretReference: types.SecretReference{Name: "vault"}, Key: "token"},
+			"kubeconfig":  {SecretReference: types.SecretReference{Name: "kube", Namespace: "infra"}, Key: "kubeconfig"},
+		},
+	}
+	secrets, err = GetProviderConfigSecrets(ctx, k8sClient, provider)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"TF_VAR_vault_token": "s.abc", "TF_VAR_kubeconfig": "apiVersion: v1"}, secrets)
+
+	provider.Spec.ProviderConfig.SecretRefs["vault_token"] = types.SecretKeySelector{SecretReference: types.SecretReference{Name: "vault"}, Key: "address"}
+	_, err = GetProviderConfigSecrets(ctx, k8sClient, provider)
+	assert.EqualError(t, err, "in the provider vault, the key address not found in the secret vault of the provider config")
+
+	provider.Spec.ProviderConfig.SecretRefs["vault_token"] = types.SecretKeySelector{SecretReference: types.SecretReference{Name: "missing"}, Key: "token"}
+	_, err = GetProviderConfigSecrets(ctx, k8sClient, provider)
+	assert.Contains(t, err.Error(), "failed to get the Secret of the provider config")
+}
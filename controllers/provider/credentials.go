@@ -2,12 +2,17 @@ package provider
 
 import (
 	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -48,6 +53,11 @@ const (
 	ErrCredentialNotRetrieved = "Credentials are not retrieved from referenced Provider"
 )
 
+// credentialsExpiration is the expiration of the temporary credentials of any cloud provider
+type credentialsExpiration struct {
+	Expiration string `yaml:"expiration"`
+}
+
 // AlibabaCloudCredentials are credentials for Alibaba Cloud
 type AlibabaCloudCredentials struct {
 	AccessKeyID     string `yaml:"accessKeyID"`
@@ -55,8 +65,66 @@ type AlibabaCloudCredentials struct {
 	SecurityToken   string `yaml:"securityToken"`
 }
 
+// quotedValuePattern matches the values quoted in the errors of yaml.v2, like "cannot unmarshal !!str `LTAI5t...`",
+// which are parts of the credentials
+var quotedValuePattern = regexp.MustCompile("`[^`]*`")
+
+// scrubSecretError redacts the values of the secret data in the error of parsing it, so the error could be logged and
+// stored in the status
+func scrubSecretError(err error) error {
+	return errors.New(quotedValuePattern.ReplaceAllString(err.Error(), "`******`"))
+}
+
 // GetProviderCredentials gets provider credentials by cloud provider name
 func GetProviderCredentials(ctx context.Context, k8sClient client.Client, provider *v1beta1.Provider, region string) (map[string]string, error) {
+	return GetProviderProfileCredentials(ctx, k8sClient, provider, region, "")
+}
+
+// GetProviderProfileCredentials gets the credentials of a profile of the Provider. A profile is a key of the secret
+// referenced by the Provider, and the key of the secretRef is the default profile which is used if profile is empty.
+func GetProviderProfileCredentials(ctx context.Context, k8sClient client.Client, provider *v1beta1.Provider, region, profile string) (map[string]string, error) {
+	secretData, err := getProfileSecretData(ctx, k8sClient, provider, profile)
+	if err != nil {
+		return nil, err
+	}
+	secretRef := provider.Spec.Credentials.SecretRef
+	if provider.Spec.Credentials.File != nil {
+		return getFileCredentials(provider, secretData, secretRef.Name)
+	}
+	return convertCredentials(provider, secretData, secretRef.Name, secretRef.Namespace, region)
+}
+
+// GetProviderProfileCredentialsExpiration gets the expiration of the temporary credentials, like STS tokens, of a
+// profile of the Provider. It's the optional `expiration` key in the credentials in RFC 3339, and nil is returned if
+// it's not set. The credentials file of spec.Credentials.File has no expiration.
+func GetProviderProfileCredentialsExpiration(ctx context.Context, k8sClient client.Client, provider *v1beta1.Provider, profile string) (*metav1.Time, error) {
+	if provider.Spec.Credentials.File != nil {
+		return nil, nil
+	}
+	secretData, err := getProfileSecretData(ctx, k8sClient, provider, profile)
+	if err != nil {
+		return nil, err
+	}
+	secretData, err = mapCredentialKeys(secretData, provider.Spec.Credentials.KeyMapping)
+	if err != nil {
+		return nil, errors.Wrap(err, errConvertCredentials)
+	}
+	var expiration credentialsExpiration
+	if err := yaml.Unmarshal(secretData, &expiration); err != nil {
+		return nil, errors.Wrap(scrubSecretError(err), errConvertCredentials)
+	}
+	if expiration.Expiration == "" {
+		return nil, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, expiration.Expiration)
+	if err != nil {
+		return nil, errors.Errorf("in the provider %s, the expiration %s of the credentials is not in RFC 3339", provider.Name, expiration.Expiration)
+	}
+	return &metav1.Time{Time: expiresAt}, nil
+}
+
+// getProfileSecretData gets the credentials of a profile of the Provider from the referenced secret
+func getProfileSecretData(ctx context.Context, k8sClient client.Client, provider *v1beta1.Provider, profile string) ([]byte, error) {
 	switch provider.Spec.Credentials.Source {
 	case "Secret":
 		var secret v1.Secret
@@ -68,50 +136,19 @@ func GetProviderCredentials(ctx context.Context, k8sClient client.Client, provid
 			klog.ErrorS(err, errMsg, "Name", name, "Namespace", namespace)
 			return nil, errors.Wrap(err, errMsg)
 		}
+		if profile != "" {
+			secretData, ok := secret.Data[profile]
+			if !ok {
+				return nil, errors.Errorf("in the provider %s, the profile %s not found in the referenced secret %s, available profiles are %s",
+					provider.Name, profile, name, strings.Join(secretKeys(&secret), ", "))
+			}
+			return secretData, nil
+		}
 		secretData, ok := secret.Data[secretRef.Key]
 		if !ok {
 			return nil, errors.Errorf("in the provider %s, the key %s not found in the referenced secret %s", provider.Name, secretRef.Key, name)
 		}
-		switch provider.Spec.Provider {
-		case string(alibaba):
-			var ak AlibabaCloudCredentials
-			if err := yaml.Unmarshal(secret.Data[secretRef.Key], &ak); err != nil {
-				klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
-				return nil, errors.Wrap(err, errConvertCredentials)
-			}
-			if err := checkAlibabaCloudCredentials(region, ak.AccessKeyID, ak.AccessKeySecret, ak.SecurityToken); err != nil {
-				klog.ErrorS(err, errCredentialValid)
-				return nil, errors.Wrap(err, errCredentialValid)
-			}
-			return map[string]string{
-				envAlicloudAcessKey:  ak.AccessKeyID,
-				envAlicloudSecretKey: ak.AccessKeySecret,
-				envAlicloudRegion:    region,
-				envAliCloudStsToken:  ak.SecurityToken,
-			}, nil
-		case string(ucloud):
-			return getUCloudCredentials(secretData, name, namespace)
-		case string(aws):
-			return getAWSCredentials(secretData, name, namespace, region)
-		case string(gcp):
-			return getGCPCredentials(secretData, name, namespace, region)
-		case string(tencent):
-			return getTencentCloudCredentials(secretData, name, namespace, region)
-		case string(azure):
-			return getAzureCredentials(secretData, name, namespace)
-		case string(vsphere):
-			return getVSphereCredentials(secretData, name, namespace)
-		case string(ec):
-			return getECCloudCredentials(secretData, name, namespace)
-		case string(custom):
-			return getCustomCredentials(secretData, name, namespace)
-		case string(baidu):
-			return getBaiduCloudCredentials(secretData, name, namespace, region)
-		default:
-			errMsg := "unsupported provider"
-			klog.InfoS(errMsg, "Provider", provider.Spec.Provider)
-			return nil, errors.New(errMsg)
-		}
+		return secretData, nil
 	default:
 		errMsg := "the credentials type is not supported."
 		err := errors.New(errMsg)
@@ -120,12 +157,131 @@ func GetProviderCredentials(ctx context.Context, k8sClient client.Client, provid
 	}
 }
 
+// GetProviderProfiles gets the profiles of the Provider, which are the keys of the referenced secret
+func GetProviderProfiles(ctx context.Context, k8sClient client.Client, provider *v1beta1.Provider) ([]string, error) {
+	if provider.Spec.Credentials.Source != "Secret" || provider.Spec.Credentials.SecretRef == nil {
+		return nil, nil
+	}
+	var secret v1.Secret
+	secretRef := provider.Spec.Credentials.SecretRef
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: secretRef.Name, Namespace: secretRef.Namespace}, &secret); err != nil {
+		return nil, errors.Wrap(err, "failed to get the Secret from Provider")
+	}
+	return secretKeys(&secret), nil
+}
+
+func secretKeys(secret *v1.Secret) []string {
+	keys := make([]string, 0, len(secret.Data))
+	for k := range secret.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// convertCredentials converts the credentials in the secret to the envs of the cloud provider
+func convertCredentials(provider *v1beta1.Provider, secretData []byte, name, namespace, region string) (map[string]string, error) {
+	secretData, err := mapCredentialKeys(secretData, provider.Spec.Credentials.KeyMapping)
+	if err != nil {
+		klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
+		return nil, errors.Wrap(err, errConvertCredentials)
+	}
+
+	switch provider.Spec.Provider {
+	case string(alibaba):
+		var ak AlibabaCloudCredentials
+		if err := yaml.Unmarshal(secretData, &ak); err != nil {
+			err = scrubSecretError(err)
+			klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
+			return nil, errors.Wrap(err, errConvertCredentials)
+		}
+		return map[string]string{
+			envAlicloudAcessKey:  ak.AccessKeyID,
+			envAlicloudSecretKey: ak.AccessKeySecret,
+			envAlicloudRegion:    region,
+			envAliCloudStsToken:  ak.SecurityToken,
+		}, nil
+	case string(ucloud):
+		return getUCloudCredentials(secretData, name, namespace)
+	case string(aws):
+		return getAWSCredentials(secretData, name, namespace, region)
+	case string(gcp):
+		return getGCPCredentials(secretData, name, namespace, region)
+	case string(tencent):
+		return getTencentCloudCredentials(secretData, name, namespace, region)
+	case string(azure):
+		return getAzureCredentials(secretData, name, namespace)
+	case string(vsphere):
+		return getVSphereCredentials(secretData, name, namespace)
+	case string(ec):
+		return getECCloudCredentials(secretData, name, namespace)
+	case string(custom):
+		return getCustomCredentials(secretData, name, namespace)
+	case string(baidu):
+		return getBaiduCloudCredentials(secretData, name, namespace, region)
+	default:
+		errMsg := "unsupported provider"
+		klog.InfoS(errMsg, "Provider", provider.Spec.Provider)
+		return nil, errors.New(errMsg)
+	}
+}
+
+// mapCredentialKeys renames the keys of the credentials in the secret according to spec.Credentials.KeyMapping
+func mapCredentialKeys(secretData []byte, keyMapping map[string]string) ([]byte, error) {
+	if len(keyMapping) == 0 {
+		return secretData, nil
+	}
+	var credentials map[string]interface{}
+	if err := yaml.Unmarshal(secretData, &credentials); err != nil {
+		return nil, scrubSecretError(err)
+	}
+	mapped := make(map[string]interface{}, len(credentials))
+	for k, v := range credentials {
+		key := k
+		if to, ok := keyMapping[k]; ok {
+			key = to
+		}
+		if _, ok := mapped[key]; ok {
+			return nil, errors.Errorf("more than one key of the credentials is mapped to %s", key)
+		}
+		mapped[key] = v
+	}
+	return yaml.Marshal(mapped)
+}
+
+// credentialsValidators validate the credentials of a cloud provider with a cheap API call, like GetCallerIdentity
+var credentialsValidators = map[CloudProvider]func(credentials map[string]string) error{
+	alibaba: func(credentials map[string]string) error {
+		return checkAlibabaCloudCredentials(credentials[envAlicloudRegion], credentials[envAlicloudAcessKey],
+			credentials[envAlicloudSecretKey], credentials[envAliCloudStsToken])
+	},
+}
+
+// ValidateProviderCredentials validates the credentials retrieved by GetProviderCredentials with the cloud provider.
+// It's skipped if spec.SkipCredentialsValidation is true, or the credentials of the cloud provider can't be validated,
+// like a credentials file.
+func ValidateProviderCredentials(provider *v1beta1.Provider, credentials map[string]string) error {
+	if provider.Spec.SkipCredentialsValidation || provider.Spec.Credentials.File != nil {
+		return nil
+	}
+	validate, ok := credentialsValidators[CloudProvider(provider.Spec.Provider)]
+	if !ok {
+		return nil
+	}
+	if err := validate(credentials); err != nil {
+		klog.ErrorS(err, errCredentialValid, "Provider", provider.Name)
+		return errors.Wrap(err, errCredentialValid)
+	}
+	return nil
+}
+
 // GetProviderFromConfiguration gets provider object from Configuration
 // Returns:
-// 1) (nil, err): hit an issue to find the provider
+// 1) (nil, err): hit an issue to find the provider, or an AccessDeniedError if the Configuration is not allowed to
+// use it
 // 2) (nil, nil): provider not found
 // 3) (provider, nil): provider found
-func GetProviderFromConfiguration(ctx context.Context, k8sClient client.Client, namespace, name string) (*v1beta1.Provider, error) {
+func GetProviderFromConfiguration(ctx context.Context, k8sClient client.Client, configuration metav1.Object, namespace, name string) (*v1beta1.Provider, error) {
 	var provider = &v1beta1.Provider{}
 	if err := k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, provider); err != nil {
 		if kerrors.IsNotFound(err) {
@@ -135,6 +291,9 @@ func GetProviderFromConfiguration(ctx context.Context, k8sClient client.Client,
 		klog.ErrorS(err, errMsg, "Name", name)
 		return nil, errors.Wrap(err, errMsg)
 	}
+	if err := CheckProviderAccess(provider, configuration); err != nil {
+		return nil, err
+	}
 	return provider, nil
 }
 
@@ -2,14 +2,17 @@ package provider
 
 import (
 	"context"
+	"encoding/base64"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/agiledragon/gomonkey/v2"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
 	"github.com/google/go-cmp/cmp"
 	"github.com/jinzhu/copier"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
 	v1 "k8s.io/api/core/v1"
@@ -69,6 +72,62 @@ func TestCheckAlibabaCloudCredentials(t *testing.T) {
 	}
 }
 
+func TestValidateProviderCredentials(t *testing.T) {
+	credentials := map[string]string{
+		envAlicloudAcessKey:  "aaaa",
+		envAlicloudSecretKey: "bbbbb",
+		envAlicloudRegion:    "cn-hangzhou",
+	}
+	patches := ApplyMethod(reflect.TypeOf(&sts.Client{}), "GetCallerIdentity", func(_ *sts.Client, request *sts.GetCallerIdentityRequest) (*sts.GetCallerIdentityResponse, error) {
+		return nil, errors.New("InvalidAccessKeyId.NotFound")
+	})
+	defer patches.Reset()
+
+	testcases := []struct {
+		name     string
+		provider *v1beta1.Provider
+		errMsg   string
+	}{
+		{
+			name: "invalid Alibaba Cloud credentials",
+			provider: &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider: "alibaba",
+				},
+			},
+			errMsg: "Credentials are not valid: Alibaba Cloud credentials are invalid: InvalidAccessKeyId.NotFound",
+		},
+		{
+			name: "skip validating credentials",
+			provider: &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider:                  "alibaba",
+					SkipCredentialsValidation: true,
+				},
+			},
+		},
+		{
+			name: "credentials of the provider are not validated",
+			provider: &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider: "aws",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateProviderCredentials(tc.provider, credentials)
+			if tc.errMsg == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.errMsg)
+		})
+	}
+}
+
 func newFakeClient4CustomProvider() client.Client {
 	objects := []runtime.Object{
 		&v1.Secret{
@@ -707,7 +766,21 @@ func TestGetProviderFromConfiguration(t *testing.T) {
 			Namespace: "a",
 		},
 	}
-	k8sClient2 := fake.NewClientBuilder().WithScheme(s).WithObjects(provider).Build()
+	restricted := &v1beta1.Provider{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "terraform.core.oam.dev/v1beta1",
+			Kind:       "Provider",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restricted",
+			Namespace: "a",
+		},
+		Spec: v1beta1.ProviderSpec{
+			AllowedNamespaces: []string{"b"},
+		},
+	}
+	k8sClient2 := fake.NewClientBuilder().WithScheme(s).WithObjects(provider, restricted).Build()
+	configuration := &metav1.ObjectMeta{Name: "c", Namespace: "c"}
 
 	type args struct {
 		k8sClient client.Client
@@ -754,10 +827,21 @@ func TestGetProviderFromConfiguration(t *testing.T) {
 				provider: provider,
 			},
 		},
+		{
+			name: "provider is not allowed",
+			args: args{
+				k8sClient: k8sClient2,
+				namespace: "a",
+				name:      "restricted",
+			},
+			want: want{
+				errMsg: "Configuration c/c is not allowed to use Provider a/restricted",
+			},
+		},
 	}
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := GetProviderFromConfiguration(ctx, tc.args.k8sClient, tc.args.namespace, tc.args.name)
+			got, err := GetProviderFromConfiguration(ctx, tc.args.k8sClient, configuration, tc.args.namespace, tc.args.name)
 			if err != nil {
 				if !strings.Contains(err.Error(), tc.want.errMsg) {
 					t.Errorf("IsDeletable() error = %v, wantErr %v", err, tc.want.errMsg)
@@ -1254,3 +1338,280 @@ func TestGetProviderCredentials4Custom(t *testing.T) {
 		})
 	}
 }
+
+func TestGetProviderProfileCredentials(t *testing.T) {
+	ctx := context.TODO()
+	k8sClient := fake.NewClientBuilder().Build()
+	defaultCreds, _ := yaml.Marshal(&AWSCredentials{AWSAccessKeyID: "a", AWSSecretAccessKey: "b"})
+	prodCreds, _ := yaml.Marshal(&AWSCredentials{AWSAccessKeyID: "c", AWSSecretAccessKey: "d"})
+	assert.Nil(t, k8sClient.Create(ctx, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"credentials": defaultCreds,
+			"prod":        prodCreds,
+		},
+	}))
+	provider := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws",
+			Namespace: "default",
+		},
+		Spec: v1beta1.ProviderSpec{
+			Provider: string(aws),
+			Credentials: v1beta1.ProviderCredentials{
+				Source: "Secret",
+				SecretRef: &types.SecretKeySelector{
+					SecretReference: types.SecretReference{
+						Name:      "aws",
+						Namespace: "default",
+					},
+					Key: "credentials",
+				},
+			},
+		},
+	}
+
+	profiles, err := GetProviderProfiles(ctx, k8sClient, provider)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"credentials", "prod"}, profiles)
+
+	credentials, err := GetProviderProfileCredentials(ctx, k8sClient, provider, "us-east-1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", credentials[envAWSAccessKeyID])
+
+	credentials, err = GetProviderProfileCredentials(ctx, k8sClient, provider, "us-east-1", "prod")
+	assert.Nil(t, err)
+	assert.Equal(t, "c", credentials[envAWSAccessKeyID])
+	assert.Equal(t, "d", credentials[envAWSSecretAccessKey])
+
+	_, err = GetProviderProfileCredentials(ctx, k8sClient, provider, "us-east-1", "dev")
+	assert.EqualError(t, err, "in the provider aws, the profile dev not found in the referenced secret aws, available profiles are credentials, prod")
+}
+
+func TestGetProviderCredentialsScrubsSecretData(t *testing.T) {
+	ctx := context.TODO()
+	secretValue := "AKIAXXXXSECRETVALUE"
+	k8sClient := fake.NewClientBuilder().WithObjects(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"credentials": []byte(secretValue),
+		},
+	}).Build()
+	provider := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws",
+			Namespace: "default",
+		},
+		Spec: v1beta1.ProviderSpec{
+			Provider: string(aws),
+			Credentials: v1beta1.ProviderCredentials{
+				Source: "Secret",
+				SecretRef: &types.SecretKeySelector{
+					SecretReference: types.SecretReference{
+						Name:      "aws",
+						Namespace: "default",
+					},
+					Key: "credentials",
+				},
+			},
+		},
+	}
+
+	_, err := GetProviderCredentials(ctx, k8sClient, provider, "us-east-1")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), errConvertCredentials)
+	assert.Contains(t, err.Error(), "`******`")
+	assert.NotContains(t, err.Error(), secretValue[:7])
+}
+
+func TestGetProviderCredentialsWithKeyMapping(t *testing.T) {
+	ctx := context.TODO()
+	k8sClient := fake.NewClientBuilder().WithObjects(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"credentials": []byte("accessKey: a\nsecretKey: b\nawsSessionToken: c\n"),
+			"conflict":    []byte("accessKey: a\nawsAccessKeyID: b\n"),
+		},
+	}).Build()
+	provider := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws",
+			Namespace: "default",
+		},
+		Spec: v1beta1.ProviderSpec{
+			Provider: string(aws),
+			Credentials: v1beta1.ProviderCredentials{
+				Source: "Secret",
+				SecretRef: &types.SecretKeySelector{
+					SecretReference: types.SecretReference{
+						Name:      "aws",
+						Namespace: "default",
+					},
+					Key: "credentials",
+				},
+				KeyMapping: map[string]string{
+					"accessKey": "awsAccessKeyID",
+					"secretKey": "awsSecretAccessKey",
+				},
+			},
+		},
+	}
+
+	credentials, err := GetProviderCredentials(ctx, k8sClient, provider, "us-east-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", credentials[envAWSAccessKeyID])
+	assert.Equal(t, "b", credentials[envAWSSecretAccessKey])
+	assert.Equal(t, "c", credentials[envAWSSessionToken])
+
+	_, err = GetProviderProfileCredentials(ctx, k8sClient, provider, "us-east-1", "conflict")
+	assert.Contains(t, err.Error(), "more than one key of the credentials is mapped to awsAccessKeyID")
+
+	provider.Spec.Credentials.KeyMapping = nil
+	credentials, err = GetProviderCredentials(ctx, k8sClient, provider, "us-east-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "", credentials[envAWSAccessKeyID])
+}
+
+func TestGetProviderProfileCredentialsExpiration(t *testing.T) {
+	ctx := context.TODO()
+	k8sClient := fake.NewClientBuilder().WithObjects(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"credentials": []byte("awsAccessKeyID: a\nawsSecretAccessKey: b\n"),
+			"sts":         []byte("awsAccessKeyID: a\nawsSecretAccessKey: b\nawsSessionToken: c\nexpiresAt: \"2026-10-14T08:00:00Z\"\n"),
+			"invalid":     []byte("awsAccessKeyID: a\nexpiration: tomorrow\n"),
+		},
+	}).Build()
+	provider := &v1beta1.Provider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws",
+			Namespace: "default",
+		},
+		Spec: v1beta1.ProviderSpec{
+			Provider: string(aws),
+			Credentials: v1beta1.ProviderCredentials{
+				Source: "Secret",
+				SecretRef: &types.SecretKeySelector{
+					SecretReference: types.SecretReference{
+						Name:      "aws",
+						Namespace: "default",
+					},
+					Key: "credentials",
+				},
+				KeyMapping: map[string]string{
+					"expiresAt": "expiration",
+				},
+			},
+		},
+	}
+
+	expiration, err := GetProviderProfileCredentialsExpiration(ctx, k8sClient, provider, "")
+	assert.Nil(t, err)
+	assert.Nil(t, expiration)
+
+	expiration, err = GetProviderProfileCredentialsExpiration(ctx, k8sClient, provider, "sts")
+	assert.Nil(t, err)
+	assert.Equal(t, "2026-10-14T08:00:00Z", expiration.UTC().Format(time.RFC3339))
+
+	_, err = GetProviderProfileCredentialsExpiration(ctx, k8sClient, provider, "invalid")
+	assert.EqualError(t, err, "in the provider aws, the expiration tomorrow of the credentials is not in RFC 3339")
+}
+
+func TestGetProviderCredentialsFile(t *testing.T) {
+	ctx := context.TODO()
+	key := `{"type": "service_account", "project_id": "p", "private_key": "s3cr3t-key"}`
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "gcp", Namespace: "default"},
+		Data: map[string][]byte{
+			"key.json":   []byte(key + "\n"),
+			"key.base64": []byte(base64.StdEncoding.EncodeToString([]byte(key))),
+			"invalid":    []byte(`{"private_key": "s3cr3t-key"`),
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithObjects(secret).Build()
+	newProvider := func(secretKey, encoding string) *v1beta1.Provider {
+		return &v1beta1.Provider{
+			ObjectMeta: metav1.ObjectMeta{Name: "gcp", Namespace: "default"},
+			Spec: v1beta1.ProviderSpec{
+				Provider: string(gcp),
+				Credentials: v1beta1.ProviderCredentials{
+					Source: "Secret",
+					SecretRef: &types.SecretKeySelector{
+						SecretReference: types.SecretReference{Name: "gcp", Namespace: "default"},
+						Key:             secretKey,
+					},
+					File: &v1beta1.CredentialsFile{Env: "GOOGLE_APPLICATION_CREDENTIALS", Encoding: encoding},
+				},
+			},
+		}
+	}
+
+	testcases := []struct {
+		name     string
+		provider *v1beta1.Provider
+		want     map[string]string
+		errMsg   string
+	}{
+		{
+			name:     "json",
+			provider: newProvider("key.json", ""),
+			want:     map[string]string{CredentialsFileKey: key, "GOOGLE_APPLICATION_CREDENTIALS": CredentialsFilePath},
+		},
+		{
+			name:     "base64",
+			provider: newProvider("key.base64", CredentialsFileEncodingBase64),
+			want:     map[string]string{CredentialsFileKey: key, "GOOGLE_APPLICATION_CREDENTIALS": CredentialsFilePath},
+		},
+		{
+			name:     "not base64",
+			provider: newProvider("key.json", CredentialsFileEncodingBase64),
+			errMsg:   "in the provider gcp, the credentials file in the referenced secret gcp is not base64-encoded",
+		},
+		{
+			name:     "not json",
+			provider: newProvider("invalid", CredentialsFileEncodingJSON),
+			errMsg:   "in the provider gcp, the credentials file in the referenced secret gcp is not a JSON object",
+		},
+		{
+			name: "invalid env",
+			provider: func() *v1beta1.Provider {
+				p := newProvider("key.json", "")
+				p.Spec.Credentials.File.Env = "GOOGLE-CREDENTIALS"
+				return p
+			}(),
+			errMsg: "in the provider gcp, the env GOOGLE-CREDENTIALS of the credentials file should be named by letters, digits and underscores",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := GetProviderCredentials(ctx, k8sClient, tc.provider, "us-central1")
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				assert.NotContains(t, err.Error(), "s3cr3t-key")
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	// the credentials file is neither validated nor has an expiration
+	p := newProvider("key.json", "")
+	p.Spec.Provider = string(alibaba)
+	assert.Nil(t, ValidateProviderCredentials(p, map[string]string{CredentialsFileKey: key}))
+	expiration, err := GetProviderProfileCredentialsExpiration(ctx, k8sClient, p, "")
+	assert.Nil(t, err)
+	assert.Nil(t, expiration)
+}
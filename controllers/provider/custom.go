@@ -12,6 +12,7 @@ type CustomCredentials map[string]string
 func getCustomCredentials(secretData []byte, name, namespace string) (map[string]string, error) {
 	var ck = make(CustomCredentials)
 	if err := yaml.Unmarshal(secretData, &ck); err != nil {
+		err = scrubSecretError(err)
 		klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
 		return nil, errors.Wrap(err, errConvertCredentials)
 	}
@@ -18,6 +18,7 @@ type ECCredentials struct {
 func getECCloudCredentials(secretData []byte, name, namespace string) (map[string]string, error) {
 	var ak ECCredentials
 	if err := yaml.Unmarshal(secretData, &ak); err != nil {
+		err = scrubSecretError(err)
 		klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
 		return nil, errors.Wrap(err, errConvertCredentials)
 	}
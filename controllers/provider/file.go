@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+)
+
+const (
+	// CredentialsFileKey is the key of the content of the credentials file in the credentials. It's written to
+	// CredentialsFilePath in the Terraform jobs instead of an environment variable.
+	CredentialsFileKey = "credentials-file"
+	// CredentialsFilePath is the path of the credentials file in the Terraform jobs
+	CredentialsFilePath = "/opt/tf-credentials/credentials"
+
+	// CredentialsFileEncodingJSON is a credentials file in JSON
+	CredentialsFileEncodingJSON = "json"
+	// CredentialsFileEncodingBase64 is a credentials file in JSON, which is base64-encoded
+	CredentialsFileEncodingBase64 = "base64"
+)
+
+// getFileCredentials gets the credentials file of spec.Credentials.File from the secret data. The content is checked
+// to be a JSON object, and the error doesn't contain the content as it's the credentials.
+func getFileCredentials(provider *v1beta1.Provider, secretData []byte, name string) (map[string]string, error) {
+	file := provider.Spec.Credentials.File
+	if !providerConfigVariablePattern.MatchString(file.Env) {
+		return nil, errors.Errorf("in the provider %s, the env %s of the credentials file should be named by letters, digits and underscores",
+			provider.Name, file.Env)
+	}
+	content := bytes.TrimSpace(secretData)
+	switch file.Encoding {
+	case "", CredentialsFileEncodingJSON:
+	case CredentialsFileEncodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(string(content))
+		if err != nil {
+			return nil, errors.Errorf("in the provider %s, the credentials file in the referenced secret %s is not base64-encoded", provider.Name, name)
+		}
+		content = bytes.TrimSpace(decoded)
+	default:
+		return nil, errors.Errorf("in the provider %s, the encoding %s of the credentials file should be %s or %s",
+			provider.Name, file.Encoding, CredentialsFileEncodingJSON, CredentialsFileEncodingBase64)
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(content, &object); err != nil {
+		return nil, errors.Errorf("in the provider %s, the credentials file in the referenced secret %s is not a JSON object", provider.Name, name)
+	}
+	return map[string]string{
+		CredentialsFileKey: string(content),
+		file.Env:           CredentialsFilePath,
+	}, nil
+}
@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+)
+
+// awsMaxRetriesLimit is the maximum of `max_retries` of the AWS provider. The retries back off exponentially, so a
+// request would hang for hours beyond it.
+const awsMaxRetriesLimit = 100
+
+// awsRetryModes are the values of `retry_mode` supported by the AWS provider
+var awsRetryModes = map[string]bool{"standard": true, "adaptive": true}
+
+// SupportRetry checks whether the Terraform provider of a cloud provider supports the retry settings
+func SupportRetry(providerType string) bool {
+	return providerType == string(aws)
+}
+
+// ValidRetry validates the retry settings of a Provider against the ranges supported by the cloud provider
+func ValidRetry(provider *v1beta1.Provider) error {
+	retry := provider.Spec.Retry
+	if retry == nil || retry.MaxRetries == nil && retry.RetryMode == "" {
+		return nil
+	}
+	if !SupportRetry(provider.Spec.Provider) {
+		return fmt.Errorf("retry settings are not supported by provider %s", provider.Spec.Provider)
+	}
+	if retry.MaxRetries != nil && (*retry.MaxRetries < 0 || *retry.MaxRetries > awsMaxRetriesLimit) {
+		return fmt.Errorf("the max retries %d should be between 0 and %d", *retry.MaxRetries, awsMaxRetriesLimit)
+	}
+	if retry.RetryMode != "" && !awsRetryModes[retry.RetryMode] {
+		return fmt.Errorf("the retry mode %s is not supported, it should be standard or adaptive", retry.RetryMode)
+	}
+	return nil
+}
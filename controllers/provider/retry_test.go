@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+)
+
+func TestValidRetry(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	testcases := map[string]struct {
+		providerType string
+		retry        *v1beta1.ProviderRetry
+		errMsg       string
+	}{
+		"no retry settings": {
+			providerType: "gcp",
+		},
+		"empty retry settings": {
+			providerType: "gcp",
+			retry:        &v1beta1.ProviderRetry{},
+		},
+		"valid retry settings": {
+			providerType: "aws",
+			retry:        &v1beta1.ProviderRetry{MaxRetries: intPtr(0), RetryMode: "adaptive"},
+		},
+		"not supported by the provider": {
+			providerType: "alibaba",
+			retry:        &v1beta1.ProviderRetry{MaxRetries: intPtr(5)},
+			errMsg:       "retry settings are not supported by provider alibaba",
+		},
+		"too many retries": {
+			providerType: "aws",
+			retry:        &v1beta1.ProviderRetry{MaxRetries: intPtr(101)},
+			errMsg:       "the max retries 101 should be between 0 and 100",
+		},
+		"negative retries": {
+			providerType: "aws",
+			retry:        &v1beta1.ProviderRetry{MaxRetries: intPtr(-1)},
+			errMsg:       "the max retries -1 should be between 0 and 100",
+		},
+		"invalid retry mode": {
+			providerType: "aws",
+			retry:        &v1beta1.ProviderRetry{RetryMode: "legacy"},
+			errMsg:       "the retry mode legacy is not supported, it should be standard or adaptive",
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			provider := &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider: tc.providerType,
+					Retry:    tc.retry,
+				},
+			}
+			err := ValidRetry(provider)
+			if tc.errMsg == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.errMsg)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+)
+
+const (
+	awsTagKeyMaxLength   = 128
+	awsTagValueMaxLength = 256
+	awsTagReservedPrefix = "aws:"
+)
+
+// awsTagPattern are the characters allowed in AWS tag keys and values
+var awsTagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// SupportDefaultTags checks whether the Terraform provider of a cloud provider supports default tags
+func SupportDefaultTags(providerType string) bool {
+	return providerType == string(aws)
+}
+
+// ValidDefaultTags validates the default tags of a Provider against the limits of the cloud provider
+func ValidDefaultTags(provider *v1beta1.Provider) error {
+	tags := provider.Spec.DefaultTags
+	if len(tags) == 0 {
+		return nil
+	}
+	if !SupportDefaultTags(provider.Spec.Provider) {
+		return fmt.Errorf("default tags are not supported by provider %s", provider.Spec.Provider)
+	}
+	for k, v := range tags {
+		if k == "" || len(k) > awsTagKeyMaxLength {
+			return fmt.Errorf("the length of tag key %q should be between 1 and %d", k, awsTagKeyMaxLength)
+		}
+		if len(v) > awsTagValueMaxLength {
+			return fmt.Errorf("the length of the value of tag %s should not exceed %d", k, awsTagValueMaxLength)
+		}
+		if strings.HasPrefix(strings.ToLower(k), awsTagReservedPrefix) {
+			return fmt.Errorf("tag key %s should not start with the reserved prefix %s", k, awsTagReservedPrefix)
+		}
+		if !awsTagPattern.MatchString(k) || !awsTagPattern.MatchString(v) {
+			return fmt.Errorf("tag %s contains characters which are not allowed, only letters, numbers, spaces and _.:/=+-@ are allowed", k)
+		}
+	}
+	return nil
+}
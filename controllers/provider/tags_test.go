@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+)
+
+func TestValidDefaultTags(t *testing.T) {
+	testcases := map[string]struct {
+		provider string
+		tags     map[string]string
+		errMsg   string
+	}{
+		"no tags": {
+			provider: "alibaba",
+		},
+		"valid AWS tags": {
+			provider: "aws",
+			tags:     map[string]string{"team": "infra", "cost-center": "a/b:c=d+e@f"},
+		},
+		"unsupported provider": {
+			provider: "alibaba",
+			tags:     map[string]string{"team": "infra"},
+			errMsg:   "default tags are not supported by provider alibaba",
+		},
+		"empty key": {
+			provider: "aws",
+			tags:     map[string]string{"": "infra"},
+			errMsg:   "should be between 1 and 128",
+		},
+		"key too long": {
+			provider: "aws",
+			tags:     map[string]string{strings.Repeat("k", 129): "infra"},
+			errMsg:   "should be between 1 and 128",
+		},
+		"value too long": {
+			provider: "aws",
+			tags:     map[string]string{"team": strings.Repeat("v", 257)},
+			errMsg:   "should not exceed 256",
+		},
+		"reserved prefix": {
+			provider: "aws",
+			tags:     map[string]string{"AWS:team": "infra"},
+			errMsg:   "reserved prefix",
+		},
+		"invalid characters": {
+			provider: "aws",
+			tags:     map[string]string{"team": "${var.secret}"},
+			errMsg:   "contains characters which are not allowed",
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			provider := &v1beta1.Provider{
+				Spec: v1beta1.ProviderSpec{
+					Provider:    tc.provider,
+					DefaultTags: tc.tags,
+				},
+			}
+			err := ValidDefaultTags(provider)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Errorf("ValidDefaultTags() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Errorf("ValidDefaultTags() error = %v, wantErr %v", err, tc.errMsg)
+			}
+		})
+	}
+}
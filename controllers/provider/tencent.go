@@ -21,6 +21,7 @@ type TencentCloudCredentials struct {
 func getTencentCloudCredentials(secretData []byte, name, namespace, region string) (map[string]string, error) {
 	var ak TencentCloudCredentials
 	if err := yaml.Unmarshal(secretData, &ak); err != nil {
+		err = scrubSecretError(err)
 		klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
 		return nil, errors.Wrap(err, errConvertCredentials)
 	}
@@ -24,6 +24,7 @@ type UCloudCredentials struct {
 func getUCloudCredentials(secretData []byte, name, namespace string) (map[string]string, error) {
 	var ak UCloudCredentials
 	if err := yaml.Unmarshal(secretData, &ak); err != nil {
+		err = scrubSecretError(err)
 		klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
 		return nil, errors.Wrap(err, errConvertCredentials)
 	}
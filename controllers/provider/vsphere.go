@@ -24,6 +24,7 @@ type VSphereCredentials struct {
 func getVSphereCredentials(secretData []byte, name, namespace string) (map[string]string, error) {
 	var cred VSphereCredentials
 	if err := yaml.Unmarshal(secretData, &cred); err != nil {
+		err = scrubSecretError(err)
 		klog.ErrorS(err, errConvertCredentials, "Name", name, "Namespace", namespace)
 		return nil, errors.Wrap(err, errConvertCredentials)
 	}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+)
+
+// ProviderChecksumAllowlistEnv is the env of the ConfigMap, like `vela-system/provider-checksums`, which lists the
+// SHA256 checksums of the provider plugins allowed to run. The plugins downloaded by `terraform init` are verified
+// against it in every Terraform job, regardless of the lock files of the Configurations. No plugin is verified if the
+// env is not set.
+const ProviderChecksumAllowlistEnv = "TERRAFORM_PROVIDER_CHECKSUM_ALLOWLIST"
+
+// providerChecksumAllowlistFileName is the file of the allowlist in the input Terraform configuration
+const providerChecksumAllowlistFileName = "provider-checksum-allowlist"
+
+// providerChecksumPattern is a SHA256 checksum, like the first field of the output of `sha256sum`
+var providerChecksumPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// GetProviderChecksumAllowlistRef gets the namespaced name of the ConfigMap of ProviderChecksumAllowlistEnv, it's nil
+// if the env is not set
+func GetProviderChecksumAllowlistRef() (*apitypes.NamespacedName, error) {
+	value := strings.TrimSpace(os.Getenv(ProviderChecksumAllowlistEnv))
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("%s should be like `namespace/name`, but got %s", ProviderChecksumAllowlistEnv, value)
+	}
+	return &apitypes.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+// getProviderChecksumAllowlist gets the checksums of the allowlist ConfigMap, one per line. Every value of the
+// ConfigMap lists the checksums in the format of `sha256sum`, and the empty lines and the comments starting with `#`
+// are skipped.
+func (meta *TFConfigurationMeta) getProviderChecksumAllowlist(ctx context.Context, k8sClient client.Client, ref apitypes.NamespacedName) (string, error) {
+	var cm v1.ConfigMap
+	if err := k8sClient.Get(ctx, ref, &cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return "", fmt.Errorf("the provider checksum allowlist ConfigMap %s is not found", ref)
+		}
+		return "", errors.Wrap(err, "failed to get the provider checksum allowlist ConfigMap")
+	}
+	var checksums []string
+	seen := map[string]bool{}
+	for key, value := range cm.Data {
+		for _, line := range strings.Split(value, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			checksum := strings.ToLower(fields[0])
+			if !providerChecksumPattern.MatchString(checksum) {
+				return "", fmt.Errorf("%s in %s of the provider checksum allowlist ConfigMap %s is not a SHA256 checksum", fields[0], key, ref)
+			}
+			if !seen[checksum] {
+				seen[checksum] = true
+				checksums = append(checksums, checksum)
+			}
+		}
+	}
+	if len(checksums) == 0 {
+		return "", fmt.Errorf("the provider checksum allowlist ConfigMap %s has no checksums", ref)
+	}
+	// sorted, so the input configuration doesn't change along with the order of the keys
+	sort.Strings(checksums)
+	return strings.Join(checksums, "\n") + "\n", nil
+}
+
+// assembleProviderChecksumCommand verifies the provider plugins downloaded by `terraform init` against the allowlist,
+// which is read from the input configuration. The plugins linked from the plugin cache are verified too.
+func (meta *TFConfigurationMeta) assembleProviderChecksumCommand() string {
+	if meta.ProviderChecksumAllowlist == "" {
+		return ""
+	}
+	message := fmt.Sprintf(types.MessageProviderChecksumMismatch, "$sum", "${f#*/providers/}", meta.ProviderChecksumAllowlistRef)
+	return fmt.Sprintf(` && for f in $(find -L %s -type f -name 'terraform-provider-*' 2>/dev/null); do sum=$(sha256sum $f | cut -d' ' -f1); grep -qxF "$sum" %s || { echo "%s%s"; exit 1; }; done`,
+		filepath.Join(WorkingVolumeMountPath, ".terraform", "providers"),
+		filepath.Join(InputTFConfigurationVolumeMountPath, providerChecksumAllowlistFileName),
+		types.ProviderChecksumMismatchLogPrefix, message)
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	checksumA = "0f3b1e4c7d8a9b2c5e6f1a0b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d"
+	checksumB = "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+)
+
+func TestGetProviderChecksumAllowlistRef(t *testing.T) {
+	testcases := []struct {
+		name   string
+		value  string
+		ref    *apitypes.NamespacedName
+		errMsg string
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "namespaced name",
+			value: "vela-system/provider-checksums",
+			ref:   &apitypes.NamespacedName{Namespace: "vela-system", Name: "provider-checksums"},
+		},
+		{
+			name:   "name only",
+			value:  "provider-checksums",
+			errMsg: "TERRAFORM_PROVIDER_CHECKSUM_ALLOWLIST should be like `namespace/name`, but got provider-checksums",
+		},
+		{
+			name:   "empty namespace",
+			value:  "/provider-checksums",
+			errMsg: "TERRAFORM_PROVIDER_CHECKSUM_ALLOWLIST should be like `namespace/name`, but got /provider-checksums",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(ProviderChecksumAllowlistEnv, tc.value)
+			ref, err := GetProviderChecksumAllowlistRef()
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.ref, ref)
+		})
+	}
+}
+
+func TestGetProviderChecksumAllowlist(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	ref := apitypes.NamespacedName{Namespace: "vela-system", Name: "provider-checksums"}
+
+	newConfigMap := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: ref.Name, Namespace: ref.Namespace}, Data: data}
+	}
+	testcases := []struct {
+		name      string
+		objects   []client.Object
+		allowlist string
+		errMsg    string
+	}{
+		{
+			name: "sha256sum format",
+			objects: []client.Object{newConfigMap(map[string]string{
+				"aws":        "# hashicorp/aws 4.0.0\n" + checksumB + "  terraform-provider-aws_v4.0.0_x5\n\n",
+				"alicloud":   strings.ToUpper(checksumA) + "\n",
+				"duplicated": checksumB,
+			})},
+			allowlist: checksumA + "\n" + checksumB + "\n",
+		},
+		{
+			name:   "not found",
+			errMsg: "the provider checksum allowlist ConfigMap vela-system/provider-checksums is not found",
+		},
+		{
+			name:    "invalid checksum",
+			objects: []client.Object{newConfigMap(map[string]string{"aws": "abc terraform-provider-aws"})},
+			errMsg:  "abc in aws of the provider checksum allowlist ConfigMap vela-system/provider-checksums is not a SHA256 checksum",
+		},
+		{
+			name:    "no checksums",
+			objects: []client.Object{newConfigMap(map[string]string{"aws": "# nothing\n"})},
+			errMsg:  "the provider checksum allowlist ConfigMap vela-system/provider-checksums has no checksums",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(tc.objects...).Build()
+			meta := &TFConfigurationMeta{Name: "abc", Namespace: "default"}
+			allowlist, err := meta.getProviderChecksumAllowlist(ctx, k8sClient, ref)
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.allowlist, allowlist)
+		})
+	}
+}
+
+func TestAssembleProviderChecksum(t *testing.T) {
+	meta := &TFConfigurationMeta{Name: "a", Namespace: "e", TerraformImage: "f"}
+	assert.Equal(t, "", meta.assembleProviderChecksumCommand())
+	assert.NotContains(t, meta.prepareTFInputConfigurationData(), providerChecksumAllowlistFileName)
+
+	meta.ProviderChecksumAllowlist = checksumA + "\n"
+	meta.ProviderChecksumAllowlistRef = "vela-system/provider-checksums"
+	assert.Equal(t, checksumA+"\n", meta.prepareTFInputConfigurationData()[providerChecksumAllowlistFileName])
+
+	job := meta.assembleTerraformJob(TerraformApply)
+	initContainers := job.Spec.Template.Spec.InitContainers
+	initContainer := initContainers[len(initContainers)-1]
+	assert.Equal(t, terraformInitContainerName, initContainer.Name)
+	assert.Equal(t, `terraform init && for f in $(find -L /data/.terraform/providers -type f -name 'terraform-provider-*' 2>/dev/null); do sum=$(sha256sum $f | cut -d' ' -f1); grep -qxF "$sum" /opt/tf-configuration/provider-checksum-allowlist || { echo "ProviderChecksumMismatch: The checksum $sum of the provider plugin ${f#*/providers/} is not in the allowlist vela-system/provider-checksums"; exit 1; }; done`,
+		initContainer.Command[2])
+}
@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -30,12 +31,25 @@ import (
 
 	"github.com/oam-dev/terraform-controller/api/types"
 	terraformv1beta1 "github.com/oam-dev/terraform-controller/api/v1beta1"
+	tfcfg "github.com/oam-dev/terraform-controller/controllers/configuration"
 	providercred "github.com/oam-dev/terraform-controller/controllers/provider"
 )
 
 const (
 	errGetCredentials = "failed to get credentials from the cloud provider"
 	errSettingStatus  = "failed to set status"
+	// errInvalidDefaultTags means the default tags of the Provider are not valid
+	errInvalidDefaultTags = "the default tags are not valid"
+	// errInvalidRetry means the retry settings of the Provider are not valid
+	errInvalidRetry = "the retry settings are not valid"
+	// errInvalidProviderConfig means the verbatim provider config of the Provider is not valid
+	errInvalidProviderConfig = "the provider config is not valid"
+	// errCrossNamespace means the Provider references a secret out of the namespace which the controller is scoped to
+	errCrossNamespace = "the credentials are not in the watch namespace"
+	// errInvalidCredentials means the credentials of the Provider are rejected by the cloud provider
+	errInvalidCredentials = "the credentials are not valid"
+	// errCredentialsExpired means the temporary credentials of the Provider are expired
+	errCredentialsExpired = "the credentials expired at %s"
 )
 
 // ProviderReconciler reconciles a Provider object
@@ -61,28 +75,77 @@ func (r *ProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, err
 	}
 
-	if _, err := providercred.GetProviderCredentials(ctx, r.Client, &provider, provider.Spec.Region); err != nil {
-		provider.Status.State = types.ProviderIsNotReady
-		provider.Status.Message = fmt.Sprintf("%s: %s", errGetCredentials, err.Error())
-		klog.ErrorS(err, errGetCredentials, "Provider", req.NamespacedName)
-		if updateErr := r.Status().Update(ctx, &provider); updateErr != nil {
-			klog.ErrorS(updateErr, errSettingStatus, "Provider", req.NamespacedName)
-			return ctrl.Result{}, errors.Wrap(updateErr, errSettingStatus)
-		}
-		return ctrl.Result{}, errors.Wrap(err, errGetCredentials)
+	if err := providercred.ValidDefaultTags(&provider); err != nil {
+		return ctrl.Result{}, r.setNotReady(ctx, &provider, errInvalidDefaultTags, err)
+	}
+
+	if err := providercred.ValidRetry(&provider); err != nil {
+		return ctrl.Result{}, r.setNotReady(ctx, &provider, errInvalidRetry, err)
+	}
+
+	if err := providercred.ValidProviderConfig(&provider); err != nil {
+		return ctrl.Result{}, r.setNotReady(ctx, &provider, errInvalidProviderConfig, err)
+	}
+
+	if err := tfcfg.ValidProviderNamespaceScope(&provider); err != nil {
+		return ctrl.Result{}, r.setNotReady(ctx, &provider, errCrossNamespace, err)
+	}
+
+	credentials, err := providercred.GetProviderCredentials(ctx, r.Client, &provider, provider.Spec.Region)
+	if err != nil {
+		return ctrl.Result{}, r.setNotReady(ctx, &provider, errGetCredentials, err)
+	}
+
+	if err := providercred.ValidateProviderCredentials(&provider, credentials); err != nil {
+		return ctrl.Result{}, r.setNotReady(ctx, &provider, errInvalidCredentials, err)
+	}
+
+	expiration, err := providercred.GetProviderProfileCredentialsExpiration(ctx, r.Client, &provider, "")
+	if err != nil {
+		return ctrl.Result{}, r.setNotReady(ctx, &provider, errGetCredentials, err)
 	}
 
 	provider.Status = terraformv1beta1.ProviderStatus{
-		State: types.ProviderIsReady,
+		State:                 types.ProviderIsReady,
+		CredentialsExpiration: expiration,
+	}
+	if expiration != nil && !expiration.After(time.Now()) {
+		provider.Status.State = types.ProviderIsNotReady
+		provider.Status.Message = fmt.Sprintf(errCredentialsExpired, expiration.Format(time.RFC3339))
 	}
 	if updateErr := r.Status().Update(ctx, &provider); updateErr != nil {
 		klog.ErrorS(updateErr, errSettingStatus, "Provider", req.NamespacedName)
 		return ctrl.Result{}, errors.Wrap(updateErr, errSettingStatus)
 	}
 
+	// the refreshed credentials don't trigger a reconcile of the Provider, so it's checked again when they expire
+	if expiration != nil {
+		return ctrl.Result{RequeueAfter: credentialsExpirationRequeue(expiration.Time)}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
+// setNotReady records the error in the status of the Provider, and returns the error wrapped by the reason
+func (r *ProviderReconciler) setNotReady(ctx context.Context, provider *terraformv1beta1.Provider, reason string, err error) error {
+	provider.Status.State = types.ProviderIsNotReady
+	provider.Status.Message = fmt.Sprintf("%s: %s", reason, err.Error())
+	klog.ErrorS(err, reason, "Provider", client.ObjectKeyFromObject(provider))
+	if updateErr := r.Status().Update(ctx, provider); updateErr != nil {
+		klog.ErrorS(updateErr, errSettingStatus, "Provider", client.ObjectKeyFromObject(provider))
+		return errors.Wrap(updateErr, errSettingStatus)
+	}
+	return errors.Wrap(err, reason)
+}
+
+// credentialsExpirationRequeue is the time to reconcile the Provider again, which is when the credentials expire, or a
+// minute later if they are already expired
+func credentialsExpirationRequeue(expiration time.Time) time.Duration {
+	if d := time.Until(expiration); d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
 // SetupWithManager setups with a manager
 func (r *ProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
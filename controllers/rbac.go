@@ -2,7 +2,10 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"reflect"
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
@@ -36,7 +39,7 @@ func createTerraformExecutorClusterRole(ctx context.Context, k8sClient client.Cl
 	}
 	if err := k8sClient.Get(ctx, client.ObjectKey{Name: clusterRoleName}, &rbacv1.ClusterRole{}); err != nil {
 		if kerrors.IsNotFound(err) {
-			if err := k8sClient.Create(ctx, &clusterRole); err != nil {
+			if err := k8sClient.Create(ctx, &clusterRole); err != nil && !kerrors.IsAlreadyExists(err) {
 				return errors.Wrap(err, "failed to create ClusterRole for Terraform executor")
 			}
 		}
@@ -44,6 +47,8 @@ func createTerraformExecutorClusterRole(ctx context.Context, k8sClient client.Cl
 	return nil
 }
 
+// createTerraformExecutorClusterRoleBinding binds the ClusterRole to the ServiceAccount created by the controller. The
+// ServiceAccount set by users is never bound to it, see createTerraformExecutorRole.
 func createTerraformExecutorClusterRoleBinding(ctx context.Context, k8sClient client.Client, namespace, clusterRoleName, serviceAccountName string) error {
 	var crbName = fmt.Sprintf("%s-tf-executor-clusterrole-binding", namespace)
 	var clusterRoleBinding = rbacv1.ClusterRoleBinding{
@@ -70,7 +75,7 @@ func createTerraformExecutorClusterRoleBinding(ctx context.Context, k8sClient cl
 	}
 	if err := k8sClient.Get(ctx, client.ObjectKey{Name: crbName}, &rbacv1.ClusterRoleBinding{}); err != nil {
 		if kerrors.IsNotFound(err) {
-			if err := k8sClient.Create(ctx, &clusterRoleBinding); err != nil {
+			if err := k8sClient.Create(ctx, &clusterRoleBinding); err != nil && !kerrors.IsAlreadyExists(err) {
 				return errors.Wrap(err, "failed to create ClusterRoleBinding for Terraform executor")
 			}
 		}
@@ -91,10 +96,119 @@ func createTerraformExecutorServiceAccount(ctx context.Context, k8sClient client
 	}
 	if err := k8sClient.Get(ctx, client.ObjectKey{Name: serviceAccountName, Namespace: namespace}, &v1.ServiceAccount{}); err != nil {
 		if kerrors.IsNotFound(err) {
-			if err := k8sClient.Create(ctx, &serviceAccount); err != nil {
+			if err := k8sClient.Create(ctx, &serviceAccount); err != nil && !kerrors.IsAlreadyExists(err) {
 				return errors.Wrap(err, "failed to create ServiceAccount for Terraform executor")
 			}
 		}
 	}
 	return nil
 }
+
+// executorRoleName is the name of the Role and the RoleBinding of the Configuration in the backend namespace. It's
+// hashed from the namespace and the name of the Configuration, so two Configurations never share one.
+func executorRoleName(namespace, name string) string {
+	sum := sha256.Sum256([]byte(namespace + "/" + name))
+	return "tf-executor-" + hex.EncodeToString(sum[:])[:16]
+}
+
+// createTerraformExecutorRole grants the ServiceAccount set by users the access to the backend secret of the
+// Configuration and its lock only, by a Role and a RoleBinding in the backend namespace. The ServiceAccount may be
+// shared by the other pods, so it's not bound to the ClusterRole, which grants the access to every secret.
+func createTerraformExecutorRole(ctx context.Context, k8sClient client.Client, meta *TFConfigurationMeta) error {
+	name := executorRoleName(meta.Namespace, meta.Name)
+	role := rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: meta.TerraformBackendNamespace},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{meta.BackendSecretName},
+				Verbs:         []string{"get", "update", "delete"},
+			},
+			{
+				// the name of a created object isn't known to the authorization
+				APIGroups: []string{""},
+				Resources: []string{"secrets"},
+				Verbs:     []string{"create"},
+			},
+			{
+				APIGroups:     []string{"coordination.k8s.io"},
+				Resources:     []string{"leases"},
+				ResourceNames: []string{"lock-" + meta.BackendSecretName},
+				Verbs:         []string{"get", "update", "delete"},
+			},
+			{
+				APIGroups: []string{"coordination.k8s.io"},
+				Resources: []string{"leases"},
+				Verbs:     []string{"create"},
+			},
+		},
+	}
+	var existingRole rbacv1.Role
+	err := k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: role.Namespace}, &existingRole)
+	switch {
+	case kerrors.IsNotFound(err):
+		if err := k8sClient.Create(ctx, &role); err != nil && !kerrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, "failed to create Role for Terraform executor")
+		}
+	case err != nil:
+		return errors.Wrap(err, "failed to get Role for Terraform executor")
+	case !reflect.DeepEqual(existingRole.Rules, role.Rules):
+		// the backend secret changes with spec.Backend
+		existingRole.Rules = role.Rules
+		if err := k8sClient.Update(ctx, &existingRole); err != nil {
+			return errors.Wrap(err, "failed to update Role for Terraform executor")
+		}
+	}
+
+	roleBinding := rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: meta.TerraformBackendNamespace},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     name,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      meta.executorServiceAccount(),
+				Namespace: meta.Namespace,
+			},
+		},
+	}
+	var existingRoleBinding rbacv1.RoleBinding
+	err = k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: roleBinding.Namespace}, &existingRoleBinding)
+	switch {
+	case kerrors.IsNotFound(err):
+		if err := k8sClient.Create(ctx, &roleBinding); err != nil && !kerrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, "failed to create RoleBinding for Terraform executor")
+		}
+	case err != nil:
+		return errors.Wrap(err, "failed to get RoleBinding for Terraform executor")
+	case !reflect.DeepEqual(existingRoleBinding.Subjects, roleBinding.Subjects):
+		// spec.ServiceAccountName is changed
+		existingRoleBinding.Subjects = roleBinding.Subjects
+		if err := k8sClient.Update(ctx, &existingRoleBinding); err != nil {
+			return errors.Wrap(err, "failed to update RoleBinding for Terraform executor")
+		}
+	}
+	return nil
+}
+
+// deleteTerraformExecutorRole deletes the Role and the RoleBinding created by createTerraformExecutorRole
+func deleteTerraformExecutorRole(ctx context.Context, k8sClient client.Client, meta *TFConfigurationMeta) error {
+	key := client.ObjectKey{Name: executorRoleName(meta.Namespace, meta.Name), Namespace: meta.TerraformBackendNamespace}
+	var roleBinding rbacv1.RoleBinding
+	if err := k8sClient.Get(ctx, key, &roleBinding); err == nil {
+		if err := k8sClient.Delete(ctx, &roleBinding); err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to delete RoleBinding for Terraform executor")
+		}
+	}
+	var role rbacv1.Role
+	if err := k8sClient.Get(ctx, key, &role); err == nil {
+		if err := k8sClient.Delete(ctx, &role); err != nil && !kerrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to delete Role for Terraform executor")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tfcfg "github.com/oam-dev/terraform-controller/controllers/configuration"
+)
+
+// ReadinessChecker checks whether the controller can reach the Kubernetes API server, which is also where the
+// Kubernetes backend stores Terraform state. The result is cached for Interval, so frequent probes don't put load on
+// the API server.
+type ReadinessChecker struct {
+	Reader   client.Reader
+	Interval time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	lastErr   error
+}
+
+// Check gets the namespace of the Kubernetes backend, it implements healthz.Checker
+func (c *ReadinessChecker) Check(req *http.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.Interval {
+		return c.lastErr
+	}
+
+	backendNamespace := tfcfg.GetBackendNamespace()
+	var ns v1.Namespace
+	c.lastErr = nil
+	if err := c.Reader.Get(req.Context(), types.NamespacedName{Name: backendNamespace}, &ns); err != nil {
+		c.lastErr = errors.Wrapf(err, "failed to get the namespace %s of the Kubernetes backend", backendNamespace)
+	}
+	c.checkedAt = time.Now()
+	return c.lastErr
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReadinessChecker(t *testing.T) {
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	req, _ := http.NewRequest(http.MethodGet, "/readyz", nil)
+	t.Setenv("TERRAFORM_BACKEND_NAMESPACE", "")
+
+	checker := &ReadinessChecker{
+		Reader:   fake.NewClientBuilder().WithScheme(s).Build(),
+		Interval: time.Hour,
+	}
+	err := checker.Check(req)
+	assert.Contains(t, err.Error(), "failed to get the namespace vela-system of the Kubernetes backend")
+
+	// the result is cached within the interval
+	checker.Reader = fake.NewClientBuilder().WithScheme(s).WithObjects(&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "vela-system"}}).Build()
+	assert.NotNil(t, checker.Check(req))
+
+	checker.Interval = 0
+	assert.Nil(t, checker.Check(req))
+}
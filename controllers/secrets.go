@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	tfcfg "github.com/oam-dev/terraform-controller/controllers/configuration"
+	"github.com/oam-dev/terraform-controller/controllers/provider"
+)
+
+// A SecretRole is what a Secret referenced by a Configuration is used for
+type SecretRole string
+
+const (
+	// SecretRoleProviderCredentials is the Secret of the credentials of the Provider
+	SecretRoleProviderCredentials SecretRole = "ProviderCredentials"
+	// SecretRoleTerraformState is the Secret of the kubernetes backend which stores the Terraform state
+	SecretRoleTerraformState SecretRole = "TerraformState"
+	// SecretRoleVariables is the Secret which stores the variables, including the credentials, of the Terraform jobs
+	SecretRoleVariables SecretRole = "Variables"
+	// SecretRoleGitCredentials is the Secret of the credentials to clone the git repo
+	SecretRoleGitCredentials SecretRole = "GitCredentials"
+	// SecretRoleConnection is the Secret which the outputs are written to
+	SecretRoleConnection SecretRole = "Connection"
+	// SecretRoleBookkeeping is the Secret which stores the bookkeeping of the Configuration
+	SecretRoleBookkeeping SecretRole = "Bookkeeping"
+)
+
+// ReferencedSecret is a Secret which a Configuration reads or writes
+type ReferencedSecret struct {
+	Name      string
+	Namespace string
+	Role      SecretRole
+}
+
+// GetReferencedSecrets gets all the Secrets which the Configuration reads or writes, like for auditing the RBAC. The
+// Secrets are not required to exist, and none of them is changed.
+func GetReferencedSecrets(ctx context.Context, k8sClient client.Client, configuration *v1beta2.Configuration) ([]ReferencedSecret, error) {
+	var secrets []ReferencedSecret
+
+	providerRef, err := tfcfg.ResolveProviderReference(ctx, k8sClient, *configuration)
+	if err != nil {
+		return nil, err
+	}
+	// the credentials of a Provider which the Configuration is not allowed to use are not read
+	var deniedErr *provider.AccessDeniedError
+	providerObj, err := provider.GetProviderFromConfiguration(ctx, k8sClient, configuration, providerRef.Namespace, providerRef.Name)
+	if err != nil && !errors.As(err, &deniedErr) {
+		return nil, err
+	}
+	if providerObj != nil && providerObj.Spec.Credentials.Source == "Secret" && providerObj.Spec.Credentials.SecretRef != nil {
+		secretRef := providerObj.Spec.Credentials.SecretRef
+		secrets = append(secrets, ReferencedSecret{Name: secretRef.Name, Namespace: secretRef.Namespace, Role: SecretRoleProviderCredentials})
+	}
+
+	if !tfcfg.IsLocalBackend(configuration) {
+		state := ReferencedSecret{Role: SecretRoleTerraformState}
+		if stateRef := configuration.Status.StateSecretRef; stateRef != nil {
+			state.Name, state.Namespace = stateRef.Name, stateRef.Namespace
+		} else {
+			state.Name = fmt.Sprintf(TFBackendSecret, terraformWorkspace, tfcfg.BackendSecretSuffix(configuration))
+			state.Namespace = tfcfg.GetBackendNamespace()
+		}
+		secrets = append(secrets, state)
+	}
+
+	secrets = append(secrets, ReferencedSecret{
+		Name:      fmt.Sprintf(TFVariableSecret, configuration.Name),
+		Namespace: configuration.Namespace,
+		Role:      SecretRoleVariables,
+	})
+
+	if gitRemote := tfcfg.GetGitRemote(configuration); gitRemote != nil && gitRemote.CredentialsSecretRef != nil {
+		secrets = append(secrets, ReferencedSecret{
+			Name:      gitRemote.CredentialsSecretRef.Name,
+			Namespace: configuration.Namespace,
+			Role:      SecretRoleGitCredentials,
+		})
+	}
+
+	if connection := configuration.Spec.WriteConnectionSecretToReference; connection != nil && connection.Name != "" {
+		namespace := connection.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		secrets = append(secrets, ReferencedSecret{Name: connection.Name, Namespace: namespace, Role: SecretRoleConnection})
+	}
+
+	if GetBookkeepingStorage() == BookkeepingInSecret {
+		secrets = append(secrets, ReferencedSecret{
+			Name:      fmt.Sprintf(TFBookkeepingName, configuration.Name),
+			Namespace: configuration.Namespace,
+			Role:      SecretRoleBookkeeping,
+		})
+	}
+	return secrets, nil
+}
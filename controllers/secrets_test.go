@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	crossplane "github.com/oam-dev/terraform-controller/api/types/crossplane-runtime"
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestGetReferencedSecrets(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	corev1.AddToScheme(s)
+	v1beta1.AddToScheme(s)
+	v1beta2.AddToScheme(s)
+	t.Setenv("TERRAFORM_BACKEND_NAMESPACE", "")
+
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(&v1beta1.Provider{
+		ObjectMeta: v1.ObjectMeta{Name: "default", Namespace: "default"},
+		Spec: v1beta1.ProviderSpec{
+			Provider: "aws",
+			Credentials: v1beta1.ProviderCredentials{
+				Source: "Secret",
+				SecretRef: &crossplane.SecretKeySelector{
+					SecretReference: crossplane.SecretReference{Name: "aws-account-creds", Namespace: "vela-system"},
+					Key:             "credentials",
+				},
+			},
+		},
+	}).Build()
+
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: v1.ObjectMeta{Name: "rds", Namespace: "prod"},
+		Spec: v1beta2.ConfigurationSpec{
+			GitRemote: &v1beta2.GitRemote{
+				URL:                  "https://github.com/a/b.git",
+				CredentialsSecretRef: &corev1.LocalObjectReference{Name: "git-credentials"},
+			},
+		},
+	}
+	configuration.Spec.WriteConnectionSecretToReference = &crossplane.SecretReference{Name: "rds-conn", Namespace: "prod"}
+
+	secrets, err := GetReferencedSecrets(ctx, k8sClient, configuration)
+	assert.Nil(t, err)
+	assert.Equal(t, []ReferencedSecret{
+		{Name: "aws-account-creds", Namespace: "vela-system", Role: SecretRoleProviderCredentials},
+		{Name: "tfstate-default-rds", Namespace: "vela-system", Role: SecretRoleTerraformState},
+		{Name: "variable-rds", Namespace: "prod", Role: SecretRoleVariables},
+		{Name: "git-credentials", Namespace: "prod", Role: SecretRoleGitCredentials},
+		{Name: "rds-conn", Namespace: "prod", Role: SecretRoleConnection},
+	}, secrets)
+
+	// the Secret in the status is the one storing the state, a local backend has no state Secret, and a missing
+	// Provider has no credentials Secret
+	configuration.Spec.ProviderReference = &crossplane.Reference{Name: "missing", Namespace: "default"}
+	configuration.Status.StateSecretRef = &crossplane.SecretReference{Name: "tfstate-default-rds-old", Namespace: "terraform"}
+	secrets, err = GetReferencedSecrets(ctx, k8sClient, configuration)
+	assert.Nil(t, err)
+	assert.Equal(t, ReferencedSecret{Name: "tfstate-default-rds-old", Namespace: "terraform", Role: SecretRoleTerraformState}, secrets[0])
+
+	configuration.Spec.Backend = &v1beta2.Backend{Type: string(types.BackendLocal)}
+	secrets, err = GetReferencedSecrets(ctx, k8sClient, configuration)
+	assert.Nil(t, err)
+	assert.Equal(t, SecretRoleVariables, secrets[0].Role)
+	assert.Equal(t, 3, len(secrets))
+}
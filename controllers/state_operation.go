@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+	tfcfg "github.com/oam-dev/terraform-controller/controllers/configuration"
+)
+
+// stateOperationsJobAnnotation is the annotation of the apply job which runs the state operations, its value is the
+// keys of the operations separated by newlines
+const stateOperationsJobAnnotation = "terraform.core.oam.dev/state-operations"
+
+// stateOperationKeys gets the keys of the state operations which run in the apply job
+func (meta *TFConfigurationMeta) stateOperationKeys() []string {
+	var keys []string
+	for _, operation := range meta.StateOperations {
+		keys = append(keys, tfcfg.StateOperationKey(operation))
+	}
+	return keys
+}
+
+// assembleStateOperationsCommand runs the pending operations of spec.StateOperations in order before `terraform
+// apply`. An operation which already took effect, like a move whose source is not in the state anymore, is skipped,
+// so the operations of an apply which is retried don't fail.
+func (meta *TFConfigurationMeta) assembleStateOperationsCommand(lockArg string) string {
+	var command string
+	for _, operation := range meta.StateOperations {
+		switch operation.Type {
+		case v1beta2.StateOperationMove:
+			command += fmt.Sprintf(` && found=$(terraform state list '%[1]s') && if [ -n "$found" ]; then terraform state mv %[3]s '%[1]s' '%[2]s'; fi`,
+				operation.Address, operation.Destination, lockArg)
+		case v1beta2.StateOperationRemove:
+			command += fmt.Sprintf(` && found=$(terraform state list '%[1]s') && if [ -n "$found" ]; then terraform state rm %[2]s '%[1]s'; fi`,
+				operation.Address, lockArg)
+		case v1beta2.StateOperationImport:
+			command += fmt.Sprintf(` && found=$(terraform state list '%[1]s') && if [ -z "$found" ]; then terraform import -input=false %[3]s '%[1]s' '%[2]s'; fi`,
+				operation.Address, operation.ID, lockArg)
+		}
+	}
+	return command
+}
+
+// recordStateOperations records the state operations run by the apply job in status.stateOperations once the job
+// succeeds, so they are not run again. The operations which are not in spec.StateOperations anymore are forgotten.
+func (meta *TFConfigurationMeta) recordStateOperations(ctx context.Context, k8sClient client.Client, job *batchv1.Job) error {
+	value := job.Annotations[stateOperationsJobAnnotation]
+	if value == "" || job.Status.Succeeded != int32(1) || len(meta.StateOperations) == 0 {
+		return nil
+	}
+	var configuration v1beta2.Configuration
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: meta.Name, Namespace: meta.Namespace}, &configuration); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	recorded := map[string]bool{}
+	if configuration.Status.StateOperations != nil {
+		for _, key := range configuration.Status.StateOperations.Completed {
+			recorded[key] = true
+		}
+	}
+	ran := map[string]bool{}
+	for _, key := range strings.Split(value, "\n") {
+		ran[key] = true
+	}
+	var completed, newlyCompleted []string
+	for _, operation := range configuration.Spec.StateOperations {
+		key := tfcfg.StateOperationKey(operation)
+		switch {
+		case recorded[key]:
+			completed = append(completed, key)
+		case ran[key]:
+			completed = append(completed, key)
+			newlyCompleted = append(newlyCompleted, key)
+		}
+	}
+	if len(newlyCompleted) == 0 {
+		return nil
+	}
+	klog.InfoS("The state operations completed", "Name", meta.Name, "Namespace", meta.Namespace, "Operations", newlyCompleted)
+	now := metav1.Now()
+	configuration.Status.StateOperations = &v1beta2.ConfigurationStateOperationsStatus{Completed: completed, LastCompletionTime: &now}
+	if err := k8sClient.Status().Update(ctx, &configuration); err != nil {
+		return err
+	}
+	meta.StateOperations = tfcfg.GetPendingStateOperations(&configuration)
+	return nil
+}
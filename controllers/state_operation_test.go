@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestAssembleStateOperations(t *testing.T) {
+	meta := &TFConfigurationMeta{Name: "a", Namespace: "default"}
+	job := meta.assembleTerraformJob(TerraformApply)
+	assert.NotContains(t, job.Annotations, stateOperationsJobAnnotation)
+
+	meta.StateOperations = []v1beta2.StateOperation{
+		{Type: v1beta2.StateOperationMove, Address: "module.a", Destination: "module.b"},
+		{Type: v1beta2.StateOperationRemove, Address: "aws_s3_bucket.logs"},
+		{Type: v1beta2.StateOperationImport, Address: "aws_s3_bucket.data", ID: "data-bucket"},
+	}
+	assert.Equal(t, ` && found=$(terraform state list 'module.a') && if [ -n "$found" ]; then terraform state mv -lock=false 'module.a' 'module.b'; fi`+
+		` && found=$(terraform state list 'aws_s3_bucket.logs') && if [ -n "$found" ]; then terraform state rm -lock=false 'aws_s3_bucket.logs'; fi`+
+		` && found=$(terraform state list 'aws_s3_bucket.data') && if [ -z "$found" ]; then terraform import -input=false -lock=false 'aws_s3_bucket.data' 'data-bucket'; fi`,
+		meta.assembleStateOperationsCommand("-lock=false"))
+
+	job = meta.assembleTerraformJob(TerraformApply)
+	assert.Equal(t, "mv module.a module.b\nrm aws_s3_bucket.logs\nimport aws_s3_bucket.data data-bucket", job.Annotations[stateOperationsJobAnnotation])
+	assert.Contains(t, job.Spec.Template.Spec.Containers[0].Command[2],
+		"terraform init && found=$(terraform state list 'module.a')")
+	assert.NotContains(t, meta.assembleTerraformJob(TerraformDestroy).Spec.Template.Spec.Containers[0].Command[2], "terraform state mv")
+}
+
+func TestRecordStateOperations(t *testing.T) {
+	ctx := context.Background()
+	s := runtime.NewScheme()
+	v1beta2.AddToScheme(s)
+
+	configuration := &v1beta2.Configuration{
+		ObjectMeta: v1.ObjectMeta{Name: "a", Namespace: "default"},
+		Spec: v1beta2.ConfigurationSpec{
+			StateOperations: []v1beta2.StateOperation{
+				{Type: v1beta2.StateOperationMove, Address: "module.a", Destination: "module.b"},
+				{Type: v1beta2.StateOperationRemove, Address: "aws_s3_bucket.logs"},
+				{Type: v1beta2.StateOperationImport, Address: "aws_s3_bucket.data", ID: "data-bucket"},
+			},
+		},
+		Status: v1beta2.ConfigurationStatus{
+			StateOperations: &v1beta2.ConfigurationStateOperationsStatus{Completed: []string{"mv module.a module.b", "rm module.removed"}},
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(s).WithObjects(configuration).Build()
+	meta := &TFConfigurationMeta{Name: "a", Namespace: "default", StateOperations: configuration.Spec.StateOperations[1:]}
+	job := &batchv1.Job{ObjectMeta: v1.ObjectMeta{
+		Name:        "a-apply",
+		Namespace:   "default",
+		Annotations: map[string]string{stateOperationsJobAnnotation: "rm aws_s3_bucket.logs"},
+	}}
+
+	// the job is running
+	assert.Nil(t, meta.recordStateOperations(ctx, k8sClient, job))
+	var got v1beta2.Configuration
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "a", Namespace: "default"}, &got))
+	assert.Nil(t, got.Status.StateOperations.LastCompletionTime)
+
+	job.Status.Succeeded = 1
+	assert.Nil(t, meta.recordStateOperations(ctx, k8sClient, job))
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKey{Name: "a", Namespace: "default"}, &got))
+	assert.Equal(t, []string{"mv module.a module.b", "rm aws_s3_bucket.logs"}, got.Status.StateOperations.Completed)
+	assert.NotNil(t, got.Status.StateOperations.LastCompletionTime)
+	assert.Equal(t, configuration.Spec.StateOperations[2:], meta.StateOperations)
+}
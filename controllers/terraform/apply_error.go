@@ -0,0 +1,103 @@
+package terraform
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+)
+
+const (
+	// RetryableApplyErrorsEnv is the env of the comma-separated regular expressions of the apply errors which are safe
+	// to retry, like throttling. It overrides defaultRetryableApplyErrors.
+	RetryableApplyErrorsEnv = "TERRAFORM_RETRYABLE_APPLY_ERRORS"
+	// FatalApplyErrorsEnv is the env of the comma-separated regular expressions of the apply errors which make a retry
+	// worse, like a state which can't be saved. It overrides defaultFatalApplyErrors.
+	FatalApplyErrorsEnv = "TERRAFORM_FATAL_APPLY_ERRORS"
+)
+
+// defaultRetryableApplyErrors are the signatures of the transient errors, the resources created before them are in
+// the state, so the next apply continues from where it stopped
+var defaultRetryableApplyErrors = []string{
+	`Error acquiring the state lock`,
+	`(?i)throttl`,
+	`(?i)rate exceeded`,
+	`(?i)too many requests`,
+	`RequestLimitExceeded`,
+	`ServiceUnavailable`,
+	`context deadline exceeded`,
+	`i/o timeout`,
+	`TLS handshake timeout`,
+	`connection reset by peer`,
+	`timeout while waiting for state to become`,
+}
+
+// defaultFatalApplyErrors are the signatures of the errors of the state, a retry may create the resources which are
+// not recorded in the state again, or overwrite a state it can't read
+var defaultFatalApplyErrors = []string{
+	`Failed to persist state`,
+	`Failed to save state`,
+	`Error saving state`,
+	`Failed to load state`,
+	`Error loading state`,
+	`Unsupported state file format`,
+	`state snapshot was created by Terraform v`,
+	`Invalid resource instance data in state`,
+	`Resource instance managed by newer provider version`,
+}
+
+// ParseApplyErrorSignatures parses the regular expressions of RetryableApplyErrorsEnv and FatalApplyErrorsEnv. It's
+// checked when the controller starts, so an invalid expression doesn't change the retries silently.
+func ParseApplyErrorSignatures() (retryable []*regexp.Regexp, fatal []*regexp.Regexp, err error) {
+	if retryable, err = parseApplyErrorSignatures(RetryableApplyErrorsEnv, defaultRetryableApplyErrors); err != nil {
+		return nil, nil, err
+	}
+	if fatal, err = parseApplyErrorSignatures(FatalApplyErrorsEnv, defaultFatalApplyErrors); err != nil {
+		return nil, nil, err
+	}
+	return retryable, fatal, nil
+}
+
+func parseApplyErrorSignatures(env string, defaults []string) ([]*regexp.Regexp, error) {
+	exprs := defaults
+	if value := os.Getenv(env); value != "" {
+		exprs = strings.Split(value, ",")
+	}
+	var signatures []*regexp.Regexp
+	for _, expr := range exprs {
+		if expr = strings.TrimSpace(expr); expr == "" {
+			continue
+		}
+		signature, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s in env %s is not a valid regular expression", expr, env)
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// classifyApplyError classifies the error of a failed apply by the signatures. A fatal signature wins over a retryable
+// one, and the error which matches neither is ApplyFailed, which is retried like a retryable one.
+func classifyApplyError(errMsg string) types.ConfigurationState {
+	retryable, fatal, err := ParseApplyErrorSignatures()
+	if err != nil {
+		klog.ErrorS(err, "Failed to parse the signatures of the apply errors")
+		return types.ConfigurationApplyFailed
+	}
+	for _, signature := range fatal {
+		if signature.MatchString(errMsg) {
+			return types.FatalApplyError
+		}
+	}
+	for _, signature := range retryable {
+		if signature.MatchString(errMsg) {
+			return types.RetryableApplyError
+		}
+	}
+	return types.ConfigurationApplyFailed
+}
@@ -0,0 +1,59 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+)
+
+func TestClassifyApplyError(t *testing.T) {
+	t.Setenv(RetryableApplyErrorsEnv, "")
+	t.Setenv(FatalApplyErrorsEnv, "")
+	testcases := []struct {
+		name   string
+		errMsg string
+		state  types.ConfigurationState
+	}{
+		{
+			name:   "throttled",
+			errMsg: "Error: creating S3 Bucket (aws_s3_bucket.b): Throttling: Rate exceeded",
+			state:  types.RetryableApplyError,
+		},
+		{
+			name:   "state lock",
+			errMsg: "Error: Error acquiring the state lock",
+			state:  types.RetryableApplyError,
+		},
+		{
+			name:   "state can't be saved",
+			errMsg: "Error: Failed to save state: context deadline exceeded",
+			state:  types.FatalApplyError,
+		},
+		{
+			name:   "unclassified",
+			errMsg: "Error: creating S3 Bucket (aws_s3_bucket.b): BucketAlreadyExists",
+			state:  types.ConfigurationApplyFailed,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.state, classifyApplyError(tc.errMsg))
+			state, ok := failedState(tc.errMsg, types.TerraformApply)
+			assert.True(t, ok)
+			assert.Equal(t, tc.state, state)
+		})
+	}
+
+	t.Setenv(RetryableApplyErrorsEnv, "BucketAlreadyExists, ")
+	t.Setenv(FatalApplyErrorsEnv, "QuotaExceeded")
+	assert.Equal(t, types.RetryableApplyError, classifyApplyError("Error: creating S3 Bucket: BucketAlreadyExists"))
+	assert.Equal(t, types.FatalApplyError, classifyApplyError("Error: QuotaExceeded"))
+	assert.Equal(t, types.ConfigurationApplyFailed, classifyApplyError("Error: Throttling: Rate exceeded"))
+
+	t.Setenv(FatalApplyErrorsEnv, "(state")
+	_, _, err := ParseApplyErrorSignatures()
+	assert.Contains(t, err.Error(), "(state in env TERRAFORM_FATAL_APPLY_ERRORS is not a valid regular expression")
+	assert.Equal(t, types.ConfigurationApplyFailed, classifyApplyError("Error: Failed to save state"))
+}
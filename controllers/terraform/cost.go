@@ -0,0 +1,101 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+const (
+	// CostEstimatorEnv is the env of the cost estimator of the saved plans, like `infracost`. The cost is not estimated
+	// if it's empty or `none`.
+	CostEstimatorEnv = "TERRAFORM_COST_ESTIMATOR"
+	// CostEstimatorNone doesn't estimate the cost
+	CostEstimatorNone = "none"
+	// CostEstimatorInfracost estimates the cost by `infracost breakdown`, which should be in the runner image, and
+	// reads the API key from the env INFRACOST_API_KEY of the executor
+	CostEstimatorInfracost = "infracost"
+)
+
+// CostEstimator estimates the cost of the saved plan in the plan job. An estimation which fails doesn't fail the plan.
+type CostEstimator interface {
+	// Name is the name of the estimator in CostEstimatorEnv
+	Name() string
+	// Command is the shell command which runs after the plan is saved to planFile, it prints the estimate in a line
+	// prefixed by types.CostEstimateLogPrefix
+	Command(planFile string) string
+	// Parse parses the estimate printed by the command
+	Parse(estimate string) (*v1beta2.CostEstimate, error)
+}
+
+var costEstimators = map[string]CostEstimator{
+	CostEstimatorInfracost: infracost{},
+}
+
+// GetCostEstimator gets the cost estimator of CostEstimatorEnv, it's nil if the cost is not estimated
+func GetCostEstimator() (CostEstimator, error) {
+	name := strings.TrimSpace(os.Getenv(CostEstimatorEnv))
+	if name == "" || name == CostEstimatorNone {
+		return nil, nil
+	}
+	estimator, ok := costEstimators[name]
+	if !ok {
+		return nil, fmt.Errorf("%s should be %s or %s, but got %s", CostEstimatorEnv, CostEstimatorNone, CostEstimatorInfracost, name)
+	}
+	return estimator, nil
+}
+
+// ParseCostEstimate parses the cost estimate in the logs of the plan job
+func ParseCostEstimate(logs string, estimator CostEstimator) (*v1beta2.CostEstimate, error) {
+	estimate := ""
+	for _, line := range strings.Split(logs, "\n") {
+		if strings.HasPrefix(line, types.CostEstimateLogPrefix) {
+			estimate = strings.TrimSpace(strings.TrimPrefix(line, types.CostEstimateLogPrefix))
+		}
+	}
+	if estimate == "" {
+		return nil, errors.New("the cost estimate is not found in the logs of the plan job")
+	}
+	return estimator.Parse(estimate)
+}
+
+type infracost struct{}
+
+func (infracost) Name() string {
+	return CostEstimatorInfracost
+}
+
+func (infracost) Command(planFile string) string {
+	// the JSON breakdown is printed in one line, so it's found by the prefix
+	return fmt.Sprintf("terraform show -json %s > %s.json && estimate=$(infracost breakdown --path %s.json --format json --log-level error | tr -d '\\n') && echo \"%s$estimate\"",
+		planFile, planFile, planFile, types.CostEstimateLogPrefix)
+}
+
+func (infracost) Parse(estimate string) (*v1beta2.CostEstimate, error) {
+	var breakdown struct {
+		Currency             string  `json:"currency"`
+		TotalMonthlyCost     *string `json:"totalMonthlyCost"`
+		DiffTotalMonthlyCost *string `json:"diffTotalMonthlyCost"`
+	}
+	if err := json.Unmarshal([]byte(estimate), &breakdown); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the breakdown of infracost")
+	}
+	if breakdown.DiffTotalMonthlyCost == nil {
+		return nil, errors.New("the breakdown of infracost has no diffTotalMonthlyCost")
+	}
+	cost := &v1beta2.CostEstimate{
+		Estimator:        CostEstimatorInfracost,
+		Currency:         breakdown.Currency,
+		MonthlyCostDelta: *breakdown.DiffTotalMonthlyCost,
+	}
+	if breakdown.TotalMonthlyCost != nil {
+		cost.TotalMonthlyCost = *breakdown.TotalMonthlyCost
+	}
+	return cost, nil
+}
@@ -0,0 +1,54 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+func TestGetCostEstimator(t *testing.T) {
+	t.Setenv(CostEstimatorEnv, "")
+	estimator, err := GetCostEstimator()
+	assert.Nil(t, err)
+	assert.Nil(t, estimator)
+
+	t.Setenv(CostEstimatorEnv, CostEstimatorNone)
+	estimator, err = GetCostEstimator()
+	assert.Nil(t, err)
+	assert.Nil(t, estimator)
+
+	t.Setenv(CostEstimatorEnv, CostEstimatorInfracost)
+	estimator, err = GetCostEstimator()
+	assert.Nil(t, err)
+	assert.Equal(t, CostEstimatorInfracost, estimator.Name())
+
+	t.Setenv(CostEstimatorEnv, "kubecost")
+	_, err = GetCostEstimator()
+	assert.EqualError(t, err, "TERRAFORM_COST_ESTIMATOR should be none or infracost, but got kubecost")
+}
+
+func TestParseCostEstimate(t *testing.T) {
+	estimator := infracost{}
+	logs := `{"@level":"info","@message":"Plan: 1 to add, 0 to change, 0 to destroy."}
+PlanChecksum: 3a7bd3e2
+CostEstimate: {"version":"0.2","currency":"USD","totalMonthlyCost":"42.5","pastTotalMonthlyCost":"30","diffTotalMonthlyCost":"12.5"}`
+	cost, err := ParseCostEstimate(logs, estimator)
+	assert.Nil(t, err)
+	assert.Equal(t, &v1beta2.CostEstimate{
+		Estimator:        CostEstimatorInfracost,
+		Currency:         "USD",
+		MonthlyCostDelta: "12.5",
+		TotalMonthlyCost: "42.5",
+	}, cost)
+
+	_, err = ParseCostEstimate("PlanChecksum: 3a7bd3e2", estimator)
+	assert.EqualError(t, err, "the cost estimate is not found in the logs of the plan job")
+
+	_, err = ParseCostEstimate(`CostEstimate: {"currency":"USD","totalMonthlyCost":null,"diffTotalMonthlyCost":null}`, estimator)
+	assert.EqualError(t, err, "the breakdown of infracost has no diffTotalMonthlyCost")
+
+	_, err = ParseCostEstimate("CostEstimate: {", estimator)
+	assert.Contains(t, err.Error(), "failed to parse the breakdown of infracost")
+}
@@ -12,6 +12,7 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/oam-dev/terraform-controller/api/types"
+	"github.com/oam-dev/terraform-controller/controllers/client"
 )
 
 func getPods(ctx context.Context, client kubernetes.Interface, namespace, jobName string) (*v1.PodList, error) {
@@ -74,3 +75,37 @@ func flushStream(rc io.ReadCloser, podName string) (string, error) {
 	klog.V(4).Info("pod logs", "Pod", podName, "Logs", logContent)
 	return logContent, nil
 }
+
+// GetTerraformLogs gets the logs of the Terraform job, which are the logs of the init container if `terraform init`
+// doesn't finish
+func GetTerraformLogs(ctx context.Context, namespace, jobName, containerName, initContainerName string) (string, error) {
+	clientSet, err := client.Init()
+	if err != nil {
+		return "", err
+	}
+	_, logs, err := getPodLog(ctx, clientSet, namespace, jobName, containerName, initContainerName)
+	return logs, err
+}
+
+func getJobRun(ctx context.Context, client kubernetes.Interface, namespace, jobName string) (string, error) {
+	pods, err := getPods(ctx, client, namespace, jobName)
+	if err != nil || pods == nil || len(pods.Items) == 0 {
+		return "", err
+	}
+	pod := pods.Items[0]
+	var restarts int32
+	for _, c := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		restarts += c.RestartCount
+	}
+	return fmt.Sprintf("%s/%d", pod.Name, restarts), nil
+}
+
+// GetTerraformJobRun identifies the run of the Terraform job whose logs are got by GetTerraformLogs, which is the pod
+// and the restarts of its containers, as the failed containers of the job are restarted in the same pod
+func GetTerraformJobRun(ctx context.Context, namespace, jobName string) (string, error) {
+	clientSet, err := client.Init()
+	if err != nil {
+		return "", err
+	}
+	return getJobRun(ctx, clientSet, namespace, jobName)
+}
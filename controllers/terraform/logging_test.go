@@ -141,3 +141,29 @@ func TestFlushStream(t *testing.T) {
 		})
 	}
 }
+
+func TestGetJobRun(t *testing.T) {
+	ctx := context.Background()
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "p1",
+			Namespace: "default",
+			Labels: map[string]string{
+				"job-name": "j1",
+			},
+		},
+		Status: v1.PodStatus{
+			InitContainerStatuses: []v1.ContainerStatus{{Name: "terraform-init", RestartCount: 1}},
+			ContainerStatuses:     []v1.ContainerStatus{{Name: "terraform-executor", RestartCount: 2}},
+		},
+	}
+	k8sClientSet := fakeclient.NewSimpleClientset(pod)
+
+	run, err := getJobRun(ctx, k8sClientSet, "default", "j1")
+	assert.Nil(t, err)
+	assert.Equal(t, "p1/3", run)
+
+	run, err = getJobRun(ctx, k8sClientSet, "default", "j2")
+	assert.Nil(t, err)
+	assert.Equal(t, "", run)
+}
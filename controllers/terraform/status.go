@@ -2,6 +2,8 @@ package terraform
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -28,10 +30,23 @@ func GetTerraformStatus(ctx context.Context, namespace, jobName, containerName,
 		return types.ConfigurationProvisioningAndChecking, err
 	}
 
+	if diagnostics := parseTerraformDiagnostics(logs); len(diagnostics) > 0 {
+		diagnosticsErr := &DiagnosticsError{Diagnostics: diagnostics}
+		if state, ok := failedState(diagnosticsErr.Error(), stage); ok {
+			return state, diagnosticsErr
+		}
+	}
+
+	// Fall back to the raw output if there are no diagnostics in JSON format, like the output of `terraform init`
 	success, state, errMsg := analyzeTerraformLog(logs, stage)
 	if success {
 		return state, nil
 	}
+	if state == types.TerraformWarnings {
+		if warnings := ParseTerraformWarnings(logs); len(warnings) > 0 {
+			return state, &WarningsError{Warnings: warnings}
+		}
+	}
 
 	return state, errors.New(errMsg)
 }
@@ -39,18 +54,286 @@ func GetTerraformStatus(ctx context.Context, namespace, jobName, containerName,
 func analyzeTerraformLog(logs string, stage types.Stage) (bool, types.ConfigurationState, string) {
 	lines := strings.Split(logs, "\n")
 	for i, line := range lines {
+		if stage == types.TerraformInit && strings.HasPrefix(line, types.RegistryUnreachableLogPrefix) {
+			return false, types.RegistryUnreachable, strings.TrimPrefix(line, types.RegistryUnreachableLogPrefix)
+		}
+		if stage == types.TerraformInit && strings.HasPrefix(line, types.ProviderChecksumMismatchLogPrefix) {
+			return false, types.ProviderChecksumMismatch, strings.TrimPrefix(line, types.ProviderChecksumMismatchLogPrefix)
+		}
+		if stage == types.TerraformApply && strings.HasPrefix(line, types.PlanStaleLogPrefix) {
+			return false, types.PlanStale, strings.TrimPrefix(line, types.PlanStaleLogPrefix)
+		}
+		if stage == types.TerraformApply && strings.HasPrefix(line, types.TerraformWarningsLogPrefix) {
+			return false, types.TerraformWarnings, strings.TrimPrefix(line, types.TerraformWarningsLogPrefix)
+		}
 		if strings.Contains(line, "31mError:") {
 			errMsg := strings.Join(lines[i:], "\n")
-			if strings.Contains(errMsg, "Invalid Alibaba Cloud region") {
-				return false, types.InvalidRegion, errMsg
-			}
-			switch stage {
-			case types.TerraformInit:
-				return false, types.TerraformInitError, errMsg
-			case types.TerraformApply:
-				return false, types.ConfigurationApplyFailed, errMsg
+			if state, ok := failedState(errMsg, stage); ok {
+				return false, state, errMsg
 			}
 		}
 	}
 	return true, types.ConfigurationProvisioningAndChecking, ""
 }
+
+// failedState gets the state of a failed Terraform execution by the error message and the stage
+func failedState(errMsg string, stage types.Stage) (types.ConfigurationState, bool) {
+	if strings.Contains(errMsg, "Invalid Alibaba Cloud region") {
+		return types.InvalidRegion, true
+	}
+	switch stage {
+	case types.TerraformInit:
+		return types.TerraformInitError, true
+	case types.TerraformApply:
+		return classifyApplyError(errMsg), true
+	}
+	return "", false
+}
+
+// Diagnostic is an error diagnostic of a Terraform execution
+type Diagnostic struct {
+	// Address is the address of the resource which the diagnostic is about, it could be empty
+	Address string
+	Summary string
+	Detail  string
+}
+
+// DiagnosticsError is the error of a Terraform execution, which carries the diagnostics parsed from the JSON output
+type DiagnosticsError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *DiagnosticsError) Error() string {
+	messages := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		message := "Error: " + d.Summary
+		if d.Address != "" {
+			message += fmt.Sprintf(" (%s)", d.Address)
+		}
+		if d.Detail != "" {
+			message += ": " + d.Detail
+		}
+		messages = append(messages, message)
+	}
+	return strings.Join(messages, "\n")
+}
+
+// WarningsError is the error of an apply which is refused as the plan reports warnings, when they are treated as
+// errors
+type WarningsError struct {
+	Warnings []Diagnostic
+}
+
+func (e *WarningsError) Error() string {
+	messages := make([]string, 0, len(e.Warnings))
+	for _, d := range e.Warnings {
+		message := "Warning: " + d.Summary
+		if d.Address != "" {
+			message += fmt.Sprintf(" (%s)", d.Address)
+		}
+		if d.Detail != "" {
+			message += ": " + d.Detail
+		}
+		messages = append(messages, message)
+	}
+	return strings.Join(messages, "\n")
+}
+
+// jsonLogLine is a line of the JSON output of `terraform plan/apply/destroy -json`
+type jsonLogLine struct {
+	Level      string `json:"@level"`
+	Message    string `json:"@message"`
+	Type       string `json:"type"`
+	Diagnostic *struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+		Detail   string `json:"detail"`
+		Address  string `json:"address"`
+	} `json:"diagnostic"`
+	Change *struct {
+		Resource struct {
+			Addr string `json:"addr"`
+		} `json:"resource"`
+		Action string `json:"action"`
+	} `json:"change"`
+	Changes *struct {
+		Operation string `json:"operation"`
+	} `json:"changes"`
+	Hook *struct {
+		Resource struct {
+			Addr string `json:"addr"`
+		} `json:"resource"`
+		Action string `json:"action"`
+	} `json:"hook"`
+}
+
+// GetApplyPhase gets the phase reached by the running apply job from its pod. It's empty if `terraform init` in the
+// init container doesn't complete yet.
+func GetApplyPhase(ctx context.Context, namespace, jobName, containerName, initContainerName string) (types.ApplyPhase, error) {
+	clientSet, err := client.Init()
+	if err != nil {
+		return "", err
+	}
+	pods, err := getPods(ctx, clientSet, namespace, jobName)
+	if err != nil || pods == nil || len(pods.Items) == 0 {
+		return "", err
+	}
+	var started bool
+	for _, c := range pods.Items[0].Status.ContainerStatuses {
+		started = started || (c.Name == containerName && (c.State.Running != nil || c.State.Terminated != nil))
+	}
+	if !started {
+		return "", nil
+	}
+	_, logs, err := getPodLog(ctx, clientSet, namespace, jobName, containerName, initContainerName)
+	if err != nil {
+		return "", err
+	}
+	return parseApplyPhase(logs), nil
+}
+
+// parseApplyPhase parses the phase of an apply from its JSON output. The plan is complete once its summary is printed,
+// and the apply is in progress once any resource starts changing.
+func parseApplyPhase(logs string) types.ApplyPhase {
+	phase := types.ApplyPhaseInitComplete
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var logLine jsonLogLine
+		if err := json.Unmarshal([]byte(line), &logLine); err != nil {
+			continue
+		}
+		switch {
+		case logLine.Type == "apply_start":
+			return types.ApplyPhaseApplyInProgress
+		case logLine.Type == "change_summary" && logLine.Changes != nil && logLine.Changes.Operation == "plan":
+			phase = types.ApplyPhasePlanComplete
+		}
+	}
+	return phase
+}
+
+// parseTerraformDiagnostics parses the error diagnostics from the JSON output of Terraform. The lines which are not
+// in JSON format are skipped.
+func parseTerraformDiagnostics(logs string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var logLine jsonLogLine
+		if err := json.Unmarshal([]byte(line), &logLine); err != nil || logLine.Level != "error" {
+			continue
+		}
+		if logLine.Type == "diagnostic" && logLine.Diagnostic != nil {
+			diagnostics = append(diagnostics, Diagnostic{
+				Address: logLine.Diagnostic.Address,
+				Summary: logLine.Diagnostic.Summary,
+				Detail:  logLine.Diagnostic.Detail,
+			})
+			continue
+		}
+		diagnostics = append(diagnostics, Diagnostic{Summary: strings.TrimPrefix(logLine.Message, "Error: ")})
+	}
+	return diagnostics
+}
+
+// ParseTerraformWarnings parses the warning diagnostics from the JSON output of Terraform. The lines which are not in
+// JSON format are skipped.
+func ParseTerraformWarnings(logs string) []Diagnostic {
+	var warnings []Diagnostic
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var logLine jsonLogLine
+		if err := json.Unmarshal([]byte(line), &logLine); err != nil || logLine.Type != "diagnostic" || logLine.Diagnostic == nil ||
+			logLine.Diagnostic.Severity != "warning" {
+			continue
+		}
+		warnings = append(warnings, Diagnostic{
+			Address: logLine.Diagnostic.Address,
+			Summary: logLine.Diagnostic.Summary,
+			Detail:  logLine.Diagnostic.Detail,
+		})
+	}
+	return warnings
+}
+
+// ParsePlannedDeletions parses the addresses of the resources which would be deleted from the JSON output of
+// `terraform plan -destroy`. The lines which are not in JSON format are skipped.
+func ParsePlannedDeletions(logs string) []string {
+	var addresses []string
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var logLine jsonLogLine
+		if err := json.Unmarshal([]byte(line), &logLine); err != nil || logLine.Type != "planned_change" || logLine.Change == nil {
+			continue
+		}
+		if logLine.Change.Action == "delete" {
+			addresses = append(addresses, logLine.Change.Resource.Addr)
+		}
+	}
+	return addresses
+}
+
+// ParsePlannedChanges parses the changes of the resources, like `create aws_s3_bucket.b`, from the JSON output of
+// `terraform plan`. The lines which are not in JSON format are skipped.
+func ParsePlannedChanges(logs string) []string {
+	var changes []string
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var logLine jsonLogLine
+		if err := json.Unmarshal([]byte(line), &logLine); err != nil || logLine.Type != "planned_change" || logLine.Change == nil {
+			continue
+		}
+		changes = append(changes, logLine.Change.Action+" "+logLine.Change.Resource.Addr)
+	}
+	return changes
+}
+
+// ParseAppliedChanges parses the addresses of the resources which were created, updated and deleted from the JSON
+// output of `terraform apply`, in the order they completed. The data sources which were read are skipped.
+func ParseAppliedChanges(logs string) (created, updated, deleted []string) {
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var logLine jsonLogLine
+		if err := json.Unmarshal([]byte(line), &logLine); err != nil || logLine.Type != "apply_complete" || logLine.Hook == nil {
+			continue
+		}
+		switch logLine.Hook.Action {
+		case "create":
+			created = append(created, logLine.Hook.Resource.Addr)
+		case "update":
+			updated = append(updated, logLine.Hook.Resource.Addr)
+		case "delete":
+			deleted = append(deleted, logLine.Hook.Resource.Addr)
+		}
+	}
+	return created, updated, deleted
+}
+
+// ParsePlanChecksum parses the checksum of the saved plan, which is printed with types.PlanChecksumLogPrefix by the
+// plan job. It's empty if the checksum is not found.
+func ParsePlanChecksum(logs string) string {
+	checksum := ""
+	for _, line := range strings.Split(logs, "\n") {
+		if strings.HasPrefix(line, types.PlanChecksumLogPrefix) {
+			checksum = strings.TrimSpace(strings.TrimPrefix(line, types.PlanChecksumLogPrefix))
+		}
+	}
+	return checksum
+}
@@ -154,3 +154,142 @@ func TestAnalyzeTerraformLog(t *testing.T) {
 		})
 	}
 }
+
+func TestAnalyzeRegistryUnreachableLog(t *testing.T) {
+	logs := "RegistryUnreachable: The provider registry https://registry.terraform.io is unreachable from the Terraform executor\n"
+
+	success, state, errMsg := analyzeTerraformLog(logs, types.TerraformInit)
+	assert.False(t, success)
+	assert.Equal(t, types.RegistryUnreachable, state)
+	assert.Equal(t, "The provider registry https://registry.terraform.io is unreachable from the Terraform executor", errMsg)
+
+	// the preflight only runs before `terraform init`
+	success, _, _ = analyzeTerraformLog(logs, types.TerraformApply)
+	assert.True(t, success)
+}
+
+func TestParseTerraformDiagnostics(t *testing.T) {
+	logs := `Initializing the backend...
+Terraform has been successfully initialized!
+{"@level":"info","@message":"Terraform 1.1.2","@module":"terraform.ui","type":"version"}
+{"@level":"error","@message":"Error: creating S3 Bucket","@module":"terraform.ui","diagnostic":{"severity":"error","summary":"creating S3 Bucket","detail":"BucketAlreadyExists","address":"aws_s3_bucket.b"},"type":"diagnostic"}
+{"@level":"warn","@message":"Warning: Argument is deprecated","@module":"terraform.ui","diagnostic":{"severity":"warning","summary":"Argument is deprecated"},"type":"diagnostic"}
+{"@level":"error","@message":"Error: Invalid Alibaba Cloud region","@module":"terraform.ui","type":"log"}
+{"@level":"error", broken`
+
+	diagnostics := parseTerraformDiagnostics(logs)
+	assert.Equal(t, []Diagnostic{
+		{Address: "aws_s3_bucket.b", Summary: "creating S3 Bucket", Detail: "BucketAlreadyExists"},
+		{Summary: "Invalid Alibaba Cloud region"},
+	}, diagnostics)
+
+	err := &DiagnosticsError{Diagnostics: diagnostics}
+	assert.Equal(t, "Error: creating S3 Bucket (aws_s3_bucket.b): BucketAlreadyExists\nError: Invalid Alibaba Cloud region", err.Error())
+	state, ok := failedState(err.Error(), types.TerraformApply)
+	assert.True(t, ok)
+	assert.Equal(t, types.InvalidRegion, state)
+
+	assert.Nil(t, parseTerraformDiagnostics("31mError: Invalid Alibaba Cloud region"))
+}
+
+func TestAnalyzePlanStaleLog(t *testing.T) {
+	logs := "PlanStale: The planned changes differ from the approved plan 3a7bd3e2, the changes are planned again\n"
+
+	success, state, errMsg := analyzeTerraformLog(logs, types.TerraformApply)
+	assert.False(t, success)
+	assert.Equal(t, types.PlanStale, state)
+	assert.Equal(t, "The planned changes differ from the approved plan 3a7bd3e2, the changes are planned again", errMsg)
+}
+
+func TestParsePlannedDeletions(t *testing.T) {
+	logs := `Terraform has been successfully initialized!
+{"@level":"info","@message":"Terraform 1.1.2","@module":"terraform.ui","type":"version"}
+{"@level":"info","@message":"aws_s3_bucket.b: Plan to delete","@module":"terraform.ui","change":{"resource":{"addr":"aws_s3_bucket.b","resource_type":"aws_s3_bucket"},"action":"delete"},"type":"planned_change"}
+{"@level":"info","@message":"module.vpc.aws_vpc.this[0]: Plan to delete","@module":"terraform.ui","change":{"resource":{"addr":"module.vpc.aws_vpc.this[0]"},"action":"delete"},"type":"planned_change"}
+{"@level":"info","@message":"aws_instance.a: Plan to create","@module":"terraform.ui","change":{"resource":{"addr":"aws_instance.a"},"action":"create"},"type":"planned_change"}
+{"@level":"info","@message":"Plan: 0 to add, 0 to change, 2 to destroy.","@module":"terraform.ui","changes":{"add":0,"change":0,"remove":2,"operation":"destroy"},"type":"change_summary"}
+{"@level":"info", broken`
+
+	assert.Equal(t, []string{"aws_s3_bucket.b", "module.vpc.aws_vpc.this[0]"}, ParsePlannedDeletions(logs))
+	assert.Nil(t, ParsePlannedDeletions("No changes. No objects need to be destroyed."))
+}
+
+func TestParsePlannedChanges(t *testing.T) {
+	logs := `Terraform has been successfully initialized!
+{"@level":"info","@message":"aws_s3_bucket.b: Plan to update","@module":"terraform.ui","change":{"resource":{"addr":"aws_s3_bucket.b"},"action":"update"},"type":"planned_change"}
+{"@level":"info","@message":"aws_instance.a: Plan to create","@module":"terraform.ui","change":{"resource":{"addr":"aws_instance.a"},"action":"create"},"type":"planned_change"}
+{"@level":"info","@message":"Plan: 1 to add, 1 to change, 0 to destroy.","@module":"terraform.ui","changes":{"add":1,"change":1,"remove":0,"operation":"plan"},"type":"change_summary"}
+PlanChecksum: 3a7bd3e2`
+
+	assert.Equal(t, []string{"update aws_s3_bucket.b", "create aws_instance.a"}, ParsePlannedChanges(logs))
+	assert.Nil(t, ParsePlannedChanges("No changes. Your infrastructure matches the configuration."))
+}
+
+func TestParseApplyPhase(t *testing.T) {
+	initialized := `Terraform has been successfully initialized!
+{"@level":"info","@message":"Terraform 1.1.2","@module":"terraform.ui","type":"version"}
+{"@level":"info","@message":"aws_s3_bucket.b: Plan to create","@module":"terraform.ui","change":{"resource":{"addr":"aws_s3_bucket.b"},"action":"create"},"type":"planned_change"}`
+	planned := initialized + `
+{"@level":"info","@message":"Plan: 1 to add, 0 to change, 0 to destroy.","@module":"terraform.ui","changes":{"add":1,"change":0,"remove":0,"operation":"plan"},"type":"change_summary"}`
+	applying := planned + `
+{"@level":"info","@message":"aws_s3_bucket.b: Creating...","@module":"terraform.ui","hook":{"resource":{"addr":"aws_s3_bucket.b"},"action":"create"},"type":"apply_start"}`
+
+	assert.Equal(t, types.ApplyPhaseInitComplete, parseApplyPhase(initialized))
+	assert.Equal(t, types.ApplyPhasePlanComplete, parseApplyPhase(planned))
+	assert.Equal(t, types.ApplyPhaseApplyInProgress, parseApplyPhase(applying))
+}
+
+func TestParsePlanChecksum(t *testing.T) {
+	assert.Equal(t, "3a7bd3e2", ParsePlanChecksum("Terraform has been successfully initialized!\nPlanChecksum: 3a7bd3e2\n"))
+	assert.Equal(t, "", ParsePlanChecksum("Error: Invalid Alibaba Cloud region"))
+}
+
+func TestAnalyzeProviderChecksumMismatchLog(t *testing.T) {
+	logs := "Terraform has been successfully initialized!\nProviderChecksumMismatch: The checksum abc of the provider plugin registry.terraform.io/hashicorp/aws/4.0.0/linux_amd64/terraform-provider-aws_v4.0.0_x5 is not in the allowlist vela-system/provider-checksums\n"
+
+	success, state, errMsg := analyzeTerraformLog(logs, types.TerraformInit)
+	assert.False(t, success)
+	assert.Equal(t, types.ProviderChecksumMismatch, state)
+	assert.Equal(t, "The checksum abc of the provider plugin registry.terraform.io/hashicorp/aws/4.0.0/linux_amd64/terraform-provider-aws_v4.0.0_x5 is not in the allowlist vela-system/provider-checksums", errMsg)
+}
+
+func TestParseAppliedChanges(t *testing.T) {
+	logs := `Terraform has been successfully initialized!
+{"@level":"info","@message":"aws_s3_bucket.b: Plan to update","@module":"terraform.ui","change":{"resource":{"addr":"aws_s3_bucket.b"},"action":"update"},"type":"planned_change"}
+{"@level":"info","@message":"data.aws_region.r: Read complete after 0s","@module":"terraform.ui","hook":{"resource":{"addr":"data.aws_region.r"},"action":"read"},"type":"apply_complete"}
+{"@level":"info","@message":"aws_instance.a: Destruction complete after 1s","@module":"terraform.ui","hook":{"resource":{"addr":"aws_instance.a"},"action":"delete"},"type":"apply_complete"}
+{"@level":"info","@message":"aws_instance.a: Creating...","@module":"terraform.ui","hook":{"resource":{"addr":"aws_instance.a"},"action":"create"},"type":"apply_start"}
+{"@level":"info","@message":"aws_instance.a: Creation complete after 9s [id=i-0abc]","@module":"terraform.ui","hook":{"resource":{"addr":"aws_instance.a"},"action":"create","id_key":"id","id_value":"i-0abc"},"type":"apply_complete"}
+{"@level":"info","@message":"aws_s3_bucket.b: Modifications complete after 1s [id=b]","@module":"terraform.ui","hook":{"resource":{"addr":"aws_s3_bucket.b"},"action":"update","id_key":"id","id_value":"b"},"type":"apply_complete"}`
+
+	created, updated, deleted := ParseAppliedChanges(logs)
+	assert.Equal(t, []string{"aws_instance.a"}, created)
+	assert.Equal(t, []string{"aws_s3_bucket.b"}, updated)
+	assert.Equal(t, []string{"aws_instance.a"}, deleted)
+
+	created, updated, deleted = ParseAppliedChanges("Apply complete! Resources: 0 added, 0 changed, 0 destroyed.")
+	assert.Nil(t, created)
+	assert.Nil(t, updated)
+	assert.Nil(t, deleted)
+}
+
+func TestParseTerraformWarnings(t *testing.T) {
+	logs := `Terraform has been successfully initialized!
+{"@level":"info","@message":"aws_s3_bucket.b: Plan to create","@module":"terraform.ui","change":{"resource":{"addr":"aws_s3_bucket.b"},"action":"create"},"type":"planned_change"}
+{"@level":"warn","@message":"Warning: Argument is deprecated","@module":"terraform.ui","diagnostic":{"severity":"warning","summary":"Argument is deprecated","detail":"Use the aws_s3_bucket_acl resource instead","address":"aws_s3_bucket.b"},"type":"diagnostic"}
+{"@level":"error","@message":"Error: creating S3 Bucket","@module":"terraform.ui","diagnostic":{"severity":"error","summary":"creating S3 Bucket","detail":"BucketAlreadyExists"},"type":"diagnostic"}
+TerraformWarnings: The plan reports warnings, which are treated as errors by spec.WarningsAsErrors`
+
+	warnings := ParseTerraformWarnings(logs)
+	assert.Equal(t, []Diagnostic{
+		{Address: "aws_s3_bucket.b", Summary: "Argument is deprecated", Detail: "Use the aws_s3_bucket_acl resource instead"},
+	}, warnings)
+	assert.Equal(t, "Warning: Argument is deprecated (aws_s3_bucket.b): Use the aws_s3_bucket_acl resource instead",
+		(&WarningsError{Warnings: warnings}).Error())
+	assert.Nil(t, ParseTerraformWarnings("Apply complete! Resources: 0 added, 0 changed, 0 destroyed."))
+
+	success, state, errMsg := analyzeTerraformLog(logs, types.TerraformApply)
+	assert.False(t, success)
+	assert.Equal(t, types.TerraformWarnings, state)
+	assert.Equal(t, "The plan reports warnings, which are treated as errors by spec.WarningsAsErrors", errMsg)
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// EndpointEnv is the env of the OTLP/HTTP endpoint, like `http://otel-collector:4318`, which the traces are
+	// exported to. Tracing is disabled if it's empty.
+	EndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	// SamplerRatioEnv is the env of the ratio, from 0 to 1, of the reconciles which are traced. It's 1 if it's empty.
+	SamplerRatioEnv = "OTEL_TRACES_SAMPLER_ARG"
+
+	serviceName         = "terraform-controller"
+	instrumentationName = "github.com/oam-dev/terraform-controller"
+)
+
+const (
+	// AttributeNamespace is the attribute of the namespace of the Configuration
+	AttributeNamespace = attribute.Key("configuration.namespace")
+	// AttributeName is the attribute of the name of the Configuration
+	AttributeName = attribute.Key("configuration.name")
+)
+
+// Enabled checks whether the traces are exported
+func Enabled() bool {
+	return os.Getenv(EndpointEnv) != ""
+}
+
+// Setup registers the global TracerProvider which exports the traces to EndpointEnv. It returns the function to flush
+// and stop the TracerProvider. Nothing is registered if tracing is disabled, and the spans are no-ops.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+	ratio, err := samplerRatio()
+	if err != nil {
+		return nil, err
+	}
+	// the endpoint, headers and timeout are read from the OTEL_EXPORTER_OTLP_* envs by the exporter
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the exporter of the traces")
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+func samplerRatio() (float64, error) {
+	v := os.Getenv(SamplerRatioEnv)
+	if v == "" {
+		return 1, nil
+	}
+	ratio, err := strconv.ParseFloat(v, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return 0, errors.Errorf("%s should be a number from 0 to 1, but got %s", SamplerRatioEnv, v)
+	}
+	return ratio, nil
+}
+
+// StartSpan starts a span with the namespaced name of the Configuration as the attributes, the span is a child of the
+// span in ctx if there is one
+func StartSpan(ctx context.Context, spanName, namespace, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	attributes = append([]attribute.KeyValue{AttributeNamespace.String(namespace), AttributeName.String(name)}, attributes...)
+	return otel.Tracer(instrumentationName).Start(ctx, spanName, trace.WithAttributes(attributes...))
+}
+
+// EndSpan ends the span, and marks it as failed if err is not nil
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
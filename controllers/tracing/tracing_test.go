@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupDisabled(t *testing.T) {
+	t.Setenv(EndpointEnv, "")
+	shutdown, err := Setup(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+}
+
+func TestSamplerRatio(t *testing.T) {
+	testcases := []struct {
+		name  string
+		value string
+		ratio float64
+		err   bool
+	}{
+		{
+			name:  "not set",
+			ratio: 1,
+		},
+		{
+			name:  "ratio",
+			value: "0.1",
+			ratio: 0.1,
+		},
+		{
+			name:  "not a number",
+			value: "abc",
+			err:   true,
+		},
+		{
+			name:  "out of range",
+			value: "2",
+			err:   true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(SamplerRatioEnv, tc.value)
+			ratio, err := samplerRatio()
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.ratio, ratio)
+		})
+	}
+}
+
+func TestSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, parent := StartSpan(context.Background(), "Reconcile", "default", "abc")
+	_, child := StartSpan(ctx, "PreCheck", "default", "abc")
+	EndSpan(child, errors.New("failed"))
+	EndSpan(parent, nil)
+
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "PreCheck", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Contains(t, spans[1].Attributes(), AttributeNamespace.String("default"))
+	assert.Contains(t, spans[1].Attributes(), AttributeName.String("abc"))
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
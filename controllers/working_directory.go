@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/oam-dev/terraform-controller/api/v1beta1"
+	"github.com/oam-dev/terraform-controller/api/v1beta2"
+)
+
+const (
+	// workingDirectoryHashFile is the file in the persisted working directory which records WorkingDirectoryHash once
+	// `terraform init` succeeds
+	workingDirectoryHashFile = ".terraform-controller-hash"
+	// gitCommitFile is the file in the persisted working directory which records the commit of the git repo copied to it
+	gitCommitFile = ".terraform-controller-commit"
+	// workingDirectoryClaimMountPath is the mount path of the PersistentVolumeClaim of spec.WorkingDirectoryClaimName in
+	// the destroy job, which removes the sub-directory of the Configuration
+	workingDirectoryClaimMountPath = "/opt/tf-working-directories"
+)
+
+// gitCommitPattern matches a full commit hash, which, unlike a branch or a tag, never moves to another commit
+var gitCommitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// computeWorkingDirectoryHash computes the hash of the inputs of ConfigurationHash which the clone of the git repo and
+// `terraform init` depend on, like the source, the backend and the providers rendered into the complete configuration.
+// The other changes, like the variables, reuse the working directory.
+func (meta *TFConfigurationMeta) computeWorkingDirectoryHash(providerObj *v1beta1.Provider) (string, error) {
+	inputs := struct {
+		TerraformImage            string                   `json:"terraformImage"`
+		RemoteGit                 string                   `json:"remoteGit,omitempty"`
+		RemoteGitRef              string                   `json:"remoteGitRef,omitempty"`
+		RemoteGitPath             string                   `json:"remoteGitPath,omitempty"`
+		CompleteConfiguration     string                   `json:"completeConfiguration"`
+		ProviderConfiguration     string                   `json:"providerConfiguration,omitempty"`
+		VendoredModules           *v1beta2.VendoredModules `json:"vendoredModules,omitempty"`
+		ProviderLockFile          string                   `json:"providerLockFile,omitempty"`
+		ProviderChecksumAllowlist string                   `json:"providerChecksumAllowlist,omitempty"`
+		Provider                  string                   `json:"provider,omitempty"`
+	}{
+		TerraformImage:            meta.TerraformImage,
+		RemoteGit:                 meta.RemoteGit,
+		RemoteGitRef:              meta.RemoteGitRef,
+		RemoteGitPath:             meta.RemoteGitPath,
+		CompleteConfiguration:     meta.CompleteConfiguration,
+		ProviderConfiguration:     meta.ProviderConfiguration,
+		VendoredModules:           meta.VendoredModules,
+		ProviderLockFile:          meta.ProviderLockFile,
+		ProviderChecksumAllowlist: meta.ProviderChecksumAllowlist,
+	}
+	if providerObj != nil {
+		inputs.Provider = providerObj.Spec.Provider
+	}
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal the inputs of the working directory")
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// persistsWorkingDirectory checks whether the job of the execution type runs in the persisted working directory. Only
+// the apply and destroy jobs, which never run at the same time, share it.
+func (meta *TFConfigurationMeta) persistsWorkingDirectory(executionType TerraformExecutionType) bool {
+	return meta.WorkingDirectoryClaimName != "" && (executionType == TerraformApply || executionType == TerraformDestroy)
+}
+
+// persistWorkingDirectory replaces the emptyDir of the working directory with the sub-directory of the Configuration
+// in the PersistentVolumeClaim of spec.WorkingDirectoryClaimName. The executor of the destroy job mounts the whole
+// PersistentVolumeClaim as well, so the sub-directory could be removed.
+func (meta *TFConfigurationMeta) persistWorkingDirectory(executionType TerraformExecutionType, spec *v1.PodSpec) {
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == meta.Name {
+			spec.Volumes[i].VolumeSource = v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: meta.WorkingDirectoryClaimName},
+			}
+		}
+	}
+	for _, containers := range [][]v1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			mounts := make([]v1.VolumeMount, len(containers[i].VolumeMounts))
+			for j, mount := range containers[i].VolumeMounts {
+				if mount.Name == meta.Name {
+					mount.SubPath = meta.Name
+				}
+				mounts[j] = mount
+			}
+			containers[i].VolumeMounts = mounts
+		}
+	}
+	if executionType == TerraformDestroy {
+		for i := range spec.Containers {
+			spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+				v1.VolumeMount{Name: meta.Name, MountPath: workingDirectoryClaimMountPath})
+		}
+	}
+}
+
+// assembleWorkingDirectoryCheckCommand wipes the persisted working directory before the input configuration is copied
+// if it's not initialized with WorkingDirectoryHash, like the source or the providers are changed
+func (meta *TFConfigurationMeta) assembleWorkingDirectoryCheckCommand(executionType TerraformExecutionType) string {
+	if !meta.persistsWorkingDirectory(executionType) {
+		return ""
+	}
+	return fmt.Sprintf(`{ [ "$(cat %[1]s 2>/dev/null)" = '%[2]s' ] || find %[3]s -mindepth 1 -delete; } && `,
+		filepath.Join(WorkingVolumeMountPath, workingDirectoryHashFile), meta.WorkingDirectoryHash, WorkingVolumeMountPath)
+}
+
+// assembleReuseWorkingDirectoryCommand skips the command, like the clone of the git repo and `terraform init`, if the
+// persisted working directory is initialized with WorkingDirectoryHash
+func (meta *TFConfigurationMeta) assembleReuseWorkingDirectoryCommand(executionType TerraformExecutionType, command string) string {
+	if !meta.persistsWorkingDirectory(executionType) {
+		return command
+	}
+	return fmt.Sprintf("if [ -f %s ]; then echo 'Reusing the working directory'; else %s; fi",
+		filepath.Join(WorkingVolumeMountPath, workingDirectoryHashFile), command)
+}
+
+// assembleWorkingDirectoryHashCommand records WorkingDirectoryHash in the persisted working directory after
+// `terraform init` succeeds
+func (meta *TFConfigurationMeta) assembleWorkingDirectoryHashCommand(executionType TerraformExecutionType) string {
+	if !meta.persistsWorkingDirectory(executionType) {
+		return ""
+	}
+	return fmt.Sprintf(" && echo '%s' > %s", meta.WorkingDirectoryHash, filepath.Join(WorkingVolumeMountPath, workingDirectoryHashFile))
+}
+
+// reusesGitClone checks whether the clone of the git repo is reused with the persisted working directory. Only a commit
+// is in WorkingDirectoryHash for good, a branch or a tag is cloned by every job, as it may move to another commit.
+func (meta *TFConfigurationMeta) reusesGitClone(executionType TerraformExecutionType) bool {
+	return meta.persistsWorkingDirectory(executionType) && gitCommitPattern.MatchString(meta.RemoteGitRef)
+}
+
+// assembleReuseGitCloneCommand skips the clone of the git repo like assembleReuseWorkingDirectoryCommand, if it's reused
+func (meta *TFConfigurationMeta) assembleReuseGitCloneCommand(executionType TerraformExecutionType, command string) string {
+	if !meta.reusesGitClone(executionType) {
+		return command
+	}
+	return meta.assembleReuseWorkingDirectoryCommand(executionType, command)
+}
+
+// assembleGitCommitCheckCommand wipes the persisted working directory, and copies the input configuration to it again,
+// if the cloned git repo is not at the commit copied to it, so `terraform init` runs again with the moved branch or tag
+func (meta *TFConfigurationMeta) assembleGitCommitCheckCommand(executionType TerraformExecutionType) string {
+	if !meta.persistsWorkingDirectory(executionType) || meta.reusesGitClone(executionType) {
+		return ""
+	}
+	return fmt.Sprintf(` && commit=$(git -C %[1]s rev-parse HEAD) && { [ "$(cat %[2]s 2>/dev/null)" = "$commit" ] || { find %[3]s -mindepth 1 -delete && %[4]s; }; }`,
+		BackendVolumeMountPath, filepath.Join(WorkingVolumeMountPath, gitCommitFile), WorkingVolumeMountPath, meta.assembleCopyInputCommand())
+}
+
+// assembleGitCommitRecordCommand records the commit checked by assembleGitCommitCheckCommand after the hcl files are
+// copied to the persisted working directory
+func (meta *TFConfigurationMeta) assembleGitCommitRecordCommand(executionType TerraformExecutionType) string {
+	if !meta.persistsWorkingDirectory(executionType) || meta.reusesGitClone(executionType) {
+		return ""
+	}
+	return fmt.Sprintf(` && echo "$commit" > %s`, filepath.Join(WorkingVolumeMountPath, gitCommitFile))
+}
+
+// assembleWorkingDirectoryRemoveCommand removes the sub-directory of the Configuration from the PersistentVolumeClaim
+// once the destroy succeeds, as nothing reuses it anymore
+func (meta *TFConfigurationMeta) assembleWorkingDirectoryRemoveCommand(executionType TerraformExecutionType) string {
+	if executionType != TerraformDestroy || !meta.persistsWorkingDirectory(executionType) {
+		return ""
+	}
+	return fmt.Sprintf("; if [ $code -eq 0 ]; then rm -rf %s; fi", filepath.Join(workingDirectoryClaimMountPath, meta.Name))
+}
@@ -17,19 +17,26 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
 	terraformv1beta1 "github.com/oam-dev/terraform-controller/api/v1beta1"
 	"github.com/oam-dev/terraform-controller/api/v1beta2"
 	"github.com/oam-dev/terraform-controller/controllers"
+	tfcfg "github.com/oam-dev/terraform-controller/controllers/configuration"
+	"github.com/oam-dev/terraform-controller/controllers/terraform"
+	"github.com/oam-dev/terraform-controller/controllers/tracing"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -48,9 +55,11 @@ func init() {
 
 func main() {
 	var metricsAddr string
+	var probeAddr string
 	var enableLeaderElection bool
 	var syncPeriod time.Duration
 	flag.StringVar(&metricsAddr, "metrics-addr", ":38080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-addr", ":38081", "The address the health and readiness probe endpoints bind to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -62,13 +71,55 @@ func main() {
 
 	ctrl.SetLogger(klogr.New())
 
+	if _, err := tfcfg.ParseGithubBlockedAllowlist(); err != nil {
+		setupLog.Error(err, "unable to parse the allowlist of GitHub sources")
+		os.Exit(1)
+	}
+	if _, _, err := terraform.ParseApplyErrorSignatures(); err != nil {
+		setupLog.Error(err, "unable to parse the signatures of the apply errors")
+		os.Exit(1)
+	}
+	if _, err := terraform.GetCostEstimator(); err != nil {
+		setupLog.Error(err, "unable to estimate the cost of the plans")
+		os.Exit(1)
+	}
+	if err := tfcfg.ValidWatchNamespace(); err != nil {
+		setupLog.Error(err, "unable to scope the controller to the watch namespace")
+		os.Exit(1)
+	}
+	if err := controllers.ValidBookkeepingStorage(); err != nil {
+		setupLog.Error(err, "unable to store the bookkeeping of the Configurations")
+		os.Exit(1)
+	}
+	if err := controllers.ValidConfigurationFinalizer(); err != nil {
+		setupLog.Error(err, "unable to add the finalizer to the Configurations")
+		os.Exit(1)
+	}
+	if _, err := controllers.GetProviderChecksumAllowlistRef(); err != nil {
+		setupLog.Error(err, "unable to verify the provider plugins")
+		os.Exit(1)
+	}
+
+	// the traces are only exported if the endpoint is set
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "ce329a9c.core.oam.dev",
-		SyncPeriod:         &syncPeriod,
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		Port:                   9443,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "ce329a9c.core.oam.dev",
+		SyncPeriod:             &syncPeriod,
+		// only the objects in the namespace are cached and reconciled if the controller is scoped to a namespace
+		Namespace: tfcfg.GetWatchNamespace(),
+		// the namespace of a Configuration is read from the API server, like the APIReader, as the controller is only
+		// allowed to get the namespaces, so a Namespace informer would never sync
+		ClientDisableCacheFor: []client.Object{&corev1.Namespace{}},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -93,9 +144,24 @@ func main() {
 	}
 	// +kubebuilder:scaffold:builder
 
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("kubernetes-backend", (&controllers.ReadinessChecker{
+		Reader:   mgr.GetAPIReader(),
+		Interval: 10 * time.Second,
+	}).Check); err != nil {
+		setupLog.Error(err, "unable to set up readiness check")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		setupLog.Error(err, "unable to flush the traces")
+	}
 }